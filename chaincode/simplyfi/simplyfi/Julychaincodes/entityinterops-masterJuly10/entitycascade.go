@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+const _CascadeObjectType = "EntityCascade"
+const _CascadeEvent = "CASCADE_ENTITY"
+
+// cascade actions
+const _CascadeSuspend = "SUSPEND"
+const _CascadeRestore = "RESTORE"
+
+// cascade step status
+const _StepPending = "P"
+const _StepCompleted = "C"
+
+// _CascadeTargetsKey keeps the cascade targets given to Init
+const _CascadeTargetsKey = "ENTITY_CASCADE_TARGETS"
+
+// CascadeTarget is a chaincode holding records owned by an entity, which are to be
+// suspended when the entity is blacklisted and restored when it is un-blacklisted
+type CascadeTarget struct {
+	Chaincode string `json:"cc"`  //chaincode name
+	Channel   string `json:"ch"`  //channel on which the chaincode is instantiated
+	Suspend   string `json:"sfn"` //function suspending all records of the entity, args: peid, uts
+	Restore   string `json:"rfn"` //function restoring the records suspended by Suspend, args: peid, uts
+	Query     string `json:"qfn"` //function returning the records suspended for the entity, args: peid
+}
+
+// CascadeStep is the state of the cascade on a single target
+type CascadeStep struct {
+	Status   string   `json:"sts"`   //P - pending, C - completed
+	Items    []string `json:"items"` //keys of the records suspended / restored on the target
+	TxID     string   `json:"txid"`  //transaction recording the completion of the step
+	UpdateTs string   `json:"uts"`
+}
+
+// EntityCascade keeps track of the blacklist cascade of an entity
+type EntityCascade struct {
+	ObjType    string                 `json:"obj"`
+	EntityID   string                 `json:"id"`
+	Action     string                 `json:"action"`         //SUSPEND or RESTORE
+	Steps      map[string]CascadeStep `json:"steps"`          //target name wise state
+	Suspension map[string]CascadeStep `json:"susp,omitempty"` //RESTORE only: steps of the SUSPEND cascade being restored
+	UpdateTs   string                 `json:"uts"`
+	UpdatedBy  string                 `json:"uby"`
+	TxID       string                 `json:"txid"`
+}
+
+// blacklistEventPayload is the BLACKLIST_ENTITY payload, the entity fields are kept at
+// top level for the existing listeners and the cascade is added for the saga listener
+type blacklistEventPayload struct {
+	Entity
+	Cascade EntityCascade `json:"cascade"`
+}
+
+// targetSuspension is the response of the Query function of a target
+type targetSuspension struct {
+	Suspended bool     `json:"suspended"`
+	Items     []string `json:"items"`
+}
+
+func getCascadeKey(stub shim.ChaincodeStubInterface, entityID string) (string, error) {
+	return stub.CreateCompositeKey(_CascadeObjectType, []string{entityID})
+}
+
+// setCascadeTargets validates and saves the target name wise cascade targets given as json,
+// e.g. {"HEADERSMS":{"cc":"header","ch":"chheader","sfn":"sbe","rfn":"rbe","qfn":"qbe"}}
+func setCascadeTargets(stub shim.ChaincodeStubInterface, targetsJSON string) error {
+	targets := make(map[string]CascadeTarget)
+	if err := json.Unmarshal([]byte(targetsJSON), &targets); err != nil {
+		return fmt.Errorf("Invalid cascade targets: %v", err)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("Invalid cascade targets: no target given")
+	}
+	for name, target := range targets {
+		if target.Chaincode == "" || target.Channel == "" || target.Suspend == "" || target.Restore == "" || target.Query == "" {
+			return fmt.Errorf("Invalid cascade target %s: cc, ch, sfn, rfn and qfn are mandatory", name)
+		}
+	}
+	targetsBytes, err := json.Marshal(targets)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(_CascadeTargetsKey, targetsBytes)
+}
+
+// getCascadeTargets returns the cascade targets saved by Init
+func getCascadeTargets(stub shim.ChaincodeStubInterface) (map[string]CascadeTarget, error) {
+	targetsBytes, err := stub.GetState(_CascadeTargetsKey)
+	if err != nil {
+		return nil, err
+	}
+	if targetsBytes == nil {
+		return nil, fmt.Errorf("Cascade targets are not configured")
+	}
+	targets := make(map[string]CascadeTarget)
+	if err := json.Unmarshal(targetsBytes, &targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// getCascade returns the cascade record of the entity, nil if none
+func getCascade(stub shim.ChaincodeStubInterface, entityID string) (*EntityCascade, error) {
+	cascadeKey, err := getCascadeKey(stub, entityID)
+	if err != nil {
+		return nil, err
+	}
+	cascadeBytes, err := stub.GetState(cascadeKey)
+	if err != nil || cascadeBytes == nil {
+		return nil, err
+	}
+	var cascade EntityCascade
+	if err := json.Unmarshal(cascadeBytes, &cascade); err != nil {
+		return nil, err
+	}
+	return &cascade, nil
+}
+
+// startCascade saves the cascade record for the given action with every target pending.
+// The targets are on other channels and are driven by the listener of BLACKLIST_ENTITY,
+// each of them accepting the Suspend / Restore call only while its step is pending.
+// A RESTORE keeps the steps of the SUSPEND it restores, so that what was suspended is not lost.
+func (em *EntityManager) startCascade(stub shim.ChaincodeStubInterface, entityID, action, updateTs, updatedBy string) (EntityCascade, error) {
+	cascade := EntityCascade{
+		ObjType:   _CascadeObjectType,
+		EntityID:  entityID,
+		Action:    action,
+		Steps:     make(map[string]CascadeStep),
+		UpdateTs:  updateTs,
+		UpdatedBy: updatedBy,
+		TxID:      stub.GetTxID(),
+	}
+	targets, err := getCascadeTargets(stub)
+	if err != nil {
+		return cascade, err
+	}
+	for name := range targets {
+		cascade.Steps[name] = CascadeStep{Status: _StepPending, Items: make([]string, 0), UpdateTs: updateTs}
+	}
+	if action == _CascadeRestore {
+		previous, err := getCascade(stub, entityID)
+		if err != nil {
+			return cascade, err
+		}
+		if previous != nil && previous.Action == _CascadeSuspend {
+			cascade.Suspension = previous.Steps
+		}
+	}
+
+	cascadeKey, err := getCascadeKey(stub, entityID)
+	if err != nil {
+		return cascade, err
+	}
+	cascadeJSON, err := json.Marshal(cascade)
+	if err != nil {
+		return cascade, err
+	}
+	if err := stub.PutState(cascadeKey, cascadeJSON); err != nil {
+		return cascade, err
+	}
+	return cascade, nil
+}
+
+// queryTargetSuspension reads the records the target keeps suspended for the entity
+func queryTargetSuspension(stub shim.ChaincodeStubInterface, target CascadeTarget, entityID string) (targetSuspension, error) {
+	suspension := targetSuspension{Items: make([]string, 0)}
+	ccArgs := [][]byte{[]byte(target.Query), []byte(entityID)}
+	response := stub.InvokeChaincode(target.Chaincode, ccArgs, target.Channel)
+	if response.Status != shim.OK {
+		return suspension, fmt.Errorf("%s on %s failed: %s", target.Query, target.Chaincode, response.Message)
+	}
+	if err := json.Unmarshal(response.Payload, &suspension); err != nil {
+		return suspension, fmt.Errorf("%s on %s returned invalid json", target.Query, target.Chaincode)
+	}
+	if suspension.Items == nil {
+		suspension.Items = make([]string, 0)
+	}
+	return suspension, nil
+}
+
+// ConfirmBlacklistCascade completes a pending step of the cascade once the target has executed it.
+// The outcome is read from the target chaincode and not taken from the invoker: a SUSPEND step is
+// completed with the records the target keeps suspended, a RESTORE step once the target keeps none,
+// with the records suspended by the SUSPEND step.
+// args[0] entityID
+// args[1] target name e.g. HEADERSMS, HEADERVOICE, TEMPLATES, CONSENT
+// args[2] updateTs
+func (em *EntityManager) ConfirmBlacklistCascade(stub shim.ChaincodeStubInterface) peer.Response {
+	_entityLogger.Info("within ConfirmBlacklistCascade")
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 3 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+
+	authorize, updatedBy := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+
+	entityID, targetName := args[0], args[1]
+	if args[2] == "" {
+		return shim.Error("Update timeStamp should be present there")
+	}
+	targets, err := getCascadeTargets(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	target, ok := targets[targetName]
+	if !ok {
+		return shim.Error("Invalid cascade target " + targetName)
+	}
+
+	cascade, err := getCascade(stub, entityID)
+	if err != nil {
+		return shim.Error("{\"error\":\"Error when reading the cascade\"}")
+	}
+	if cascade == nil {
+		return shim.Error("{\"error\":\"No cascade exists for the EntityID\"}")
+	}
+	step, ok := cascade.Steps[targetName]
+	if !ok || step.Status != _StepPending {
+		return shim.Error("{\"error\":\"No pending cascade step for " + targetName + "\"}")
+	}
+
+	suspension, err := queryTargetSuspension(stub, target, entityID)
+	if err != nil {
+		_entityLogger.Errorf("ConfirmBlacklistCascade : %v", err)
+		return shim.Error("{\"error\":\"Unable to read the cascade state of " + targetName + "\"}")
+	}
+	items := suspension.Items
+	if cascade.Action == _CascadeSuspend && !suspension.Suspended {
+		return shim.Error("{\"error\":\"Entity not suspended yet on " + targetName + "\"}")
+	}
+	if cascade.Action == _CascadeRestore {
+		if suspension.Suspended {
+			return shim.Error("{\"error\":\"Entity not restored yet on " + targetName + "\"}")
+		}
+		items = make([]string, 0)
+		if suspended, ok := cascade.Suspension[targetName]; ok && suspended.Status == _StepCompleted {
+			items = suspended.Items
+		}
+	}
+
+	cascade.Steps[targetName] = CascadeStep{Status: _StepCompleted, Items: items, TxID: stub.GetTxID(), UpdateTs: args[2]}
+	cascade.UpdateTs = args[2]
+	cascade.UpdatedBy = updatedBy
+
+	cascadeKey, err := getCascadeKey(stub, entityID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	cascadeJSON, err := json.Marshal(cascade)
+	if err != nil {
+		return shim.Error("{\"error\":\"Error at the time of Marshaling\"}")
+	}
+	if err := stub.PutState(cascadeKey, cascadeJSON); err != nil {
+		return shim.Error("Unable to save cascade for entity id " + entityID)
+	}
+	if err := stub.SetEvent(_CascadeEvent, cascadeJSON); err != nil {
+		_entityLogger.Errorf("Event not generated for event : CASCADE_ENTITY")
+		return shim.Error("{\"error\":\"Unable to generate Cascade Entity Event.\"}")
+	}
+	resultData := map[string]interface{}{
+		"trxnID":   stub.GetTxID(),
+		"entityID": entityID,
+		"message":  "Cascade step recorded",
+		"cascade":  cascade,
+		"status":   "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// GetBlacklistCascade returns the cascade record of the entity given in args[0]
+func (em *EntityManager) GetBlacklistCascade(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 arguments: " + strconv.Itoa(len(args)) + " given.")
+	}
+	authorize, _ := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	cascadeKey, err := getCascadeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	cascadeBytes, err := stub.GetState(cascadeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if cascadeBytes == nil {
+		return shim.Error("{\"error\":\"No cascade exists for the EntityID\"}")
+	}
+	return shim.Success(cascadeBytes)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const testCascadeTargets = `{"HEADERSMS":{"cc":"header","ch":"chheader","sfn":"sbe","rfn":"rbe","qfn":"qbe"}}`
+
+// fakeTarget answers the query of the records a cascade target keeps suspended
+type fakeTarget struct {
+	suspension targetSuspension
+}
+
+func (target *fakeTarget) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (target *fakeTarget) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	payload, _ := json.Marshal(target.suspension)
+	return shim.Success(payload)
+}
+
+func newCascadeStub(t *testing.T) (*SmartContract, *testStub, *fakeTarget) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	target := new(fakeTarget)
+	stub.MockPeerChaincode("header/chheader", shim.NewMockStub("header", target))
+	if res := stub.init(cc, "init", testCascadeTargets); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	stub.put(t, "E1", legacyEntity("E1", "ABCDE1234F", "AI"))
+	return cc, stub, target
+}
+
+func getTestCascade(t *testing.T, stub *testStub) EntityCascade {
+	key, err := getCascadeKey(stub, "E1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cascade EntityCascade
+	stub.get(t, key, &cascade)
+	return cascade
+}
+
+func TestBlacklistWithoutCascadeTargets(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", legacyEntity("E1", "ABCDE1234F", "AI"))
+	if res := stub.invoke(cc, "updateBlacklistedValue", "E1", "true", "2019-08-01 10:00:00"); res.Status == shim.OK {
+		t.Fatal("entity blacklisted without cascade targets")
+	}
+}
+
+func TestInitRejectsIncompleteCascadeTarget(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	if res := stub.init(cc, "init", `{"HEADERSMS":{"cc":"header","ch":"chheader","sfn":"sbe","rfn":"rbe"}}`); res.Status == shim.OK {
+		t.Fatal("Init accepted a target without qfn")
+	}
+}
+
+func TestConfirmCascadeFromTargetState(t *testing.T) {
+	cc, stub, target := newCascadeStub(t)
+
+	if res := stub.invoke(cc, "updateBlacklistedValue", "E1", "true", "2019-08-01 10:00:00"); res.Status != shim.OK {
+		t.Fatalf("blacklisting failed: %s", res.Message)
+	}
+	if cascade := getTestCascade(t, stub); cascade.Action != _CascadeSuspend || cascade.Steps["HEADERSMS"].Status != _StepPending {
+		t.Fatalf("expected a pending SUSPEND step, got %+v", cascade)
+	}
+
+	//nothing suspended on the target yet
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "HEADERSMS", "2019-08-01 10:01:00"); res.Status == shim.OK {
+		t.Fatal("step confirmed before the target suspended the entity")
+	}
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "TEMPLATES", "2019-08-01 10:01:00"); res.Status == shim.OK {
+		t.Fatal("step confirmed for a target not configured")
+	}
+
+	target.suspension = targetSuspension{Suspended: true, Items: []string{"AIRTEL", "AXISBK"}}
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "HEADERSMS", "2019-08-01 10:01:00"); res.Status != shim.OK {
+		t.Fatalf("confirmation failed: %s", res.Message)
+	}
+	step := getTestCascade(t, stub).Steps["HEADERSMS"]
+	if step.Status != _StepCompleted || !reflect.DeepEqual(step.Items, []string{"AIRTEL", "AXISBK"}) {
+		t.Fatalf("expected the items of the target, got %+v", step)
+	}
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "HEADERSMS", "2019-08-01 10:02:00"); res.Status == shim.OK {
+		t.Fatal("completed step confirmed again")
+	}
+
+	//the restore keeps what was suspended
+	if res := stub.invoke(cc, "updateBlacklistedValue", "E1", "false", "2019-08-02 10:00:00"); res.Status != shim.OK {
+		t.Fatalf("un-blacklisting failed: %s", res.Message)
+	}
+	cascade := getTestCascade(t, stub)
+	if cascade.Action != _CascadeRestore || !reflect.DeepEqual(cascade.Suspension["HEADERSMS"].Items, []string{"AIRTEL", "AXISBK"}) {
+		t.Fatalf("expected the RESTORE to keep the suspended items, got %+v", cascade)
+	}
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "HEADERSMS", "2019-08-02 10:01:00"); res.Status == shim.OK {
+		t.Fatal("restore confirmed while the target keeps the entity suspended")
+	}
+	target.suspension = targetSuspension{}
+	if res := stub.invoke(cc, "confirmBlacklistCascade", "E1", "HEADERSMS", "2019-08-02 10:01:00"); res.Status != shim.OK {
+		t.Fatalf("restore confirmation failed: %s", res.Message)
+	}
+	step = getTestCascade(t, stub).Steps["HEADERSMS"]
+	if step.Status != _StepCompleted || !reflect.DeepEqual(step.Items, []string{"AIRTEL", "AXISBK"}) {
+		t.Fatalf("expected the suspended items to be restored, got %+v", step)
+	}
+}
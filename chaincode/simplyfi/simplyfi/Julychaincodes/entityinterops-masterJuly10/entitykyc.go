@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+const _KYCEvent = "KYC_ENTITY"
+
+// KYCDocument is the fingerprint of a KYC document submitted by the entity, the document itself is kept off chain
+type KYCDocument struct {
+	DocType     string `json:"dtyp"` //PAN, TAN, GST, CIN, COI or AUTH
+	DocHash     string `json:"hash"` //SHA-256 of the document in hex
+	Status      string `json:"vsts"` //P - pending, V - verified, R - rejected
+	SubmittedBy string `json:"sby"`  //domain of the operator adding the document
+	VerifiedBy  string `json:"vby"`  //domain of the operator verifying the document, other than sby
+	UpdateTs    string `json:"uts"`
+}
+
+var kycDocType = map[string]bool{
+	"PAN":  true, //Permanent Account Number
+	"TAN":  true, //Tax deduction Account Number
+	"GST":  true, //GST registration certificate
+	"CIN":  true, //Corporate Identification Number
+	"COI":  true, //Certificate of Incorporation
+	"AUTH": true, //Authorisation letter
+}
+
+var kycStatus = map[string]bool{
+	"P": true,
+	"V": true,
+	"R": true,
+}
+
+var poiSeparators = regexp.MustCompile(`[\s\-./]`)
+var docHashFormat = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// normalizePOI brings PAN/TAN to a comparable form, i.e. without separators and in upper case
+func normalizePOI(poi string) string {
+	return strings.ToUpper(poiSeparators.ReplaceAllString(poi, ""))
+}
+
+// getEntitiesByPOI returns the entities, other than excludeID, registered with the normalised POI
+func (em *EntityManager) getEntitiesByPOI(stub shim.ChaincodeStubInterface, npoi, excludeID string) (bool, []Entity) {
+	entitySearchCriteria := `{
+		"obj":"Entity"	,
+		"npoi":"%s"
+	}`
+	isOK, entities := em.retriveEntityRecords(stub, fmt.Sprintf(entitySearchCriteria, npoi), "entitySearchByNpoi")
+	if !isOK {
+		return false, nil
+	}
+	records := make([]Entity, 0)
+	for _, e := range entities {
+		if e.EntityID != excludeID {
+			records = append(records, e)
+		}
+	}
+	return true, records
+}
+
+// flagDuplicatePOI sets poidup on the given entities not flagged yet and saves them
+func flagDuplicatePOI(stub shim.ChaincodeStubInterface, entities []Entity) error {
+	for _, other := range entities {
+		if other.DuplicatePOI {
+			continue
+		}
+		other.DuplicatePOI = true
+		otherJSON, err := json.Marshal(other)
+		if err != nil {
+			return err
+		}
+		if err := stub.PutState(other.EntityID, otherJSON); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDuplicatePOI normalises the POI of the entity and looks for other entities with the same POI.
+// A duplicate under the same service provider is rejected, a duplicate under other service providers
+// is flagged on both entities for review and the duplicate entity ids are returned
+func (em *EntityManager) checkDuplicatePOI(stub shim.ChaincodeStubInterface, e *Entity) (bool, string, []string) {
+	duplicates := make([]string, 0)
+	e.NormalizedPOI = normalizePOI(e.POI)
+	e.DuplicatePOI = false
+	if e.NormalizedPOI == "" {
+		return true, "", duplicates
+	}
+	isOK, entities := em.getEntitiesByPOI(stub, e.NormalizedPOI, e.EntityID)
+	if !isOK {
+		return false, "Unable to check the POI for duplicates", duplicates
+	}
+	for _, existing := range entities {
+		if existing.ServiceProvider == e.ServiceProvider {
+			return false, "POI already registered with entity id " + existing.EntityID, duplicates
+		}
+		duplicates = append(duplicates, existing.EntityID)
+	}
+	if err := flagDuplicatePOI(stub, entities); err != nil {
+		return false, "Unable to flag the duplicate entities", duplicates
+	}
+	e.DuplicatePOI = len(duplicates) > 0
+	return true, "", duplicates
+}
+
+// SearchEntitiesByPOI returns all the entities sharing the POI given in args[0], POI is normalised before search
+func (em *EntityManager) SearchEntitiesByPOI(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	authorize, _ := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	npoi := normalizePOI(args[0])
+	if npoi == "" {
+		return shim.Error("POI is mandatory")
+	}
+	isOK, entities := em.getEntitiesByPOI(stub, npoi, "")
+	if !isOK {
+		return shim.Error("queryEntity:GetQueryResult is Failed")
+	}
+	svcprvs := make(map[string]bool)
+	for _, e := range entities {
+		svcprvs[e.ServiceProvider] = true
+	}
+	resultData := map[string]interface{}{
+		"npoi":        npoi,
+		"count":       len(entities),
+		"crossSvcprv": len(svcprvs) > 1,
+		"entities":    entities,
+		"status":      "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// NormalizeEntityPOI sets the normalised POI and the duplicate flag on entities created before POI normalisation.
+// args are the entity ids to be normalised, the result contains the ids updated and the ones failed
+func (em *EntityManager) NormalizeEntityPOI(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	authorize, _ := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	updated := make([]string, 0)
+	failed := make(map[string]string)
+	for _, entityID := range args {
+		entityRecords, err := stub.GetState(entityID)
+		if err != nil || entityRecords == nil {
+			failed[entityID] = "The EntityID doesn't exists"
+			continue
+		}
+		var entity Entity
+		if err := json.Unmarshal(entityRecords, &entity); err != nil {
+			failed[entityID] = "Error when unmarshaling the data"
+			continue
+		}
+		entity.NormalizedPOI = normalizePOI(entity.POI)
+		if entity.NormalizedPOI != "" {
+			//existing duplicates are only flagged, never rejected
+			isOK, entities := em.getEntitiesByPOI(stub, entity.NormalizedPOI, entity.EntityID)
+			if !isOK {
+				failed[entityID] = "Unable to check the POI for duplicates"
+				continue
+			}
+			if err := flagDuplicatePOI(stub, entities); err != nil {
+				failed[entityID] = "Unable to flag the duplicate entities"
+				continue
+			}
+			entity.DuplicatePOI = len(entities) > 0
+		}
+		marshalEntryJSON, _ := json.Marshal(entity)
+		if err := stub.PutState(entity.EntityID, marshalEntryJSON); err != nil {
+			failed[entityID] = "Unable to save with entity id " + entityID
+			continue
+		}
+		updated = append(updated, entityID)
+	}
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"updated": updated,
+		"failed":  failed,
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// AddKYCDocument records the hash of a KYC document of the entity, in pending verification status.
+// A document of the same type replaces the earlier one. Only the service provider of the entity can add documents.
+// The document is verified by another operator with UpdateKYCVerification.
+// args[0] entityID
+// args[1] json {"dtyp":"PAN","hash":"<sha256 hex>","uts":"<timestamp>"}
+func (em *EntityManager) AddKYCDocument(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	authorize, updatedBy := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	var doc KYCDocument
+	if err := json.Unmarshal([]byte(args[1]), &doc); err != nil {
+		return shim.Error("Invalid json provided as input")
+	}
+	if !validEnumEntry(doc.DocType, kycDocType) {
+		return shim.Error("Document Type: Enter either PAN, TAN, GST, CIN, COI or AUTH")
+	}
+	doc.DocHash = strings.ToLower(doc.DocHash)
+	if !docHashFormat.MatchString(doc.DocHash) {
+		return shim.Error("Document hash should be SHA-256 in hex")
+	}
+	if doc.UpdateTs == "" {
+		return shim.Error("Update timeStamp should be present there")
+	}
+	doc.Status = "P"
+	doc.SubmittedBy = updatedBy
+	doc.VerifiedBy = ""
+
+	entity, errMsg := em.getKYCEntity(stub, args[0])
+	if errMsg != "" {
+		return shim.Error(errMsg)
+	}
+	if svcprvDomain[entity.ServiceProvider] != updatedBy {
+		return shim.Error("Sevice Provider and domain operator do not match")
+	}
+	return em.saveKYCDocument(stub, entity, doc, updatedBy)
+}
+
+// UpdateKYCVerification sets the verification status of a KYC document of the entity.
+// The document is verified by an operator other than the one which added it, documents added
+// before sby was kept are taken as added by the service provider of the entity.
+// args[0] entityID
+// args[1] document type
+// args[2] verification status V (verified) or R (rejected)
+// args[3] updateTs
+func (em *EntityManager) UpdateKYCVerification(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 4 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	authorize, updatedBy := em.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	if !validEnumEntry(args[2], kycStatus) || args[2] == "P" {
+		return shim.Error("Status: Enter either V or R")
+	}
+	if args[3] == "" {
+		return shim.Error("Update timeStamp should be present there")
+	}
+	entity, errMsg := em.getKYCEntity(stub, args[0])
+	if errMsg != "" {
+		return shim.Error(errMsg)
+	}
+	for _, doc := range entity.KYCDocs {
+		if doc.DocType == args[1] {
+			submittedBy := doc.SubmittedBy
+			if submittedBy == "" {
+				submittedBy = svcprvDomain[entity.ServiceProvider]
+			}
+			if submittedBy == updatedBy {
+				return shim.Error("{\"error\":\"The document can not be verified by the operator which added it\"}")
+			}
+			doc.Status = args[2]
+			doc.VerifiedBy = updatedBy
+			doc.UpdateTs = args[3]
+			return em.saveKYCDocument(stub, entity, doc, updatedBy)
+		}
+	}
+	return shim.Error("{\"error\":\"No document of type " + args[1] + " for the entity\"}")
+}
+
+// getKYCEntity reads the entity of a KYC transaction, the error message is empty on success
+func (em *EntityManager) getKYCEntity(stub shim.ChaincodeStubInterface, entityID string) (Entity, string) {
+	var entity Entity
+	entityRecords, err := stub.GetState(entityID)
+	if err != nil {
+		return entity, err.Error()
+	}
+	if entityRecords == nil {
+		return entity, "{\"error\":\"The EntityID doesn't exists\"}"
+	}
+	if err := json.Unmarshal(entityRecords, &entity); err != nil {
+		return entity, "{\"error\":\"Error when unmarshaling the data\"}"
+	}
+	return entity, ""
+}
+
+// saveKYCDocument replaces the document of the same type in the entity and saves the entity
+func (em *EntityManager) saveKYCDocument(stub shim.ChaincodeStubInterface, entity Entity, doc KYCDocument, updatedBy string) peer.Response {
+	docs := make([]KYCDocument, 0)
+	for _, existing := range entity.KYCDocs {
+		if existing.DocType != doc.DocType {
+			docs = append(docs, existing)
+		}
+	}
+	entity.KYCDocs = append(docs, doc)
+	entity.UpdateTs = doc.UpdateTs
+	entity.UpdatedBy = updatedBy
+
+	marshalEntryJSON, err := json.Marshal(entity)
+	if err != nil {
+		return shim.Error("{\"error\":\"Error at the time of Marshaling\"}")
+	}
+	if err := stub.PutState(entity.EntityID, marshalEntryJSON); err != nil {
+		return shim.Error("Unable to save with entity id " + entity.EntityID)
+	}
+	if err := stub.SetEvent(_KYCEvent, marshalEntryJSON); err != nil {
+		_entityLogger.Errorf("Event not generated for event : KYC_ENTITY")
+		return shim.Error("{\"error\":\"Unable to generate KYC Entity Event.\"}")
+	}
+	resultData := map[string]interface{}{
+		"trxnID":   stub.GetTxID(),
+		"entityID": entity.EntityID,
+		"message":  "Save successful",
+		"document": doc,
+		"status":   "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//migratedEntity is an entity of the service provider with npoi set
+func migratedEntity(id, poi, svcprv string) map[string]interface{} {
+	entity := legacyEntity(id, poi, svcprv)
+	entity["npoi"] = normalizePOI(poi)
+	entity["poidup"] = false
+	return entity
+}
+
+func TestDuplicatePOIFlagsBothEntities(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "jio.com")
+	stub.put(t, "E1", migratedEntity("E1", "ABCDE1234F", "AI"))
+
+	res := stub.invoke(cc, "createEntityRecord", `{"id":"E2","etype":"P","poi":"abcde-1234f","name":"E2","eclass":"PE","svcprv":"JI","sts":{"JI":"A"},"uts":"2019-08-01 10:00:00","cts":"2019-08-01 10:00:00"}`)
+	if res.Status != shim.OK {
+		t.Fatalf("createEntityRecord failed: %s", res.Message)
+	}
+	for _, id := range []string{"E1", "E2"} {
+		var entity Entity
+		stub.get(t, id, &entity)
+		if !entity.DuplicatePOI {
+			t.Fatalf("%s: expected to be flagged as duplicate", id)
+		}
+	}
+
+	//same service provider is rejected
+	res = stub.invoke(cc, "createEntityRecord", `{"id":"E3","etype":"P","poi":"ABCDE1234F","name":"E3","eclass":"PE","svcprv":"JI","sts":{"JI":"A"},"uts":"2019-08-01 10:00:00","cts":"2019-08-01 10:00:00"}`)
+	if res.Status == shim.OK {
+		t.Fatal("duplicate POI accepted for the same service provider")
+	}
+}
+
+func TestNormalizeEntityPOIFlagsBothEntities(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", migratedEntity("E1", "ABCDE1234F", "AI"))
+	stub.put(t, "E2", legacyEntity("E2", "ABCDE 1234F", "JI"))
+
+	if res := stub.invoke(cc, "normalizeEntityPOI", "E2"); res.Status != shim.OK {
+		t.Fatalf("normalizeEntityPOI failed: %s", res.Message)
+	}
+	for _, id := range []string{"E1", "E2"} {
+		var entity Entity
+		stub.get(t, id, &entity)
+		if !entity.DuplicatePOI {
+			t.Fatalf("%s: expected to be flagged as duplicate", id)
+		}
+	}
+}
+
+func TestKYCVerifiedByAnotherOperator(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", migratedEntity("E1", "ABCDE1234F", "AI"))
+	doc := `{"dtyp":"PAN","hash":"` + sha256Hex + `","uts":"2019-08-01 10:00:00"}`
+
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "addKYCDocument", "E1", doc); res.Status == shim.OK {
+		t.Fatal("document added by an operator other than the service provider")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "addKYCDocument", "E1", doc); res.Status != shim.OK {
+		t.Fatalf("addKYCDocument failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "updateKYCVerification", "E1", "PAN", "V", "2019-08-01 11:00:00"); res.Status == shim.OK {
+		t.Fatal("document verified by the operator which added it")
+	}
+
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "updateKYCVerification", "E1", "PAN", "V", "2019-08-01 11:00:00"); res.Status != shim.OK {
+		t.Fatalf("updateKYCVerification failed: %s", res.Message)
+	}
+	var entity Entity
+	stub.get(t, "E1", &entity)
+	if len(entity.KYCDocs) != 1 || entity.KYCDocs[0].Status != "V" || entity.KYCDocs[0].SubmittedBy != "airtel.com" || entity.KYCDocs[0].VerifiedBy != "jio.com" {
+		t.Fatalf("expected the document added by airtel.com verified by jio.com, got %+v", entity.KYCDocs)
+	}
+}
+
+func TestKYCLegacyDocumentVerifiedByAnotherOperator(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	//documents added before sby was kept are taken as added by the service provider
+	entity := migratedEntity("E1", "ABCDE1234F", "AI")
+	entity["kyc"] = []map[string]string{{"dtyp": "PAN", "hash": sha256Hex, "vsts": "P", "vby": "", "uts": "2019-08-01 10:00:00"}}
+	stub.put(t, "E1", entity)
+
+	if res := stub.invoke(cc, "updateKYCVerification", "E1", "PAN", "V", "2019-08-01 11:00:00"); res.Status == shim.OK {
+		t.Fatal("legacy document verified by the service provider")
+	}
+	stub.setDomain(t, "vil.com")
+	if res := stub.invoke(cc, "updateKYCVerification", "E1", "PAN", "R", "2019-08-01 11:00:00"); res.Status != shim.OK {
+		t.Fatalf("updateKYCVerification failed: %s", res.Message)
+	}
+}
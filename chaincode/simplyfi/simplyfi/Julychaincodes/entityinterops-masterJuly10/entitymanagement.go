@@ -43,6 +43,9 @@ type Entity struct {
 	CreateTs             string            `json:"cts"`  //CreatedTs - autogenerated in backend
 	UpdatedBy            string            `json:"uby"`  //UpdatedBy
 	Blacklisted          bool              `json:"blacklisted"`
+	NormalizedPOI        string            `json:"npoi"`   //POI without separators in upper case - search key
+	DuplicatePOI         bool              `json:"poidup"` //POI shared with an entity of other service provider
+	KYCDocs              []KYCDocument     `json:"kyc"`    //hashes of the KYC documents
 }
 
 //EntityManager manages entity transactions
@@ -58,8 +61,6 @@ var dltDomainNames = map[string]bool{
 	"qtl.infotelconnect.com": true, //QTL
 	"tata.com":               true, //TATA
 	"jio.com":                true, //JIO
-	"org1": true,//for testing purpose in local
-	"org2":true,//for testing purpose in local
 }
 
 var svcprvDomain = map[string]string{
@@ -72,8 +73,6 @@ var svcprvDomain = map[string]string{
 	"JI": "jio.com",
 	"VO": "vil.com",
 	"ID": "vil.com",
-	"Org1":"org1",
-	"Org2":"org2",
 }
 
 var serviceProvider = map[string]bool{
@@ -86,8 +85,6 @@ var serviceProvider = map[string]bool{
 	"TA": true,
 	"JI": true,
 	"VI": true,
-	"Org1":true,
-	"Org2":true,
 }
 
 var orgType = map[string]bool{
@@ -395,13 +392,20 @@ func (em *EntityManager) CreateEntity(stub shim.ChaincodeStubInterface) peer.Res
 	entityToSave.Creator = creator
 	entityToSave.UpdatedBy = creator
 	entityToSave.Blacklisted = false //default to false
+	entityToSave.KYCDocs = nil       //documents are added through addKYCDocument
 
 	//mandatory field validation
-	entityJSON, _ := json.Marshal(entityToSave)
 	if isValid, errMsg := IsValid(entityToSave, creator); !isValid {
 		return shim.Error(errMsg)
 	}
 
+	//POI duplicate check across service providers
+	isUnique, errMsg, duplicates := em.checkDuplicatePOI(stub, &entityToSave)
+	if !isUnique {
+		return shim.Error(errMsg)
+	}
+	entityJSON, _ := json.Marshal(entityToSave)
+
 	//svcprv with domain name validation
 
 	//Save the entry
@@ -422,6 +426,7 @@ func (em *EntityManager) CreateEntity(stub shim.ChaincodeStubInterface) peer.Res
 		"entityID": entityToSave.EntityID,
 		"message":  "Save successful",
 		"entity":   entityToSave,
+		"dupIDs":   duplicates,
 		"status":   "true",
 	}
 	respJSON, _ := json.Marshal(resultData)
@@ -463,6 +468,7 @@ func (em *EntityManager) ModifyEntity(stub shim.ChaincodeStubInterface) peer.Res
 	modifiedEntity.ObjType = existingEntity.ObjType
 	modifiedEntity.CreateTs = existingEntity.CreateTs
 	modifiedEntity.Creator = existingEntity.Creator
+	modifiedEntity.KYCDocs = existingEntity.KYCDocs
 
 	modifiedEntity.UpdatedBy = creatorUpdateBy
 
@@ -470,6 +476,11 @@ func (em *EntityManager) ModifyEntity(stub shim.ChaincodeStubInterface) peer.Res
 		return shim.Error(errMsg)
 	}
 
+	isUnique, errMsg, duplicates := em.checkDuplicatePOI(stub, &modifiedEntity)
+	if !isUnique {
+		return shim.Error(errMsg)
+	}
+
 	marshalEntryJSON, _ := json.Marshal(modifiedEntity)
 	finalErr := stub.PutState(modifiedEntity.EntityID, marshalEntryJSON)
 
@@ -487,6 +498,7 @@ func (em *EntityManager) ModifyEntity(stub shim.ChaincodeStubInterface) peer.Res
 		"entityID": modifiedEntity.EntityID,
 		"message":  "Save successful",
 		"entity":   modifiedEntity,
+		"dupIDs":   duplicates,
 		"status":   "true",
 	}
 	respJSON, _ := json.Marshal(resultData)
@@ -649,7 +661,23 @@ func (em *EntityManager) UpdateBlacklistedValue(stub shim.ChaincodeStubInterface
 	if finalErr != nil {
 		return shim.Error("Unable to save with entity id " + updatedBlacklistEntity.EntityID)
 	}
-	retErr := stub.SetEvent(_BlacklistEntity, marshalEntryJSON)
+
+	//cascade the blacklisting to headers, templates and consents of the entity
+	cascadeAction := _CascadeSuspend
+	if !blackListStsBool {
+		cascadeAction = _CascadeRestore
+	}
+	cascade, cascadeErr := em.startCascade(stub, updatedBlacklistEntity.EntityID, cascadeAction, newUpdatedTS, updatedBy)
+	if cascadeErr != nil {
+		_entityLogger.Errorf("Cascade failed for entity id %s : %v", updatedBlacklistEntity.EntityID, cascadeErr)
+		return shim.Error("{\"error\":\"" + cascadeErr.Error() + "\"}")
+	}
+
+	eventJSON, err := json.Marshal(blacklistEventPayload{Entity: updatedBlacklistEntity, Cascade: cascade})
+	if err != nil {
+		return shim.Error("{\"error\":\"Error at the time of Marshaling\"}")
+	}
+	retErr := stub.SetEvent(_BlacklistEntity, eventJSON)
 
 	if retErr != nil {
 		_entityLogger.Errorf("Event not generated for event : BLACKLIST_ENTITY")
@@ -660,6 +688,7 @@ func (em *EntityManager) UpdateBlacklistedValue(stub shim.ChaincodeStubInterface
 		"entityID": updatedBlacklistEntity.EntityID,
 		"message":  "Save successful",
 		"entity":   updatedBlacklistEntity,
+		"cascade":  cascade,
 		"status":   "true",
 	}
 	respJSON, _ := json.Marshal(resultData)
@@ -7,15 +7,34 @@ import (
 
 var _mainLogger = shim.NewLogger("EntityManagementSmartContract")
 
+//entityMgr is stateless, so it is created with the package and not in Init, which is
+//not called again when the peer restarts the chaincode container
+var entityMgr = new(EntityManager)
+
 //SmartContract represents the main entart contract
 type SmartContract struct {
-	entityMgr *EntityManager
 }
 
-// Init initializes chaincode.
+// Init initializes chaincode. It is called on instantiate and on every upgrade,
+// and applies the schema migrations not yet applied to the ledger. args[0], when
+// given, is the json of the blacklist cascade targets, kept until given again.
 func (sc *SmartContract) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	_mainLogger.Infof("Inside the init method ")
-	sc.entityMgr = new(EntityManager)
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) > 0 && args[0] != "" {
+		if err := setCascadeTargets(stub, args[0]); err != nil {
+			_mainLogger.Errorf("Init failed: %v", err)
+			return shim.Error(err.Error())
+		}
+	}
+	version, done, err := runMigrations(stub)
+	if err != nil {
+		_mainLogger.Errorf("Init failed: %v", err)
+		return shim.Error(err.Error())
+	}
+	if !done {
+		_mainLogger.Warningf("Schema version %d, pending migrations to be continued with runMigrations", version)
+	}
 	return shim.Success(nil)
 }
 func (sc *SmartContract) probe(stub shim.ChaincodeStubInterface) pb.Response {
@@ -37,22 +56,38 @@ func (sc *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	switch action {
 	case "probe":
 		response = sc.probe(stub)
+	case "getSchemaVersion":
+		response = sc.getSchemaVersionInfo(stub)
+	case "runMigrations":
+		response = sc.runPendingMigrations(stub)
 	case "createEntityRecord":
-		response = sc.entityMgr.CreateEntity(stub)
+		response = entityMgr.CreateEntity(stub)
 	case "searchEntityRecord":
-		response = sc.entityMgr.SearchEntity(stub)
+		response = entityMgr.SearchEntity(stub)
 	case "modifyEntityRecord":
-		response = sc.entityMgr.ModifyEntity(stub)
+		response = entityMgr.ModifyEntity(stub)
 	case "getHistoryByKey":
-		response = sc.entityMgr.GetHistoryByKey(stub)
+		response = entityMgr.GetHistoryByKey(stub)
 	case "updateEntityStatus":
-		response = sc.entityMgr.UpdateEntityStatus(stub)
+		response = entityMgr.UpdateEntityStatus(stub)
 	case "searchEntityIDArray":
-		response = sc.entityMgr.SearchEntityIDArray(stub)
+		response = entityMgr.SearchEntityIDArray(stub)
 	case "entityQueryWithPagination":
-		response = sc.entityMgr.EntityQueryWithPagination(stub, args)
+		response = entityMgr.EntityQueryWithPagination(stub, args)
 	case "updateBlacklistedValue":
-		response = sc.entityMgr.UpdateBlacklistedValue(stub)
+		response = entityMgr.UpdateBlacklistedValue(stub)
+	case "searchEntitiesByPOI":
+		response = entityMgr.SearchEntitiesByPOI(stub)
+	case "normalizeEntityPOI":
+		response = entityMgr.NormalizeEntityPOI(stub)
+	case "addKYCDocument":
+		response = entityMgr.AddKYCDocument(stub)
+	case "updateKYCVerification":
+		response = entityMgr.UpdateKYCVerification(stub)
+	case "confirmBlacklistCascade":
+		response = entityMgr.ConfirmBlacklistCascade(stub)
+	case "getBlacklistCascade":
+		response = entityMgr.GetBlacklistCascade(stub)
 	default:
 		response = shim.Error("Invalid action provided")
 	}
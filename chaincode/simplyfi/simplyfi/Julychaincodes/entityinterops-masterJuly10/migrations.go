@@ -0,0 +1,109 @@
+package main
+
+// The schema version key, the list of migrations and runPendingMigrations are in migrationsteps.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// _MigrationBatchSize is the maximum number of records a migration changes in one transaction
+const _MigrationBatchSize = 500
+
+// migration is a change of the ledger data needed by a new version of the chaincode.
+// Migrations are applied in order by Init, on instantiate as well as on upgrade, each of
+// them only once. apply changes at most limit records and returns true once nothing is
+// left, a migration not completed in Init is continued with runMigrations.
+type migration struct {
+	version     int
+	description string
+	apply       func(stub shim.ChaincodeStubInterface, limit int) (bool, error)
+}
+
+// getSchemaVersion returns the version of the last migration completed, 0 if none
+func getSchemaVersion(stub shim.ChaincodeStubInterface) (int, error) {
+	versionBytes, err := stub.GetState(_SchemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if versionBytes == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(versionBytes))
+}
+
+// runMigrations applies the pending migrations in order, one batch each, stopping at the
+// first migration which is not done. Returns the schema version reached and whether all
+// the migrations are done. Any failure fails the transaction as a whole.
+func runMigrations(stub shim.ChaincodeStubInterface) (int, bool, error) {
+	current, err := getSchemaVersion(stub)
+	if err != nil {
+		return current, false, fmt.Errorf("Unable to read schema version: %v", err)
+	}
+	startVersion := current
+	allDone := true
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		_mainLogger.Infof("Applying migration %d : %s", m.version, m.description)
+		done, err := m.apply(stub, _MigrationBatchSize)
+		if err != nil {
+			return current, false, fmt.Errorf("Migration %d failed: %v", m.version, err)
+		}
+		if !done {
+			allDone = false
+			break
+		}
+		current = m.version
+	}
+	if current != startVersion {
+		if err := stub.PutState(_SchemaVersionKey, []byte(strconv.Itoa(current))); err != nil {
+			return current, false, fmt.Errorf("Unable to save schema version: %v", err)
+		}
+	}
+	return current, allDone, nil
+}
+
+// queryBatch returns the values of the first limit records matching the selector, and whether
+// no other record matches. CouchDB is asked for limit+1 records and the iterator is closed after
+// them, so a batch never reads the rest of the ledger. A migration changes the records of its
+// batch so that they no longer match, the next batch continues with the others.
+func queryBatch(stub shim.ChaincodeStubInterface, selector string, limit int) ([][]byte, bool, error) {
+	query := fmt.Sprintf("{\"selector\":%s, \"limit\":%d}", selector, limit+1)
+	resultsIterator, err := stub.GetQueryResult(query)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resultsIterator.Close()
+	values := make([][]byte, 0)
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		if len(values) == limit {
+			return values, false, nil
+		}
+		values = append(values, record.Value)
+	}
+	return values, true, nil
+}
+
+// getSchemaVersionInfo returns the schema version of the ledger along with the migrations known to the chaincode
+func (sc *SmartContract) getSchemaVersionInfo(stub shim.ChaincodeStubInterface) peer.Response {
+	current, err := getSchemaVersion(stub)
+	if err != nil {
+		return shim.Error("Unable to read schema version")
+	}
+	known := make([]map[string]interface{}, 0)
+	for _, m := range migrations {
+		known = append(known, map[string]interface{}{"version": m.version, "description": m.description, "applied": m.version <= current})
+	}
+	respJSON, _ := json.Marshal(map[string]interface{}{"version": current, "migrations": known, "status": "true"})
+	return shim.Success(respJSON)
+}
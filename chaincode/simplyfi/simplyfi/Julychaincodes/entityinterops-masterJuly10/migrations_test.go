@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//legacyEntity is an entity as stored before npoi, poidup and kyc were added
+func legacyEntity(id, poi, svcprv string) map[string]interface{} {
+	return map[string]interface{}{"obj": "Entity", "id": id, "etype": "PRIVATE", "poi": poi, "name": id, "svcprv": svcprv, "sts": map[string]string{"AI": "A"}}
+}
+
+func TestInvokeWithoutInit(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+
+	if res := stub.invoke(cc, "probe"); res.Status != shim.OK {
+		t.Fatalf("probe failed: %s", res.Message)
+	}
+	res := stub.invoke(cc, "getSchemaVersion")
+	if res.Status != shim.OK {
+		t.Fatalf("getSchemaVersion failed: %s", res.Message)
+	}
+	info := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &info); err != nil || info.Version != 0 {
+		t.Fatalf("expected schema version 0 before any migration, got %s", res.Payload)
+	}
+	res = stub.invoke(cc, "addKYCDocument", "E1", `{"dtyp":"PAN","hash":"`+sha256Hex+`","uts":"2019-08-01 10:00:00"}`)
+	if res.Status == shim.OK || res.Message != `{"error":"The EntityID doesn't exists"}` {
+		t.Fatalf("expected missing entity error, got %d %s", res.Status, res.Message)
+	}
+}
+
+func TestRunMigrationsWithoutInit(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", legacyEntity("E1", "abcde-1234f", "AI"))
+
+	res := stub.invoke(cc, "runMigrations")
+	if res.Status != shim.OK {
+		t.Fatalf("runMigrations failed: %s", res.Message)
+	}
+	var entity Entity
+	stub.get(t, "E1", &entity)
+	if entity.NormalizedPOI != "ABCDE1234F" {
+		t.Fatalf("expected npoi ABCDE1234F, got %q", entity.NormalizedPOI)
+	}
+	if version := string(stub.State[_SchemaVersionKey]); version != "1" {
+		t.Fatalf("expected schema version 1, got %q", version)
+	}
+}
+
+func TestRunMigrationsUnauthorized(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "example.com")
+	if res := stub.invoke(cc, "runMigrations"); res.Status == shim.OK {
+		t.Fatal("runMigrations allowed for an unknown domain")
+	}
+}
+
+func TestInitMigratesLegacyEntities(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", legacyEntity("E1", "ABCDE1234F", "AI"))
+	stub.put(t, "E2", legacyEntity("E2", "abcde 1234f", "JI"))
+	stub.put(t, "E3", legacyEntity("E3", "", "AI"))
+
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	for _, id := range []string{"E1", "E2"} {
+		var entity Entity
+		stub.get(t, id, &entity)
+		if entity.NormalizedPOI != "ABCDE1234F" || !entity.DuplicatePOI {
+			t.Fatalf("%s: expected npoi ABCDE1234F flagged as duplicate, got %q %v", id, entity.NormalizedPOI, entity.DuplicatePOI)
+		}
+	}
+	if version := string(stub.State[_SchemaVersionKey]); version != "1" {
+		t.Fatalf("expected schema version 1, got %q", version)
+	}
+
+	//an upgrade with nothing left to migrate keeps the version
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("second Init failed: %s", res.Message)
+	}
+	if version := string(stub.State[_SchemaVersionKey]); version != "1" {
+		t.Fatalf("expected schema version 1 after second Init, got %q", version)
+	}
+}
+
+func TestInitFlagsMigratedDuplicate(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	migrated := legacyEntity("E1", "ABCDE1234F", "AI")
+	migrated["npoi"] = "ABCDE1234F"
+	migrated["poidup"] = false
+	stub.put(t, "E1", migrated)
+	stub.put(t, "E2", legacyEntity("E2", "ABCDE-1234F", "JI"))
+
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	for _, id := range []string{"E1", "E2"} {
+		var entity Entity
+		stub.get(t, id, &entity)
+		if !entity.DuplicatePOI {
+			t.Fatalf("%s: expected to be flagged as duplicate", id)
+		}
+	}
+}
+
+func TestMigrationResumesInBatches(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "entity", cc, "airtel.com")
+	stub.put(t, "E1", legacyEntity("E1", "ABCDE1234F", "AI"))
+	stub.put(t, "E2", legacyEntity("E2", "PQRST6789K", "JI"))
+
+	//each batch migrates at most limit entities and reports done once none is left
+	for i, expected := range []bool{false, true} {
+		stub.MockTransactionStart("batch")
+		done, err := migrateNormalizePOI(stub, 1)
+		stub.MockTransactionEnd("batch")
+		if err != nil || done != expected {
+			t.Fatalf("batch %d: expected done %v, got %v %v", i+1, expected, done, err)
+		}
+		var entity Entity
+		stub.get(t, []string{"E1", "E2"}[i], &entity)
+		if entity.NormalizedPOI == "" {
+			t.Fatalf("batch %d: entity not migrated", i+1)
+		}
+	}
+}
+
+func TestInitFailsOnQueryError(t *testing.T) {
+	cc := new(SmartContract)
+	//the plain MockStub has no rich query, the migration must fail instead of panicking
+	stub := shim.NewMockStub("entity", cc)
+	if res := stub.MockInit("tx1", nil); res.Status == shim.OK {
+		t.Fatal("Init succeeded without being able to query the entities")
+	}
+}
+
+const sha256Hex = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
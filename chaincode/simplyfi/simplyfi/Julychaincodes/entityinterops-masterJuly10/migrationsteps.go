@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// _SchemaVersionKey keeps the version of the last migration completed on the ledger
+const _SchemaVersionKey = "ENTITY_SCHEMA_VERSION"
+
+// migrations - append new migrations at the end with the next version, never reorder
+var migrations = []migration{
+	{1, "normalise POI of entities created before POI duplicate check", migrateNormalizePOI},
+}
+
+// migrateNormalizePOI sets npoi and poidup on the entities which do not have npoi yet
+func migrateNormalizePOI(stub shim.ChaincodeStubInterface, limit int) (bool, error) {
+	criteria := `{
+		"obj":"Entity"	,
+		"npoi":{"$exists":false}
+	}`
+	values, done, err := queryBatch(stub, criteria, limit)
+	if err != nil {
+		return false, fmt.Errorf("unable to query entities: %v", err)
+	}
+	entities := make([]Entity, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal(value, &entities[i]); err != nil {
+			return false, err
+		}
+	}
+	//the writes of a transaction are not visible to its own queries, so duplicates among the
+	//entities of the batch are counted from the batch and the query finds the migrated ones
+	batchPOI := make(map[string]int)
+	for i := range entities {
+		entities[i].NormalizedPOI = normalizePOI(entities[i].POI)
+		batchPOI[entities[i].NormalizedPOI]++
+	}
+	for _, entity := range entities {
+		if entity.NormalizedPOI != "" {
+			isOK, others := entityMgr.getEntitiesByPOI(stub, entity.NormalizedPOI, entity.EntityID)
+			if !isOK {
+				return false, fmt.Errorf("unable to query entities by POI")
+			}
+			entity.DuplicatePOI = len(others) > 0 || batchPOI[entity.NormalizedPOI] > 1
+			//entities migrated earlier are flagged as well once a duplicate turns up
+			if err := flagDuplicatePOI(stub, others); err != nil {
+				return false, err
+			}
+		}
+		entityJSON, err := json.Marshal(entity)
+		if err != nil {
+			return false, err
+		}
+		if err := stub.PutState(entity.EntityID, entityJSON); err != nil {
+			return false, err
+		}
+	}
+	_mainLogger.Infof("POI normalised for %d entities", len(entities))
+	return done, nil
+}
+
+// runPendingMigrations continues the migrations not completed by Init, one batch per transaction
+func (sc *SmartContract) runPendingMigrations(stub shim.ChaincodeStubInterface) peer.Response {
+	authorize, _ := entityMgr.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	version, done, err := runMigrations(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	respJSON, _ := json.Marshal(map[string]interface{}{"trxnID": stub.GetTxID(), "version": version, "done": done, "status": "true"})
+	return shim.Success(respJSON)
+}
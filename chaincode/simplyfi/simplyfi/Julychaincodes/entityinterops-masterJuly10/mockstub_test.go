@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// testStub is a MockStub invoked with the certificate of an operator domain. MockStub has
+// no creator and no rich query, so GetCreator returns the certificate and GetQueryResult
+// evaluates the CouchDB selectors used by the chaincode (equality, $in and $exists on top
+// level fields, limit) over the world state.
+type testStub struct {
+	*shim.MockStub
+	args    [][]byte
+	creator []byte
+	txCount int
+}
+
+func newTestStub(t *testing.T, name string, cc shim.Chaincode, domain string) *testStub {
+	stub := &testStub{MockStub: shim.NewMockStub(name, cc)}
+	stub.setDomain(t, domain)
+	return stub
+}
+
+// setDomain switches the invoker to a self signed certificate issued by the domain
+func (stub *testStub) setDomain(t *testing.T, domain string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Admin@" + domain, Organization: []string{domain}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := &msp.SerializedIdentity{Mspid: domain, IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	stub.creator, err = proto.Marshal(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (stub *testStub) nextTxID() string {
+	stub.txCount++
+	return "tx" + strconv.Itoa(stub.txCount)
+}
+
+// init calls Init of the chaincode in a transaction
+func (stub *testStub) init(cc shim.Chaincode, args ...string) pb.Response {
+	stub.setArgs(args)
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return cc.Init(stub)
+}
+
+// invoke calls Invoke of the chaincode in a transaction, args[0] being the function
+func (stub *testStub) invoke(cc shim.Chaincode, args ...string) pb.Response {
+	stub.setArgs(args)
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return cc.Invoke(stub)
+}
+
+// put stores a record directly in the world state
+func (stub *testStub) put(t *testing.T, key string, record interface{}) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	if err := stub.PutState(key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get reads a record of the world state into record
+func (stub *testStub) get(t *testing.T, key string, record interface{}) {
+	value := stub.State[key]
+	if value == nil {
+		t.Fatalf("no record for key %s", key)
+	}
+	if err := json.Unmarshal(value, record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (stub *testStub) setArgs(args []string) {
+	stub.args = make([][]byte, 0, len(args))
+	for _, arg := range args {
+		stub.args = append(stub.args, []byte(arg))
+	}
+}
+
+func (stub *testStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *testStub) GetStringArgs() []string {
+	args := make([]string, 0, len(stub.args))
+	for _, arg := range stub.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (stub *testStub) GetFunctionAndParameters() (string, []string) {
+	args := stub.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (stub *testStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
+
+func (stub *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, err := stub.query(query)
+	if err != nil {
+		return nil, err
+	}
+	return &testIterator{results: results}, nil
+}
+
+// query returns the records of the world state matching the selector, in key order, at
+// most limit of them when the query has a limit
+func (stub *testStub) query(query string) ([]*queryresult.KV, error) {
+	request := struct {
+		Selector map[string]interface{} `json:"selector"`
+		Limit    int                    `json:"limit"`
+	}{}
+	if err := json.Unmarshal([]byte(query), &request); err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+	keys := make([]string, 0, len(stub.State))
+	for key := range stub.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	results := make([]*queryresult.KV, 0)
+	for _, key := range keys {
+		if request.Limit > 0 && len(results) == request.Limit {
+			break
+		}
+		doc := make(map[string]interface{})
+		if err := json.Unmarshal(stub.State[key], &doc); err != nil {
+			continue
+		}
+		if matchSelector(doc, request.Selector) {
+			results = append(results, &queryresult.KV{Key: key, Value: stub.State[key]})
+		}
+	}
+	return results, nil
+}
+
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		value, exists := doc[field]
+		if !matchCondition(value, exists, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCondition(value interface{}, exists bool, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok {
+		return exists && reflect.DeepEqual(value, condition)
+	}
+	for operator, operand := range operators {
+		switch operator {
+		case "$exists":
+			if exists != operand.(bool) {
+				return false
+			}
+		case "$in":
+			found := false
+			for _, candidate := range operand.([]interface{}) {
+				if exists && reflect.DeepEqual(value, candidate) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// testIterator iterates over the results of a query of testStub
+type testIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (iter *testIterator) HasNext() bool {
+	return iter.next < len(iter.results)
+}
+
+func (iter *testIterator) Next() (*queryresult.KV, error) {
+	if !iter.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	iter.next++
+	return iter.results[iter.next-1], nil
+}
+
+func (iter *testIterator) Close() error {
+	return nil
+}
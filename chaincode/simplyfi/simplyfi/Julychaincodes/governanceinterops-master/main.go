@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+var _mainLogger = shim.NewLogger("GovernanceSmartContract")
+
+// managers are stateless, so they are created with the package and not in Init
+var tspMgr = new(TSPManager)
+var registrarMgr = new(RegistrarManager)
+var proposalMgr = new(ProposalManager)
+
+// SmartContract represents the main entart contract
+type SmartContract struct {
+}
+
+// Init initializes chaincode. It is called on instantiate and on every upgrade,
+// and registers the founding operators and the default governance settings
+// not yet on the ledger.
+func (sc *SmartContract) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_mainLogger.Infof("Inside the init method ")
+	if err := tspMgr.registerFoundingTSPs(stub); err != nil {
+		_mainLogger.Errorf("Init failed: %v", err)
+		return shim.Error(err.Error())
+	}
+	if err := proposalMgr.registerDefaultConfig(stub); err != nil {
+		_mainLogger.Errorf("Init failed: %v", err)
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+func (sc *SmartContract) probe(stub shim.ChaincodeStubInterface) pb.Response {
+	ts := ""
+	_mainLogger.Info("Inside probe method")
+	tst, err := stub.GetTxTimestamp()
+	if err == nil {
+		ts = tst.String()
+	}
+	output := "{\"status\":\"Success\",\"ts\" : \"" + ts + "\" }"
+	_mainLogger.Info("Retuning " + output)
+	return shim.Success([]byte(output))
+}
+
+// Invoke is the entry point for any transaction
+func (sc *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	var response pb.Response
+	action, _ := stub.GetFunctionAndParameters()
+	switch action {
+	case "probe":
+		response = sc.probe(stub)
+	case "createProposal":
+		response = proposalMgr.CreateProposal(stub)
+	case "voteProposal":
+		response = proposalMgr.VoteProposal(stub)
+	case "expireProposals":
+		response = proposalMgr.ExpireProposals(stub)
+	case "searchProposal":
+		response = proposalMgr.SearchProposal(stub)
+	case "listProposals":
+		response = proposalMgr.ListProposals(stub)
+	case "getGovernanceConfig":
+		response = proposalMgr.GetGovernanceConfig(stub)
+	case "searchTSP":
+		response = tspMgr.SearchTSP(stub)
+	case "listTSPs":
+		response = tspMgr.ListTSPs(stub)
+	case "createRegistrar":
+		response = registrarMgr.CreateRegistrar(stub)
+	case "assignRegistrarRoles":
+		response = registrarMgr.AssignRegistrarRoles(stub)
+	case "updateRegistrarStatus":
+		response = registrarMgr.UpdateRegistrarStatus(stub)
+	case "searchRegistrar":
+		response = registrarMgr.SearchRegistrar(stub)
+	case "searchRegistrarsByTSP":
+		response = registrarMgr.SearchRegistrarsByTSP(stub)
+	case "checkRegistrarRole":
+		response = registrarMgr.CheckRegistrarRole(stub)
+	default:
+		response = shim.Error("Invalid action provided")
+	}
+	return response
+}
+
+func main() {
+	err := shim.Start(new(SmartContract))
+	if err != nil {
+		_mainLogger.Criticalf("Error starting  chaincode: %v", err)
+	}
+}
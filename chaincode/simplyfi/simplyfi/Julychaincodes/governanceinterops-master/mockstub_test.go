@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// testStub is a MockStub whose creator is a certificate of the domain set by setDomain
+type testStub struct {
+	*shim.MockStub
+	args    [][]byte
+	creator []byte
+	txCount int
+}
+
+func newTestStub(t *testing.T, name string, cc shim.Chaincode, domain string) *testStub {
+	stub := &testStub{MockStub: shim.NewMockStub(name, cc)}
+	stub.setDomain(t, domain)
+	return stub
+}
+
+// setDomain switches the invoker to a self signed certificate issued by the domain
+func (stub *testStub) setDomain(t *testing.T, domain string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Admin@" + domain, Organization: []string{domain}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := &msp.SerializedIdentity{Mspid: domain, IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	stub.creator, err = proto.Marshal(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (stub *testStub) nextTxID() string {
+	stub.txCount++
+	return "tx" + strconv.Itoa(stub.txCount)
+}
+
+// init calls Init of the chaincode in a transaction
+func (stub *testStub) init(cc shim.Chaincode, args ...string) pb.Response {
+	stub.setArgs(args)
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return cc.Init(stub)
+}
+
+// invoke calls Invoke of the chaincode in a transaction, args[0] being the function
+func (stub *testStub) invoke(cc shim.Chaincode, args ...string) pb.Response {
+	stub.setArgs(args)
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return cc.Invoke(stub)
+}
+
+// put stores a record directly in the world state
+func (stub *testStub) put(t *testing.T, key string, record interface{}) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txID := stub.nextTxID()
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	if err := stub.PutState(key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get reads a record of the world state into record
+func (stub *testStub) get(t *testing.T, key string, record interface{}) {
+	value := stub.State[key]
+	if value == nil {
+		t.Fatalf("no record for key %s", key)
+	}
+	if err := json.Unmarshal(value, record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (stub *testStub) setArgs(args []string) {
+	stub.args = make([][]byte, 0, len(args))
+	for _, arg := range args {
+		stub.args = append(stub.args, []byte(arg))
+	}
+}
+
+func (stub *testStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *testStub) GetStringArgs() []string {
+	args := make([]string, 0, len(stub.args))
+	for _, arg := range stub.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (stub *testStub) GetFunctionAndParameters() (string, []string) {
+	args := stub.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (stub *testStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+var _proposalLogger = shim.NewLogger("ProposalManager")
+
+const _CreateProposalEvent = "CREATE_PROPOSAL"
+const _VoteProposalEvent = "VOTE_PROPOSAL"
+
+const _ProposalObj = "Proposal"
+const _ConfigObj = "GovernanceConfig"
+const _ConfigKey = "network"
+
+// Proposal status
+const (
+	_ProposalPending  = "P" //open for votes
+	_ProposalApproved = "A" //quorum reached and executed, or to be executed by the target chaincode
+	_ProposalRejected = "R" //quorum can no longer be reached
+	_ProposalExpired  = "X" //deadline passed without quorum
+	_ProposalFailed   = "F" //quorum reached but the change could not be applied
+)
+
+// Proposal types
+const (
+	_AddTSP           = "ADD_TSP"           //payload : TSP {"id","name","domain"}
+	_SetCategories    = "SET_CATEGORIES"    //payload : {"categories":["0",...]}
+	_SetQuorum        = "SET_QUORUM"        //payload : {"qnum":1,"qden":2}
+	_BlacklistHeaders = "BLACKLIST_HEADERS" //payload : {"clis":["CLI1",...]}, executed by bbh of headersms
+	_SetHeaderFormat  = "SET_HEADER_FORMAT" //payload : {"chnl","htyp","min","max","charset","pfx"}, executed by shf of headersms (SMS) or headervoice (VOICE)
+	_SetCategoryRule  = "SET_CATEGORY_RULE" //payload : {"ctyp","htyp":[...],"mctgr"}, executed by scr of templates
+)
+
+// crossChannelTypes - proposal types executed by a chaincode of another channel with the proposal id
+var crossChannelTypes = map[string]string{
+	_BlacklistHeaders: "bbh %s on the header chaincodes",
+	_SetHeaderFormat:  "shf %s on the header chaincodes",
+	_SetCategoryRule:  "scr %s on the template chaincode",
+}
+
+// _ExecuteProposalEvent is raised when a proposal to be executed on another channel is approved.
+// A chaincode invoked across channels can only be read, so the header chaincodes can not be
+// changed from here: the listener of the event invokes bbh with the proposal id
+const _ExecuteProposalEvent = "EXECUTE_PROPOSAL"
+
+// proposalExecutor applies an approved proposal and returns the result recorded on it
+type proposalExecutor func(stub shim.ChaincodeStubInterface, proposal *Proposal) (string, error)
+
+var proposalTypes = map[string]proposalExecutor{
+	_AddTSP: func(stub shim.ChaincodeStubInterface, proposal *Proposal) (string, error) {
+		return "TSP registered", tspMgr.executeAddTSP(stub, proposal)
+	},
+	_SetCategories: func(stub shim.ChaincodeStubInterface, proposal *Proposal) (string, error) {
+		return "Categories updated", proposalMgr.executeSetCategories(stub, proposal)
+	},
+	_SetQuorum: func(stub shim.ChaincodeStubInterface, proposal *Proposal) (string, error) {
+		return "Quorum updated", proposalMgr.executeSetQuorum(stub, proposal)
+	},
+	_BlacklistHeaders: executeOnOtherChannel,
+	_SetHeaderFormat:  executeOnOtherChannel,
+	_SetCategoryRule:  executeOnOtherChannel,
+}
+
+// executeOnOtherChannel records on the proposal the function of the chaincode executing it
+func executeOnOtherChannel(stub shim.ChaincodeStubInterface, proposal *Proposal) (string, error) {
+	return "To be executed with " + fmt.Sprintf(crossChannelTypes[proposal.Type], proposal.ProposalID), nil
+}
+
+var voteValue = map[string]bool{
+	"A": true,
+	"R": true,
+}
+
+// Proposal structure defines the ledger record of a network-wide change put to vote
+type Proposal struct {
+	ObjType     string            `json:"obj"`     //DocType  -- Proposal
+	ProposalID  string            `json:"id"`      //Key field - autogenerated in backend
+	Type        string            `json:"ptyp"`    //ADD_TSP, SET_CATEGORIES, SET_QUORUM, BLACKLIST_HEADERS, SET_HEADER_FORMAT, SET_CATEGORY_RULE
+	Payload     json.RawMessage   `json:"payload"` //change to apply, depends on ptyp
+	Description string            `json:"desc"`    //reason of the change
+	Proposer    string            `json:"prop"`    //domain of the proposing operator
+	Votes       map[string]string `json:"votes"`   //votes of the operators by domain {"airtel.com":"A","jio.com":"R"}
+	QuorumNum   int               `json:"qnum"`    //quorum in force when proposed, approvals * qden > voters * qnum
+	QuorumDen   int               `json:"qden"`
+	Deadline    string            `json:"ddl"` //epoch seconds after which the proposal expires
+	Status      string            `json:"sts"` //P, A, R, X, F
+	Result      string            `json:"res"` //result of the execution
+	CreateTs    string            `json:"cts"` //CreatedTs
+	UpdateTs    string            `json:"uts"` //UpdatedTs
+	UpdatedBy   string            `json:"uby"` //UpdatedBy
+}
+
+// GovernanceConfig structure holds the network settings changed through proposals
+type GovernanceConfig struct {
+	ObjType    string   `json:"obj"`  //DocType  -- GovernanceConfig
+	QuorumNum  int      `json:"qnum"` //default 1/2 : a majority of the active operators
+	QuorumDen  int      `json:"qden"`
+	Categories []string `json:"categories"` //allowed categories
+	UpdateTs   string   `json:"uts"`
+	UpdatedBy  string   `json:"uby"`
+}
+
+// ProposalManager manages the proposals and votes of the operators
+type ProposalManager struct {
+}
+
+func getProposalKey(stub shim.ChaincodeStubInterface, proposalID string) (string, error) {
+	return stub.CreateCompositeKey(_ProposalObj, []string{proposalID})
+}
+
+// registerDefaultConfig saves the default settings when they are not on the ledger yet
+func (pm *ProposalManager) registerDefaultConfig(stub shim.ChaincodeStubInterface) error {
+	config, err := pm.getConfig(stub)
+	if err != nil || config != nil {
+		return err
+	}
+	return pm.putConfig(stub, GovernanceConfig{
+		ObjType:    _ConfigObj,
+		QuorumNum:  1,
+		QuorumDen:  2,
+		Categories: []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+		UpdatedBy:  "system",
+	})
+}
+
+func (pm *ProposalManager) getConfig(stub shim.ChaincodeStubInterface) (*GovernanceConfig, error) {
+	key, err := stub.CreateCompositeKey(_ConfigObj, []string{_ConfigKey})
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := stub.GetState(key)
+	if err != nil || configBytes == nil {
+		return nil, err
+	}
+	var config GovernanceConfig
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (pm *ProposalManager) putConfig(stub shim.ChaincodeStubInterface, config GovernanceConfig) error {
+	key, err := stub.CreateCompositeKey(_ConfigObj, []string{_ConfigKey})
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, configJSON)
+}
+
+func (pm *ProposalManager) getProposal(stub shim.ChaincodeStubInterface, proposalID string) (*Proposal, error) {
+	key, err := getProposalKey(stub, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	proposalBytes, err := stub.GetState(key)
+	if err != nil || proposalBytes == nil {
+		return nil, err
+	}
+	var proposal Proposal
+	if err := json.Unmarshal(proposalBytes, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// getTxSeconds returns the transaction time in epoch seconds
+func getTxSeconds(stub shim.ChaincodeStubInterface) (int64, error) {
+	txTs, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	return txTs.Seconds, nil
+}
+
+// isExpired tells if the deadline of the proposal has passed at the given time
+func isExpired(proposal *Proposal, now int64) bool {
+	deadline, err := strconv.ParseInt(proposal.Deadline, 10, 64)
+	return err != nil || now >= deadline
+}
+
+// isValidPayload checks the payload of the proposal can be executed once approved
+func (pm *ProposalManager) isValidPayload(stub shim.ChaincodeStubInterface, proposal Proposal) (bool, string) {
+	switch proposal.Type {
+	case _AddTSP:
+		var tsp TSP
+		if err := json.Unmarshal(proposal.Payload, &tsp); err != nil {
+			return false, "Invalid TSP provided as payload"
+		}
+		return tspMgr.isValidNewTSP(stub, tsp)
+	case _SetCategories:
+		var payload GovernanceConfig
+		if err := json.Unmarshal(proposal.Payload, &payload); err != nil || len(payload.Categories) == 0 {
+			return false, "Atleast one category is required in payload"
+		}
+	case _SetQuorum:
+		var payload GovernanceConfig
+		if err := json.Unmarshal(proposal.Payload, &payload); err != nil || payload.QuorumDen <= 0 || payload.QuorumNum <= 0 || payload.QuorumNum >= payload.QuorumDen {
+			return false, "qnum and qden should satisfy 0 < qnum < qden"
+		}
+	case _BlacklistHeaders:
+		var payload struct {
+			CLIs []string `json:"clis"`
+		}
+		if err := json.Unmarshal(proposal.Payload, &payload); err != nil || len(payload.CLIs) == 0 {
+			return false, "Atleast one cli is required in payload"
+		}
+	case _SetHeaderFormat:
+		var payload struct {
+			Channel string `json:"chnl"`
+			Type    string `json:"htyp"`
+			Charset string `json:"charset"`
+			Min     int    `json:"min"`
+			Max     int    `json:"max"`
+		}
+		if err := json.Unmarshal(proposal.Payload, &payload); err != nil || payload.Channel == "" || payload.Type == "" || payload.Charset == "" {
+			return false, "chnl, htyp and charset are required in payload"
+		}
+		if payload.Min < 1 || payload.Max < payload.Min {
+			return false, "min should be atleast 1 and max not less than min"
+		}
+	case _SetCategoryRule:
+		var payload struct {
+			CommunicationType string   `json:"ctyp"`
+			HeaderTypes       []string `json:"htyp"`
+		}
+		if err := json.Unmarshal(proposal.Payload, &payload); err != nil || payload.CommunicationType == "" || len(payload.HeaderTypes) == 0 {
+			return false, "ctyp and atleast one htyp are required in payload"
+		}
+	}
+	return true, ""
+}
+
+// executeSetCategories replaces the allowed categories
+func (pm *ProposalManager) executeSetCategories(stub shim.ChaincodeStubInterface, proposal *Proposal) error {
+	var payload GovernanceConfig
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	config, err := pm.getConfig(stub)
+	if err != nil || config == nil {
+		return fmt.Errorf("Governance config not found")
+	}
+	config.Categories = payload.Categories
+	config.UpdateTs = proposal.UpdateTs
+	config.UpdatedBy = proposal.UpdatedBy
+	return pm.putConfig(stub, *config)
+}
+
+// executeSetQuorum replaces the quorum of the proposals created from now on
+func (pm *ProposalManager) executeSetQuorum(stub shim.ChaincodeStubInterface, proposal *Proposal) error {
+	var payload GovernanceConfig
+	if err := json.Unmarshal(proposal.Payload, &payload); err != nil {
+		return err
+	}
+	config, err := pm.getConfig(stub)
+	if err != nil || config == nil {
+		return fmt.Errorf("Governance config not found")
+	}
+	config.QuorumNum = payload.QuorumNum
+	config.QuorumDen = payload.QuorumDen
+	config.UpdateTs = proposal.UpdateTs
+	config.UpdatedBy = proposal.UpdatedBy
+	return pm.putConfig(stub, *config)
+}
+
+// proposalEvent returns the event raised with the proposal, EXECUTE_PROPOSAL when it is approved
+// and has still to be executed on another channel
+func proposalEvent(proposal Proposal, event string) string {
+	if _, ok := crossChannelTypes[proposal.Type]; ok && proposal.Status == _ProposalApproved {
+		return _ExecuteProposalEvent
+	}
+	return event
+}
+
+// tallyVotes decides the proposal once the quorum is reached or can no longer be reached, and executes it when approved
+func (pm *ProposalManager) tallyVotes(stub shim.ChaincodeStubInterface, proposal *Proposal) error {
+	tsps, err := tspMgr.getAllTSPs(stub)
+	if err != nil {
+		return err
+	}
+	//every domain has one vote
+	domains := activeDomains(tsps)
+	voters := len(domains)
+	approvals, rejections := 0, 0
+	for domain, vote := range proposal.Votes {
+		if !domains[domain] {
+			continue
+		}
+		if vote == "A" {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	if approvals*proposal.QuorumDen > voters*proposal.QuorumNum {
+		result, err := proposalTypes[proposal.Type](stub, proposal)
+		if err != nil {
+			_proposalLogger.Errorf("Proposal %s could not be executed : %v", proposal.ProposalID, err)
+			proposal.Status = _ProposalFailed
+			proposal.Result = err.Error()
+			return nil
+		}
+		proposal.Status = _ProposalApproved
+		proposal.Result = result
+	} else if (voters-rejections)*proposal.QuorumDen <= voters*proposal.QuorumNum {
+		proposal.Status = _ProposalRejected
+	}
+	return nil
+}
+
+func (pm *ProposalManager) saveProposalWithEvent(stub shim.ChaincodeStubInterface, proposal Proposal, event string) peer.Response {
+	key, err := getProposalKey(stub, proposal.ProposalID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	proposalJSON, _ := json.Marshal(proposal)
+	if err := stub.PutState(key, proposalJSON); err != nil {
+		return shim.Error("Unable to save with proposal id " + proposal.ProposalID)
+	}
+	event = proposalEvent(proposal, event)
+	if err := stub.SetEvent(event, proposalJSON); err != nil {
+		_proposalLogger.Errorf("Event not generated for event : " + event)
+		return shim.Error("{\"error\":\"Unable to generate " + event + " Event.\"}")
+	}
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"proposalID": proposal.ProposalID,
+		"message":    "Save successful",
+		"proposal":   proposal,
+		"status":     "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// CreateProposal puts a change to vote, the proposing operator approves it
+func (pm *ProposalManager) CreateProposal(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	var proposal Proposal
+	err := json.Unmarshal([]byte(args[0]), &proposal)
+	if err != nil {
+		return shim.Error("Invalid json provided as input")
+	}
+
+	authorize, creator := tspMgr.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+
+	if len(proposal.ProposalID) == 0 {
+		return shim.Error("Proposal Id should be present there")
+	}
+	if _, ok := proposalTypes[proposal.Type]; !ok {
+		return shim.Error("ptyp: Enter ADD_TSP, SET_CATEGORIES, SET_QUORUM, BLACKLIST_HEADERS, SET_HEADER_FORMAT or SET_CATEGORY_RULE")
+	}
+	if len(proposal.CreateTs) == 0 {
+		return shim.Error("CreateTS is mandatory")
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if isExpired(&proposal, now) {
+		return shim.Error("ddl should be a future time in epoch seconds")
+	}
+	if isValid, errMsg := pm.isValidPayload(stub, proposal); !isValid {
+		return shim.Error(errMsg)
+	}
+	existing, err := pm.getProposal(stub, proposal.ProposalID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return shim.Error("Proposal id already registered. Provide an unique proposal id")
+	}
+	config, err := pm.getConfig(stub)
+	if err != nil || config == nil {
+		return shim.Error("Governance config not found")
+	}
+
+	proposal.ObjType = _ProposalObj
+	proposal.Proposer = creator
+	proposal.Votes = map[string]string{creator: "A"}
+	proposal.QuorumNum = config.QuorumNum
+	proposal.QuorumDen = config.QuorumDen
+	proposal.Status = _ProposalPending
+	proposal.Result = ""
+	proposal.UpdateTs = proposal.CreateTs
+	proposal.UpdatedBy = creator
+	if err := pm.tallyVotes(stub, &proposal); err != nil {
+		return shim.Error(err.Error())
+	}
+	return pm.saveProposalWithEvent(stub, proposal, _CreateProposalEvent)
+}
+
+// VoteProposal records the vote (A/R) of the invoking operator, args : id, vote, uts
+func (pm *ProposalManager) VoteProposal(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 3 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+
+	authorize, voter := tspMgr.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+
+	proposalID := args[0]
+	vote := args[1]
+	newUpdatedTS := args[2]
+	if !validEnumEntry(vote, voteValue) {
+		return shim.Error("Vote: Enter either A, R")
+	}
+	if newUpdatedTS == "" {
+		return shim.Error("Update timeStamp should be present there")
+	}
+	proposal, err := pm.getProposal(stub, proposalID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if proposal == nil {
+		return shim.Error("{\"error\":\"The proposal id doesn't exists\"}")
+	}
+	if proposal.Status != _ProposalPending {
+		return shim.Error("{\"error\":\"The proposal is not open for voting\"}")
+	}
+	proposal.UpdateTs = newUpdatedTS
+	proposal.UpdatedBy = voter
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if isExpired(proposal, now) {
+		proposal.Status = _ProposalExpired
+		return pm.saveProposalWithEvent(stub, *proposal, _VoteProposalEvent)
+	}
+	if _, voted := proposal.Votes[voter]; voted {
+		return shim.Error("{\"error\":\"Operator already voted on the proposal\"}")
+	}
+	proposal.Votes[voter] = vote
+	if err := pm.tallyVotes(stub, proposal); err != nil {
+		return shim.Error(err.Error())
+	}
+	return pm.saveProposalWithEvent(stub, *proposal, _VoteProposalEvent)
+}
+
+// ExpireProposals marks the pending proposals past their deadline as expired, args : uts
+func (pm *ProposalManager) ExpireProposals(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+	authorize, updatedBy := tspMgr.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	proposals, err := pm.retriveProposalRecords(stub, _ProposalPending)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	expired := make([]string, 0)
+	for _, proposal := range proposals {
+		if !isExpired(&proposal, now) {
+			continue
+		}
+		proposal.Status = _ProposalExpired
+		proposal.UpdateTs = args[0]
+		proposal.UpdatedBy = updatedBy
+		key, err := getProposalKey(stub, proposal.ProposalID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		proposalJSON, _ := json.Marshal(proposal)
+		if err := stub.PutState(key, proposalJSON); err != nil {
+			return shim.Error("Unable to save with proposal id " + proposal.ProposalID)
+		}
+		expired = append(expired, proposal.ProposalID)
+	}
+	respJSON, _ := json.Marshal(expired)
+	return shim.Success(respJSON)
+}
+
+// retriveProposalRecords returns the proposals with the given status, all of them for an empty status
+func (pm *ProposalManager) retriveProposalRecords(stub shim.ChaincodeStubInterface, status string) ([]Proposal, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_ProposalObj, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	proposals := make([]Proposal, 0)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var proposal Proposal
+		if err := json.Unmarshal(response.Value, &proposal); err != nil {
+			return nil, err
+		}
+		if status == "" || proposal.Status == status {
+			proposals = append(proposals, proposal)
+		}
+	}
+	return proposals, nil
+}
+
+// SearchProposal returns the proposal of the given id
+func (pm *ProposalManager) SearchProposal(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	proposal, err := pm.getProposal(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if proposal == nil {
+		return shim.Error("{\"error\":\"The proposal id doesn't exists\"}")
+	}
+	proposalJSON, _ := json.Marshal(proposal)
+	return shim.Success(proposalJSON)
+}
+
+// ListProposals returns all the proposals, or the ones with the status given as first argument
+func (pm *ProposalManager) ListProposals(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	status := ""
+	if len(args) > 0 {
+		status = args[0]
+	}
+	proposals, err := pm.retriveProposalRecords(stub, status)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	recordsJSON, err := json.Marshal(proposals)
+	if err != nil {
+		return shim.Error("Error marshalling Query response")
+	}
+	return shim.Success(recordsJSON)
+}
+
+// GetGovernanceConfig returns the quorum and the allowed categories in force
+func (pm *ProposalManager) GetGovernanceConfig(stub shim.ChaincodeStubInterface) peer.Response {
+	config, err := pm.getConfig(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if config == nil {
+		return shim.Error("Governance config not found")
+	}
+	configJSON, _ := json.Marshal(config)
+	return shim.Success(configJSON)
+}
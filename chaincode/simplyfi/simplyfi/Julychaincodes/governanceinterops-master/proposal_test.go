@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func futureDeadline() string {
+	return strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+}
+
+func createProposal(t *testing.T, cc *SmartContract, stub *testStub, domain, proposalID, proposalType, payload string) {
+	stub.setDomain(t, domain)
+	proposal := fmt.Sprintf(`{"id":"%s","ptyp":"%s","payload":%s,"desc":"test","ddl":"%s","cts":"1564740000"}`, proposalID, proposalType, payload, futureDeadline())
+	if res := stub.invoke(cc, "createProposal", proposal); res.Status != shim.OK {
+		t.Fatalf("createProposal failed: %s", res.Message)
+	}
+}
+
+func vote(t *testing.T, cc *SmartContract, stub *testStub, domain, proposalID, value string) {
+	stub.setDomain(t, domain)
+	if res := stub.invoke(cc, "voteProposal", proposalID, value, "1564740001"); res.Status != shim.OK {
+		t.Fatalf("voteProposal of %s failed: %s", domain, res.Message)
+	}
+}
+
+func searchProposal(t *testing.T, cc *SmartContract, stub *testStub, proposalID string) Proposal {
+	res := stub.invoke(cc, "searchProposal", proposalID)
+	if res.Status != shim.OK {
+		t.Fatalf("searchProposal failed: %s", res.Message)
+	}
+	var proposal Proposal
+	if err := json.Unmarshal(res.Payload, &proposal); err != nil {
+		t.Fatal(err)
+	}
+	return proposal
+}
+
+//7 founding domains vote, vil.com once for its three codes, so a majority is 4 approvals
+func TestProposalQuorumTally(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	createProposal(t, cc, stub, "airtel.com", "P1", "ADD_TSP", `{"id":"NT","name":"New Telco","domain":"newtelco.com"}`)
+
+	stub.setDomain(t, "newtelco.com")
+	if res := stub.invoke(cc, "voteProposal", "P1", "A", "1564740001"); res.Status == shim.OK {
+		t.Fatal("vote accepted from a domain which is not an operator")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "voteProposal", "P1", "A", "1564740001"); res.Status == shim.OK {
+		t.Fatal("proposer voted twice")
+	}
+	vote(t, cc, stub, "vil.com", "P1", "A")
+	vote(t, cc, stub, "bsnl.com", "P1", "A")
+	if proposal := searchProposal(t, cc, stub, "P1"); proposal.Status != _ProposalPending {
+		t.Fatalf("expected P1 pending with 3 approvals of 7, got %s", proposal.Status)
+	}
+	vote(t, cc, stub, "jio.com", "P1", "R")
+	vote(t, cc, stub, "mtnl.com", "P1", "A")
+	proposal := searchProposal(t, cc, stub, "P1")
+	if proposal.Status != _ProposalApproved || proposal.Result != "TSP registered" {
+		t.Fatalf("expected P1 approved with 4 approvals of 7, got %s %s", proposal.Status, proposal.Result)
+	}
+	stub.setDomain(t, "tata.com")
+	if res := stub.invoke(cc, "voteProposal", "P1", "A", "1564740002"); res.Status == shim.OK {
+		t.Fatal("vote accepted on a decided proposal")
+	}
+
+	res := stub.invoke(cc, "searchTSP", "NT")
+	if res.Status != shim.OK {
+		t.Fatalf("searchTSP failed: %s", res.Message)
+	}
+	var tsp TSP
+	if err := json.Unmarshal(res.Payload, &tsp); err != nil {
+		t.Fatal(err)
+	}
+	if tsp.Status != _TSPActive || tsp.Domain != "newtelco.com" || tsp.ProposalID != "P1" || tsp.Founding {
+		t.Fatalf("unexpected TSP %+v", tsp)
+	}
+	//the new operator votes, a majority of 8 is 5 approvals
+	createProposal(t, cc, stub, "newtelco.com", "P2", "SET_CATEGORIES", `{"categories":["1","2"]}`)
+	vote(t, cc, stub, "airtel.com", "P2", "A")
+	vote(t, cc, stub, "vil.com", "P2", "A")
+	vote(t, cc, stub, "bsnl.com", "P2", "A")
+	if proposal := searchProposal(t, cc, stub, "P2"); proposal.Status != _ProposalPending {
+		t.Fatalf("expected P2 pending with 4 approvals of 8, got %s", proposal.Status)
+	}
+	vote(t, cc, stub, "jio.com", "P2", "A")
+	if proposal := searchProposal(t, cc, stub, "P2"); proposal.Status != _ProposalApproved {
+		t.Fatalf("expected P2 approved with 5 approvals of 8, got %s", proposal.Status)
+	}
+}
+
+func TestProposalRejectedOnceQuorumUnreachable(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	createProposal(t, cc, stub, "airtel.com", "P1", "SET_CATEGORIES", `{"categories":["1"]}`)
+	vote(t, cc, stub, "vil.com", "P1", "R")
+	vote(t, cc, stub, "bsnl.com", "P1", "R")
+	vote(t, cc, stub, "mtnl.com", "P1", "R")
+	if proposal := searchProposal(t, cc, stub, "P1"); proposal.Status != _ProposalPending {
+		t.Fatalf("expected P1 pending with 3 rejections of 7, got %s", proposal.Status)
+	}
+	vote(t, cc, stub, "jio.com", "P1", "R")
+	if proposal := searchProposal(t, cc, stub, "P1"); proposal.Status != _ProposalRejected {
+		t.Fatalf("expected P1 rejected with 4 rejections of 7, got %s", proposal.Status)
+	}
+}
+
+func TestSetQuorumAppliesToNewProposals(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	createProposal(t, cc, stub, "airtel.com", "P1", "SET_QUORUM", `{"qnum":2,"qden":3}`)
+	createProposal(t, cc, stub, "airtel.com", "P2", "SET_CATEGORIES", `{"categories":["1"]}`)
+	for _, domain := range []string{"vil.com", "bsnl.com", "mtnl.com"} {
+		vote(t, cc, stub, domain, "P1", "A")
+	}
+	res := stub.invoke(cc, "getGovernanceConfig")
+	var config GovernanceConfig
+	if err := json.Unmarshal(res.Payload, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.QuorumNum != 2 || config.QuorumDen != 3 {
+		t.Fatalf("expected a 2/3 quorum, got %d/%d", config.QuorumNum, config.QuorumDen)
+	}
+
+	//P2 keeps the majority it was proposed with, P3 needs 5 approvals of 7
+	createProposal(t, cc, stub, "airtel.com", "P3", "SET_CATEGORIES", `{"categories":["2"]}`)
+	for _, domain := range []string{"vil.com", "bsnl.com", "mtnl.com"} {
+		vote(t, cc, stub, domain, "P2", "A")
+		vote(t, cc, stub, domain, "P3", "A")
+	}
+	if proposal := searchProposal(t, cc, stub, "P2"); proposal.Status != _ProposalApproved {
+		t.Fatalf("expected P2 approved at 1/2, got %s", proposal.Status)
+	}
+	if proposal := searchProposal(t, cc, stub, "P3"); proposal.Status != _ProposalPending || proposal.QuorumNum != 2 {
+		t.Fatalf("expected P3 pending at 2/3, got %s %d/%d", proposal.Status, proposal.QuorumNum, proposal.QuorumDen)
+	}
+	vote(t, cc, stub, "jio.com", "P3", "A")
+	if proposal := searchProposal(t, cc, stub, "P3"); proposal.Status != _ProposalApproved {
+		t.Fatalf("expected P3 approved with 5 approvals of 7, got %s", proposal.Status)
+	}
+}
+
+func TestProposalExpiry(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "createProposal", `{"id":"P0","ptyp":"SET_CATEGORIES","payload":{"categories":["1"]},"ddl":"1564740000","cts":"1564740000"}`); res.Status == shim.OK {
+		t.Fatal("proposal created with a past deadline")
+	}
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	for _, proposalID := range []string{"P1", "P2"} {
+		key, _ := stub.CreateCompositeKey(_ProposalObj, []string{proposalID})
+		stub.put(t, key, Proposal{ObjType: _ProposalObj, ProposalID: proposalID, Type: _SetCategories, Payload: json.RawMessage(`{"categories":["1"]}`),
+			Proposer: "airtel.com", Votes: map[string]string{"airtel.com": "A"}, QuorumNum: 1, QuorumDen: 2, Deadline: past, Status: _ProposalPending})
+	}
+	createProposal(t, cc, stub, "airtel.com", "P3", "SET_CATEGORIES", `{"categories":["2"]}`)
+
+	vote(t, cc, stub, "vil.com", "P1", "A")
+	if proposal := searchProposal(t, cc, stub, "P1"); proposal.Status != _ProposalExpired || proposal.Votes["vil.com"] != "" {
+		t.Fatalf("expected P1 expired without the late vote, got %s %v", proposal.Status, proposal.Votes)
+	}
+	res := stub.invoke(cc, "expireProposals", "1564740002")
+	if res.Status != shim.OK {
+		t.Fatalf("expireProposals failed: %s", res.Message)
+	}
+	var expired []string
+	if err := json.Unmarshal(res.Payload, &expired); err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0] != "P2" {
+		t.Fatalf("expected P2 expired, got %v", expired)
+	}
+	if proposal := searchProposal(t, cc, stub, "P3"); proposal.Status != _ProposalPending {
+		t.Fatalf("expected P3 still pending, got %s", proposal.Status)
+	}
+}
+
+func TestCrossChannelProposalEvent(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	createProposal(t, cc, stub, "airtel.com", "P1", "BLACKLIST_HEADERS", `{"clis":["SPAMMR"]}`)
+	for _, domain := range []string{"vil.com", "bsnl.com", "mtnl.com"} {
+		vote(t, cc, stub, domain, "P1", "A")
+	}
+	proposal := searchProposal(t, cc, stub, "P1")
+	if proposal.Status != _ProposalApproved || proposal.Result != "To be executed with bbh P1 on the header chaincodes" {
+		t.Fatalf("unexpected proposal %s %s", proposal.Status, proposal.Result)
+	}
+	//create and two votes, then the approving vote raises the event of the listener
+	for i := 0; i < 3; i++ {
+		<-stub.ChaincodeEventsChannel
+	}
+	if event := <-stub.ChaincodeEventsChannel; event.EventName != _ExecuteProposalEvent {
+		t.Fatalf("expected %s, got %s", _ExecuteProposalEvent, event.EventName)
+	}
+}
+
+//founding operators dropped from foundingTSPs are removed on upgrade and no longer vote
+func TestUpgradeRemovesFormerFoundingTSPs(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	key, err := getTSPKey(stub, "Org1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.put(t, key, TSP{ObjType: _TSPObj, TSPID: "Org1", Name: "Org1", Domain: "org1", Status: _TSPActive, Founding: true})
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "searchTSP", "Org1"); res.Status == shim.OK {
+		t.Fatal("former founding TSP kept on upgrade")
+	}
+	if res := stub.invoke(cc, "searchTSP", "JI"); res.Status != shim.OK {
+		t.Fatalf("founding TSP removed: %s", res.Message)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+var _registrarLogger = shim.NewLogger("RegistrarManager")
+
+const _CreateRegistrarEvent = "CREATE_REGISTRAR"
+const _ModifyRegistrarEvent = "MODIFY_REGISTRAR"
+
+const _RegistrarObj = "Registrar"
+
+// registrarRole - registrar functions an operator can assign
+var registrarRole = map[string]bool{
+	"HR":  true, //header registrar
+	"CTR": true, //content template registrar
+	"CR":  true, //consent registrar
+	"ER":  true, //entity registrar
+}
+
+var registrarStatus = map[string]bool{
+	"A": true,
+	"I": true,
+}
+
+// Registrar structure defines the ledger record of a registrar function of an operator
+type Registrar struct {
+	ObjType     string   `json:"obj"`   //DocType  -- Registrar
+	RegistrarID string   `json:"id"`    //Key field - autogenerated in backend
+	TSPID       string   `json:"tsp"`   //operator the registrar belongs to -- search key
+	Name        string   `json:"name"`  //name of the registrar
+	EmailID     string   `json:"email"` //contact of the registrar
+	Roles       []string `json:"roles"` //registrar functions : HR, CTR, CR, ER
+	Status      string   `json:"sts"`   //A active, I inactive
+	Creator     string   `json:"crtr"`  //CreatedBy
+	CreateTs    string   `json:"cts"`   //CreatedTs
+	UpdateTs    string   `json:"uts"`   //UpdatedTs
+	UpdatedBy   string   `json:"uby"`   //UpdatedBy
+}
+
+// RegistrarManager manages the registrars of the operators
+type RegistrarManager struct {
+}
+
+func getRegistrarKey(stub shim.ChaincodeStubInterface, registrarID string) (string, error) {
+	return stub.CreateCompositeKey(_RegistrarObj, []string{registrarID})
+}
+
+// isValidRoles checks the roles are known and not repeated
+func isValidRoles(roles []string) (bool, string) {
+	if len(roles) == 0 {
+		return false, "Atleast one role is mandatory"
+	}
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if !validEnumEntry(role, registrarRole) {
+			return false, "Role: Enter HR, CTR, CR or ER"
+		}
+		if seen[role] {
+			return false, "Role " + role + " is repeated"
+		}
+		seen[role] = true
+	}
+	return true, ""
+}
+
+func (rm *RegistrarManager) getRegistrar(stub shim.ChaincodeStubInterface, registrarID string) (*Registrar, error) {
+	key, err := getRegistrarKey(stub, registrarID)
+	if err != nil {
+		return nil, err
+	}
+	registrarBytes, err := stub.GetState(key)
+	if err != nil || registrarBytes == nil {
+		return nil, err
+	}
+	var registrar Registrar
+	if err := json.Unmarshal(registrarBytes, &registrar); err != nil {
+		return nil, err
+	}
+	return &registrar, nil
+}
+
+// authorizeForTSP checks the invoker is the active operator the registrar belongs to
+func (rm *RegistrarManager) authorizeForTSP(stub shim.ChaincodeStubInterface, tspID string) (bool, string) {
+	authorize, invoker := tspMgr.getInvokerIdentity(stub)
+	if authorize == false {
+		return false, "Unauthorized access"
+	}
+	tsp, err := tspMgr.getTSP(stub, tspID)
+	if err != nil {
+		return false, err.Error()
+	}
+	if tsp == nil || tsp.Status != _TSPActive {
+		return false, "TSP " + tspID + " is not an active operator"
+	}
+	if tsp.Domain != invoker {
+		return false, "Sevice Provider and domain operator do not match"
+	}
+	return true, invoker
+}
+
+func (rm *RegistrarManager) saveRegistrarWithEvent(stub shim.ChaincodeStubInterface, registrar Registrar, event string) peer.Response {
+	key, err := getRegistrarKey(stub, registrar.RegistrarID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	registrarJSON, _ := json.Marshal(registrar)
+	if err := stub.PutState(key, registrarJSON); err != nil {
+		return shim.Error("Unable to save with registrar id " + registrar.RegistrarID)
+	}
+	if err := stub.SetEvent(event, registrarJSON); err != nil {
+		_registrarLogger.Errorf("Event not generated for event : " + event)
+		return shim.Error("{\"error\":\"Unable to generate " + event + " Event.\"}")
+	}
+	resultData := map[string]interface{}{
+		"trxnID":      stub.GetTxID(),
+		"registrarID": registrar.RegistrarID,
+		"message":     "Save successful",
+		"registrar":   registrar,
+		"status":      "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// CreateRegistrar creates a registrar of the invoking operator
+func (rm *RegistrarManager) CreateRegistrar(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	var registrar Registrar
+	err := json.Unmarshal([]byte(args[0]), &registrar)
+	if err != nil {
+		return shim.Error("Invalid json provided as input")
+	}
+
+	authorize, creator := rm.authorizeForTSP(stub, registrar.TSPID)
+	if authorize == false {
+		return shim.Error(creator)
+	}
+
+	if len(registrar.RegistrarID) == 0 || len(registrar.Name) == 0 {
+		return shim.Error("id and name are mandatory")
+	}
+	if len(registrar.CreateTs) == 0 {
+		return shim.Error("CreateTS is mandatory")
+	}
+	if isValid, errMsg := isValidRoles(registrar.Roles); !isValid {
+		return shim.Error(errMsg)
+	}
+	existing, err := rm.getRegistrar(stub, registrar.RegistrarID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return shim.Error("Registrar id already registered. Provide an unique registrar id")
+	}
+
+	registrar.ObjType = _RegistrarObj
+	registrar.Status = "A"
+	registrar.Creator = creator
+	registrar.UpdateTs = registrar.CreateTs
+	registrar.UpdatedBy = creator
+	return rm.saveRegistrarWithEvent(stub, registrar, _CreateRegistrarEvent)
+}
+
+// AssignRegistrarRoles replaces the roles of a registrar, args : id, roles json array, uts
+func (rm *RegistrarManager) AssignRegistrarRoles(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 3 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+	var roles []string
+	if err := json.Unmarshal([]byte(args[1]), &roles); err != nil {
+		return shim.Error("Invalid json provided as input")
+	}
+	if isValid, errMsg := isValidRoles(roles); !isValid {
+		return shim.Error(errMsg)
+	}
+	return rm.modifyRegistrar(stub, args[0], args[2], func(registrar *Registrar) {
+		registrar.Roles = roles
+	})
+}
+
+// UpdateRegistrarStatus activates (A) or deactivates (I) a registrar, args : id, sts, uts
+func (rm *RegistrarManager) UpdateRegistrarStatus(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 3 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+	newStatus := args[1]
+	if !validEnumEntry(newStatus, registrarStatus) {
+		return shim.Error("Status: Enter either A, I")
+	}
+	return rm.modifyRegistrar(stub, args[0], args[2], func(registrar *Registrar) {
+		registrar.Status = newStatus
+	})
+}
+
+func (rm *RegistrarManager) modifyRegistrar(stub shim.ChaincodeStubInterface, registrarID, newUpdatedTS string, modify func(*Registrar)) peer.Response {
+	if newUpdatedTS == "" {
+		return shim.Error("Update timeStamp should be present there")
+	}
+	registrar, err := rm.getRegistrar(stub, registrarID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if registrar == nil {
+		return shim.Error("{\"error\":\"The registrar id doesn't exists\"}")
+	}
+	authorize, updatedBy := rm.authorizeForTSP(stub, registrar.TSPID)
+	if authorize == false {
+		return shim.Error(updatedBy)
+	}
+	modify(registrar)
+	registrar.UpdateTs = newUpdatedTS
+	registrar.UpdatedBy = updatedBy
+	return rm.saveRegistrarWithEvent(stub, *registrar, _ModifyRegistrarEvent)
+}
+
+// SearchRegistrar returns the registrar of the given id
+func (rm *RegistrarManager) SearchRegistrar(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	registrar, err := rm.getRegistrar(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if registrar == nil {
+		return shim.Error("{\"error\":\"The registrar id doesn't exists\"}")
+	}
+	registrarJSON, _ := json.Marshal(registrar)
+	return shim.Success(registrarJSON)
+}
+
+// SearchRegistrarsByTSP returns the registrars of an operator, args : tsp, role (optional)
+func (rm *RegistrarManager) SearchRegistrarsByTSP(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	registrarSearchCriteria := `{
+		"obj":"Registrar"	,
+		"tsp":"%s"
+	}`
+	criteria := fmt.Sprintf(registrarSearchCriteria, args[0])
+	if len(args) > 1 && args[1] != "" {
+		registrarSearchCriteria = `{
+			"obj":"Registrar"	,
+			"tsp":"%s",
+			"roles":{"$elemMatch":{"$eq":"%s"}}
+		}`
+		criteria = fmt.Sprintf(registrarSearchCriteria, args[0], args[1])
+	}
+	finalSelector := fmt.Sprintf("{\"selector\":%s , \"use_index\" : \"%s\" }", criteria, "registrarSearchByTSP")
+	resultsIterator, err := stub.GetQueryResult(finalSelector)
+	if err != nil {
+		return shim.Error("queryRegistrar:GetQueryResult is Failed")
+	}
+	defer resultsIterator.Close()
+	records := make([]Registrar, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var registrar Registrar
+		if err := json.Unmarshal(recordBytes.Value, &registrar); err != nil {
+			_registrarLogger.Infof("Unable to unmarshal Registrar retrived:: %v", err)
+			continue
+		}
+		records = append(records, registrar)
+	}
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return shim.Error("Error marshalling Query response")
+	}
+	return shim.Success(recordsJSON)
+}
+
+// CheckRegistrarRole tells if an active registrar of an active operator holds the role, args : id, role
+func (rm *RegistrarManager) CheckRegistrarRole(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 2 {
+		return shim.Error("Invalid No of arguments provided")
+	}
+	registrarID := args[0]
+	role := args[1]
+	valid, reason := false, ""
+	registrar, err := rm.getRegistrar(stub, registrarID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if registrar == nil {
+		reason = "The registrar id doesn't exists"
+	} else if registrar.Status != "A" {
+		reason = "The registrar is not active"
+	} else if tsp, err := tspMgr.getTSP(stub, registrar.TSPID); err != nil || tsp == nil || tsp.Status != _TSPActive {
+		reason = "The TSP of the registrar is not active"
+	} else {
+		reason = "The registrar does not hold the role " + role
+		for _, r := range registrar.Roles {
+			if r == role {
+				valid, reason = true, ""
+			}
+		}
+	}
+	resultData := map[string]interface{}{
+		"registrarID": registrarID,
+		"role":        role,
+		"valid":       valid,
+		"reason":      reason,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
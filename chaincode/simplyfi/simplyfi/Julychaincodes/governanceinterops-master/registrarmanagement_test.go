@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func newGovernanceStub(t *testing.T) (*SmartContract, *testStub) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "governance", cc, "airtel.com")
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	return cc, stub
+}
+
+func checkRole(t *testing.T, cc *SmartContract, stub *testStub, registrarID, role string) (bool, string) {
+	res := stub.invoke(cc, "checkRegistrarRole", registrarID, role)
+	if res.Status != shim.OK {
+		t.Fatalf("checkRegistrarRole failed: %s", res.Message)
+	}
+	var result struct {
+		Valid  bool   `json:"valid"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Valid, result.Reason
+}
+
+func TestRegistrarOfOwningTSP(t *testing.T) {
+	cc, stub := newGovernanceStub(t)
+	registrar := `{"id":"R1","tsp":"AI","name":"Airtel HR","roles":["HR","CTR"],"cts":"1564740000"}`
+
+	stub.setDomain(t, "unknown.com")
+	if res := stub.invoke(cc, "createRegistrar", registrar); res.Status == shim.OK {
+		t.Fatal("registrar created by a domain which is not an operator")
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "createRegistrar", registrar); res.Status == shim.OK {
+		t.Fatal("registrar created by another operator")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "createRegistrar", `{"id":"R1","tsp":"AI","name":"Airtel HR","roles":["HR","HR"],"cts":"1564740000"}`); res.Status == shim.OK {
+		t.Fatal("registrar created with a repeated role")
+	}
+	if res := stub.invoke(cc, "createRegistrar", registrar); res.Status != shim.OK {
+		t.Fatalf("createRegistrar failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "createRegistrar", registrar); res.Status == shim.OK {
+		t.Fatal("registrar id registered twice")
+	}
+	if valid, reason := checkRole(t, cc, stub, "R1", "CTR"); !valid {
+		t.Fatalf("expected the CTR role, got %s", reason)
+	}
+	if valid, _ := checkRole(t, cc, stub, "R1", "ER"); valid {
+		t.Fatal("role not assigned reported as held")
+	}
+
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "assignRegistrarRoles", "R1", `["ER"]`, "1564740001"); res.Status == shim.OK {
+		t.Fatal("roles assigned by another operator")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "assignRegistrarRoles", "R1", `["ER"]`, "1564740001"); res.Status != shim.OK {
+		t.Fatalf("assignRegistrarRoles failed: %s", res.Message)
+	}
+	if valid, _ := checkRole(t, cc, stub, "R1", "HR"); valid {
+		t.Fatal("replaced role still reported as held")
+	}
+	if valid, reason := checkRole(t, cc, stub, "R1", "ER"); !valid {
+		t.Fatalf("expected the ER role, got %s", reason)
+	}
+
+	if res := stub.invoke(cc, "updateRegistrarStatus", "R1", "I", "1564740002"); res.Status != shim.OK {
+		t.Fatalf("updateRegistrarStatus failed: %s", res.Message)
+	}
+	if valid, reason := checkRole(t, cc, stub, "R1", "ER"); valid || reason != "The registrar is not active" {
+		t.Fatalf("expected an inactive registrar, got %v %s", valid, reason)
+	}
+	var registrar1 Registrar
+	key, _ := stub.CreateCompositeKey(_RegistrarObj, []string{"R1"})
+	stub.get(t, key, &registrar1)
+	if registrar1.Creator != "airtel.com" || registrar1.UpdatedBy != "airtel.com" || registrar1.UpdateTs != "1564740002" {
+		t.Fatalf("unexpected registrar %+v", registrar1)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	id "github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+var _tspLogger = shim.NewLogger("TSPManager")
+
+const _TSPObj = "TSP"
+
+// TSP status
+const (
+	_TSPActive = "A"
+)
+
+// TSP structure defines the ledger record of an operator of the network
+type TSP struct {
+	ObjType    string `json:"obj"`    //DocType  -- TSP
+	TSPID      string `json:"id"`     //service provider code as in svcprv, e.g. AI -- Key field
+	Name       string `json:"name"`   //name of the operator
+	Domain     string `json:"domain"` //organization of the certificates issued to the operator
+	Status     string `json:"sts"`    //A active
+	Founding   bool   `json:"fnd"`    //registered at instantiation, not voted in
+	ProposalID string `json:"prop"`   //ADD_TSP proposal the operator was voted in by
+	Creator    string `json:"crtr"`   //CreatedBy
+	CreateTs   string `json:"cts"`    //CreatedTs
+	UpdateTs   string `json:"uts"`    //UpdatedTs
+	UpdatedBy  string `json:"uby"`    //UpdatedBy
+}
+
+// TSPManager manages the operators of the network
+type TSPManager struct {
+}
+
+// foundingTSPs - operators registered when the chaincode is instantiated, svcprv -> domain
+var foundingTSPs = map[string]string{
+	"AI": "airtel.com",
+	"VI": "vil.com",
+	"BL": "bsnl.com",
+	"ML": "mtnl.com",
+	"QL": "qtl.infotelconnect.com",
+	"TA": "tata.com",
+	"JI": "jio.com",
+	"VO": "vil.com",
+	"ID": "vil.com",
+}
+
+func validEnumEntry(input string, enumMap map[string]bool) bool {
+	if _, isEntryExists := enumMap[input]; !isEntryExists {
+		return false
+	}
+	return true
+}
+
+func getTSPKey(stub shim.ChaincodeStubInterface, tspID string) (string, error) {
+	return stub.CreateCompositeKey(_TSPObj, []string{tspID})
+}
+
+// registerFoundingTSPs saves the founding operators which are not on the ledger yet, and removes the
+// founding operators no longer in foundingTSPs
+func (tm *TSPManager) registerFoundingTSPs(stub shim.ChaincodeStubInterface) error {
+	tsps, err := tm.getAllTSPs(stub)
+	if err != nil {
+		return err
+	}
+	for _, tsp := range tsps {
+		if _, ok := foundingTSPs[tsp.TSPID]; ok || !tsp.Founding {
+			continue
+		}
+		key, err := getTSPKey(stub, tsp.TSPID)
+		if err != nil {
+			return err
+		}
+		if err := stub.DelState(key); err != nil {
+			return err
+		}
+		_tspLogger.Infof("Founding TSP %s removed", tsp.TSPID)
+	}
+	ids := make([]string, 0, len(foundingTSPs))
+	for tspID := range foundingTSPs {
+		ids = append(ids, tspID)
+	}
+	sort.Strings(ids)
+	for _, tspID := range ids {
+		existing, err := tm.getTSP(stub, tspID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		tsp := TSP{ObjType: _TSPObj, TSPID: tspID, Name: tspID, Domain: foundingTSPs[tspID], Status: _TSPActive, Founding: true, Creator: "system", UpdatedBy: "system"}
+		if err := tm.putTSP(stub, tsp); err != nil {
+			return err
+		}
+		_tspLogger.Infof("Founding TSP %s registered", tspID)
+	}
+	return nil
+}
+
+func (tm *TSPManager) getTSP(stub shim.ChaincodeStubInterface, tspID string) (*TSP, error) {
+	key, err := getTSPKey(stub, tspID)
+	if err != nil {
+		return nil, err
+	}
+	tspBytes, err := stub.GetState(key)
+	if err != nil || tspBytes == nil {
+		return nil, err
+	}
+	var tsp TSP
+	if err := json.Unmarshal(tspBytes, &tsp); err != nil {
+		return nil, err
+	}
+	return &tsp, nil
+}
+
+func (tm *TSPManager) putTSP(stub shim.ChaincodeStubInterface, tsp TSP) error {
+	key, err := getTSPKey(stub, tsp.TSPID)
+	if err != nil {
+		return err
+	}
+	tspJSON, err := json.Marshal(tsp)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, tspJSON)
+}
+
+// getAllTSPs returns the operators ordered by id
+func (tm *TSPManager) getAllTSPs(stub shim.ChaincodeStubInterface) ([]TSP, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_TSPObj, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	tsps := make([]TSP, 0)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var tsp TSP
+		if err := json.Unmarshal(response.Value, &tsp); err != nil {
+			return nil, err
+		}
+		tsps = append(tsps, tsp)
+	}
+	return tsps, nil
+}
+
+// activeDomains returns the domains of the active operators
+func activeDomains(tsps []TSP) map[string]bool {
+	domains := make(map[string]bool)
+	for _, tsp := range tsps {
+		if tsp.Status == _TSPActive {
+			domains[tsp.Domain] = true
+		}
+	}
+	return domains
+}
+
+// Returns the domain of the invoker certificate when it belongs to an active operator
+func (tm *TSPManager) getInvokerIdentity(stub shim.ChaincodeStubInterface) (bool, string) {
+	enCert, err := id.GetX509Certificate(stub)
+	if err != nil {
+		return false, "Unknown."
+	}
+
+	issuersOrgs := enCert.Issuer.Organization
+	if len(issuersOrgs) == 0 {
+		return false, "Unknown.."
+	}
+	tsps, err := tm.getAllTSPs(stub)
+	if err != nil {
+		return false, err.Error()
+	}
+	if !activeDomains(tsps)[issuersOrgs[0]] {
+		return false, "WARNING: Needs to be the domain of an active TSP"
+	}
+	return true, fmt.Sprintf("%s", issuersOrgs[0])
+}
+
+// executeAddTSP registers the operator of an approved ADD_TSP proposal as active
+func (tm *TSPManager) executeAddTSP(stub shim.ChaincodeStubInterface, proposal *Proposal) error {
+	var tsp TSP
+	if err := json.Unmarshal(proposal.Payload, &tsp); err != nil {
+		return err
+	}
+	if isValid, errMsg := tm.isValidNewTSP(stub, tsp); !isValid {
+		return errors.New(errMsg)
+	}
+	tsp.ObjType = _TSPObj
+	tsp.Status = _TSPActive
+	tsp.Founding = false
+	tsp.ProposalID = proposal.ProposalID
+	tsp.Creator = proposal.Proposer
+	tsp.CreateTs = proposal.UpdateTs
+	tsp.UpdateTs = proposal.UpdateTs
+	tsp.UpdatedBy = proposal.UpdatedBy
+	return tm.putTSP(stub, tsp)
+}
+
+// isValidNewTSP checks the payload of an ADD_TSP proposal
+func (tm *TSPManager) isValidNewTSP(stub shim.ChaincodeStubInterface, tsp TSP) (bool, string) {
+	if len(tsp.TSPID) == 0 || len(tsp.Name) == 0 || len(tsp.Domain) == 0 {
+		return false, "id, name and domain are mandatory"
+	}
+	existing, err := tm.getTSP(stub, tsp.TSPID)
+	if err != nil {
+		return false, err.Error()
+	}
+	if existing != nil {
+		return false, "TSP id already registered. Provide an unique TSP id"
+	}
+	return true, ""
+}
+
+// SearchTSP returns the operator of the given id
+func (tm *TSPManager) SearchTSP(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	tsp, err := tm.getTSP(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if tsp == nil {
+		return shim.Error("{\"error\":\"The TSP id doesn't exists\"}")
+	}
+	tspJSON, _ := json.Marshal(tsp)
+	return shim.Success(tspJSON)
+}
+
+// ListTSPs returns all the operators, or the ones with the status given as first argument
+func (tm *TSPManager) ListTSPs(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	tsps, err := tm.getAllTSPs(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	records := make([]TSP, 0)
+	for _, tsp := range tsps {
+		if len(args) > 0 && args[0] != "" && tsp.Status != args[0] {
+			continue
+		}
+		records = append(records, tsp)
+	}
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return shim.Error("Error marshalling Query response")
+	}
+	return shim.Success(recordsJSON)
+}
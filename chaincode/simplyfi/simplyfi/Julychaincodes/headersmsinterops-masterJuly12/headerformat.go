@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	sc "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Format rules are stored under the composite key {HeaderFormatRule, htyp}
+const FormatRuleObjType = "HeaderFormatRule"
+
+// FormatChannel - channel of the rules kept by this chaincode, SET_HEADER_FORMAT proposals of
+// the other channel are executed by the headervoice chaincode
+const FormatChannel = "SMS"
+
+// Character sets of a header name
+const (
+	CharsetAlpha        = "ALPHA"
+	CharsetNumeric      = "NUMERIC"
+	CharsetAlphanumeric = "ALPHANUMERIC"
+)
+
+// FormatRule is the format a header name of a header type has to follow
+type FormatRule struct {
+	ObjType   string   `json:"obj"`           // obj   : HeaderFormatRule
+	Channel   string   `json:"chnl"`          // chnl  : SMS
+	Type      string   `json:"htyp"`          // htyp  : T / SE / SI / P -- Key field
+	MinLength int      `json:"min"`           // min   : Minimum length of the cli
+	MaxLength int      `json:"max"`           // max   : Maximum length of the cli
+	Charset   string   `json:"charset"`       // charset : ALPHA / NUMERIC / ALPHANUMERIC
+	Prefixes  []string `json:"pfx,omitempty"` // pfx   : Numbering series the cli has to start with, any when empty
+	UpdatedTs string   `json:"uts"`
+	UpdatedBy string   `json:"uby"`
+}
+
+var validCharset = map[string]bool{
+	CharsetAlpha:        true,
+	CharsetNumeric:      true,
+	CharsetAlphanumeric: true,
+}
+
+// defaultFormatRules - headers of T / SE / SI are 6 letters and promotional headers 6 digits
+var defaultFormatRules = []FormatRule{
+	{Channel: FormatChannel, Type: "T", MinLength: 6, MaxLength: 6, Charset: CharsetAlpha},
+	{Channel: FormatChannel, Type: "SE", MinLength: 6, MaxLength: 6, Charset: CharsetAlpha},
+	{Channel: FormatChannel, Type: "SI", MinLength: 6, MaxLength: 6, Charset: CharsetAlpha},
+	{Channel: FormatChannel, Type: "P", MinLength: 6, MaxLength: 6, Charset: CharsetNumeric},
+}
+
+// getFormatRuleKey - composite key of the format rule of a header type
+func getFormatRuleKey(stub shim.ChaincodeStubInterface, headerType string) (string, error) {
+	return stub.CreateCompositeKey(FormatRuleObjType, []string{headerType})
+}
+
+// getFormatRule - format rule of a header type, nil when there is none
+func getFormatRule(stub shim.ChaincodeStubInterface, headerType string) (*FormatRule, error) {
+	key, err := getFormatRuleKey(stub, headerType)
+	if err != nil {
+		return nil, err
+	}
+	ruleBytes, err := stub.GetState(key)
+	if err != nil || ruleBytes == nil {
+		return nil, err
+	}
+	var rule FormatRule
+	if err := json.Unmarshal(ruleBytes, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// getFormatRules - format rules by header type
+func getFormatRules(stub shim.ChaincodeStubInterface) (map[string]FormatRule, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(FormatRuleObjType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	rules := make(map[string]FormatRule)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var rule FormatRule
+		if err := json.Unmarshal(response.Value, &rule); err != nil {
+			return nil, err
+		}
+		rules[rule.Type] = rule
+	}
+	return rules, nil
+}
+
+// putFormatRule - saves a format rule
+func putFormatRule(stub shim.ChaincodeStubInterface, rule FormatRule) ([]byte, error) {
+	key, err := getFormatRuleKey(stub, rule.Type)
+	if err != nil {
+		return nil, err
+	}
+	rule.ObjType = FormatRuleObjType
+	ruleBytes, _ := json.Marshal(rule)
+	return ruleBytes, stub.PutState(key, ruleBytes)
+}
+
+// registerDefaultFormatRules - saves the default rules which are not on the ledger yet, so that
+// rules changed with "shf" are kept on upgrade
+func registerDefaultFormatRules(stub shim.ChaincodeStubInterface) error {
+	for _, rule := range defaultFormatRules {
+		existing, err := getFormatRule(stub, rule.Type)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		if _, err := putFormatRule(stub, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isValidFormatRule - validates a format rule to be saved
+func isValidFormatRule(rule FormatRule) (bool, string) {
+	if rule.Channel != FormatChannel {
+		return false, "Channel: only " + FormatChannel + " rules are kept by this chaincode"
+	}
+	if !validHeaderEntry(rule.Type, validHeaderType) {
+		return false, "Invalid Header Type"
+	}
+	if !validHeaderEntry(rule.Charset, validCharset) {
+		return false, "charset: Enter either ALPHA, NUMERIC, ALPHANUMERIC"
+	}
+	if rule.MinLength < 1 || rule.MaxLength < rule.MinLength {
+		return false, "min should be atleast 1 and max not less than min"
+	}
+	for _, prefix := range rule.Prefixes {
+		if len(prefix) == 0 || len(prefix) > rule.MaxLength || !matchesCharset(prefix, rule.Charset) {
+			return false, "Invalid prefix : " + prefix
+		}
+	}
+	if len(rule.UpdatedTs) == 0 {
+		return false, "Updated Timestamp is mandatory"
+	}
+	return true, ""
+}
+
+// matchesCharset - every character of the value is of the character set
+func matchesCharset(value, charset string) bool {
+	for _, r := range value {
+		isAlpha := (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+		isDigit := r >= '0' && r <= '9'
+		switch charset {
+		case CharsetAlpha:
+			if !isAlpha {
+				return false
+			}
+		case CharsetNumeric:
+			if !isDigit {
+				return false
+			}
+		default:
+			if !isAlpha && !isDigit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkHeaderFormat - the header name follows the format rule of its header type
+func checkHeaderFormat(rule FormatRule, cli string) (bool, string) {
+	if len(cli) < rule.MinLength || len(cli) > rule.MaxLength {
+		if rule.MinLength == rule.MaxLength {
+			return false, "CLI should be of " + strconv.Itoa(rule.MinLength) + " characters"
+		}
+		return false, "CLI should be of " + strconv.Itoa(rule.MinLength) + " to " + strconv.Itoa(rule.MaxLength) + " characters"
+	}
+	if !matchesCharset(cli, rule.Charset) {
+		switch rule.Charset {
+		case CharsetAlpha:
+			return false, "CLI should have only alphabets"
+		case CharsetNumeric:
+			return false, "CLI is not numeric"
+		}
+		return false, "CLI should have only alphabets and digits"
+	}
+	if len(rule.Prefixes) == 0 {
+		return true, ""
+	}
+	for _, prefix := range rule.Prefixes {
+		if strings.HasPrefix(cli, prefix) {
+			return true, ""
+		}
+	}
+	return false, "CLI should be of the numbering series " + strings.Join(rule.Prefixes, ", ")
+}
+
+// ===========================================================================================
+// getApprovedFormatProposal - Reads a SET_HEADER_FORMAT proposal from the governance chaincode
+// and returns the rule of its payload when it is approved and not yet executed here
+// ===========================================================================================
+func getApprovedFormatProposal(stub shim.ChaincodeStubInterface, proposalID string) (FormatRule, error) {
+	var rule FormatRule
+	proposalKey, err := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+	if err != nil {
+		return rule, err
+	}
+	executedTx, err := stub.GetState(proposalKey)
+	if err != nil {
+		return rule, err
+	} else if executedTx != nil {
+		return rule, fmt.Errorf("Proposal %s already executed in transaction %s", proposalID, string(executedTx))
+	}
+
+	ccArgs := [][]byte{[]byte("searchProposal"), []byte(proposalID)}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	if response.Status != shim.OK {
+		return rule, fmt.Errorf("Unable to get proposal %s : %s", proposalID, response.Message)
+	}
+	proposal := struct {
+		Type    string          `json:"ptyp"`
+		Status  string          `json:"sts"`
+		Payload json.RawMessage `json:"payload"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &proposal); err != nil {
+		return rule, fmt.Errorf("Unable to read proposal %s", proposalID)
+	}
+	if proposal.Type != "SET_HEADER_FORMAT" {
+		return rule, fmt.Errorf("Proposal %s is not a SET_HEADER_FORMAT proposal", proposalID)
+	}
+	if proposal.Status != "A" {
+		return rule, fmt.Errorf("Proposal %s is not approved", proposalID)
+	}
+	if err := json.Unmarshal(proposal.Payload, &rule); err != nil {
+		return rule, fmt.Errorf("Unable to read the rule of proposal %s", proposalID)
+	}
+	return rule, nil
+}
+
+// ===========================================================================================
+// setHeaderFormat - adds or replaces the format rule of a header type. Input : id of the
+// SET_HEADER_FORMAT proposal approved on the governance chaincode, uts. The rule
+// {"chnl", "htyp", "min", "max", "charset", "pfx"} is taken from the payload of the proposal
+// ===========================================================================================
+func (t *HeaderChainCode) setHeaderFormat(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, proposal id and uts expected")
+	}
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("setHeaderFormat : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("setHeaderFormat : Getting certificate Details Error : " + string(err.Error()))
+	}
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]]; !ok {
+		return shim.Error("Unauthorized Node Access")
+	}
+
+	proposalID := args[0]
+	rule, err := getApprovedFormatProposal(stub, proposalID)
+	if err != nil {
+		logger.Errorf("setHeaderFormat : " + err.Error())
+		return shim.Error("setHeaderFormat : " + err.Error())
+	}
+	rule.UpdatedTs = args[1]
+	if isValid, errMsg := isValidFormatRule(rule); !isValid {
+		logger.Errorf("setHeaderFormat : " + errMsg)
+		return shim.Error(errMsg)
+	}
+	rule.UpdatedBy = Organizations[0]
+
+	ruleBytes, err := putFormatRule(stub, rule)
+	if err != nil {
+		logger.Errorf("setHeaderFormat : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setHeaderFormat : PutState Failed Error : " + string(err.Error()))
+	}
+	proposalKey, _ := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+	if err := stub.PutState(proposalKey, []byte(stub.GetTxID())); err != nil {
+		logger.Errorf("setHeaderFormat : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setHeaderFormat : Unable to mark the proposal executed")
+	}
+	logger.Infof("setHeaderFormat : PutState Success : " + string(ruleBytes))
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"proposalID": proposalID,
+		"rule":       rule,
+		"message":    "Header format rule saved",
+		"status":     "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// queryHeaderFormats - format rules of the header types
+// ===========================================================================================
+func (t *HeaderChainCode) queryHeaderFormats(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	rules, err := getFormatRules(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	records := make([]FormatRule, 0, len(rules))
+	for _, headerType := range []string{"T", "SE", "SI", "P"} {
+		if rule, ok := rules[headerType]; ok {
+			records = append(records, rule)
+		}
+	}
+	respJSON, _ := json.Marshal(records)
+	return shim.Success(respJSON)
+}
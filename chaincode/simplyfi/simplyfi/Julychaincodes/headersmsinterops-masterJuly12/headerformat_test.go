@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func typedHeader(cli, headerType string) string {
+	return `{"hid":"H` + cli + `","peid":"E1","htyp":"` + headerType + `","cli":"` + cli + `","ctgr":"8","cts":"1","uts":"1","sts":{"AI":"A"}}`
+}
+
+func TestDefaultHeaderFormats(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	var rules []FormatRule
+	if err := json.Unmarshal(stub.invoke(cc, "qhf").Payload, &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(defaultFormatRules) {
+		t.Fatalf("expected the %d default rules, got %+v", len(defaultFormatRules), rules)
+	}
+	tests := []struct {
+		cli        string
+		headerType string
+		valid      bool
+	}{
+		{"BLOCKC", "T", true},
+		{"BLOCKS", "SE", true},
+		{"BLOCKI", "SI", true},
+		{"BLOCKCUBE", "T", false},
+		{"BL0CKC", "T", false},
+		{"BLOCK", "SE", false},
+		{"123456", "P", true},
+		{"12345A", "P", false},
+		{"1234567", "P", false},
+	}
+	for _, test := range tests {
+		res := stub.invoke(cc, "rh", typedHeader(test.cli, test.headerType))
+		if (res.Status == shim.OK) != test.valid {
+			t.Fatalf("%s %s: expected valid %v, got %d %s", test.headerType, test.cli, test.valid, res.Status, res.Message)
+		}
+	}
+}
+
+func TestBulkHeaderFormats(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	res := stub.invoke(cc, "rbh", typedHeader("BLOCKC", "T"), typedHeader("BLOCKCUBE", "T"), typedHeader("ABCDEF", "P"))
+	if res.Status != shim.OK {
+		t.Fatalf("rbh failed: %s", res.Message)
+	}
+	var result struct {
+		Rejected []map[string]interface{} `json:"headerRejected"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rejected) != 2 || result.Rejected[0]["Header_Name"] != "BLOCKCUBE" || result.Rejected[1]["Value"] != "CLI is not numeric" {
+		t.Fatalf("expected BLOCKCUBE and ABCDEF rejected, got %+v", result.Rejected)
+	}
+	if header := getTestHeader(t, stub, "BLOCKC"); header == nil {
+		t.Fatal("valid header of the batch not registered")
+	}
+}
+
+func TestSetHeaderFormat(t *testing.T) {
+	governance := &fakeGovernance{proposals: map[string]string{
+		"P1": `{"id":"P1","ptyp":"SET_HEADER_FORMAT","sts":"A","payload":{"chnl":"SMS","htyp":"T","min":6,"max":9,"charset":"ALPHANUMERIC"}}`,
+		"P2": `{"id":"P2","ptyp":"SET_HEADER_FORMAT","sts":"P","payload":{"chnl":"SMS","htyp":"SE","min":6,"max":9,"charset":"ALPHANUMERIC"}}`,
+		"P3": `{"id":"P3","ptyp":"SET_HEADER_FORMAT","sts":"A","payload":{"chnl":"VOICE","htyp":"T","min":10,"max":10,"charset":"NUMERIC"}}`,
+		"P4": `{"id":"P4","ptyp":"BLACKLIST_HEADERS","sts":"A","payload":{"clis":["BLOCKC"]}}`,
+	}}
+	cc, stub := newGovernedHeaderStub(t, governance)
+	if res := stub.invoke(cc, "rh", typedHeader("BLOCK2CUBE", "T")); res.Status == shim.OK {
+		t.Fatal("header longer than the default rule registered")
+	}
+	for _, proposalID := range []string{"P2", "P3", "P4", "P5"} {
+		if res := stub.invoke(cc, "shf", proposalID, "2"); res.Status == shim.OK {
+			t.Fatalf("rule of proposal %s saved", proposalID)
+		}
+	}
+	if res := stub.invoke(cc, "shf", "P1", "2"); res.Status != shim.OK {
+		t.Fatalf("shf failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "shf", "P1", "3"); res.Status == shim.OK {
+		t.Fatal("proposal executed twice")
+	}
+	rule, err := getFormatRule(stub, "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.MaxLength != 9 || rule.Charset != CharsetAlphanumeric || rule.UpdatedBy != "airtel.com" {
+		t.Fatalf("rule of P1 not saved: %+v", rule)
+	}
+	if res := stub.invoke(cc, "rh", typedHeader("BLOCK2CUB", "T")); res.Status != shim.OK {
+		t.Fatalf("rh failed with the new rule: %s", res.Message)
+	}
+	//the rule of the other header types is kept
+	if res := stub.invoke(cc, "rh", typedHeader("BLOCK2", "SE")); res.Status == shim.OK {
+		t.Fatal("SE header checked with the rule of T")
+	}
+	//Init of an upgrade keeps the rule changed
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	if rule, _ := getFormatRule(stub, "T"); rule.MaxLength != 9 {
+		t.Fatalf("rule reset on upgrade: %+v", rule)
+	}
+}
@@ -34,8 +34,34 @@ This Chaincode is written for Storing, Retrieving, querying the Header that are
 
 // peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["bhe","22"]}'
 
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["bbh","P0001"]}'
+
 // peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["bbh","BLOCKCUBE6","BLOCKCUBE7"]}'
 
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["sbe","22","2345678"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["rbe","22","2345679"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["qbe","22"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["mhk","500"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["qhs","BL0CKCUBE","A11111111102"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["ahr","{\"cli\":\"BL0CKCUBE\",\"sts\":\"A\",\"uts\":\"2345679\"}"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["apb","{\"brand\":\"HDFC\",\"peids\":[\"A11111111101\"],\"uts\":\"2345678\"}"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["dpb","HDFC"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["qpb"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["ihv","500",""]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["shf","P0003","2345678"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n header -C chheader  -c '{"args":["qhf"]}'
+
 
 // ====CHAINCODE EXECUTION SAMPLES (CLI) ================== END
 
@@ -61,6 +87,26 @@ var logger = shim.NewLogger("HEADERSMS-CHAINCODE-INITIALIZED")
 const EVTRegisterHeader = "EVT_RegisterHeaderSMS"
 const EVTUpdateHeaderStatus = "EVT_UpdateHeaderStatusSMS"
 const EVTBlacklistHeader = "EVT_BlacklistHeader"
+const EVTSuspendHeader = "EVT_SuspendHeaderSMS"
+const EVTRestoreHeader = "EVT_RestoreHeaderSMS"
+
+// Headers are stored under the composite key {HeaderSMS, cli}
+const HeaderObjType = "HeaderSMS"
+
+// Default number of headers moved to composite keys by one "mhk" invocation
+const MigrationBatchSize = 500
+
+// Network-wide blacklisting ("bbh") is executed only for a BLACKLIST_HEADERS proposal
+// approved on the governance chaincode, and only once per proposal
+const GovernanceChaincode = "governance"
+const GovernanceChannel = "entitychannel"
+const GovernanceProposalObjType = "GovernanceProposal"
+
+// "sbe" and "rbe" are accepted only while the blacklist cascade of the entity chaincode
+// has the step of this chaincode (CascadeTargetName) pending
+const EntityChaincode = "entity"
+const EntityChannel = "entitychannel"
+const CascadeTargetName = "HEADERSMS"
 
 
 // Smart contract structure
@@ -83,6 +129,17 @@ type Header struct {
 	UpdatedBy         string `json:"uby"`	// uby     : DLT Node's name
 	TMID 			  string `json:"tmid"`  // tmid    : Details of the RTM who added this header on behalf of Entity
 	Blacklisted       bool `json:"blklst"`   // blklst  : Header is blacklisted (or not) across TSP
+	Review            *HeaderReview `json:"rvw,omitempty"` // rvw : Similarity review of a header looking alike another header or a protected brand
+}
+
+//EntitySuspension keeps the headers blacklisted due to blacklisting of the entity,
+//so that only those are restored when the entity is un-blacklisted
+type EntitySuspension struct {
+	ObjType           string   `json:"obj"`   // obj     : EntitySuspension
+	PrincipleEntityId string   `json:"peid"`  // peid    : Entity which is blacklisted
+	Headers           []string `json:"items"` // items   : CLI of the headers suspended
+	UpdatedTs         string   `json:"uts"`   // uts     : Suspension time
+	UpdatedBy         string   `json:"uby"`   // uby     : DLT Node's name
 }
 
 // Header Type 
@@ -93,7 +150,7 @@ var validHeaderType = map[string]bool{
 	"P": true,
 }
 
-// Valid Category type
+// Valid Category type, used when the categories of the governance chaincode can not be read
 var validCategory = map[string]bool{
 	"0": true,
 	"1": true,
@@ -145,7 +202,7 @@ func validHeaderEntry(input string, enumMap map[string]bool) bool {
         return true
 }
 
-func isValidHeader(header Header,dltnode string) (bool, string) {
+func isValidHeader(header Header,dltnode string,categories map[string]bool,rules map[string]FormatRule) (bool, string) {
 	
 	if len(header.Header_ID) == 0 {
 		return false, "Header_ID is mandatory"
@@ -171,7 +228,12 @@ func isValidHeader(header Header,dltnode string) (bool, string) {
 		return false, "Header_Type is mandatory"
 	} 
 
-	if header.Header_Type == "P" {
+	// header names follow the format rule of their header type, changed with "shf"
+	if rule, ok := rules[header.Header_Type]; ok {
+		if isValid, errMsg := checkHeaderFormat(rule, header.Header_Name); !isValid {
+			return false, errMsg
+		}
+	} else if header.Header_Type == "P" {
 		if _,err:=strconv.Atoi(header.Header_Name); err!=nil{
     		return false, "CLI is not numeric"
 		}
@@ -195,7 +257,7 @@ func isValidHeader(header Header,dltnode string) (bool, string) {
         return false, "Invalid Header Type"
     }
 
-	if !validHeaderEntry(header.Category,validCategory){
+	if !validHeaderEntry(header.Category,categories){
     return false, "Invalid Category Provided" 
     } 
    
@@ -233,6 +295,10 @@ func main() {
 // ===================================================================================
 func (t *HeaderChainCode) Init(stub shim.ChaincodeStubInterface) sc.Response {
 	logger.Info("|| HEADER CHAINCODE IS INITIALIZED ||")
+	if err := registerDefaultFormatRules(stub); err != nil {
+		logger.Errorf("Init : Unable to save the default format rules : " + err.Error())
+		return shim.Error(err.Error())
+	}
 	return shim.Success(nil)
 }
 
@@ -263,10 +329,34 @@ func (t *HeaderChainCode) Invoke(stub shim.ChaincodeStubInterface) sc.Response {
 		case "bhe":
 			return t.blacklistHeaderByEntity(stub,args)       // Set status Blacklisted to "true" for headers  against Entity 
 		case "bbh":
-			return t.blacklistBulkHeaders(stub,args)          // Set all headers to blacklisted when cli array is passed
+			return t.blacklistBulkHeaders(stub,args)          // Blacklist the headers of an approved BLACKLIST_HEADERS governance proposal
+		case "sbe":
+			return t.suspendHeadersByEntity(stub,args)        // Blacklist headers of a blacklisted entity, remembering the ones changed
+		case "rbe":
+			return t.restoreHeadersByEntity(stub,args)        // Restore the headers suspended by "sbe"
+		case "qbe":
+			return t.queryEntitySuspension(stub,args)         // Query the headers kept suspended by "sbe" for an entity
+		case "mhk":
+			return t.migrateHeaderKeys(stub,args)             // Move headers stored against the raw CLI to composite keys
+		case "qhs":
+			return t.querySimilarHeaders(stub,args)           // Headers and protected brands looking alike a header name
+		case "ahr":
+			return t.reviewHeader(stub,args)                  // Approve / reject a header flagged as similar at registration
+		case "apb":
+			return t.addProtectedBrand(stub,args)             // Add a protected brand with its owning entities
+		case "dpb":
+			return t.deleteProtectedBrand(stub,args)          // Remove a protected brand
+		case "qpb":
+			return t.queryProtectedBrands(stub,args)          // List protected brands
+		case "ihv":
+			return t.indexExistingHeaders(stub,args)          // Index the headers registered before the similarity check
+		case "shf":
+			return t.setHeaderFormat(stub,args)               // Add / replace the format rule of an approved SET_HEADER_FORMAT governance proposal
+		case "qhf":
+			return t.queryHeaderFormats(stub,args)            // Query the format rules of the header types
 		default:
-			logger.Errorf("Received Unknown Function invocation : Available Function : rh , rbh , uhs, qh, hfh, qhwp, bhe, bbh")
-			return shim.Error("Received Unknown Function invocation : Available function : rh , rbh , uhs, qh, hfh, qhwp, bhe, bbh")
+			logger.Errorf("Received Unknown Function invocation : Available Function : rh , rbh , uhs, qh, hfh, qhwp, bhe, bbh, sbe, rbe, qbe, mhk, qhs, ahr, apb, dpb, qpb, ihv, shf, qhf")
+			return shim.Error("Received Unknown Function invocation : Available function : rh , rbh , uhs, qh, hfh, qhwp, bhe, bbh, sbe, rbe, qbe, mhk, qhs, ahr, apb, dpb, qpb, ihv, shf, qhf")
 		}
 }
 
@@ -300,7 +390,12 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
 		return shim.Error("setHeader : Input arguments unmarhsaling Error : " + string(err.Error()))
 	}
 
-	if isValid,errMsg:=isValidHeader(data,dltNode);!isValid{
+	rules, err := getFormatRules(stub)
+	if err != nil {
+		logger.Errorf("setHeader : Unable to read the format rules : " + err.Error())
+		return shim.Error("setHeader : Unable to read the format rules : " + err.Error())
+	}
+	if isValid,errMsg:=isValidHeader(data,dltNode,getAllowedCategories(stub),rules);!isValid{
 			logger.Errorf("setHeader:"+string(errMsg))
 			return shim.Error(errMsg)
 	}
@@ -316,9 +411,14 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
         return shim.Error("Header_ID already exist for : " + data.Header_Name + ", Please provide unique hid ")
 	}
 
-	if recordBytes, _ := stub.GetState(data.Header_Name); len(recordBytes) > 0 {
+	if recordBytes, _ := getHeaderState(stub, data.Header_Name); len(recordBytes) > 0 {
 		return shim.Error("Header already registered. Provide an unique header name")
 	}
+
+	if isValid,errMsg:=applySimilarity(stub, &data, nil);!isValid{
+			logger.Errorf("setHeader:"+string(errMsg))
+			return shim.Error(errMsg)
+	}
 	
 		// data.ObjType = "HeaderSMS"
 		// var m = make(map[string]string)
@@ -334,11 +434,15 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
 			return shim.Error("setHeader : Marshalling Error : " + string(err.Error()))
 		}
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(data.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("setHeader : PutState Failed Error : " + string(err.Error()))
 			return shim.Error("setHeader : PutState Failed Error : " + string(err.Error()))
 		}
+		if err := indexHeaderVariants(stub, data.Header_Name); err != nil {
+			logger.Errorf("setHeader : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("setHeader : PutState Failed Error : " + string(err.Error()))
+		}
 		logger.Infof("setHeader : PutState Success : " + string(headerAsBytes))
 
 		err2 := stub.SetEvent(EVTRegisterHeader, headerAsBytes)
@@ -387,6 +491,14 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 	}
 
 	recordcount = 0
+	categories := getAllowedCategories(stub)
+	rules, err := getFormatRules(stub)
+	if err != nil {
+		logger.Errorf("registerBulkHeader : Unable to read the format rules : " + err.Error())
+		return shim.Error("registerBulkHeader : Unable to read the format rules : " + err.Error())
+	}
+	// headers registered by this transaction are not read back by the similarity check of the next ones
+	registered := make([]Header, 0)
 	for i := 0; i < len(args); i++ {
 		var data Header
 		logger.Infof(args[i])
@@ -396,7 +508,7 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 			continue
 		}
 
-		if isValid,errMsg:=isValidHeader(data,dltNode);!isValid{
+		if isValid,errMsg:=isValidHeader(data,dltNode,categories,rules);!isValid{
 			logger.Errorf("registerBulkHeader:"+string(errMsg))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name, "Value": string(errMsg) })	
 			continue
@@ -414,12 +526,18 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 			continue
 		}
 			
-		if recordBytes, _ := stub.GetState(data.Header_Name); len(recordBytes) > 0 {
+		if recordBytes, _ := getHeaderState(stub, data.Header_Name); len(recordBytes) > 0 {
 			logger.Errorf("Header already registered. Provide an unique header name")
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "Header already registered"})	
 			continue
 		}
 
+		if isValid,errMsg:=applySimilarity(stub, &data, registered);!isValid{
+			logger.Errorf("registerBulkHeader:"+string(errMsg))
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name, "Value": string(errMsg) })	
+			continue
+		}
+
 		recordcount = recordcount + 1
 		data.ObjType = "HeaderSMS"
 		// var m = make(map[string]string)
@@ -439,12 +557,17 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 		}
 
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(data.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "PutState Failed Error" })	
 			continue
 		}
+		if err := indexHeaderVariants(stub, data.Header_Name); err != nil {
+			logger.Errorf("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
+		}
+		registered = append(registered, data)
 
 		err2 := stub.SetEvent(EVTRegisterHeader, headerAsBytes)
 		if err2 != nil {
@@ -501,7 +624,7 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 
 	if len(data) == 3 {
 
-		RecordAsBytes, err1 := stub.GetState(data["cli"].(string))
+		RecordAsBytes, err1 := getHeaderState(stub, data["cli"].(string))
 		if err1 != nil {
 			logger.Infof(" Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
 			return shim.Error(" Failed to get Header Record " + data["cli"].(string) + " Error : " + string(err.Error()))
@@ -517,6 +640,11 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 			return shim.Error("updateHeaderStatus : Existing header data Unmarhsaling Error : " + string(err.Error()))
 		}
 
+		if header.Review != nil && header.Review.Status == ReviewPending {
+			logger.Errorf("Header is pending similarity review")
+			return shim.Error("Header is pending similarity review")
+		}
+
 		var existingStatus = make(map[string]string)
 		existingStatus = header.Status				
 
@@ -559,7 +687,7 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 			return shim.Error("updateHeaderStatus : Marshalling Error : " + string(err.Error()))
 		}
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(header.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, header.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("updateHeaderStatus : PutState Failed Error : " + string(err.Error()))
 			return shim.Error("updateHeaderStatus : PutState Failed Error : " + string(err.Error()))
@@ -603,7 +731,7 @@ func (t *HeaderChainCode) queryHeader(stub shim.ChaincodeStubInterface, args []s
 	}
 
 	for i:=0; i<len(args); i++ {
-		valAsBytes, err := stub.GetState(args[i]) //get the record from chaincode state
+		valAsBytes, err := getHeaderState(stub, args[i]) //get the record from chaincode state
 		if err != nil {
 			logger.Infof("Failed to get state for Header_Name " + args[i] )
 			headerNotExist = append(headerNotExist, map[string]interface{}{"Header_Name": args[i] , "Value": "Failed to get state for Header" })	
@@ -711,35 +839,41 @@ func (t *HeaderChainCode) getHistoryForHeader(stub shim.ChaincodeStubInterface,
 		return shim.Error("getHistoryForHeader : Input arguments unmarhsaling Error : " + string(err.Error()))
 	}
 
-	RecordAsBytes, err := stub.GetState(data["cli"].(string))
+	cli := data["cli"].(string)
+	headerKey := getHeaderKey(stub, cli)
+	RecordAsBytes, err := getHeaderState(stub, cli)
 	if err != nil {
-		logger.Infof("Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
-		return shim.Error("Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
+		logger.Infof("Failed to get Header Record : " + cli + " Error : " + string(err.Error()))
+		return shim.Error("Failed to get Header Record : " + cli + " Error : " + string(err.Error()))
 	} else if RecordAsBytes == nil {
-		fmt.Println("This record does not exists : " + data["cli"].(string))
-		return shim.Error("This record does not exists : " + data["cli"].(string))
+		fmt.Println("This record does not exists : " + cli)
+		return shim.Error("This record does not exists : " + cli)
 	}
 
-	historyIer, err := stub.GetHistoryForKey(data["cli"].(string))
+	// Modifications made before "mhk" moved the header are recorded against the raw CLI key
+	historicResponse := make([]map[string]interface{}, 0)
+	for _, key := range []string{cli, headerKey} {
+		historyIer, err := stub.GetHistoryForKey(key)
+		if err != nil {
+			fmt.Println(err.Error())
+			return shim.Error(err.Error())
+		}
 
-	if err != nil {
-	    fmt.Println(err.Error())
-	    return shim.Error(err.Error())
-	}
+		for historyIer.HasNext() {
+			modification, err := historyIer.Next()
+			if err != nil {
+				historyIer.Close()
+				fmt.Println(err.Error())
+				return shim.Error(err.Error())
+			}
 
-	historicResponse := make([]map[string]interface{}, 0)
-	for historyIer.HasNext() {
-	    modification, err := historyIer.Next()
-	    if err != nil {
-	        fmt.Println(err.Error())
-	        return shim.Error(err.Error())
+			value := make(map[string]interface{})
+			json.Unmarshal(modification.Value, &value)
+			historicResponse = append(historicResponse, map[string]interface{}{"txId": modification.TxId, "value": value})
+		}
+		historyIer.Close()
 	}
 
-		value := make(map[string]interface{})
-		json.Unmarshal(modification.Value, &value)
-		historicResponse = append(historicResponse, map[string]interface{}{"txId": modification.TxId, "value": value})
-	} 
-
 	respJSON, _ := json.Marshal(historicResponse)
 	return shim.Success(respJSON)
 }
@@ -853,7 +987,7 @@ func (t *HeaderChainCode) blacklistHeaderByEntity(stub shim.ChaincodeStubInterfa
 		}
 
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(hName, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, hName), headerAsBytes)
 		if err != nil {
 			logger.Errorf("blacklistHeaderByEntity : PutState Failed Error : " + string(err.Error()))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "PutState Failed Error" })	
@@ -887,7 +1021,10 @@ func (t *HeaderChainCode) blacklistHeaderByEntity(stub shim.ChaincodeStubInterfa
 
 
 // ===========================================================================================
-// blacklistBulkHeaders - Blacklist headers in bulk
+// blacklistBulkHeaders - Blacklist headers in bulk. Input : id of the BLACKLIST_HEADERS
+// proposal approved on the governance chaincode, the CLIs are taken from its payload.
+// The earlier input, a list of CLIs, is still accepted for the CLIs of approved
+// BLACKLIST_HEADERS proposals, the others are rejected
 // ===========================================================================================
 func (t *HeaderChainCode)  blacklistBulkHeaders(stub shim.ChaincodeStubInterface, args []string) sc.Response {
 
@@ -909,22 +1046,47 @@ func (t *HeaderChainCode)  blacklistBulkHeaders(stub shim.ChaincodeStubInterface
 		return shim.Error("Invalid number of arguments provided for transaction")
 	}
 
-	for i:=0; i<len(args); i++ {
-		valAsBytes, err := stub.GetState(args[i]) //get the record from chaincode state
+	proposalID := ""
+	clis := args
+	if len(args) == 1 && isGovernanceProposal(stub, args[0]) {
+		proposalID = args[0]
+		clis, err = getApprovedBlacklistProposal(stub, proposalID)
 		if err != nil {
-			logger.Infof("Failed to get state for Header_Name " + args[i] )
-			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": args[i] , "Value": "Failed to get state for Header" })	
+			logger.Errorf("blacklistBulkHeaders : " + err.Error())
+			return shim.Error("blacklistBulkHeaders : " + err.Error())
+		}
+	} else {
+		approved, err := getApprovedBlacklistCLIs(stub)
+		if err != nil {
+			logger.Errorf("blacklistBulkHeaders : " + err.Error())
+			return shim.Error("blacklistBulkHeaders : " + err.Error())
+		}
+		clis = make([]string, 0, len(args))
+		for _, cli := range args {
+			if !approved[cli] {
+				headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": cli , "Value": "Not in an approved BLACKLIST_HEADERS proposal" })
+				continue
+			}
+			clis = append(clis, cli)
+		}
+	}
+
+	for i:=0; i<len(clis); i++ {
+		valAsBytes, err := getHeaderState(stub, clis[i]) //get the record from chaincode state
+		if err != nil {
+			logger.Infof("Failed to get state for Header_Name " + clis[i] )
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": clis[i] , "Value": "Failed to get state for Header" })	
 			continue
 		} else if valAsBytes == nil {
-			logger.Infof("Record does not exist for Header_Name " + args[i] )
-			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": args[i] , "Value": "Record does not exist for Header" })	
+			logger.Infof("Record does not exist for Header_Name " + clis[i] )
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": clis[i] , "Value": "Record does not exist for Header" })	
 			continue
 		}
 
 		var data Header
 		err1 := json.Unmarshal([]byte(valAsBytes), &data)
 		if err1 != nil {
-			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": args[i] , "Value": "Input arguments unmarhsaling Error" })	
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": clis[i] , "Value": "Input arguments unmarhsaling Error" })	
 			continue
 		}
 
@@ -945,7 +1107,7 @@ func (t *HeaderChainCode)  blacklistBulkHeaders(stub shim.ChaincodeStubInterface
 		}
 
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(data.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("blacklistBulkHeaders : PutState Failed Error : " + string(err.Error()))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "PutState Failed Error" })	
@@ -961,12 +1123,22 @@ func (t *HeaderChainCode)  blacklistBulkHeaders(stub shim.ChaincodeStubInterface
 
 		recordcount = recordcount + 1
 		logger.Infof("blacklistBulkHeaders : PutState Success : " + string(headerAsBytes))
-		headerDeleted = append(headerDeleted, args[i])
+		headerDeleted = append(headerDeleted, clis[i])
+	}
+
+	if proposalID != "" {
+		proposalKey, _ := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+		err = stub.PutState(proposalKey, []byte(stub.GetTxID()))
+		if err != nil {
+			logger.Errorf("blacklistBulkHeaders : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("blacklistBulkHeaders : Unable to mark the proposal executed")
+		}
 	}
 
 		logger.Info("")
 		resultData := map[string]interface{}{
 			"trxnID":   stub.GetTxID(),
+			"proposalID": proposalID,
 			"headerRejected": headerRejected,
 			"headerBlacklisted":   headerDeleted, 
 			"message" : "All headers have been set to blacklisted",
@@ -978,6 +1150,519 @@ func (t *HeaderChainCode)  blacklistBulkHeaders(stub shim.ChaincodeStubInterface
 }
 
 
+// ===========================================================================================
+// isGovernanceProposal - true when the governance chaincode has a proposal of the given id
+// ===========================================================================================
+func isGovernanceProposal(stub shim.ChaincodeStubInterface, proposalID string) bool {
+	ccArgs := [][]byte{[]byte("searchProposal"), []byte(proposalID)}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	return response.Status == shim.OK
+}
+
+
+// ===========================================================================================
+// getApprovedBlacklistCLIs - Reads the approved proposals from the governance chaincode and
+// returns the CLIs of the BLACKLIST_HEADERS ones
+// ===========================================================================================
+func getApprovedBlacklistCLIs(stub shim.ChaincodeStubInterface) (map[string]bool, error) {
+	ccArgs := [][]byte{[]byte("listProposals"), []byte("A")}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Unable to get the approved proposals : %s", response.Message)
+	}
+	proposals := make([]struct {
+		Type    string `json:"ptyp"`
+		Payload struct {
+			CLIs []string `json:"clis"`
+		} `json:"payload"`
+	}, 0)
+	if err := json.Unmarshal(response.Payload, &proposals); err != nil {
+		return nil, fmt.Errorf("Unable to read the approved proposals")
+	}
+	clis := make(map[string]bool)
+	for _, proposal := range proposals {
+		if proposal.Type != "BLACKLIST_HEADERS" {
+			continue
+		}
+		for _, cli := range proposal.Payload.CLIs {
+			clis[cli] = true
+		}
+	}
+	return clis, nil
+}
+
+
+// ===========================================================================================
+// getAllowedCategories - Reads the categories allowed by the governance chaincode, changed
+// there with SET_CATEGORIES proposals. validCategory is used when they can not be read
+// ===========================================================================================
+func getAllowedCategories(stub shim.ChaincodeStubInterface) map[string]bool {
+	ccArgs := [][]byte{[]byte("getGovernanceConfig")}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	if response.Status != shim.OK {
+		logger.Warningf("getAllowedCategories : governance config not read, using the default categories : " + response.Message)
+		return validCategory
+	}
+	config := struct {
+		Categories []string `json:"categories"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &config); err != nil || len(config.Categories) == 0 {
+		logger.Warningf("getAllowedCategories : governance config has no categories, using the default categories")
+		return validCategory
+	}
+	categories := make(map[string]bool)
+	for _, category := range config.Categories {
+		categories[category] = true
+	}
+	return categories
+}
+
+
+// ===========================================================================================
+// getApprovedBlacklistProposal - Reads the proposal from the governance chaincode and returns
+// the CLIs to be blacklisted when it is an approved BLACKLIST_HEADERS proposal not executed yet
+// ===========================================================================================
+func getApprovedBlacklistProposal(stub shim.ChaincodeStubInterface, proposalID string) ([]string, error) {
+	proposalKey, err := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+	if err != nil {
+		return nil, err
+	}
+	executedTx, err := stub.GetState(proposalKey)
+	if err != nil {
+		return nil, err
+	} else if executedTx != nil {
+		return nil, fmt.Errorf("Proposal %s already executed in transaction %s", proposalID, string(executedTx))
+	}
+
+	ccArgs := [][]byte{[]byte("searchProposal"), []byte(proposalID)}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("Unable to get proposal %s : %s", proposalID, response.Message)
+	}
+	proposal := struct {
+		Type    string `json:"ptyp"`
+		Status  string `json:"sts"`
+		Payload struct {
+			CLIs []string `json:"clis"`
+		} `json:"payload"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &proposal); err != nil {
+		return nil, fmt.Errorf("Unable to read proposal %s", proposalID)
+	}
+	if proposal.Type != "BLACKLIST_HEADERS" {
+		return nil, fmt.Errorf("Proposal %s is not a BLACKLIST_HEADERS proposal", proposalID)
+	}
+	if proposal.Status != "A" {
+		return nil, fmt.Errorf("Proposal %s is not approved", proposalID)
+	}
+	return proposal.Payload.CLIs, nil
+}
+
+
+// ===========================================================================================
+// suspendHeadersByEntity - Called on blacklisting of an entity. Blacklists all the headers of
+// the entity which are not already blacklisted and keeps their CLI against the entity, so that
+// "rbe" restores exactly these headers. Input : peid, uts
+// ===========================================================================================
+func (t *HeaderChainCode) suspendHeadersByEntity(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid and uts expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "SUSPEND"); err != nil {
+		logger.Errorf("suspendHeadersByEntity : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Composite key Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Composite key Error : " + string(err.Error()))
+	}
+	if recordBytes, _ := stub.GetState(suspensionKey); len(recordBytes) > 0 {
+		logger.Errorf("suspendHeadersByEntity : Headers are already suspended for PEID : " + peid)
+		return shim.Error("suspendHeadersByEntity : Headers are already suspended for PEID : " + peid)
+	}
+
+	headerSearch := `{
+		"obj":"HeaderSMS",
+		"peid":"%s"
+	}`
+	headerData := t.retriveHeaderRecords(stub, fmt.Sprintf(headerSearch, peid), "headerSearchByPeid")
+
+	headerSuspended := make([]string, 0)
+	for i:=0; i<len(headerData); i++ {
+		if headerData[i].Blacklisted == true {
+			continue
+		}
+		headerData[i].Blacklisted = true
+		headerData[i].UpdatedTs = args[1]
+		headerData[i].UpdatedBy = Organizations[0]
+		headerAsBytes, err := json.Marshal(headerData[i])
+		if err != nil {
+			logger.Errorf("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(getHeaderKey(stub, headerData[i].Header_Name), headerAsBytes)
+		if err != nil {
+			logger.Errorf("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		}
+		headerSuspended = append(headerSuspended, headerData[i].Header_Name)
+	}
+
+	suspension := EntitySuspension{ObjType: "EntitySuspension", PrincipleEntityId: peid, Headers: headerSuspended, UpdatedTs: args[1], UpdatedBy: Organizations[0]}
+	suspensionAsBytes, err := json.Marshal(suspension)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+	}
+	err = stub.PutState(suspensionKey, suspensionAsBytes)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+	}
+
+	err2 := stub.SetEvent(EVTSuspendHeader, suspensionAsBytes)
+	if err2 != nil {
+		logger.Errorf("Event not generated for event : EVTSuspendHeader")
+		return shim.Error("Event not generated for event : EVTSuspendHeader")
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"items": headerSuspended,
+	"message" : "Suspended all headers against PEID : " +peid ,
+	"countSuccess":  strconv.Itoa(len(headerSuspended)),
+	"status": "true",
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// ===========================================================================================
+// restoreHeadersByEntity - Called on un-blacklisting of an entity. Removes the blacklisting of
+// the headers suspended by "sbe" for the entity. Input : peid, uts
+// ===========================================================================================
+func (t *HeaderChainCode) restoreHeadersByEntity(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid and uts expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "RESTORE"); err != nil {
+		logger.Errorf("restoreHeadersByEntity : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Composite key Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Composite key Error : " + string(err.Error()))
+	}
+	suspensionAsBytes, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : GetState Failed Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : GetState Failed Error : " + string(err.Error()))
+	} else if suspensionAsBytes == nil {
+		// entities blacklisted before sbe kept the suspended headers have nothing to restore
+		logger.Infof("restoreHeadersByEntity : No suspended headers for PEID : " + peid)
+		resultData := map[string]interface{} {
+		"trxnID":   stub.GetTxID(),
+		"items": make([]string, 0),
+		"headerRejected": make([]map[string]interface{}, 0),
+		"message" : "No suspended headers against PEID : " +peid ,
+		"countSuccess":  "0",
+		"status": "true",
+		}
+		respJSON, _ := json.Marshal(resultData)
+		return shim.Success(respJSON)
+	}
+
+	suspension := EntitySuspension{}
+	err = json.Unmarshal(suspensionAsBytes, &suspension)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Unmarhsaling Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Unmarhsaling Error : " + string(err.Error()))
+	}
+
+	headerRestored := make([]string, 0)
+	headerRejected := make([]map[string]interface{}, 0)
+	for _, hName := range suspension.Headers {
+		valAsBytes, err := getHeaderState(stub, hName)
+		if err != nil || valAsBytes == nil {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Record does not exist for Header" })
+			continue
+		}
+		var header Header
+		err = json.Unmarshal(valAsBytes, &header)
+		if err != nil {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Unmarhsaling Error" })
+			continue
+		}
+		// header may have been reassigned or un-blacklisted in the meantime
+		if header.PrincipleEntityId != peid || header.Blacklisted == false {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Header changed after suspension" })
+			continue
+		}
+		header.Blacklisted = false
+		header.UpdatedTs = args[1]
+		header.UpdatedBy = Organizations[0]
+		headerAsBytes, err := json.Marshal(header)
+		if err != nil {
+			logger.Errorf("restoreHeadersByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("restoreHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(getHeaderKey(stub, hName), headerAsBytes)
+		if err != nil {
+			logger.Errorf("restoreHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("restoreHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		}
+		headerRestored = append(headerRestored, hName)
+	}
+
+	err = stub.DelState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : DelState Failed Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : DelState Failed Error : " + string(err.Error()))
+	}
+
+	suspension.Headers = headerRestored
+	suspension.UpdatedTs = args[1]
+	suspension.UpdatedBy = Organizations[0]
+	eventAsBytes, _ := json.Marshal(suspension)
+	err2 := stub.SetEvent(EVTRestoreHeader, eventAsBytes)
+	if err2 != nil {
+		logger.Errorf("Event not generated for event : EVTRestoreHeader")
+		return shim.Error("Event not generated for event : EVTRestoreHeader")
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"items": headerRestored,
+	"headerRejected": headerRejected,
+	"message" : "Restored suspended headers against PEID : " +peid ,
+	"countSuccess":  strconv.Itoa(len(headerRestored)),
+	"status": "true",
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+
+// ===========================================================================================
+// checkEntityCascade - Returns an error unless the blacklist cascade of the entity, read from
+// the entity chaincode, has the given action (SUSPEND / RESTORE) pending for this chaincode
+// ===========================================================================================
+func checkEntityCascade(stub shim.ChaincodeStubInterface, peid string, action string) error {
+	ccArgs := [][]byte{[]byte("getBlacklistCascade"), []byte(peid)}
+	response := stub.InvokeChaincode(EntityChaincode, ccArgs, EntityChannel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("No blacklist cascade for PEID %s : %s", peid, response.Message)
+	}
+	cascade := struct {
+		Action string `json:"action"`
+		Steps  map[string]struct {
+			Status string `json:"sts"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &cascade); err != nil {
+		return fmt.Errorf("Invalid blacklist cascade for PEID %s", peid)
+	}
+	if cascade.Action != action || cascade.Steps[CascadeTargetName].Status != "P" {
+		return fmt.Errorf("No %s of PEID %s pending for %s", action, peid, CascadeTargetName)
+	}
+	return nil
+}
+
+
+// ===========================================================================================
+// queryEntitySuspension - Returns the CLI of the headers kept suspended by "sbe" for the
+// entity, read by the entity chaincode to confirm its blacklist cascade. Input : peid
+// ===========================================================================================
+func (t *HeaderChainCode) queryEntitySuspension(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{args[0]})
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Composite key Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Composite key Error : " + string(err.Error()))
+	}
+	suspensionAsBytes, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : GetState Failed Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : GetState Failed Error : " + string(err.Error()))
+	}
+	suspension := EntitySuspension{Headers: make([]string, 0)}
+	if suspensionAsBytes != nil {
+		err = json.Unmarshal(suspensionAsBytes, &suspension)
+		if err != nil {
+			logger.Errorf("queryEntitySuspension : Unmarhsaling Error : " + string(err.Error()))
+			return shim.Error("queryEntitySuspension : Unmarhsaling Error : " + string(err.Error()))
+		}
+	}
+
+	resultData := map[string]interface{} {
+	"peid": args[0],
+	"suspended": suspensionAsBytes != nil,
+	"items": suspension.Headers,
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// ===========================================================================================
+// migrateHeaderKeys - Moves the headers stored against the raw CLI to their composite key.
+// Range query over the simple keys leaves out the composite keys, so every invocation moves
+// the next batch. A header already written to its composite key is kept and the raw CLI
+// record is only deleted (superseded). Invoke until done is true. Input : batch size (optional)
+// ===========================================================================================
+func (t *HeaderChainCode) migrateHeaderKeys(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("migrateHeaderKeys : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderKeys : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	batchSize := MigrationBatchSize
+	if len(args) > 0 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("migrateHeaderKeys : Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+
+	resultsIterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		logger.Errorf("migrateHeaderKeys : GetStateByRange Failed Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderKeys : GetStateByRange Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	migrated := make([]string, 0)
+	superseded := make([]string, 0)
+	skipped := make([]string, 0)
+	for len(migrated)+len(superseded) < batchSize && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("migrateHeaderKeys : Iterator Error : " + string(err.Error()))
+		}
+		var header Header
+		if err := json.Unmarshal(queryResponse.Value, &header); err != nil || header.ObjType != HeaderObjType {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		headerKey := getHeaderKey(stub, queryResponse.Key)
+		if headerKey == "" {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		// a header written to its composite key since the upgrade is newer than the raw CLI record
+		existing, err := stub.GetState(headerKey)
+		if err != nil {
+			logger.Errorf("migrateHeaderKeys : GetState Failed Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderKeys : GetState Failed Error : " + string(err.Error()))
+		}
+		if len(existing) == 0 {
+			if err := stub.PutState(headerKey, queryResponse.Value); err != nil {
+				logger.Errorf("migrateHeaderKeys : PutState Failed Error : " + string(err.Error()))
+				return shim.Error("migrateHeaderKeys : PutState Failed Error : " + string(err.Error()))
+			}
+		}
+		if err := stub.DelState(queryResponse.Key); err != nil {
+			logger.Errorf("migrateHeaderKeys : DelState Failed Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderKeys : DelState Failed Error : " + string(err.Error()))
+		}
+		if len(existing) == 0 {
+			migrated = append(migrated, queryResponse.Key)
+		} else {
+			superseded = append(superseded, queryResponse.Key)
+		}
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"migrated": migrated,
+	"superseded": superseded,
+	"skipped": skipped,
+	"countSuccess":  strconv.Itoa(len(migrated)),
+	"done": len(migrated)+len(superseded) < batchSize,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// getHeaderKey - composite key of the header record for a CLI, empty if it can not be created
+func getHeaderKey(stub shim.ChaincodeStubInterface, cli string) string {
+	headerKey, err := stub.CreateCompositeKey(HeaderObjType, []string{cli})
+	if err != nil {
+		logger.Errorf("getHeaderKey : CreateCompositeKey Failed Error : " + string(err.Error()))
+		return ""
+	}
+	return headerKey
+}
+
+// getHeaderState - header record of a CLI, read from its composite key or, until migrateHeaderKeys
+// has moved it, from the raw CLI
+func getHeaderState(stub shim.ChaincodeStubInterface, cli string) ([]byte, error) {
+	valAsBytes, err := stub.GetState(getHeaderKey(stub, cli))
+	if err != nil || len(valAsBytes) > 0 {
+		return valAsBytes, err
+	}
+	return stub.GetState(cli)
+}
+
 func (t *HeaderChainCode) retriveHeaderRecords(stub shim.ChaincodeStubInterface, criteria string, indexs ...string) []Header {
     
 	var finalSelector string
@@ -991,15 +1676,33 @@ func (t *HeaderChainCode) retriveHeaderRecords(stub shim.ChaincodeStubInterface,
 	}
 
 	logger.Infof("Query Selector : %s", finalSelector)
-	resultsIterator, _ := stub.GetQueryResult(finalSelector)
+	resultsIterator, err := stub.GetQueryResult(finalSelector)
+	if err != nil {
+		logger.Errorf("retriveHeaderRecords : GetQueryResult Failed Error : " + string(err.Error()))
+		return records
+	}
+	defer resultsIterator.Close()
+	// until migrateHeaderKeys is done a header written since the upgrade is found at the raw CLI
+	// as well as at its composite key, the latter being the current one
+	position := make(map[string]int)
 	for resultsIterator.HasNext() {
 		record := Header{}
-		recordBytes, _ := resultsIterator.Next()
-		err := json.Unmarshal(recordBytes.Value, &record)
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			logger.Errorf("retriveHeaderRecords : Iterator Error : " + string(err.Error()))
+			return records
+		}
+		err = json.Unmarshal(recordBytes.Value, &record)
 		if err != nil {
 			logger.Infof("Unable to unmarshal Header retrived:: %v", err)
 		}
-		records = append(records, record)
+		i, found := position[record.Header_Name]
+		if !found {
+			position[record.Header_Name] = len(records)
+			records = append(records, record)
+		} else if len(recordBytes.Key) > 0 && recordBytes.Key[0] == 0 {
+			records[i] = record
+		}
 	}
 	return records
 }
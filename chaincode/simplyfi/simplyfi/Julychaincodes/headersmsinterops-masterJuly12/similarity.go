@@ -0,0 +1,665 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	sc "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Look-alike headers are found through the variants of their normalised name: the name and
+// every name with up to MaxVariantDeletes characters removed. Two names within that many
+// edits share a variant. Variants are stored under {HeaderVariant, variant, cli}
+const VariantObjType = "HeaderVariant"
+const MaxVariantDeletes = 2
+const BrandObjType = "ProtectedBrand"
+
+// Default number of headers indexed by one "ihv" invocation
+const IndexBatchSize = 500
+
+// Similarity score is 1 - edit distance / length of the longer normalised name
+const (
+	SimilarityReview = 0.8 // registered, pending manual approval ("ahr")
+	SimilarityReject = 0.9 // not registered
+)
+
+// Similarity decisions
+const (
+	SimilarityOK     = "OK"
+	SimilarityFlag   = "REVIEW"
+	SimilarityDenied = "REJECT"
+)
+
+// Review status of a flagged header
+const (
+	ReviewPending  = "P"
+	ReviewApproved = "A"
+	ReviewRejected = "R"
+)
+
+// lookAlike - characters read alike in a header name, mapped to the letter they imitate
+var lookAlike = map[rune]rune{
+	'0': 'O',
+	'1': 'I',
+	'L': 'I',
+	'2': 'Z',
+	'3': 'E',
+	'4': 'A',
+	'5': 'S',
+	'6': 'G',
+	'7': 'T',
+	'8': 'B',
+	'Q': 'O',
+}
+
+// SimilarHeader is a registered header or a protected brand looking alike the header checked
+type SimilarHeader struct {
+	Name  string  `json:"cli"`            // cli of the header, or the protected brand
+	PEID  string  `json:"peid,omitempty"` // entity of the header
+	Brand bool    `json:"brand"`          // Name is a protected brand
+	Score float64 `json:"score"`
+}
+
+// HeaderReview keeps why a header was flagged and the manual decision on it
+type HeaderReview struct {
+	Status     string            `json:"sts"`     // P pending, A approved, R rejected
+	Score      float64           `json:"score"`   // highest similarity found
+	Matches    []SimilarHeader   `json:"matches"` // headers and brands looking alike
+	Requested  map[string]string `json:"req"`     // operator status requested at registration, applied on approval
+	ReviewedBy string            `json:"rby"`
+	ReviewedTs string            `json:"rts"`
+}
+
+// ProtectedBrand is a brand only its entities may use in a header name
+type ProtectedBrand struct {
+	ObjType   string   `json:"obj"`   // obj   : ProtectedBrand
+	Brand     string   `json:"brand"` // brand : as given, e.g. HDFC
+	Norm      string   `json:"norm"`  // norm  : normalised brand -- Key field
+	PEIDs     []string `json:"peids"` // peids : entities owning the brand
+	Creator   string   `json:"crtr"`
+	UpdatedTs string   `json:"uts"`
+	UpdatedBy string   `json:"uby"`
+}
+
+// getInvoker - domain of the invoking operator and its service provider code
+func getInvoker(stub shim.ChaincodeStubInterface) (string, string, error) {
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", "", errors.New("Getting certificate Details Error : " + err.Error())
+	}
+	Organizations := certData.Issuer.Organization
+	if len(Organizations) == 0 {
+		return "", "", errors.New("Unauthorized Node Access")
+	}
+	dltNode, ok := dltDomainNames[Organizations[0]]
+	if !ok {
+		return "", "", errors.New("Unauthorized Node Access")
+	}
+	return Organizations[0], dltNode, nil
+}
+
+// getHeader - header of a CLI, nil when it is not registered
+func getHeader(stub shim.ChaincodeStubInterface, cli string) (*Header, error) {
+	headerBytes, err := getHeaderState(stub, cli)
+	if err != nil || len(headerBytes) == 0 {
+		return nil, err
+	}
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// normaliseHeader - upper case name without separators, look-alike characters replaced
+func normaliseHeader(cli string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(cli) {
+		if mapped, ok := lookAlike[r]; ok {
+			r = mapped
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isNumericHeader - numeric CLIs are allocated from numbering series, not chosen, so they are not compared
+func isNumericHeader(cli string) bool {
+	_, err := strconv.ParseUint(cli, 10, 64)
+	return err == nil
+}
+
+// headerVariants - the normalised name and the names with up to MaxVariantDeletes characters
+// removed, sorted. Two edits cover the review score for the names of less than 15 characters
+func headerVariants(norm string) []string {
+	seen := map[string]bool{norm: true}
+	level := []string{norm}
+	for deletes := 0; deletes < MaxVariantDeletes; deletes++ {
+		next := make([]string, 0)
+		for _, name := range level {
+			for i := 0; i < len(name); i++ {
+				variant := name[:i] + name[i+1:]
+				if !seen[variant] {
+					seen[variant] = true
+					next = append(next, variant)
+				}
+			}
+		}
+		level = next
+	}
+	variants := make([]string, 0, len(seen))
+	for variant := range seen {
+		if variant != "" {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Strings(variants)
+	return variants
+}
+
+// editDistance - Levenshtein distance of two names
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// similarity - 1 for the same normalised names, 0 for nothing in common
+func similarity(a, b string) float64 {
+	longer := len(a)
+	if len(b) > longer {
+		longer = len(b)
+	}
+	if longer == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(a, b))/float64(longer)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// variantKeys - keys of the variants of a header name, none for a numeric CLI
+func variantKeys(stub shim.ChaincodeStubInterface, cli string) ([]string, error) {
+	keys := make([]string, 0)
+	if isNumericHeader(cli) {
+		return keys, nil
+	}
+	for _, variant := range headerVariants(normaliseHeader(cli)) {
+		key, err := stub.CreateCompositeKey(VariantObjType, []string{variant, cli})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// indexHeaderVariants - makes the header found by the similarity check of later registrations
+func indexHeaderVariants(stub shim.ChaincodeStubInterface, cli string) error {
+	keys, err := variantKeys(stub, cli)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeHeaderVariants - removes the variants of a header which is no longer registered
+func removeHeaderVariants(stub shim.ChaincodeStubInterface, cli string) error {
+	keys, err := variantKeys(stub, cli)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := stub.DelState(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getProtectedBrand - the protected brand stored at the key, nil when there is none
+func getProtectedBrand(stub shim.ChaincodeStubInterface, key string) (*ProtectedBrand, error) {
+	brandBytes, err := stub.GetState(key)
+	if err != nil || brandBytes == nil {
+		return nil, err
+	}
+	var brand ProtectedBrand
+	if err := json.Unmarshal(brandBytes, &brand); err != nil {
+		return nil, err
+	}
+	return &brand, nil
+}
+
+// getProtectedBrands - all the protected brands
+func getProtectedBrands(stub shim.ChaincodeStubInterface) ([]ProtectedBrand, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(BrandObjType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	brands := make([]ProtectedBrand, 0)
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var brand ProtectedBrand
+		if err := json.Unmarshal(response.Value, &brand); err != nil {
+			return nil, err
+		}
+		brands = append(brands, brand)
+	}
+	return brands, nil
+}
+
+// checkSimilarity - compares the header with the headers of other entities, registered or earlier
+// in the same transaction (batch), and with the brands the entity does not own. A brand contained
+// in the name is rejected whatever the score
+func checkSimilarity(stub shim.ChaincodeStubInterface, header Header, batch []Header) (string, float64, []SimilarHeader, error) {
+	matches := make([]SimilarHeader, 0)
+	if isNumericHeader(header.Header_Name) {
+		return SimilarityOK, 0, matches, nil
+	}
+	norm := normaliseHeader(header.Header_Name)
+	decision := SimilarityOK
+	highest := 0.0
+	consider := func(match SimilarHeader) {
+		if match.Score < SimilarityReview {
+			return
+		}
+		matches = append(matches, match)
+		if match.Score > highest {
+			highest = match.Score
+		}
+		if match.Score >= SimilarityReject {
+			decision = SimilarityDenied
+		} else if decision == SimilarityOK {
+			decision = SimilarityFlag
+		}
+	}
+
+	seen := map[string]bool{header.Header_Name: true}
+	for _, variant := range headerVariants(norm) {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(VariantObjType, []string{variant})
+		if err != nil {
+			return "", 0, nil, err
+		}
+		for resultsIterator.HasNext() {
+			response, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return "", 0, nil, err
+			}
+			_, attributes, err := stub.SplitCompositeKey(response.Key)
+			if err != nil || len(attributes) != 2 || seen[attributes[1]] {
+				continue
+			}
+			cli := attributes[1]
+			seen[cli] = true
+			existing, err := getHeader(stub, cli)
+			if err != nil || existing == nil || existing.PrincipleEntityId == header.PrincipleEntityId {
+				continue
+			}
+			consider(SimilarHeader{Name: cli, PEID: existing.PrincipleEntityId, Score: similarity(norm, normaliseHeader(cli))})
+		}
+		resultsIterator.Close()
+	}
+	for _, other := range batch {
+		if seen[other.Header_Name] || other.PrincipleEntityId == header.PrincipleEntityId || isNumericHeader(other.Header_Name) {
+			continue
+		}
+		seen[other.Header_Name] = true
+		consider(SimilarHeader{Name: other.Header_Name, PEID: other.PrincipleEntityId, Score: similarity(norm, normaliseHeader(other.Header_Name))})
+	}
+
+	brands, err := getProtectedBrands(stub)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	for _, brand := range brands {
+		if containsString(brand.PEIDs, header.PrincipleEntityId) {
+			continue
+		}
+		score := similarity(norm, brand.Norm)
+		if strings.Contains(norm, brand.Norm) {
+			score = 1
+		}
+		consider(SimilarHeader{Name: brand.Brand, Brand: true, Score: score})
+	}
+	return decision, highest, matches, nil
+}
+
+// applySimilarity - rejects a header too similar to another or flags it for manual approval.
+// A flagged header is registered with its operator status inactive until approved
+func applySimilarity(stub shim.ChaincodeStubInterface, header *Header, batch []Header) (bool, string) {
+	decision, score, matches, err := checkSimilarity(stub, *header, batch)
+	if err != nil {
+		return false, "Similarity check failed : " + err.Error()
+	}
+	switch decision {
+	case SimilarityDenied:
+		names := make([]string, 0, len(matches))
+		for _, match := range matches {
+			names = append(names, match.Name)
+		}
+		return false, "Header is similar to registered header or protected brand : " + strings.Join(names, ", ")
+	case SimilarityFlag:
+		header.Review = &HeaderReview{Status: ReviewPending, Score: score, Matches: matches, Requested: header.Status}
+		inactive := make(map[string]string)
+		for operator := range header.Status {
+			inactive[operator] = "I"
+		}
+		header.Status = inactive
+	}
+	return true, ""
+}
+
+// ===========================================================================================
+// querySimilarHeaders - similarity check of a header name for an entity without registering
+// it. Input : cli, peid
+// ===========================================================================================
+func (t *HeaderChainCode) querySimilarHeaders(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, cli and peid expected")
+	}
+	header := Header{Header_Name: args[0], PrincipleEntityId: args[1]}
+	decision, score, matches, err := checkSimilarity(stub, header, nil)
+	if err != nil {
+		return shim.Error("Similarity check failed : " + err.Error())
+	}
+	resultData := map[string]interface{}{
+		"cli":        args[0],
+		"normalised": normaliseHeader(args[0]),
+		"decision":   decision,
+		"score":      score,
+		"matches":    matches,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// reviewHeader - manual approval (A) or rejection (R) of a header flagged at registration by
+// an operator other than the one which registered it. A rejected header is removed so that
+// the name can be registered again. Input : {"cli", "sts", "uts"}
+// ===========================================================================================
+func (t *HeaderChainCode) reviewHeader(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	var data map[string]string
+	if err := json.Unmarshal([]byte(args[0]), &data); err != nil {
+		return shim.Error("reviewHeader : Input arguments unmarhsaling Error : " + string(err.Error()))
+	}
+	reviewer, _, err := getInvoker(stub)
+	if err != nil {
+		logger.Errorf("reviewHeader : " + err.Error())
+		return shim.Error(err.Error())
+	}
+	if data["uts"] == "" || (data["sts"] != ReviewApproved && data["sts"] != ReviewRejected) {
+		return shim.Error("reviewHeader : uts is mandatory and sts should be either A, R")
+	}
+
+	header, err := getHeader(stub, data["cli"])
+	if err != nil || header == nil {
+		return shim.Error("Failed to get Header Record " + data["cli"] + " Error : Record Does not exist ")
+	}
+	if header.Review == nil || header.Review.Status != ReviewPending {
+		return shim.Error("Header is not pending review")
+	}
+	if header.Creator == reviewer {
+		return shim.Error("Header can not be reviewed by the operator which registered it")
+	}
+
+	header.Review.Status = data["sts"]
+	header.Review.ReviewedBy = reviewer
+	header.Review.ReviewedTs = data["uts"]
+	if data["sts"] == ReviewApproved {
+		for operator, status := range header.Review.Requested {
+			header.Status[operator] = status
+		}
+	}
+	header.UpdatedTs = data["uts"]
+	header.UpdatedBy = reviewer
+	headerAsBytes, err := json.Marshal(header)
+	if err != nil {
+		return shim.Error("reviewHeader : Marshalling Error : " + string(err.Error()))
+	}
+	if data["sts"] == ReviewApproved {
+		err = stub.PutState(getHeaderKey(stub, header.Header_Name), headerAsBytes)
+	} else {
+		err = stub.DelState(getHeaderKey(stub, header.Header_Name))
+		if err == nil {
+			err = removeHeaderVariants(stub, header.Header_Name)
+		}
+	}
+	if err != nil {
+		logger.Errorf("reviewHeader : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("reviewHeader : PutState Failed Error : " + string(err.Error()))
+	}
+	if err := stub.SetEvent(EVTUpdateHeaderStatus, headerAsBytes); err != nil {
+		logger.Errorf("Event not generated for event : EVTUpdateHeaderStatus")
+		return shim.Error("Event not generated for event : EVTUpdateHeaderStatus")
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":         stub.GetTxID(),
+		"headerReviewed": header.Header_Name,
+		"message":        "Header review is updated Successfully.",
+		"Header":         header,
+		"status":         "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// addProtectedBrand - adds a brand, or replaces its entities. Only the operator which added
+// the brand can change it. Input : {"brand", "peids", "uts"}
+// ===========================================================================================
+func (t *HeaderChainCode) addProtectedBrand(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	var brand ProtectedBrand
+	if err := json.Unmarshal([]byte(args[0]), &brand); err != nil {
+		return shim.Error("addProtectedBrand : Input arguments unmarhsaling Error : " + string(err.Error()))
+	}
+	updatedBy, _, err := getInvoker(stub)
+	if err != nil {
+		logger.Errorf("addProtectedBrand : " + err.Error())
+		return shim.Error(err.Error())
+	}
+	brand.Norm = normaliseHeader(brand.Brand)
+	if len(brand.Norm) < 3 {
+		return shim.Error("addProtectedBrand : brand should have atleast 3 letters or digits")
+	}
+	if len(brand.PEIDs) == 0 || brand.UpdatedTs == "" {
+		return shim.Error("addProtectedBrand : peids and uts are mandatory")
+	}
+
+	key, err := stub.CreateCompositeKey(BrandObjType, []string{brand.Norm})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	brand.ObjType = BrandObjType
+	brand.Creator = updatedBy
+	existing, err := getProtectedBrand(stub, key)
+	if err != nil {
+		return shim.Error("addProtectedBrand : " + err.Error())
+	}
+	if existing != nil && existing.Creator != updatedBy {
+		return shim.Error("addProtectedBrand : Protected brand can be changed only by the operator which added it")
+	}
+	brand.UpdatedBy = updatedBy
+	brandAsBytes, _ := json.Marshal(brand)
+	if err := stub.PutState(key, brandAsBytes); err != nil {
+		logger.Errorf("addProtectedBrand : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("addProtectedBrand : PutState Failed Error : " + string(err.Error()))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"brand":   brand,
+		"message": "Protected brand saved",
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// deleteProtectedBrand - removes a brand from the protected brands. Only the operator which
+// added the brand can remove it. Input : brand
+// ===========================================================================================
+func (t *HeaderChainCode) deleteProtectedBrand(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction")
+	}
+	updatedBy, _, err := getInvoker(stub)
+	if err != nil {
+		logger.Errorf("deleteProtectedBrand : " + err.Error())
+		return shim.Error(err.Error())
+	}
+	key, err := stub.CreateCompositeKey(BrandObjType, []string{normaliseHeader(args[0])})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	existing, err := getProtectedBrand(stub, key)
+	if err != nil {
+		return shim.Error("deleteProtectedBrand : " + err.Error())
+	}
+	if existing == nil {
+		return shim.Error("Protected brand does not exist : " + args[0])
+	}
+	if existing.Creator != updatedBy {
+		return shim.Error("deleteProtectedBrand : Protected brand can be removed only by the operator which added it")
+	}
+	if err := stub.DelState(key); err != nil {
+		return shim.Error("deleteProtectedBrand : DelState Failed Error : " + string(err.Error()))
+	}
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"brand":   args[0],
+		"message": "Protected brand removed",
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// queryProtectedBrands - all the protected brands
+// ===========================================================================================
+func (t *HeaderChainCode) queryProtectedBrands(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+	brands, err := getProtectedBrands(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	respJSON, _ := json.Marshal(brands)
+	return shim.Success(respJSON)
+}
+
+// ===========================================================================================
+// indexExistingHeaders - indexes the variants of the headers registered before the similarity
+// check, a batch of headers at a time. Run it after mhk has moved the headers to composite
+// keys, and invoke it with the returned bookmark until done is true.
+// Input : batch size (optional), bookmark (optional)
+// ===========================================================================================
+func (t *HeaderChainCode) indexExistingHeaders(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if _, _, err := getInvoker(stub); err != nil {
+		logger.Errorf("indexExistingHeaders : " + err.Error())
+		return shim.Error(err.Error())
+	}
+	batchSize := IndexBatchSize
+	if len(args) > 0 && args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("indexExistingHeaders : Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+	bookmark := ""
+	if len(args) > 1 {
+		bookmark = args[1]
+	}
+
+	resultsIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(HeaderObjType, []string{}, int32(batchSize), bookmark)
+	if err != nil {
+		logger.Errorf("indexExistingHeaders : GetStateByPartialCompositeKeyWithPagination Failed Error : " + string(err.Error()))
+		return shim.Error("indexExistingHeaders : GetStateByPartialCompositeKeyWithPagination Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	indexed := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("indexExistingHeaders : Iterator Error : " + string(err.Error()))
+		}
+		var header Header
+		if err := json.Unmarshal(queryResponse.Value, &header); err != nil || header.Header_Name == "" {
+			continue
+		}
+		if err := indexHeaderVariants(stub, header.Header_Name); err != nil {
+			logger.Errorf("indexExistingHeaders : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("indexExistingHeaders : PutState Failed Error : " + string(err.Error()))
+		}
+		indexed = append(indexed, header.Header_Name)
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":       stub.GetTxID(),
+		"indexed":      indexed,
+		"countSuccess": strconv.Itoa(len(indexed)),
+		"bookmark":     metadata.Bookmark,
+		"done":         metadata.Bookmark == "" || int(metadata.FetchedRecordsCount) < batchSize,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
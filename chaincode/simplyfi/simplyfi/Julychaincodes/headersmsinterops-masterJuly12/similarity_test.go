@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// fakeGovernance answers searchProposal with the given proposals. It has no config, the
+// default categories are used
+type fakeGovernance struct {
+	proposals map[string]string
+}
+
+func (governance *fakeGovernance) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (governance *fakeGovernance) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "searchProposal" {
+		if proposal, ok := governance.proposals[args[0]]; ok {
+			return shim.Success([]byte(proposal))
+		}
+		return shim.Error("The proposal id doesn't exists")
+	}
+	return shim.Error("Governance config not found")
+}
+
+func newHeaderStub(t *testing.T) (*HeaderChainCode, *testStub) {
+	return newGovernedHeaderStub(t, new(fakeGovernance))
+}
+
+func newGovernedHeaderStub(t *testing.T, governance *fakeGovernance) (*HeaderChainCode, *testStub) {
+	cc := new(HeaderChainCode)
+	stub := newTestStub(t, "header", cc, "airtel.com")
+	stub.MockPeerChaincode(GovernanceChaincode+"/"+GovernanceChannel, shim.NewMockStub(GovernanceChaincode, governance))
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	return cc, stub
+}
+
+// allowAlphanumericHeaders replaces the 6 letter rule of the T headers, so that the look-alike
+// digits can be checked by the similarity tests
+func allowAlphanumericHeaders(t *testing.T, stub *testStub) {
+	key, err := getFormatRuleKey(stub, "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.put(t, key, FormatRule{ObjType: FormatRuleObjType, Channel: FormatChannel, Type: "T", MinLength: 6, MaxLength: 11, Charset: CharsetAlphanumeric, UpdatedTs: "1"})
+}
+
+func smsHeader(cli, peid string) string {
+	return fmt.Sprintf(`{"hid":"H%s","peid":"%s","htyp":"T","cli":"%s","ctgr":"8","cts":"1","uts":"1","sts":{"AI":"A"}}`, cli, peid, cli)
+}
+
+func getTestHeader(t *testing.T, stub *testStub, cli string) *Header {
+	header, err := getHeader(stub, cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestSimilarHeaderRegistration(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	for _, header := range []string{smsHeader("HDFCBK", "E1"), smsHeader("HDFCBN", "E1")} {
+		if res := stub.invoke(cc, "rh", header); res.Status != shim.OK {
+			t.Fatalf("rh failed: %s", res.Message)
+		}
+	}
+	//8 reads as B, the same name as HDFCBK
+	if res := stub.invoke(cc, "rh", smsHeader("HDFC8K", "E2")); res.Status == shim.OK {
+		t.Fatal("look-alike header of another entity registered")
+	}
+	//one edit of 6 from HDFCBK: registered inactive pending review
+	if res := stub.invoke(cc, "rh", smsHeader("HDFCBQ", "E2")); res.Status != shim.OK {
+		t.Fatalf("rh failed: %s", res.Message)
+	}
+	header := getTestHeader(t, stub, "HDFCBQ")
+	if header.Review == nil || header.Review.Status != ReviewPending || header.Status["AI"] != "I" || len(header.Review.Matches) != 2 {
+		t.Fatalf("expected HDFCBQ inactive pending review against 2 headers, got %+v %+v", header.Status, header.Review)
+	}
+	if res := stub.invoke(cc, "uhs", `{"cli":"HDFCBQ","sts":"A","uts":"2"}`); res.Status == shim.OK {
+		t.Fatal("status of a header pending review changed")
+	}
+	if res := stub.invoke(cc, "ahr", `{"cli":"HDFCBQ","sts":"A","uts":"2"}`); res.Status == shim.OK {
+		t.Fatal("header reviewed by the operator which registered it")
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "ahr", `{"cli":"HDFCBQ","sts":"A","uts":"2"}`); res.Status != shim.OK {
+		t.Fatalf("ahr failed: %s", res.Message)
+	}
+	if header := getTestHeader(t, stub, "HDFCBQ"); header.Status["AI"] != "A" || header.Review.ReviewedBy != "jio.com" {
+		t.Fatalf("expected HDFCBQ active once approved, got %+v %+v", header.Status, header.Review)
+	}
+
+	//a rejected header is removed and no longer compared
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "rh", smsHeader("HDFCXK", "E3")); res.Status != shim.OK {
+		t.Fatalf("rh failed: %s", res.Message)
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "ahr", `{"cli":"HDFCXK","sts":"R","uts":"3"}`); res.Status != shim.OK {
+		t.Fatalf("ahr failed: %s", res.Message)
+	}
+	if header := getTestHeader(t, stub, "HDFCXK"); header != nil {
+		t.Fatalf("rejected header kept: %+v", header)
+	}
+	res := stub.invoke(cc, "qhs", "HDFCXK", "E4")
+	var result struct {
+		Decision string          `json:"decision"`
+		Matches  []SimilarHeader `json:"matches"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	for _, match := range result.Matches {
+		if match.Name == "HDFCXK" {
+			t.Fatal("rejected header still found by the similarity check")
+		}
+	}
+	if result.Decision != SimilarityFlag {
+		t.Fatalf("expected HDFCXK flagged against HDFCBK, got %s", result.Decision)
+	}
+}
+
+func TestProtectedBrand(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "apb", `{"brand":"ICICI","peids":["E9"],"uts":"1"}`); res.Status != shim.OK {
+		t.Fatalf("apb failed: %s", res.Message)
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "apb", `{"brand":"ICICI","peids":["E2"],"uts":"2"}`); res.Status == shim.OK {
+		t.Fatal("protected brand changed by another operator")
+	}
+	if res := stub.invoke(cc, "dpb", "ICICI"); res.Status == shim.OK {
+		t.Fatal("protected brand removed by another operator")
+	}
+	if res := stub.invoke(cc, "rh", smsHeader("MYICICIBANK", "E2")); res.Status == shim.OK {
+		t.Fatal("header containing a protected brand registered for another entity")
+	}
+	if res := stub.invoke(cc, "rh", smsHeader("ICICIB", "E9")); res.Status != shim.OK {
+		t.Fatalf("rh of the brand owner failed: %s", res.Message)
+	}
+	//numeric CLIs are not compared
+	if res := stub.invoke(cc, "rh", `{"hid":"H1","peid":"E2","htyp":"P","cli":"123456","ctgr":"8","cts":"1","uts":"1","sts":{"AI":"A"}}`); res.Status != shim.OK {
+		t.Fatalf("rh of a numeric header failed: %s", res.Message)
+	}
+}
+
+func TestBulkSimilarHeaders(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	res := stub.invoke(cc, "rbh", smsHeader("PAYTMOX", "E5"), smsHeader("PAYTM0X", "E6"), smsHeader("PAYTNOX", "E6"))
+	if res.Status != shim.OK {
+		t.Fatalf("rbh failed: %s", res.Message)
+	}
+	var result struct {
+		Registered []string                 `json:"headerRegistered"`
+		Rejected   []map[string]interface{} `json:"headerRejected"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	//headers of the same transaction are compared with each other
+	if len(result.Registered) != 2 || len(result.Rejected) != 1 || result.Rejected[0]["Header_Name"] != "PAYTM0X" {
+		t.Fatalf("expected PAYTM0X rejected, got %+v", result)
+	}
+	if header := getTestHeader(t, stub, "PAYTNOX"); header.Review == nil || header.Review.Status != ReviewPending {
+		t.Fatalf("expected PAYTNOX pending review, got %+v", header.Review)
+	}
+}
+
+func TestIndexExistingHeaders(t *testing.T) {
+	cc, stub := newHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	for i, cli := range []string{"ALPHAX", "BRAVOX", "CHARLI"} {
+		stub.put(t, getHeaderKey(stub, cli), Header{ObjType: HeaderObjType, Header_ID: fmt.Sprint(i), PrincipleEntityId: "E1", Header_Type: "T", Header_Name: cli, Category: "8"})
+	}
+	if res := stub.invoke(cc, "rh", smsHeader("BRAV0X", "E2")); res.Status != shim.OK {
+		t.Fatalf("rh failed: %s", res.Message)
+	}
+
+	bookmark, done := "", false
+	indexed := 0
+	for calls := 0; !done; calls++ {
+		if calls == 2 {
+			t.Fatal("ihv not done after 2 batches")
+		}
+		res := stub.invoke(cc, "ihv", "2", bookmark)
+		if res.Status != shim.OK {
+			t.Fatalf("ihv failed: %s", res.Message)
+		}
+		var result struct {
+			Indexed  []string `json:"indexed"`
+			Bookmark string   `json:"bookmark"`
+			Done     bool     `json:"done"`
+		}
+		if err := json.Unmarshal(res.Payload, &result); err != nil {
+			t.Fatal(err)
+		}
+		indexed += len(result.Indexed)
+		bookmark, done = result.Bookmark, result.Done
+	}
+	if indexed != 4 {
+		t.Fatalf("expected 4 headers indexed, got %d", indexed)
+	}
+	if res := stub.invoke(cc, "rh", smsHeader("CHARL1", "E3")); res.Status == shim.OK {
+		t.Fatal("look-alike of an indexed header registered")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// fakeGovernance answers searchProposal with the given proposals
+type fakeGovernance struct {
+	proposals map[string]string
+}
+
+func (governance *fakeGovernance) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (governance *fakeGovernance) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "searchProposal" {
+		if proposal, ok := governance.proposals[args[0]]; ok {
+			return shim.Success([]byte(proposal))
+		}
+	}
+	return shim.Error("The proposal id doesn't exists")
+}
+
+func typedVoiceHeader(cli, headerType string) string {
+	return `{"hid":"H` + cli + `","peid":"E1","cname":"OLAPAY","cli":"` + cli + `","ctgr":"8","cts":"1","uts":"1","cmode":"11","htyp":"` + headerType + `","sts":{"AI":"A"}}`
+}
+
+func TestDefaultVoiceHeaderFormats(t *testing.T) {
+	cc, stub := newVoiceHeaderStub(t)
+	var rules []FormatRule
+	if err := json.Unmarshal(stub.invoke(cc, "qhf").Payload, &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(defaultFormatRules) {
+		t.Fatalf("expected the %d default rules, got %+v", len(defaultFormatRules), rules)
+	}
+	tests := []struct {
+		cli        string
+		headerType string
+		valid      bool
+	}{
+		{"1601234567", "T", true},
+		{"1609876543", "SE", true},
+		{"1401234567", "P", true},
+		{"1401234567", "T", false},
+		{"1601234567", "P", false},
+		{"160123456", "SI", false},
+		{"16012345AB", "T", false},
+		{"BLOCKCUBE", "T", false},
+	}
+	for _, test := range tests {
+		res := stub.invoke(cc, "rh", typedVoiceHeader(test.cli, test.headerType))
+		if (res.Status == shim.OK) != test.valid {
+			t.Fatalf("%s %s: expected valid %v, got %d %s", test.headerType, test.cli, test.valid, res.Status, res.Message)
+		}
+	}
+	res := stub.invoke(cc, "rbh", typedVoiceHeader("1602222222", "T"), typedVoiceHeader("1403333333", "SI"))
+	var result struct {
+		Rejected []map[string]interface{} `json:"headerRejected"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0]["Header_Name"] != "1403333333" {
+		t.Fatalf("expected the 140 series SI header rejected, got %+v", result.Rejected)
+	}
+}
+
+func TestSetVoiceHeaderFormat(t *testing.T) {
+	cc, stub := newVoiceHeaderStub(t)
+	stub.MockPeerChaincode(GovernanceChaincode+"/"+GovernanceChannel, shim.NewMockStub(GovernanceChaincode, &fakeGovernance{proposals: map[string]string{
+		"P1": `{"id":"P1","ptyp":"SET_HEADER_FORMAT","sts":"A","payload":{"chnl":"VOICE","htyp":"SE","min":10,"max":10,"charset":"NUMERIC","pfx":["160","161"]}}`,
+		"P2": `{"id":"P2","ptyp":"SET_HEADER_FORMAT","sts":"A","payload":{"chnl":"SMS","htyp":"SE","min":6,"max":6,"charset":"ALPHA"}}`,
+		"P3": `{"id":"P3","ptyp":"SET_HEADER_FORMAT","sts":"A","payload":{"chnl":"VOICE","htyp":"SE","min":10,"max":10,"charset":"NUMERIC","pfx":["16A"]}}`,
+	}}))
+	if res := stub.invoke(cc, "rh", typedVoiceHeader("1611234567", "SE")); res.Status == shim.OK {
+		t.Fatal("CLI out of the default series registered")
+	}
+	for _, proposalID := range []string{"P2", "P3", "P9"} {
+		if res := stub.invoke(cc, "shf", proposalID, "2"); res.Status == shim.OK {
+			t.Fatalf("rule of proposal %s saved", proposalID)
+		}
+	}
+	if res := stub.invoke(cc, "shf", "P1", "2"); res.Status != shim.OK {
+		t.Fatalf("shf failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "shf", "P1", "3"); res.Status == shim.OK {
+		t.Fatal("proposal executed twice")
+	}
+	if res := stub.invoke(cc, "rh", typedVoiceHeader("1611234567", "SE")); res.Status != shim.OK {
+		t.Fatalf("rh failed with the new rule: %s", res.Message)
+	}
+}
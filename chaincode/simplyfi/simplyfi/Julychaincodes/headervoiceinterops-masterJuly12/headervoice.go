@@ -16,9 +16,9 @@ This Chaincode is written for Storing, Retrieving, querying the Header that are
 // ====CHAINCODE EXECUTION SAMPLES (CLI) ================== START
 
 
-// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["rh","{\"hid\":\"QT041111111111111101\",\"peid\":\"A11111111101\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htype\",\"T\"]}"]}'
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["rh","{\"hid\":\"QT041111111111111101\",\"peid\":\"A11111111101\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htyp\":\"T\",\"sts\":{\"OR\":\"A\"}}"]}'
 
-// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["rbh","{\"hid\":\"QT041111111111111102\",\"peid\":\"A11111111101\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE2\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htype\",\"T\"}","{\"hid\":\"QT041111111111111103\",\"peid\":\"A11111111102\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE3\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htype\",\"T\"}"]}'
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["rbh","{\"hid\":\"QT041111111111111102\",\"peid\":\"A11111111101\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE2\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htyp\":\"T\",\"sts\":{\"OR\":\"A\"}}","{\"hid\":\"QT041111111111111103\",\"peid\":\"A11111111102\",\"cname\":\"OLAPAY\",\"cli\":\"BLOCKCUBE3\",\"ctgr\":\"8\",\"cts\":\"456789\",\"uts\":\"456787678\",\"cmode\":\"11\",\"htyp\":\"T\",\"sts\":{\"OR\":\"A\"}}"]}'
 
 // peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["uhs","{\"cli\":\"BLOCKCUBE\",\"sts\":\"I\",\"uts\":\"2345678\"}"]}'
 
@@ -36,6 +36,32 @@ This Chaincode is written for Storing, Retrieving, querying the Header that are
 
 // peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["dbh","BLOCKCUBE1","BLOCKCUBE2"]}'
 
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["sbe","55","2345678"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["rbe","55","2345679"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["qbe","55"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["mhk","500"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["mhs","500"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["qhs","BL0CKCUBE","A11111111102"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["ahr","{\"cli\":\"BL0CKCUBE\",\"sts\":\"A\",\"uts\":\"2345679\"}"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["apb","{\"brand\":\"HDFC\",\"peids\":[\"A11111111101\"],\"uts\":\"2345678\"}"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["dpb","HDFC"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["qpb"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["ihv","500",""]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["shf","P0004","2345678"]}'
+
+// peer chaincode invoke -o <ORDERER_ENDPOINT> -n headervoice -C chheader  -c '{"args":["qhf"]}'
+
 
 
 // ====CHAINCODE EXECUTION SAMPLES (CLI) ================== END
@@ -47,6 +73,7 @@ package main
 
 import (
 	"strings"
+	"sort"
  	"strconv"
 	"encoding/json"
 	"fmt"
@@ -60,6 +87,26 @@ var logger = shim.NewLogger("HEADERVOICE-CHAINCODE-INITIALIZED")
 const EVTRegisterHeader = "EVT_RegisterHeaderVoice"
 const EVTUpdateHeaderStatus = "EVT_UpdateHeaderStatusVoice"
 const EVTDeleteHeader = "EVT_DeleteHeader"
+const EVTSuspendHeader = "EVT_SuspendHeaderVoice"
+const EVTRestoreHeader = "EVT_RestoreHeaderVoice"
+
+// Headers are stored under the composite key {HeaderVoice, cli}
+const HeaderObjType = "HeaderVoice"
+
+// Default number of headers moved to composite keys by one "mhk" invocation
+const MigrationBatchSize = 500
+
+// Format rules ("shf") are changed only for a SET_HEADER_FORMAT proposal approved on the
+// governance chaincode, and only once per proposal
+const GovernanceChaincode = "governance"
+const GovernanceChannel = "entitychannel"
+const GovernanceProposalObjType = "GovernanceProposal"
+
+// "sbe" and "rbe" are accepted only while the blacklist cascade of the entity chaincode
+// has the step of this chaincode (CascadeTargetName) pending
+const EntityChaincode = "entity"
+const EntityChannel = "entitychannel"
+const CascadeTargetName = "HEADERVOICE"
 
 
 type HeaderChainCode struct {
@@ -74,7 +121,7 @@ type Header struct {
     Header_Type		  string `json:"htyp"`  // htyp    : Either T/SE/SI/P : Transactional / Service / Promotional 
     Cname			  string `json:"cname"` // cname   : May have value for voice
     Header_Name		  string `json:"cli"`	// cli     : Unique name to be registeres in DLT
-    Status 			  string `json:"sts"`	// sts     : Either A/I/D
+    Status 			  map[string]string`json:"sts"`	   // sts     : Either A/I : Active /  Inactive Operator wise
     Category          string `json:"ctgr"`  // ctgr    : In betwen 1-8 
     CreatedTs		  string `json:"cts"`   // cts     : Header creation time : Autogenerated in Backend
     UpdatedTs         string `json:"uts"`	// uts     : When the header last updated : Autogenerate in Backend
@@ -82,6 +129,57 @@ type Header struct {
 	UpdatedBy         string `json:"uby"`	// uby     : DLT Node's name
 	TMID 			  string `json:"tmid"`  // tmid    : Details of the RTM who added this header on behalf of Entity
 	CommunicationMode string `json:"cmode"` // cmode   : Communication mode to capture different modes of the Voice.
+	Deleted           bool `json:"del"`     // del     : Header is deleted (or not) across TSP, earlier status "D"
+	Review            *HeaderReview `json:"rvw,omitempty"` // rvw : Similarity review of a header looking alike another header or a protected brand
+}
+
+// UnmarshalJSON - reads the operator wise status as well as the single status of the records
+// not yet converted by migrateHeaderStatus, which is taken as the status of the creator
+func (header *Header) UnmarshalJSON(data []byte) error {
+	type headerRecord Header
+	record := struct {
+		*headerRecord
+		Status json.RawMessage `json:"sts"`
+	}{headerRecord: (*headerRecord)(header)}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	header.Status = nil
+	if len(record.Status) == 0 || string(record.Status) == "null" {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(record.Status, &single); err == nil {
+		status, deleted := legacyStatus(single, header.Creator, header.Creator)
+		header.Status = status
+		header.Deleted = header.Deleted || deleted
+		return nil
+	}
+	return json.Unmarshal(record.Status, &header.Status)
+}
+
+// legacyStatus - operator wise status of a single status record, given to the operator of the
+// creator or else to fallback. The earlier status "D" is a deleted header
+func legacyStatus(single, creator, fallback string) (map[string]string, bool) {
+	operator := fallback
+	if creatorNode, ok := dltDomainNames[creator]; ok {
+		operator = creatorNode
+	}
+	if single == "D" {
+		return map[string]string{operator: "I"}, true
+	}
+	return map[string]string{operator: single}, false
+}
+
+//EntitySuspension keeps the headers made inactive due to blacklisting of the entity,
+//so that only those are restored when the entity is un-blacklisted
+type EntitySuspension struct {
+	ObjType           string   `json:"obj"`   // obj     : EntitySuspension
+	PrincipleEntityId string   `json:"peid"`  // peid    : Entity which is blacklisted
+	Headers           []string `json:"items"` // items   : CLI of the headers suspended
+	Operators         map[string][]string `json:"ops"` // ops : CLI wise operators whose status was set to "I"
+	UpdatedTs         string   `json:"uts"`   // uts     : Suspension time
+	UpdatedBy         string   `json:"uby"`   // uby     : DLT Node's name
 }
 
 
@@ -112,6 +210,11 @@ var validCategory = map[string]bool{
 	"8": true,
 }
 
+var headerStatus = map[string]bool{
+	"A": true,
+	"I": true,
+}
+
 var dltDomainNames = map [string]string {
     "airtel.com" : "AI",                     //Airtel
     "vil.com"    : "VO",                     //"VO" , "ID", "VI"
@@ -132,16 +235,12 @@ func validHeaderEntry(input string, enumMap map[string]bool) bool {
         return true
 }
 
-func isValidHeader(header Header) (bool, string) {
+func isValidHeader(header Header,dltnode string,rules map[string]FormatRule) (bool, string) {
 
 	if len(header.Header_ID) == 0 {
 		return false, "Header_ID is mandatory"
 	}
 
-	if header.Status != "A" || header.Status != "I" {
-		return false, "Status: Enter either A, I"
-	}
-
 	if len(header.CommunicationMode) == 0 {
 		return false, "Communication mode is mandatory"
 	}
@@ -174,7 +273,12 @@ func isValidHeader(header Header) (bool, string) {
         return false, "Invalid Communication Mode"
     }
 
-    if header.Header_Type == "P" {
+	// CLIs follow the format rule of their header type, changed with "shf"
+	if rule, ok := rules[header.Header_Type]; ok {
+		if isValid, errMsg := checkHeaderFormat(rule, header.Header_Name); !isValid {
+			return false, errMsg
+		}
+	} else if header.Header_Type == "P" {
 		if _,err:=strconv.Atoi(header.Header_Name); err!=nil{
     		return false, "CLI is not numeric"
 		} 
@@ -184,6 +288,19 @@ func isValidHeader(header Header) (bool, string) {
 		return false, "Cname is mandatory"
 	} 
 
+	if len(header.Status) == 0 {
+		return false, "Status is mandatory"
+	}
+
+	for srvcPrv, addStatus := range header.Status {
+		if srvcPrv == dltnode  {
+			if !validHeaderEntry(addStatus, headerStatus) {
+			return false, "Status: Enter either A, I" }
+		} else {
+        	return false, "Invalid status update by Operator"
+   		 }	
+	}
+
 	if !validHeaderEntry(header.Category,validCategory){
     return false, "Invalid Category Provided" 
     } 
@@ -223,6 +340,10 @@ func main() {
 // ===================================================================================
 func (t *HeaderChainCode) Init(stub shim.ChaincodeStubInterface) sc.Response {
 	logger.Info("  HEADER CHAINCODE IS INITIALIZED  ")
+	if err := registerDefaultFormatRules(stub); err != nil {
+		logger.Errorf("Init : Unable to save the default format rules : " + err.Error())
+		return shim.Error(err.Error())
+	}
 	return shim.Success(nil)
 }
 
@@ -252,12 +373,38 @@ func (t *HeaderChainCode) Invoke(stub shim.ChaincodeStubInterface) sc.Response {
 		case "ra":
 			return t.reassignHeader(stub,args)				// Reassign header to different entity In case the entity gets deregisterd.
 		case "dhe":
-			return t.deleteHeaderByEntity(stub,args)        // Set status to "D" belongs to that particular entity
+			return t.deleteHeaderByEntity(stub,args)        // Mark the headers of that particular entity deleted
 		case "dbh":
 			return t.deleteBulkHeaders(stub,args)           // Delete headers in Bulk
+		case "sbe":
+			return t.suspendHeadersByEntity(stub,args)      // Set active operator status of headers of a blacklisted entity to "I", remembering the ones changed
+		case "rbe":
+			return t.restoreHeadersByEntity(stub,args)      // Restore the headers suspended by "sbe"
+		case "qbe":
+			return t.queryEntitySuspension(stub,args)       // Query the headers kept suspended by "sbe" for an entity
+		case "mhk":
+			return t.migrateHeaderKeys(stub,args)           // Move headers stored against the raw CLI to composite keys
+		case "mhs":
+			return t.migrateHeaderStatus(stub,args)         // Convert the single status of the headers to operator wise status
+		case "qhs":
+			return t.querySimilarHeaders(stub,args)         // Headers and protected brands looking alike a header name
+		case "ahr":
+			return t.reviewHeader(stub,args)                // Approve / reject a header flagged as similar at registration
+		case "apb":
+			return t.addProtectedBrand(stub,args)           // Add a protected brand with its owning entities
+		case "dpb":
+			return t.deleteProtectedBrand(stub,args)        // Remove a protected brand
+		case "qpb":
+			return t.queryProtectedBrands(stub,args)        // List protected brands
+		case "ihv":
+			return t.indexExistingHeaders(stub,args)        // Index the headers registered before the similarity check
+		case "shf":
+			return t.setHeaderFormat(stub,args)             // Add / replace the format rule of an approved SET_HEADER_FORMAT governance proposal
+		case "qhf":
+			return t.queryHeaderFormats(stub,args)          // Query the format rules of the header types
 		default:
-			logger.Errorf("Received Unknown Function invocation : Available Function : rh , rbh , uhs, qh, hfh, qhwp, ra, dhe, dbh")
-			return shim.Error("Received Unknown Function invocation : Available function : rh , rbh , uhs, qh, hfh, qhwp, ra, dhe, dbh")
+			logger.Errorf("Received Unknown Function invocation : Available Function : rh , rbh , uhs, qh, hfh, qhwp, ra, dhe, dbh, sbe, rbe, qbe, mhk, mhs, qhs, ahr, apb, dpb, qpb, ihv, shf, qhf")
+			return shim.Error("Received Unknown Function invocation : Available function : rh , rbh , uhs, qh, hfh, qhwp, ra, dhe, dbh, sbe, rbe, qbe, mhk, mhs, qhs, ahr, apb, dpb, qpb, ihv, shf, qhf")
 		}
 }
 
@@ -267,6 +414,7 @@ func (t *HeaderChainCode) Invoke(stub shim.ChaincodeStubInterface) sc.Response {
 // ========================================================================================
 func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args []string) sc.Response {
 
+	var dltNode string
 	certData, err := cid.GetX509Certificate(stub)
 	if err != nil {
 		logger.Errorf("setHeader : Getting certificate Details Error : " + string(err.Error()))
@@ -274,9 +422,11 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
 	}
 	
 	Organizations := certData.Issuer.Organization
-	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	if isExists, ok := dltDomainNames[Organizations[0]];!ok{
 	return shim.Error("Unauthorized Node Access")
-    } 
+    } else {
+    	dltNode = isExists
+    }
 
 
 	if len(args) < 1 {
@@ -290,7 +440,12 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
 		return shim.Error("setHeader : Input arguments unmarhsaling Error : " + string(err.Error()))
 	}
 
-	if isValid,errMsg:=isValidHeader(data);!isValid{
+	rules, err := getFormatRules(stub)
+	if err != nil {
+		logger.Errorf("setHeader : Unable to read the format rules : " + err.Error())
+		return shim.Error("setHeader : Unable to read the format rules : " + err.Error())
+	}
+	if isValid,errMsg:=isValidHeader(data,dltNode,rules);!isValid{
 			logger.Errorf("setHeader:"+string(errMsg))
 			return shim.Error(errMsg)
 	}
@@ -306,12 +461,18 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
         return shim.Error(" Header_ID already exist for : " + data.Header_Name + ", Please provide unique hid ")
 	}
 	
-	if recordBytes, _ := stub.GetState(data.Header_Name); len(recordBytes) > 0 {
+	if recordBytes, _ := getHeaderState(stub, data.Header_Name); len(recordBytes) > 0 {
 		return shim.Error("Header already registered. Provide an unique header name")
 	}
+
+	if isValid,errMsg:=applySimilarity(stub, &data, nil);!isValid{
+			logger.Errorf("setHeader:"+string(errMsg))
+			return shim.Error(errMsg)
+	}
 			data.ObjType = "HeaderVoice"
 			data.Creator= Organizations[0]
 			data.UpdatedBy = Organizations[0]
+			data.Deleted = false
 			logger.Infof("Header_ID is " + data.Header_ID)
 			headerAsBytes, err := json.Marshal(data)
 			if err != nil {
@@ -319,11 +480,15 @@ func (t *HeaderChainCode) registerHeader(stub shim.ChaincodeStubInterface, args
 				return shim.Error("setHeader : Marshalling Error : " + string(err.Error()))
 			}
 			//Inserting DataBlock to BlockChain
-			err = stub.PutState(data.Header_Name, headerAsBytes)
+			err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 			if err != nil {
 				logger.Errorf("setHeader : PutState Failed Error : " + string(err.Error()))
 				return shim.Error("setHeader : PutState Failed Error : " + string(err.Error()))
 			}
+			if err := indexHeaderVariants(stub, data.Header_Name); err != nil {
+				logger.Errorf("setHeader : PutState Failed Error : " + string(err.Error()))
+				return shim.Error("setHeader : PutState Failed Error : " + string(err.Error()))
+			}
 			logger.Infof("setHeader : PutState Success : " + string(headerAsBytes))
 
 			err2 := stub.SetEvent(EVTRegisterHeader, headerAsBytes)
@@ -352,6 +517,7 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 	var recordcount int
 	headerRejected := make([]map[string]interface{}, 0)
 	headerRegistered := make([]string, 0)
+	var dltNode string
 	
 	certData, err := cid.GetX509Certificate(stub)
 	if err != nil {
@@ -360,15 +526,22 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 	}
 
 	Organizations := certData.Issuer.Organization
-	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	if isExists, ok := dltDomainNames[Organizations[0]];!ok{
 	return shim.Error("Unauthorized Node Access")
-    } 
+    } else { dltNode = isExists }
 
 	if len(args) < 1 {
 		return shim.Error("Invalid number of arguments provided for transaction")
 	}
 
 	recordcount = 0
+	rules, err := getFormatRules(stub)
+	if err != nil {
+		logger.Errorf("registerBulkHeader : Unable to read the format rules : " + err.Error())
+		return shim.Error("registerBulkHeader : Unable to read the format rules : " + err.Error())
+	}
+	// headers registered by this transaction are not read back by the similarity check of the next ones
+	registered := make([]Header, 0)
 	for i := 0; i < len(args); i++ {
 		var data Header
 		logger.Infof(args[i])
@@ -378,7 +551,7 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 			continue
 		}
 
-		if isValid,errMsg:=isValidHeader(data);!isValid{
+		if isValid,errMsg:=isValidHeader(data,dltNode,rules);!isValid{
 			logger.Errorf("registerBulkHeader:"+string(errMsg))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name, "Value": string(errMsg) })	
 			continue
@@ -396,16 +569,23 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 			continue
 		}
 
-		if recordBytes, _ := stub.GetState(data.Header_Name); len(recordBytes) > 0 {
+		if recordBytes, _ := getHeaderState(stub, data.Header_Name); len(recordBytes) > 0 {
 				logger.Errorf("Header already registered. Provide an unique header name")
 				headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "Header Already registered " })	
 				continue
 		}
 
+		if isValid,errMsg:=applySimilarity(stub, &data, registered);!isValid{
+			logger.Errorf("registerBulkHeader:"+string(errMsg))
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name, "Value": string(errMsg) })	
+			continue
+		}
+
 		recordcount = recordcount + 1
 		data.ObjType = "HeaderVoice"
 		data.Creator= Organizations[0]
 		data.UpdatedBy = Organizations[0]
+		data.Deleted = false
 		logger.Infof("Header_ID is " + data.Header_ID)
 		headerAsBytes, err := json.Marshal(data)
 		if err != nil {
@@ -413,11 +593,16 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 			return shim.Error("registerBulkHeader : Marshalling Error : " + string(err.Error()))
 		}
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(data.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
 			return shim.Error("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
 		}
+		if err := indexHeaderVariants(stub, data.Header_Name); err != nil {
+			logger.Errorf("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("registerBulkHeader : PutState Failed Error : " + string(err.Error()))
+		}
+		registered = append(registered, data)
 		logger.Infof("registerBulkHeader : PutState Success : " + string(headerAsBytes))
 		headerRegistered = append(headerRegistered, data.Header_Name)	 	
 	}	
@@ -436,9 +621,11 @@ func (t *HeaderChainCode) registerBulkHeader(stub shim.ChaincodeStubInterface, a
 
 
 // ========================================================================================
-// updateHeaderStatus - Update header status to A / B / I / D
+// updateHeaderStatus - Update header status of the invoking operator to A / I
+// Deleting the header for all operators is done using "dbh" function.
 // ========================================================================================
 func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, args []string) sc.Response { 
+	var dltNode string
 
 	if len(args) < 1 {
 		return shim.Error("Invalid number of arguments provided for transaction")
@@ -458,13 +645,13 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 	}
 
 	Organizations := certData.Issuer.Organization
-	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	if isExists, ok := dltDomainNames[Organizations[0]];!ok{
 	return shim.Error("Unauthorized Node Access")
-    } 
+    } else { dltNode = isExists }
 
 	if len(data) == 3 {
 
-		RecordAsBytes, err1 := stub.GetState(data["cli"].(string))
+		RecordAsBytes, err1 := getHeaderState(stub, data["cli"].(string))
 		if err1 != nil {
 			logger.Infof(" Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
 			return shim.Error(" Failed to get Header Record " + data["cli"].(string) + " Error : " + string(err.Error()))
@@ -481,21 +668,39 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 		}
 
 
-	    var creatr string
-		var existingUpdatedBy string
-		creatr = Organizations[0]
-		existingUpdatedBy = header.UpdatedBy
+		if header.Deleted == true {
+			logger.Errorf("Header is deleted")
+			return shim.Error("Header is deleted")
+		}
 
-		if strings.Compare(existingUpdatedBy,creatr)==0{
+		if header.Review != nil && header.Review.Status == ReviewPending {
+			logger.Errorf("Header is pending similarity review")
+			return shim.Error("Header is pending similarity review")
+		}
+
+		existingStatus := header.Status
+		if existingStatus == nil {
+			existingStatus = make(map[string]string)
+		}
+
+		if _, ok := existingStatus[dltNode]; !ok {
+		    var status = data["sts"].(string)
+		    if status == "A" || status == "I" {
+		    	existingStatus[dltNode] = status
+		    } else {
+		    	logger.Errorf("Received Unknown Status type || Must provide either A or I")
+			    return shim.Error("Received Unknown Status type || Must provide either A or I")
+		    }
+		} else {
 
 		    switch data["sts"].(string) {
 		case "A": 
-			if header.Status == "I" { header.Status = "A" } else {
+			if existingStatus[dltNode] == "I" { existingStatus[dltNode] = "A" } else {
 				logger.Errorf("Header is already Active")
 			 	return shim.Error("Header is already Active")
 			}
 		case "I":
-			if header.Status == "A" { header.Status = "I" } else {
+			if existingStatus[dltNode] == "A" { existingStatus[dltNode] = "I" } else {
 			 	logger.Errorf("Header is already Inactive")
 			 	return shim.Error("Header is already Inactive")
 			 }
@@ -504,6 +709,9 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 			return shim.Error("Received Unknown Status type || Must provide either A or I")
 		}
 
+		}
+
+		header.Status = existingStatus
 		header.UpdatedTs = data["uts"].(string)
 		header.UpdatedBy = Organizations[0]
 		logger.Infof("Header_Name is " + header.Header_Name)
@@ -513,7 +721,7 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 			return shim.Error("updateHeaderStatus : Marshalling Error : " + string(err.Error()))
 		}
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(header.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, header.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("updateHeaderStatus : PutState Failed Error : " + string(err.Error()))
 			return shim.Error("updateHeaderStatus : PutState Failed Error : " + string(err.Error()))
@@ -525,10 +733,6 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 			logger.Errorf("Event not generated for event : EVTUpdateHeaderStatus")
 			return shim.Error("Event not generated for event : EVTUpdateHeaderStatus")
 		}
-			 } else {
-	    	logger.Infof("Unauthorize operator trying to update the header")
-	    	return shim.Error("Unauthorize operator trying to update the header")
-	    }
 
 	} else {
 		logger.Errorf("updateHeaderStatus : Incorrect Number Of Arguments, i.e. CLI, Status, UpdatedTs expected")
@@ -550,6 +754,7 @@ func (t *HeaderChainCode) updateHeaderStatus(stub shim.ChaincodeStubInterface, a
 // ========================================================================================
 func (t *HeaderChainCode) reassignHeader(stub shim.ChaincodeStubInterface, args []string) sc.Response {
 
+	var dltNode string
 	var data map[string]interface{}
 	HeaderStruct:=&Header{}
 	err := json.Unmarshal([]byte(args[0]), &data)
@@ -573,12 +778,12 @@ func (t *HeaderChainCode) reassignHeader(stub shim.ChaincodeStubInterface, args
 	}
 
 	Organizations := certData.Issuer.Organization
-	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	if isExists, ok := dltDomainNames[Organizations[0]];!ok{
 	return shim.Error("Unauthorized Node Access")
-    } 
+    } else { dltNode = isExists }
 
 
-	RecordAsBytes, err := stub.GetState(data["cli"].(string))
+	RecordAsBytes, err := getHeaderState(stub, data["cli"].(string))
 	if err != nil {
 		logger.Infof(" Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
 		return shim.Error(" Failed to get Header Record " + data["cli"].(string) + " Error : " + string(err.Error()))
@@ -610,11 +815,12 @@ func (t *HeaderChainCode) reassignHeader(stub shim.ChaincodeStubInterface, args
 			HeaderStruct.Cname = data["cname"].(string)
 			HeaderStruct.Header_Name = header.Header_Name
 
-			if header.Status == "D" {
-					HeaderStruct.Status = "A" 
+			if header.Deleted == true {
+					HeaderStruct.Status = map[string]string{dltNode: "A"}
+					HeaderStruct.Deleted = false
 			} else {
-				logger.Errorf("Header is still Active/Inactive with peid : " + header.PrincipleEntityId + "  Please set header status to Delete before reassigning")
-				return shim.Error("Header is still Active/Inactive with peid : " + header.PrincipleEntityId + " Please set header status to Delete before reassigning")
+				logger.Errorf("Header is still Active/Inactive with peid : " + header.PrincipleEntityId + "  Please delete the header before reassigning")
+				return shim.Error("Header is still Active/Inactive with peid : " + header.PrincipleEntityId + " Please delete the header before reassigning")
 			}
 
 			HeaderStruct.Category = data["ctgr"].(string)
@@ -629,7 +835,7 @@ func (t *HeaderChainCode) reassignHeader(stub shim.ChaincodeStubInterface, args
 				return shim.Error("reassignHeader : Marshalling Error : " + string(err.Error()))
 			}
 
-			err = stub.PutState(HeaderStruct.Header_Name, headerAsBytes)
+			err = stub.PutState(getHeaderKey(stub, HeaderStruct.Header_Name), headerAsBytes)
 			if err != nil {
 				logger.Errorf("reassignHeader : PutState Failed Error : " + string(err.Error()))
 				return shim.Error("reassignHeader : PutState Failed Error : " + string(err.Error()))
@@ -675,7 +881,7 @@ func (t *HeaderChainCode) queryHeader(stub shim.ChaincodeStubInterface, args []s
 	}
 
 	for i:=0; i<len(args); i++ {
-		valAsBytes, err := stub.GetState(args[i]) //get the record from chaincode state
+		valAsBytes, err := getHeaderState(stub, args[i]) //get the record from chaincode state
 
 		if err != nil {
 			logger.Infof("Failed to get state for Header_Name " + args[i] )
@@ -782,35 +988,41 @@ func (t *HeaderChainCode) getHistoryForHeader(stub shim.ChaincodeStubInterface,
 		return shim.Error("getHistoryForHeader : Input arguments unmarhsaling Error : " + string(err.Error()))
 	}
 
-	RecordAsBytes, err := stub.GetState(data["cli"].(string))
+	cli := data["cli"].(string)
+	headerKey := getHeaderKey(stub, cli)
+	RecordAsBytes, err := getHeaderState(stub, cli)
 	if err != nil {
-		logger.Infof(" Failed to get Header Record : " + data["cli"].(string) + " Error : " + string(err.Error()))
-		return shim.Error(" Failed to get Header Record " + data["cli"].(string) + " Error : " + string(err.Error()))
+		logger.Infof(" Failed to get Header Record : " + cli + " Error : " + string(err.Error()))
+		return shim.Error(" Failed to get Header Record " + cli + " Error : " + string(err.Error()))
 	} else if RecordAsBytes == nil {
-		fmt.Println(" This record does not exists  " + data["cli"].(string))
-		return shim.Error(" This record does not exists " + data["cli"].(string))
+		fmt.Println(" This record does not exists  " + cli)
+		return shim.Error(" This record does not exists " + cli)
 	}
 
-	historyIer, err := stub.GetHistoryForKey(data["cli"].(string))
+	// Modifications made before "mhk" moved the header are recorded against the raw CLI key
+	historicResponse := make([]map[string]interface{}, 0)
+	for _, key := range []string{cli, headerKey} {
+		historyIer, err := stub.GetHistoryForKey(key)
+		if err != nil {
+			fmt.Println(err.Error())
+			return shim.Error(err.Error())
+		}
 
-	if err != nil {
-	    fmt.Println(err.Error())
-	    return shim.Error(err.Error())
-	}
+		for historyIer.HasNext() {
+			modification, err := historyIer.Next()
+			if err != nil {
+				historyIer.Close()
+				fmt.Println(err.Error())
+				return shim.Error(err.Error())
+			}
 
-	historicResponse := make([]map[string]interface{}, 0)
-	for historyIer.HasNext() {
-	    modification, err := historyIer.Next()
-	    if err != nil {
-	        fmt.Println(err.Error())
-	        return shim.Error(err.Error())
+			value := make(map[string]interface{})
+			json.Unmarshal(modification.Value, &value)
+			historicResponse = append(historicResponse, map[string]interface{}{"txId": modification.TxId, "value": value})
+		}
+		historyIer.Close()
 	}
 
-		value := make(map[string]interface{})
-		json.Unmarshal(modification.Value, &value)
-		historicResponse = append(historicResponse, map[string]interface{}{"txId": modification.TxId, "value": value})
-	} 
-
 	respJSON, _ := json.Marshal(historicResponse)
 	return shim.Success(respJSON)
 }
@@ -867,7 +1079,7 @@ func (t *HeaderChainCode) queryHeaderWithPagination(stub shim.ChaincodeStubInter
 
 
 // ===========================================================================================
-// deleteHeadersByEntity -  To mark all headers to D, which are not marked as D against a entity ID.
+// deleteHeadersByEntity -  To mark all headers deleted, which are not deleted against a entity ID.
 // ===========================================================================================
 func (t *HeaderChainCode) deleteHeaderByEntity(stub shim.ChaincodeStubInterface, args []string) sc.Response {
 
@@ -908,8 +1120,8 @@ func (t *HeaderChainCode) deleteHeaderByEntity(stub shim.ChaincodeStubInterface,
 
 		hName := headerData[i].Header_Name
 
-		if headerData[i].Status != "D"  {
-			headerData[i].Status = "D"
+		if headerData[i].Deleted == false  {
+			headerData[i].Deleted = true
 		} else {
 			logger.Errorf("deleteHeadersByEntity : Already Deleted  ")
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Already Deleted " })	
@@ -924,7 +1136,7 @@ func (t *HeaderChainCode) deleteHeaderByEntity(stub shim.ChaincodeStubInterface,
 		}
 
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(hName, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, hName), headerAsBytes)
 		if err != nil {
 			logger.Errorf("deleteHeadersByEntity : PutState Failed Error : " + string(err.Error()))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "PutState Failed Error" })	
@@ -957,7 +1169,7 @@ func (t *HeaderChainCode) deleteHeaderByEntity(stub shim.ChaincodeStubInterface,
 
 
 // ===========================================================================================
-// deleteBulkHeaders - input, Headers list. Mark all headers to D, which are not marked as D.
+// deleteBulkHeaders - input, Headers list. Mark all headers deleted, which are not deleted.
 // ===========================================================================================
 func (t *HeaderChainCode)  deleteBulkHeaders(stub shim.ChaincodeStubInterface, args []string) sc.Response {
 
@@ -978,7 +1190,7 @@ func (t *HeaderChainCode)  deleteBulkHeaders(stub shim.ChaincodeStubInterface, a
 	Organizations := certData.Issuer.Organization
 
 	for i:=0; i<len(args); i++ {
-		valAsBytes, err := stub.GetState(args[i]) //get the record from chaincode state
+		valAsBytes, err := getHeaderState(stub, args[i]) //get the record from chaincode state
 		if err != nil {
 			logger.Infof("Failed to get state for Header_Name " + args[i] )
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": args[i] , "Value": "Failed to get state for Header" })	
@@ -1005,8 +1217,8 @@ func (t *HeaderChainCode)  deleteBulkHeaders(stub shim.ChaincodeStubInterface, a
 
 		if strings.Compare(existingUpdatedBy,creatr)==0{
 
-		if data.Status != "D"  {
-			data.Status = "D"
+		if data.Deleted == false  {
+			data.Deleted = true
 		} else {
 			logger.Errorf("deleteBulkHeaders : Already Deleted  ")
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "Already Deleted " })	
@@ -1022,7 +1234,7 @@ func (t *HeaderChainCode)  deleteBulkHeaders(stub shim.ChaincodeStubInterface, a
 		}
 
 		//Inserting DataBlock to BlockChain
-		err = stub.PutState(data.Header_Name, headerAsBytes)
+		err = stub.PutState(getHeaderKey(stub, data.Header_Name), headerAsBytes)
 		if err != nil {
 			logger.Errorf("deleteBulkHeaders : PutState Failed Error : " + string(err.Error()))
 			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": data.Header_Name , "Value": "PutState Failed Error" })	
@@ -1061,6 +1273,532 @@ func (t *HeaderChainCode)  deleteBulkHeaders(stub shim.ChaincodeStubInterface, a
 }
 
 
+// ===========================================================================================
+// suspendHeadersByEntity - Called on blacklisting of an entity. Sets the active operator status
+// of all the headers of the entity to "I" and keeps their CLI and operators against the entity,
+// so that "rbe" restores exactly these. Input : peid, uts
+// ===========================================================================================
+func (t *HeaderChainCode) suspendHeadersByEntity(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid and uts expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "SUSPEND"); err != nil {
+		logger.Errorf("suspendHeadersByEntity : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Composite key Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Composite key Error : " + string(err.Error()))
+	}
+	if recordBytes, _ := stub.GetState(suspensionKey); len(recordBytes) > 0 {
+		logger.Errorf("suspendHeadersByEntity : Headers are already suspended for PEID : " + peid)
+		return shim.Error("suspendHeadersByEntity : Headers are already suspended for PEID : " + peid)
+	}
+
+	headerSearch := `{
+		"obj":"HeaderVoice",
+		"peid":"%s"
+	}`
+	headerData := t.retriveHeaderRecords(stub, fmt.Sprintf(headerSearch, peid), "headerSearchByPeid")
+
+	headerSuspended := make([]string, 0)
+	operatorSuspended := make(map[string][]string)
+	for i:=0; i<len(headerData); i++ {
+		if headerData[i].Deleted == true {
+			continue
+		}
+		operators := make([]string, 0)
+		for operator, status := range headerData[i].Status {
+			if status == "A" {
+				headerData[i].Status[operator] = "I"
+				operators = append(operators, operator)
+			}
+		}
+		if len(operators) == 0 {
+			continue
+		}
+		sort.Strings(operators)
+		headerData[i].UpdatedTs = args[1]
+		headerData[i].UpdatedBy = Organizations[0]
+		headerAsBytes, err := json.Marshal(headerData[i])
+		if err != nil {
+			logger.Errorf("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(getHeaderKey(stub, headerData[i].Header_Name), headerAsBytes)
+		if err != nil {
+			logger.Errorf("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		}
+		headerSuspended = append(headerSuspended, headerData[i].Header_Name)
+		operatorSuspended[headerData[i].Header_Name] = operators
+	}
+
+	suspension := EntitySuspension{ObjType: "EntitySuspension", PrincipleEntityId: peid, Headers: headerSuspended, Operators: operatorSuspended, UpdatedTs: args[1], UpdatedBy: Organizations[0]}
+	suspensionAsBytes, err := json.Marshal(suspension)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : Marshalling Error : " + string(err.Error()))
+	}
+	err = stub.PutState(suspensionKey, suspensionAsBytes)
+	if err != nil {
+		logger.Errorf("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("suspendHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+	}
+
+	err2 := stub.SetEvent(EVTSuspendHeader, suspensionAsBytes)
+	if err2 != nil {
+		logger.Errorf("Event not generated for event : EVTSuspendHeader")
+		return shim.Error("Event not generated for event : EVTSuspendHeader")
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"items": headerSuspended,
+	"message" : "Suspended all active headers against PEID : " +peid ,
+	"countSuccess":  strconv.Itoa(len(headerSuspended)),
+	"status": "true",
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// ===========================================================================================
+// restoreHeadersByEntity - Called on un-blacklisting of an entity. Sets the operator status of
+// the headers suspended by "sbe" for the entity back to "A". Input : peid, uts
+// ===========================================================================================
+func (t *HeaderChainCode) restoreHeadersByEntity(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 2 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid and uts expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "RESTORE"); err != nil {
+		logger.Errorf("restoreHeadersByEntity : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Composite key Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Composite key Error : " + string(err.Error()))
+	}
+	suspensionAsBytes, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : GetState Failed Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : GetState Failed Error : " + string(err.Error()))
+	} else if suspensionAsBytes == nil {
+		// entities blacklisted before sbe kept the suspended headers have nothing to restore
+		logger.Infof("restoreHeadersByEntity : No suspended headers for PEID : " + peid)
+		resultData := map[string]interface{} {
+		"trxnID":   stub.GetTxID(),
+		"items": make([]string, 0),
+		"headerRejected": make([]map[string]interface{}, 0),
+		"message" : "No suspended headers against PEID : " +peid ,
+		"countSuccess":  "0",
+		"status": "true",
+		}
+		respJSON, _ := json.Marshal(resultData)
+		return shim.Success(respJSON)
+	}
+
+	suspension := EntitySuspension{}
+	err = json.Unmarshal(suspensionAsBytes, &suspension)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : Unmarhsaling Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : Unmarhsaling Error : " + string(err.Error()))
+	}
+
+	headerRestored := make([]string, 0)
+	headerRejected := make([]map[string]interface{}, 0)
+	for _, hName := range suspension.Headers {
+		valAsBytes, err := getHeaderState(stub, hName)
+		if err != nil || valAsBytes == nil {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Record does not exist for Header" })
+			continue
+		}
+		var header Header
+		err = json.Unmarshal(valAsBytes, &header)
+		if err != nil {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Unmarhsaling Error" })
+			continue
+		}
+		// suspensions recorded before operator wise status restore every inactive operator
+		operators, ok := suspension.Operators[hName]
+		if !ok {
+			for operator, status := range header.Status {
+				if status == "I" {
+					operators = append(operators, operator)
+				}
+			}
+		}
+		// header may have been deleted, reassigned or activated in the meantime
+		restored := false
+		for _, operator := range operators {
+			if header.Status[operator] == "I" {
+				header.Status[operator] = "A"
+				restored = true
+			}
+		}
+		if header.PrincipleEntityId != peid || header.Deleted == true || !restored {
+			headerRejected = append(headerRejected, map[string]interface{}{"Header_Name": hName , "Value": "Header changed after suspension" })
+			continue
+		}
+		header.UpdatedTs = args[1]
+		header.UpdatedBy = Organizations[0]
+		headerAsBytes, err := json.Marshal(header)
+		if err != nil {
+			logger.Errorf("restoreHeadersByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("restoreHeadersByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(getHeaderKey(stub, hName), headerAsBytes)
+		if err != nil {
+			logger.Errorf("restoreHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("restoreHeadersByEntity : PutState Failed Error : " + string(err.Error()))
+		}
+		headerRestored = append(headerRestored, hName)
+	}
+
+	err = stub.DelState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreHeadersByEntity : DelState Failed Error : " + string(err.Error()))
+		return shim.Error("restoreHeadersByEntity : DelState Failed Error : " + string(err.Error()))
+	}
+
+	suspension.Headers = headerRestored
+	suspension.UpdatedTs = args[1]
+	suspension.UpdatedBy = Organizations[0]
+	eventAsBytes, _ := json.Marshal(suspension)
+	err2 := stub.SetEvent(EVTRestoreHeader, eventAsBytes)
+	if err2 != nil {
+		logger.Errorf("Event not generated for event : EVTRestoreHeader")
+		return shim.Error("Event not generated for event : EVTRestoreHeader")
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"items": headerRestored,
+	"headerRejected": headerRejected,
+	"message" : "Restored suspended headers against PEID : " +peid ,
+	"countSuccess":  strconv.Itoa(len(headerRestored)),
+	"status": "true",
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+
+// ===========================================================================================
+// checkEntityCascade - Returns an error unless the blacklist cascade of the entity, read from
+// the entity chaincode, has the given action (SUSPEND / RESTORE) pending for this chaincode
+// ===========================================================================================
+func checkEntityCascade(stub shim.ChaincodeStubInterface, peid string, action string) error {
+	ccArgs := [][]byte{[]byte("getBlacklistCascade"), []byte(peid)}
+	response := stub.InvokeChaincode(EntityChaincode, ccArgs, EntityChannel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("No blacklist cascade for PEID %s : %s", peid, response.Message)
+	}
+	cascade := struct {
+		Action string `json:"action"`
+		Steps  map[string]struct {
+			Status string `json:"sts"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &cascade); err != nil {
+		return fmt.Errorf("Invalid blacklist cascade for PEID %s", peid)
+	}
+	if cascade.Action != action || cascade.Steps[CascadeTargetName].Status != "P" {
+		return fmt.Errorf("No %s of PEID %s pending for %s", action, peid, CascadeTargetName)
+	}
+	return nil
+}
+
+
+// ===========================================================================================
+// queryEntitySuspension - Returns the CLI of the headers kept suspended by "sbe" for the
+// entity, read by the entity chaincode to confirm its blacklist cascade. Input : peid
+// ===========================================================================================
+func (t *HeaderChainCode) queryEntitySuspension(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	if len(args) < 1 {
+		return shim.Error("Invalid number of arguments provided for transaction, peid expected")
+	}
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{args[0]})
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Composite key Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Composite key Error : " + string(err.Error()))
+	}
+	suspensionAsBytes, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : GetState Failed Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : GetState Failed Error : " + string(err.Error()))
+	}
+	suspension := EntitySuspension{Headers: make([]string, 0)}
+	if suspensionAsBytes != nil {
+		err = json.Unmarshal(suspensionAsBytes, &suspension)
+		if err != nil {
+			logger.Errorf("queryEntitySuspension : Unmarhsaling Error : " + string(err.Error()))
+			return shim.Error("queryEntitySuspension : Unmarhsaling Error : " + string(err.Error()))
+		}
+	}
+
+	resultData := map[string]interface{} {
+	"peid": args[0],
+	"suspended": suspensionAsBytes != nil,
+	"items": suspension.Headers,
+	}
+
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// ===========================================================================================
+// migrateHeaderKeys - Moves the headers stored against the raw CLI to their composite key.
+// Range query over the simple keys leaves out the composite keys, so every invocation moves
+// the next batch. A header already written to its composite key is kept and the raw CLI
+// record is only deleted (superseded). Invoke until done is true. Input : batch size (optional)
+// ===========================================================================================
+func (t *HeaderChainCode) migrateHeaderKeys(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("migrateHeaderKeys : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderKeys : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    }
+
+	batchSize := MigrationBatchSize
+	if len(args) > 0 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("migrateHeaderKeys : Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+
+	resultsIterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		logger.Errorf("migrateHeaderKeys : GetStateByRange Failed Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderKeys : GetStateByRange Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	migrated := make([]string, 0)
+	superseded := make([]string, 0)
+	skipped := make([]string, 0)
+	for len(migrated)+len(superseded) < batchSize && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("migrateHeaderKeys : Iterator Error : " + string(err.Error()))
+		}
+		// only the doc type is read, so that headers with single status are moved as well
+		header := struct {
+			ObjType string `json:"obj"`
+		}{}
+		if err := json.Unmarshal(queryResponse.Value, &header); err != nil || header.ObjType != HeaderObjType {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		headerKey := getHeaderKey(stub, queryResponse.Key)
+		if headerKey == "" {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		// a header written to its composite key since the upgrade is newer than the raw CLI record
+		existing, err := stub.GetState(headerKey)
+		if err != nil {
+			logger.Errorf("migrateHeaderKeys : GetState Failed Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderKeys : GetState Failed Error : " + string(err.Error()))
+		}
+		if len(existing) == 0 {
+			if err := stub.PutState(headerKey, queryResponse.Value); err != nil {
+				logger.Errorf("migrateHeaderKeys : PutState Failed Error : " + string(err.Error()))
+				return shim.Error("migrateHeaderKeys : PutState Failed Error : " + string(err.Error()))
+			}
+		}
+		if err := stub.DelState(queryResponse.Key); err != nil {
+			logger.Errorf("migrateHeaderKeys : DelState Failed Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderKeys : DelState Failed Error : " + string(err.Error()))
+		}
+		if len(existing) == 0 {
+			migrated = append(migrated, queryResponse.Key)
+		} else {
+			superseded = append(superseded, queryResponse.Key)
+		}
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"migrated": migrated,
+	"superseded": superseded,
+	"skipped": skipped,
+	"countSuccess":  strconv.Itoa(len(migrated)),
+	"done": len(migrated)+len(superseded) < batchSize,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// ===========================================================================================
+// migrateHeaderStatus - Converts the single status "sts" of the headers stored before operator
+// wise status to a map against the operator of the creator node. Status "D" is kept as deleted.
+// Converted headers are left as they are, so invoke until done is true, after "mhk" is done.
+// Input : batch size (optional)
+// ===========================================================================================
+func (t *HeaderChainCode) migrateHeaderStatus(stub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+	var dltNode string
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("migrateHeaderStatus : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderStatus : Getting certificate Details Error : " + string(err.Error()))
+	}
+
+	Organizations := certData.Issuer.Organization
+	if isExists, ok := dltDomainNames[Organizations[0]];!ok{
+	return shim.Error("Unauthorized Node Access")
+    } else { dltNode = isExists }
+
+	batchSize := MigrationBatchSize
+	if len(args) > 0 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("migrateHeaderStatus : Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(HeaderObjType, []string{})
+	if err != nil {
+		logger.Errorf("migrateHeaderStatus : GetStateByPartialCompositeKey Failed Error : " + string(err.Error()))
+		return shim.Error("migrateHeaderStatus : GetStateByPartialCompositeKey Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	migrated := make([]string, 0)
+	superseded := make([]string, 0)
+	skipped := make([]string, 0)
+	for len(migrated)+len(superseded) < batchSize && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("migrateHeaderStatus : Iterator Error : " + string(err.Error()))
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		status, isSingle := record["sts"].(string)
+		if !isSingle {
+			continue
+		}
+
+		creator, _ := record["crtr"].(string)
+		converted, deleted := legacyStatus(status, creator, dltNode)
+		if deleted {
+			record["del"] = true
+		}
+		record["sts"] = converted
+
+		var header Header
+		recordAsBytes, _ := json.Marshal(record)
+		if err := json.Unmarshal(recordAsBytes, &header); err != nil {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		headerAsBytes, err := json.Marshal(header)
+		if err != nil {
+			logger.Errorf("migrateHeaderStatus : Marshalling Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderStatus : Marshalling Error : " + string(err.Error()))
+		}
+		if err := stub.PutState(queryResponse.Key, headerAsBytes); err != nil {
+			logger.Errorf("migrateHeaderStatus : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("migrateHeaderStatus : PutState Failed Error : " + string(err.Error()))
+		}
+		migrated = append(migrated, header.Header_Name)
+	}
+
+	resultData := map[string]interface{} {
+	"trxnID":   stub.GetTxID(),
+	"migrated": migrated,
+	"superseded": superseded,
+	"skipped": skipped,
+	"countSuccess":  strconv.Itoa(len(migrated)),
+	"done": len(migrated)+len(superseded) < batchSize,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+
+// getHeaderKey - composite key of the header record for a CLI, empty if it can not be created
+func getHeaderKey(stub shim.ChaincodeStubInterface, cli string) string {
+	headerKey, err := stub.CreateCompositeKey(HeaderObjType, []string{cli})
+	if err != nil {
+		logger.Errorf("getHeaderKey : CreateCompositeKey Failed Error : " + string(err.Error()))
+		return ""
+	}
+	return headerKey
+}
+
+// getHeaderState - header record of a CLI, read from its composite key or, until migrateHeaderKeys
+// has moved it, from the raw CLI
+func getHeaderState(stub shim.ChaincodeStubInterface, cli string) ([]byte, error) {
+	valAsBytes, err := stub.GetState(getHeaderKey(stub, cli))
+	if err != nil || len(valAsBytes) > 0 {
+		return valAsBytes, err
+	}
+	return stub.GetState(cli)
+}
+
 func (t *HeaderChainCode) retriveHeaderRecords(stub shim.ChaincodeStubInterface, criteria string, indexs ...string) []Header {
     
 	var finalSelector string
@@ -1074,15 +1812,34 @@ func (t *HeaderChainCode) retriveHeaderRecords(stub shim.ChaincodeStubInterface,
 	}
 
 	logger.Infof("Query Selector : %s", finalSelector)
-	resultsIterator, _ := stub.GetQueryResult(finalSelector)
+	resultsIterator, err := stub.GetQueryResult(finalSelector)
+	if err != nil {
+		logger.Errorf("retriveHeaderRecords : GetQueryResult Failed Error : " + string(err.Error()))
+		return records
+	}
+	defer resultsIterator.Close()
+	// until migrateHeaderKeys is done a header written since the upgrade is found at the raw CLI
+	// as well as at its composite key, the latter being the current one
+	position := make(map[string]int)
 	for resultsIterator.HasNext() {
 		record := Header{}
-		recordBytes, _ := resultsIterator.Next()
-		err := json.Unmarshal(recordBytes.Value, &record)
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			logger.Errorf("retriveHeaderRecords : Iterator Error : " + string(err.Error()))
+			return records
+		}
+		err = json.Unmarshal(recordBytes.Value, &record)
 		if err != nil {
-			logger.Infof("Unable to unmarshal Header retrived:: %v", err)
+			logger.Errorf("Unable to unmarshal Header retrived:: %v", err)
+			continue
+		}
+		i, found := position[record.Header_Name]
+		if !found {
+			position[record.Header_Name] = len(records)
+			records = append(records, record)
+		} else if len(recordBytes.Key) > 0 && recordBytes.Key[0] == 0 {
+			records[i] = record
 		}
-		records = append(records, record)
 	}
 	return records
 }
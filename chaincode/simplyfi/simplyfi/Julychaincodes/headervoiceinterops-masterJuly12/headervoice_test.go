@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//fakeEntity answers getBlacklistCascade of the entity chaincode with the given action and
+//status of the HEADERVOICE step
+type fakeEntity struct {
+	action string
+	status string
+}
+
+func (entity *fakeEntity) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (entity *fakeEntity) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if entity.action == "" {
+		return shim.Error("No cascade exists for the EntityID")
+	}
+	cascade := map[string]interface{}{"id": "E1", "action": entity.action, "steps": map[string]interface{}{CascadeTargetName: map[string]string{"sts": entity.status}}}
+	payload, _ := json.Marshal(cascade)
+	return shim.Success(payload)
+}
+
+func newCascadeStub(t *testing.T, cc *HeaderChainCode) (*testStub, *fakeEntity) {
+	stub := newTestStub(t, "headervoice", cc, "airtel.com")
+	entity := new(fakeEntity)
+	stub.MockPeerChaincode(EntityChaincode+"/"+EntityChannel, shim.NewMockStub(EntityChaincode, entity))
+	return stub, entity
+}
+
+//voiceHeader is a header record with the given status, a string for the records written
+//before the status was kept per operator
+func voiceHeader(cli, peid string, status interface{}) map[string]interface{} {
+	return map[string]interface{}{"obj": "HeaderVoice", "hid": "H" + cli, "peid": peid, "htyp": "T", "cli": cli, "ctgr": "8", "cmode": "11", "cts": "1", "uts": "1", "crtr": "airtel.com", "sts": status}
+}
+
+func headerKey(t *testing.T, stub *testStub, cli string) string {
+	key, err := stub.CreateCompositeKey(HeaderObjType, []string{cli})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHeaderUnmarshalStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  interface{}
+		want    map[string]string
+		deleted bool
+	}{
+		{"operator wise", map[string]string{"AI": "A", "JI": "I"}, map[string]string{"AI": "A", "JI": "I"}, false},
+		{"single active", "A", map[string]string{"AI": "A"}, false},
+		{"single inactive", "I", map[string]string{"AI": "I"}, false},
+		{"single deleted", "D", map[string]string{"AI": "I"}, true},
+		{"missing", nil, nil, false},
+	}
+	for _, test := range tests {
+		recordAsBytes, _ := json.Marshal(voiceHeader("CLI1", "E1", test.status))
+		var header Header
+		if err := json.Unmarshal(recordAsBytes, &header); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(header.Status, test.want) || header.Deleted != test.deleted {
+			t.Fatalf("%s: expected %v deleted %v, got %v deleted %v", test.name, test.want, test.deleted, header.Status, header.Deleted)
+		}
+		if header.Header_Name != "CLI1" || header.Creator != "airtel.com" || header.CommunicationMode != "11" {
+			t.Fatalf("%s: other fields not read: %+v", test.name, header)
+		}
+	}
+}
+
+func TestSuspendAndRestoreLegacyHeader(t *testing.T) {
+	cc := new(HeaderChainCode)
+	stub, entity := newCascadeStub(t, cc)
+	stub.put(t, headerKey(t, stub, "CLI1"), voiceHeader("CLI1", "E1", "A"))
+	stub.put(t, headerKey(t, stub, "CLI2"), voiceHeader("CLI2", "E1", map[string]string{"AI": "A", "JI": "A"}))
+
+	*entity = fakeEntity{action: "SUSPEND", status: "P"}
+	if res := stub.invoke(cc, "sbe", "E1", "2"); res.Status != shim.OK {
+		t.Fatalf("sbe failed: %s", res.Message)
+	}
+	var header Header
+	stub.get(t, headerKey(t, stub, "CLI1"), &header)
+	if !reflect.DeepEqual(header.Status, map[string]string{"AI": "I"}) {
+		t.Fatalf("expected the legacy header suspended, got %v", header.Status)
+	}
+	stub.get(t, headerKey(t, stub, "CLI2"), &header)
+	if !reflect.DeepEqual(header.Status, map[string]string{"AI": "I", "JI": "I"}) {
+		t.Fatalf("expected the header suspended for every operator, got %v", header.Status)
+	}
+
+	*entity = fakeEntity{action: "RESTORE", status: "P"}
+	if res := stub.invoke(cc, "rbe", "E1", "3"); res.Status != shim.OK {
+		t.Fatalf("rbe failed: %s", res.Message)
+	}
+	stub.get(t, headerKey(t, stub, "CLI1"), &header)
+	if !reflect.DeepEqual(header.Status, map[string]string{"AI": "A"}) {
+		t.Fatalf("expected the legacy header restored, got %v", header.Status)
+	}
+}
+
+func TestSuspendRequiresPendingCascade(t *testing.T) {
+	cc := new(HeaderChainCode)
+	stub, entity := newCascadeStub(t, cc)
+	stub.put(t, headerKey(t, stub, "CLI1"), voiceHeader("CLI1", "E1", "A"))
+
+	for _, state := range []fakeEntity{{}, {action: "RESTORE", status: "P"}, {action: "SUSPEND", status: "C"}} {
+		*entity = state
+		if res := stub.invoke(cc, "sbe", "E1", "2"); res.Status == shim.OK {
+			t.Fatalf("sbe accepted with cascade %+v", state)
+		}
+	}
+	*entity = fakeEntity{action: "SUSPEND", status: "P"}
+	if res := stub.invoke(cc, "rbe", "E1", "2"); res.Status == shim.OK {
+		t.Fatal("rbe accepted for a pending SUSPEND")
+	}
+
+	res := stub.invoke(cc, "qbe", "E1")
+	if res.Status != shim.OK || string(res.Payload) != `{"items":[],"peid":"E1","suspended":false}` {
+		t.Fatalf("expected nothing suspended, got %d %s %s", res.Status, res.Message, res.Payload)
+	}
+	if res := stub.invoke(cc, "sbe", "E1", "2"); res.Status != shim.OK {
+		t.Fatalf("sbe failed: %s", res.Message)
+	}
+	res = stub.invoke(cc, "qbe", "E1")
+	if res.Status != shim.OK || string(res.Payload) != `{"items":["CLI1"],"peid":"E1","suspended":true}` {
+		t.Fatalf("expected CLI1 suspended, got %d %s %s", res.Status, res.Message, res.Payload)
+	}
+}
+
+func TestDeleteLegacyHeaderKeepsStatus(t *testing.T) {
+	cc := new(HeaderChainCode)
+	stub := newTestStub(t, "headervoice", cc, "airtel.com")
+	stub.put(t, headerKey(t, stub, "CLI1"), voiceHeader("CLI1", "E1", "A"))
+
+	if res := stub.invoke(cc, "dhe", "E1"); res.Status != shim.OK {
+		t.Fatalf("dhe failed: %s", res.Message)
+	}
+	var header Header
+	stub.get(t, headerKey(t, stub, "CLI1"), &header)
+	if !header.Deleted || !reflect.DeepEqual(header.Status, map[string]string{"AI": "A"}) {
+		t.Fatalf("expected the header deleted with its status kept, got %v deleted %v", header.Status, header.Deleted)
+	}
+}
+
+func TestMigrateHeaderStatus(t *testing.T) {
+	cc := new(HeaderChainCode)
+	stub := newTestStub(t, "headervoice", cc, "jio.com")
+	stub.put(t, headerKey(t, stub, "CLI1"), voiceHeader("CLI1", "E1", "D"))
+	stub.put(t, headerKey(t, stub, "CLI2"), voiceHeader("CLI2", "E1", map[string]string{"JI": "A"}))
+
+	res := stub.invoke(cc, "mhs")
+	if res.Status != shim.OK {
+		t.Fatalf("mhs failed: %s", res.Message)
+	}
+	result := struct {
+		Migrated []string `json:"migrated"`
+		Done     bool     `json:"done"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Migrated, []string{"CLI1"}) || !result.Done {
+		t.Fatalf("expected CLI1 migrated and done, got %s", res.Payload)
+	}
+	record := make(map[string]interface{})
+	stub.get(t, headerKey(t, stub, "CLI1"), &record)
+	if !reflect.DeepEqual(record["sts"], map[string]interface{}{"AI": "I"}) || record["del"] != true {
+		t.Fatalf("expected the status of the creator stored per operator, got %v", record)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func newVoiceHeaderStub(t *testing.T) (*HeaderChainCode, *testStub) {
+	cc := new(HeaderChainCode)
+	stub := newTestStub(t, "headervoice", cc, "airtel.com")
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	return cc, stub
+}
+
+// allowAlphanumericHeaders replaces the 160 series rule of the T headers, so that named CLIs
+// can be checked by the similarity tests
+func allowAlphanumericHeaders(t *testing.T, stub *testStub) {
+	key, err := getFormatRuleKey(stub, "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.put(t, key, FormatRule{ObjType: FormatRuleObjType, Channel: FormatChannel, Type: "T", MinLength: 6, MaxLength: 10, Charset: CharsetAlphanumeric, UpdatedTs: "1"})
+}
+
+func voiceHeaderJSON(cli, peid string) string {
+	return fmt.Sprintf(`{"hid":"H%s","peid":"%s","cname":"OLAPAY","cli":"%s","ctgr":"8","cts":"1","uts":"1","cmode":"11","htyp":"T","sts":{"AI":"A"}}`, cli, peid, cli)
+}
+
+func getTestHeader(t *testing.T, stub *testStub, cli string) *Header {
+	header, err := getHeader(stub, cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestSimilarVoiceHeaderRegistration(t *testing.T) {
+	cc, stub := newVoiceHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	if res := stub.invoke(cc, "rh", voiceHeaderJSON("OLACAB", "E1")); res.Status != shim.OK {
+		t.Fatalf("rh failed: %s", res.Message)
+	}
+	//0 reads as O, the same name as OLACAB
+	if res := stub.invoke(cc, "rh", voiceHeaderJSON("0LACAB", "E2")); res.Status == shim.OK {
+		t.Fatal("look-alike header of another entity registered")
+	}
+	if res := stub.invoke(cc, "rh", voiceHeaderJSON("OLACAR", "E2")); res.Status != shim.OK {
+		t.Fatalf("rh failed: %s", res.Message)
+	}
+	header := getTestHeader(t, stub, "OLACAR")
+	if header.Review == nil || header.Review.Status != ReviewPending || header.Status["AI"] != "I" {
+		t.Fatalf("expected OLACAR inactive pending review, got %+v %+v", header.Status, header.Review)
+	}
+	if res := stub.invoke(cc, "uhs", `{"cli":"OLACAR","sts":"A","uts":"2"}`); res.Status == shim.OK {
+		t.Fatal("status of a header pending review changed")
+	}
+	if res := stub.invoke(cc, "ahr", `{"cli":"OLACAR","sts":"A","uts":"2"}`); res.Status == shim.OK {
+		t.Fatal("header reviewed by the operator which registered it")
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "ahr", `{"cli":"OLACAR","sts":"R","uts":"2"}`); res.Status != shim.OK {
+		t.Fatalf("ahr failed: %s", res.Message)
+	}
+	//a rejected voice header is kept deleted and no longer compared
+	if header := getTestHeader(t, stub, "OLACAR"); header == nil || !header.Deleted {
+		t.Fatalf("expected OLACAR deleted once rejected, got %+v", header)
+	}
+	res := stub.invoke(cc, "qhs", "OLACAR", "E4")
+	var result struct {
+		Decision string          `json:"decision"`
+		Matches  []SimilarHeader `json:"matches"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Name != "OLACAB" {
+		t.Fatalf("expected OLACAR matched against OLACAB only, got %+v", result.Matches)
+	}
+}
+
+func TestBulkSimilarVoiceHeaders(t *testing.T) {
+	cc, stub := newVoiceHeaderStub(t)
+	allowAlphanumericHeaders(t, stub)
+	res := stub.invoke(cc, "rbh", voiceHeaderJSON("SWIGGYX", "E5"), voiceHeaderJSON("5WIGGYX", "E6"), voiceHeaderJSON("SWIGGYZ", "E6"))
+	if res.Status != shim.OK {
+		t.Fatalf("rbh failed: %s", res.Message)
+	}
+	if header := getTestHeader(t, stub, "5WIGGYX"); header != nil {
+		t.Fatalf("look-alike header of the same batch registered: %+v", header)
+	}
+	header := getTestHeader(t, stub, "SWIGGYZ")
+	if header == nil || header.Review == nil || header.Review.Status != ReviewPending {
+		t.Fatalf("expected SWIGGYZ pending review against the batch, got %+v", header)
+	}
+}
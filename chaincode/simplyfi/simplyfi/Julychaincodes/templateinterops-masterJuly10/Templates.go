@@ -7,6 +7,7 @@ package main
 import (
 	"encoding/json" //reading and writing JSON
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"             // import for Chaincode Interface
@@ -20,6 +21,8 @@ var logger = shim.NewLogger("CONTENT-TEMPLATES-CHAINCODE")
 //Event Names
 const EVTADDTEMPLATE = "ADD-TEMPLATE"
 const EVTUPDTEMPLATE = "UPDATE-TEMPLATE"
+const EVTSUSPENDTEMPLATE = "SUSPEND-TEMPLATE"
+const EVTRESTORETEMPLATE = "RESTORE-TEMPLATE"
 
 //Output Structure for the output response
 type Output struct {
@@ -91,9 +94,6 @@ var dltDomainNames = map[string]string{
 	"qtl.infotelconnect.com": "QL", //QTL
 	"tata.com":               "TA", //TATA
 	"jio.com":                "JI", //JIO
-	"org1":"Org1",
-	"org2":"Org2",
-
 }
 var statusForAllDomain = map[string]string{
 	"AI": "A",
@@ -103,8 +103,6 @@ var statusForAllDomain = map[string]string{
 	"QL": "A",
 	"TA": "A",
 	"JI": "A",
-	"Org1":"A",
-	"Org2":"A",
 }
 
 func validEnumEntry(input string, enumMap map[string]bool) bool {
@@ -121,25 +119,47 @@ type TemplateMgmtChaincode struct {
 //=========================================================================================================
 // Template structure, with 13 properties.  Structure tags are used by encoding/json library
 //=========================================================================================================
+
 type Template struct {
-	ObjType             string            `json:"obj"`
-	TemplateID          string            `json:"urn"`
-	PEID                string            `json:"peid"`
-	CLI                 []string          `json:"cli"`
-	TemplateName        string            `json:"tname"`
-	TemplateType        string            `json:"ttyp"`
-	CommunicationType   string            `json:"ctyp"`
-	ConsentTemplateType string            `json:"csty"`
-	Contenttype         string            `json:"coty"`
-	NoOfVariables       string            `json:"vars"`
-	Category            string            `json:"ctgr"`
-	TempContent         string            `json:"tcont"`
-	TMID                string            `json:"tmid"`
-	Creator             string            `json:"crtr"`
-	CreateTs            string            `json:"cts"`
-	UpdatedBy           string            `json:"uby"`
-	UpdateTs            string            `json:"uts"`
-	Status              map[string]string `json:"sts"`
+	ObjType             string             `json:"obj"`
+	TemplateID          string             `json:"urn"`
+	PEID                string             `json:"peid"`
+	CLI                 []string           `json:"cli"`
+	TemplateName        string             `json:"tname"`
+	TemplateType        string             `json:"ttyp"`
+	CommunicationType   string             `json:"ctyp"`
+	ConsentTemplateType string             `json:"csty"`
+	Contenttype         string             `json:"coty"`
+	NoOfVariables       string             `json:"vars"`
+	Category            string             `json:"ctgr"`
+	TempContent         string             `json:"tcont"`
+	TMID                string             `json:"tmid"`
+	Creator             string             `json:"crtr"`
+	CreateTs            string             `json:"cts"`
+	UpdatedBy           string             `json:"uby"`
+	UpdateTs            string             `json:"uts"`
+	Status              map[string]string  `json:"sts"`            //operator wise A, I, P pending approval or R rejected
+	Reasons             map[string]string  `json:"rsn,omitempty"`  //operator wise reason code of the status
+	LastChange          *StatusChange      `json:"chg,omitempty"`  //last status change, see templateapproval.go
+	Version             int                `json:"ver"`            //version of the content, see templateversion.go
+	PendingVersion      int                `json:"pver,omitempty"` //version pending approval
+	VariableLengths     []int              `json:"vlen,omitempty"` //maximum length of the variables, in order
+	MessageLength       int                `json:"mlen,omitempty"` //SMS length with the variables at maximum length
+	Segments            int                `json:"seg,omitempty"`  //SMS parts
+	Variables           []TemplateVariable `json:"vdef,omitempty"` //type and maximum length of the variables, in order
+	Audio               *TemplateAudio     `json:"aud,omitempty"`  //recording of a voice template
+}
+
+//=========================================================================================================
+// EntitySuspension keeps the templates and operators for which the template was made inactive due to
+// blacklisting of the entity, so that only those are restored when the entity is un-blacklisted
+//=========================================================================================================
+type EntitySuspension struct {
+	ObjType   string              `json:"obj"`
+	PEID      string              `json:"peid"`
+	Templates map[string][]string `json:"tmpl"` //urn wise operators whose status was changed from A to I
+	UpdatedBy string              `json:"uby"`
+	UpdateTs  string              `json:"uts"`
 }
 
 //=========================================================================================================
@@ -148,6 +168,14 @@ type Template struct {
 //=========================================================================================================
 
 func (c *TemplateMgmtChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	if err := registerDefaultSegmentConfig(stub); err != nil {
+		logger.Errorf("Init : Unable to register the segment limits : " + string(err.Error()))
+		return shim.Error("Init : Unable to register the segment limits : " + string(err.Error()))
+	}
+	if err := registerDefaultCategoryRules(stub); err != nil {
+		logger.Errorf("Init : Unable to register the category rules : " + string(err.Error()))
+		return shim.Error("Init : Unable to register the category rules : " + string(err.Error()))
+	}
 	logger.Info("###### Templates-Chaincode is Initialized #######")
 	return shim.Success(nil)
 }
@@ -173,9 +201,47 @@ func (dlt *TemplateMgmtChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Re
 		return dlt.queryTemplatesWithPagination(stub, args)
 	case "gt": //get Template data based on TemplateID
 		return dlt.getTemplateByTemplateID(stub, args)
+	case "sbe": //suspend Templates of a blacklisted entity
+		return dlt.suspendTemplatesByEntity(stub, args)
+	case "rbe": //restore Templates suspended by sbe
+		return dlt.restoreTemplatesByEntity(stub, args)
+	case "qbe": //query the Templates kept suspended by sbe for an entity
+		return dlt.queryEntitySuspension(stub, args)
+	case "mt": //request a revision of the content of a Template
+		return dlt.modifyTemplate(stub, args)
+	case "atv": //approve or reject a pending version of a Template
+		return dlt.approveTemplateVersion(stub, args)
+	case "tv": //query the versions of a Template
+		return dlt.queryTemplateVersions(stub, args)
+	case "qtc": //query the clusters of similar Templates of an entity
+		return dlt.queryTemplateClusters(stub, args)
+	case "itc": //index the content of Templates registered before duplicate detection
+		return dlt.indexTemplates(stub, args)
+	case "ite": //index all the Templates registered before duplicate detection, a batch at a time
+		return dlt.indexExistingTemplates(stub, args)
+	case "ssc": //set the SMS segment limits
+		return dlt.setSegmentConfig(stub, args)
+	case "qsc": //query the segment limits or the parts of a content
+		return dlt.querySegments(stub, args)
+	case "vtm": //verify a message against the content and variables of a Template
+		return dlt.verifyTemplateMessage(stub, args)
+	case "swd": //add or remove a domain allowed in URL variables
+		return dlt.setWhitelistedDomain(stub, args)
+	case "qwd": //query the domains allowed in URL variables
+		return dlt.queryWhitelistedDomains(stub, args)
+	case "vta": //verify a played recording against a voice Template
+		return dlt.verifyTemplateAudio(stub, args)
+	case "ato": //approve or reject a Template for the invoking operator
+		return dlt.approveTemplate(stub, args)
+	case "qpt": //query the Templates pending approval of the invoking operator
+		return dlt.queryPendingTemplates(stub, args)
+	case "scr": //set the category rule of a communication type
+		return dlt.setCategoryRule(stub, args)
+	case "qcr": //query the category matrix
+		return dlt.queryCategoryRules(stub, args)
 	default:
-		logger.Errorf("Unknown Function Invoked, Available Function argument shall be any one of : st,abt,dt,qt,th,qtp")
-		return shim.Error("Available Functions: st,abt,dt,qt,th,qtp")
+		logger.Errorf("Unknown Function Invoked, Available Function argument shall be any one of : st,abt,dt,qt,th,qtp,gt,sbe,rbe,qbe,mt,atv,tv,qtc,itc,ite,ssc,qsc,vtm,swd,qwd,vta,ato,qpt,scr,qcr")
+		return shim.Error("Available Functions: st,abt,dt,qt,th,qtp,gt,sbe,rbe,qbe,mt,atv,tv,qtc,itc,ite,ssc,qsc,vtm,swd,qwd,vta,ato,qpt,scr,qcr")
 	}
 }
 
@@ -321,6 +387,59 @@ func (dlt *TemplateMgmtChaincode) setTemplate(stub shim.ChaincodeStubInterface,
 		return shim.Error(jsonResp)
 	}
 
+	//headers have to be compatible with the communication type and category of the template
+	var category string
+	if data["ttyp"].(string) == "CTSMS" || data["ttyp"].(string) == "CTVOICE" {
+		category = data["ctgr"].(string)
+	}
+	if errMsg := checkTemplateHeaders(stub, data["ttyp"].(string), data["ctyp"].(string), category, cli); len(errMsg) > 0 {
+		jsonResp = "{\"Error\":\"" + errMsg + "\"}"
+		logger.Errorf("setTemplate:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+
+	//same content is rejected for the entity, similar content is returned
+	similar, errMsg := checkTemplateDuplicates(stub, data["peid"].(string), data["urn"].(string), data["tcont"].(string))
+	if len(errMsg) > 0 {
+		jsonResp = "{\"Error\":\"" + errMsg + "\"}"
+		logger.Errorf("setTemplate:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+
+	//SMS content has to be of its encoding and within the segment limit
+	variableLengths, errMsg := parseVariableLengths(data["vlen"])
+	noOfVariables, _ := data["vars"].(string)
+	variables, vdefErr := parseVariables(data["vdef"], data["tcont"].(string), noOfVariables)
+	if len(errMsg) == 0 {
+		errMsg = vdefErr
+	}
+	if len(variables) > 0 {
+		variableLengths = variableLengthsOf(variables)
+	}
+	var contenttype string
+	if data["ttyp"].(string) == "CTSMS" || data["ttyp"].(string) == "CTVOICE" {
+		contenttype = data["coty"].(string)
+	}
+	if len(errMsg) == 0 && !validEnumEntry(contenttype, contentType) && len(contenttype) > 0 {
+		errMsg = "Please enter one of these value for coty 'T' or 'U'"
+	}
+	msgLength, segments, segmentErr := checkTemplateSegments(stub, data["ttyp"].(string), contenttype, data["tcont"].(string), variableLengths)
+	if len(errMsg) == 0 {
+		errMsg = segmentErr
+	}
+	audio, audioErr := parseTemplateAudio(data["aud"], data["ttyp"].(string))
+	if len(errMsg) == 0 {
+		errMsg = audioErr
+	}
+	if len(errMsg) == 0 {
+		errMsg = checkAudioDuplicates(stub, data["peid"].(string), data["urn"].(string), audio)
+	}
+	if len(errMsg) > 0 {
+		jsonResp = "{\"Error\":\"" + errMsg + "\"}"
+		logger.Errorf("setTemplate:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+
 	Organizations := certData.Issuer.Organization
 
 	//check template is already exist with same templateid
@@ -369,7 +488,13 @@ func (dlt *TemplateMgmtChaincode) setTemplate(stub shim.ChaincodeStubInterface,
 		TemplateStruct.CreateTs = data["cts"].(string)
 		TemplateStruct.UpdatedBy = Organizations[0]
 		TemplateStruct.UpdateTs = data["uts"].(string)
-		TemplateStruct.Status = statusForAllDomain
+		TemplateStruct.Status = newTemplateStatus(dltDomainNames[Organizations[0]])
+		TemplateStruct.Version = 1
+		TemplateStruct.VariableLengths = variableLengths
+		TemplateStruct.MessageLength = msgLength
+		TemplateStruct.Segments = segments
+		TemplateStruct.Variables = variables
+		TemplateStruct.Audio = audio
 		logger.Infof("TemplateID " + TemplateStruct.TemplateID + "Template peid " + TemplateStruct.PEID + "Template Name" + TemplateStruct.TemplateName)
 		TemplateAsBytes, err := json.Marshal(TemplateStruct)
 		if err != nil {
@@ -383,6 +508,14 @@ func (dlt *TemplateMgmtChaincode) setTemplate(stub shim.ChaincodeStubInterface,
 			return shim.Error("setTemplate : PutState Failed Error : " + string(err.Error()))
 		}
 		logger.Infof("setTemplate : PutState Success : " + string(TemplateAsBytes))
+		err = indexTemplateContent(stub, TemplateStruct.PEID, TemplateStruct.TemplateID, TemplateStruct.TempContent)
+		if err == nil {
+			err = indexTemplateAudio(stub, TemplateStruct.TemplateID, TemplateStruct.Audio)
+		}
+		if err != nil {
+			logger.Errorf("setTemplate : Template Index Error : " + string(err.Error()))
+			return shim.Error("setTemplate : Template Index Error : " + string(err.Error()))
+		}
 		//Txid := stub.GetTxID()
 		eventbytes := Event{Data: string(TemplateAsBytes), Txid: stub.GetTxID()}
 		payload, err := json.Marshal(eventbytes)
@@ -403,6 +536,8 @@ func (dlt *TemplateMgmtChaincode) setTemplate(stub shim.ChaincodeStubInterface,
 			"PEID":         data["peid"].(string),
 			"TemplateID":   data["urn"].(string),
 			"TemplateName": data["tname"].(string),
+			"similar":      similar,
+			"seg":          segments,
 			"message":      "Template created successfully",
 			"TxnStatus":    "true"}
 		respJSON, _ := json.Marshal(resultData)
@@ -435,8 +570,10 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 	} else {
 		dltNode = isExists
 	}
-	var s = make(map[string]string)
-	s[dltNode] = "A"
+	//templates of the batch are not read back within the transaction, their fingerprints are kept here
+	batchFingerprints := make(map[string]string)
+	batchAudio := make(map[string]string)
+	similar_urn := make(map[string][]SimilarTemplate)
 	for i := 0; i < len(args); i++ {
 		var data map[string]interface{}
 		logger.Infof(args[i])
@@ -616,6 +753,62 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 			continue
 		}
 
+		var category string
+		if data["ttyp"].(string) == "CTSMS" || data["ttyp"].(string) == "CTVOICE" {
+			category = data["ctgr"].(string)
+		}
+		if errMsg := checkTemplateHeaders(stub, data["ttyp"].(string), data["ctyp"].(string), category, cli); len(errMsg) > 0 {
+			logger.Errorf("batchTemplates:" + errMsg)
+			failed_urn = append(failed_urn, data["urn"].(string))
+			failed_urnerr = append(failed_urnerr, errMsg)
+			continue
+		}
+
+		fingerprint := data["peid"].(string) + ":" + contentFingerprint(data["tcont"].(string))
+		similar, errMsg := checkTemplateDuplicates(stub, data["peid"].(string), data["urn"].(string), data["tcont"].(string))
+		if duplicate, ok := batchFingerprints[fingerprint]; ok && len(errMsg) == 0 {
+			errMsg = "Template content is the same as of TemplateID " + duplicate
+		}
+		variableLengths, vlenErr := parseVariableLengths(data["vlen"])
+		noOfVariables, _ := data["vars"].(string)
+		variables, vdefErr := parseVariables(data["vdef"], data["tcont"].(string), noOfVariables)
+		if len(vlenErr) == 0 {
+			vlenErr = vdefErr
+		}
+		if len(variables) > 0 {
+			variableLengths = variableLengthsOf(variables)
+		}
+		var contenttype string
+		if data["ttyp"].(string) == "CTSMS" || data["ttyp"].(string) == "CTVOICE" {
+			contenttype = data["coty"].(string)
+		}
+		if len(errMsg) == 0 && len(vlenErr) > 0 {
+			errMsg = vlenErr
+		}
+		if len(errMsg) == 0 && !validEnumEntry(contenttype, contentType) && len(contenttype) > 0 {
+			errMsg = "Please enter one of these value for coty 'T' or 'U'"
+		}
+		msgLength, segments, segmentErr := checkTemplateSegments(stub, data["ttyp"].(string), contenttype, data["tcont"].(string), variableLengths)
+		if len(errMsg) == 0 {
+			errMsg = segmentErr
+		}
+		audio, audioErr := parseTemplateAudio(data["aud"], data["ttyp"].(string))
+		if len(errMsg) == 0 {
+			errMsg = audioErr
+		}
+		if len(errMsg) == 0 {
+			errMsg = checkAudioDuplicates(stub, data["peid"].(string), data["urn"].(string), audio)
+		}
+		if duplicate, ok := batchAudio[data["peid"].(string)+":"+audioHash(audio)]; ok && audio != nil && len(errMsg) == 0 {
+			errMsg = "Template recording is the same as of TemplateID " + duplicate
+		}
+		if len(errMsg) > 0 {
+			logger.Errorf("batchTemplates:" + errMsg)
+			failed_urn = append(failed_urn, data["urn"].(string))
+			failed_urnerr = append(failed_urnerr, errMsg)
+			continue
+		}
+
 		value, err := stub.GetState(data["urn"].(string))
 		if err != nil {
 			logger.Errorf("batchTemplates : GetState Failed for Template : " + data["urn"].(string) + " , Error : " + string(err.Error()))
@@ -663,7 +856,13 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 			TemplateStruct.CreateTs = data["cts"].(string)
 			TemplateStruct.UpdatedBy = Organizations[0]
 			TemplateStruct.UpdateTs = data["uts"].(string)
-			TemplateStruct.Status = statusForAllDomain
+			TemplateStruct.Status = newTemplateStatus(dltNode)
+			TemplateStruct.Version = 1
+			TemplateStruct.VariableLengths = variableLengths
+			TemplateStruct.MessageLength = msgLength
+			TemplateStruct.Segments = segments
+			TemplateStruct.Variables = variables
+			TemplateStruct.Audio = audio
 
 			logger.Infof("Template is " + TemplateStruct.PEID + "-" + TemplateStruct.TemplateName)
 			TemplateAsBytes, err := json.Marshal(TemplateStruct)
@@ -682,6 +881,21 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 				continue
 			}
 			logger.Infof("batchTemplates : PutState Success : " + string(TemplateAsBytes))
+			err = indexTemplateContent(stub, TemplateStruct.PEID, TemplateStruct.TemplateID, TemplateStruct.TempContent)
+			if err == nil {
+				err = indexTemplateAudio(stub, TemplateStruct.TemplateID, TemplateStruct.Audio)
+			}
+			if err != nil {
+				logger.Errorf("batchTemplates : Template Index Error : " + string(err.Error()))
+				return shim.Error("batchTemplates : Template Index Error : " + string(err.Error()))
+			}
+			batchFingerprints[fingerprint] = TemplateStruct.TemplateID
+			if audio != nil {
+				batchAudio[TemplateStruct.PEID+":"+audio.Hash] = TemplateStruct.TemplateID
+			}
+			if len(similar) > 0 {
+				similar_urn[TemplateStruct.TemplateID] = similar
+			}
 			eventbytes := Event{Data: string(TemplateAsBytes), Txid: stub.GetTxID()}
 			payload, err := json.Marshal(eventbytes)
 			if err != nil {
@@ -704,6 +918,7 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 		"trxnid":        stub.GetTxID(),
 		"failed_urn":    failed_urn,
 		"failed_urnerr": failed_urnerr,
+		"similar_urn":   similar_urn,
 		"message":       "Add Batch Template Success",
 	}
 	respJson, _ := json.Marshal(resultData)
@@ -716,9 +931,18 @@ func (dlt *TemplateMgmtChaincode) addBatchTemplates(stub shim.ChaincodeStubInter
 
 func (dlt *TemplateMgmtChaincode) updateTemplateStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	var jsonResp string
-	if len(args) != 3 {
-		logger.Errorf("updateTemplateStatus : Incorrect Number Of Arguments: TemplateID, Status and update timestamp are Expected.")
-		return shim.Error("updateTemplateStatus : Incorrect Number Of Arguments: TemplateID, Status and update timestamp are Expected.")
+	if len(args) != 3 && len(args) != 4 {
+		logger.Errorf("updateTemplateStatus : Incorrect Number Of Arguments: TemplateID, Status, update timestamp and optionally Reason are Expected.")
+		return shim.Error("updateTemplateStatus : Incorrect Number Of Arguments: TemplateID, Status, update timestamp and optionally Reason are Expected.")
+	}
+	var reason string
+	if len(args) == 4 {
+		reason = args[3]
+	}
+	if !validReason(reason, false) {
+		jsonResp = "{\"Error\":\"Please enter a reason code, one of CNT, HDR, VAR, DUP, SPM, ENT, REQ, OTH\"}"
+		logger.Errorf("updateTemplateStatus : " + string(jsonResp))
+		return shim.Error(jsonResp)
 	}
 
 	if _, err := strconv.Atoi(args[0]); err != nil {
@@ -772,9 +996,23 @@ func (dlt *TemplateMgmtChaincode) updateTemplateStatus(stub shim.ChaincodeStubIn
 
 		var existingStatus = make(map[string]string)
 		existingStatus = Template.Status
+		previousStatus := existingStatus[dltNode]
+		if previousStatus == TemplatePending || previousStatus == TemplateRejected {
+			logger.Errorf("Template is not approved, it has to be reviewed with ato")
+			return shim.Error("Template is not approved, it has to be reviewed with ato")
+		}
 
 		switch args[1] {
 		case "A":
+			if suspended, err := isEntitySuspended(stub, Template.PEID); err != nil || suspended {
+				logger.Errorf("updateTemplateStatus : Templates of the entity are suspended, PEID : " + Template.PEID)
+				return shim.Error("updateTemplateStatus : Templates of the entity are suspended, PEID : " + Template.PEID)
+			}
+			//headers may have changed type or category since the template was registered
+			if errMsg := checkTemplateHeaders(stub, Template.TemplateType, Template.CommunicationType, Template.Category, Template.CLI); len(errMsg) > 0 {
+				logger.Errorf("updateTemplateStatus : " + errMsg)
+				return shim.Error(errMsg)
+			}
 			if existingStatus[dltNode] == "I" {
 				existingStatus[dltNode] = "A"
 			} else {
@@ -794,6 +1032,7 @@ func (dlt *TemplateMgmtChaincode) updateTemplateStatus(stub shim.ChaincodeStubIn
 		}
 
 		Template.Status = existingStatus
+		setStatusChange(&Template, []string{dltNode}, previousStatus, args[1], reason, organizationName, args[2])
 
 		Template.UpdatedBy = organizationName
 		Template.UpdateTs = args[2]
@@ -877,11 +1116,12 @@ func (dlt *TemplateMgmtChaincode) queryTemplates(stub shim.ChaincodeStubInterfac
 }
 
 //========================================================================================
-//getTemplateByTemplateID for Getting template data based on templateid
+//getTemplateByTemplateID for Getting template data based on templateid, with the effective version of the
+//content. When a version is given, that TemplateVersion with its approvals is returned as version
 //=======================================================================================-
 func (dlt *TemplateMgmtChaincode) getTemplateByTemplateID(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	var jsonResp string
-	if len(args) != 1 {
+	if len(args) != 1 && len(args) != 2 {
 		logger.Errorf("getTemplateByTemplateID:Invalid Number of arguments are provided for transaction")
 		jsonResp = "{\"Error\":\"Invalid number of arguments are provided for transaction\"}"
 		return shim.Error(jsonResp)
@@ -904,6 +1144,34 @@ func (dlt *TemplateMgmtChaincode) getTemplateByTemplateID(stub shim.ChaincodeStu
 			jsonResp = "{\"Error\":\"Existing Template unmarshalling Error-\"" + string(err.Error()) + "\"}"
 			return shim.Error(jsonResp)
 		}
+		if len(args) == 2 && len(args[1]) > 0 {
+			version, err := strconv.Atoi(args[1])
+			if err != nil {
+				jsonResp = "{\"Error\":\"Version is not numeric\"}"
+				return shim.Error(jsonResp)
+			}
+			templateVersion, err := getTemplateVersion(stub, args[0], version)
+			if err != nil {
+				jsonResp = "{\"Error\":\"GetState is Failed with error- " + string(err.Error()) + "\"}"
+				return shim.Error(jsonResp)
+			}
+			//the effective version of a template never modified is only in the template record
+			if templateVersion == nil && version == effectiveVersion(template) {
+				effective := versionOfTemplate(template)
+				templateVersion = &effective
+			}
+			if templateVersion == nil {
+				jsonResp = "{\"Error\":\"No Version " + args[1] + " for TemplateID- " + string(args[0]) + "\"}"
+				return shim.Error(jsonResp)
+			}
+			resultData := map[string]interface{}{
+				"status":    "true",
+				"templates": template,
+				"version":   templateVersion,
+			}
+			respJson, _ := json.Marshal(resultData)
+			return shim.Success(respJson)
+		}
 		records = append(records, template)
 		resultData := map[string]interface{}{
 			"status":    "true",
@@ -1009,6 +1277,333 @@ func (dlt *TemplateMgmtChaincode) queryTemplatesWithPagination(stub shim.Chainco
 	return shim.Success(respJson)
 }
 
+//=============================================================================================================
+//checkEntityCascade returns an error unless the blacklist cascade of the entity, read from the entity chaincode,
+//has the given action (SUSPEND / RESTORE) pending for this chaincode
+//==============================================================================================================
+func checkEntityCascade(stub shim.ChaincodeStubInterface, peid string, action string) error {
+	ccArgs := [][]byte{[]byte("getBlacklistCascade"), []byte(peid)}
+	response := stub.InvokeChaincode(EntityChaincode, ccArgs, EntityChannel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("No blacklist cascade for PEID %s : %s", peid, response.Message)
+	}
+	cascade := struct {
+		Action string `json:"action"`
+		Steps  map[string]struct {
+			Status string `json:"sts"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &cascade); err != nil {
+		return fmt.Errorf("Invalid blacklist cascade for PEID %s", peid)
+	}
+	if cascade.Action != action || cascade.Steps[CascadeTargetName].Status != "P" {
+		return fmt.Errorf("No %s of PEID %s pending for %s", action, peid, CascadeTargetName)
+	}
+	return nil
+}
+
+//=============================================================================================================
+//isEntitySuspended returns true while the templates of the entity are suspended by sbe. No template of the
+//entity, pending ones included, is activated for an operator in the meantime.
+//==============================================================================================================
+func isEntitySuspended(stub shim.ChaincodeStubInterface, peid string) (bool, error) {
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		return false, err
+	}
+	value, err := stub.GetState(suspensionKey)
+	if err != nil {
+		return false, err
+	}
+	return value != nil, nil
+}
+
+//=============================================================================================================
+//queryEntitySuspension returns the urn of the templates kept suspended by sbe for the entity, read by the entity
+//chaincode to confirm its blacklist cascade.
+//args: peid
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) queryEntitySuspension(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		logger.Errorf("queryEntitySuspension : Incorrect Number Of Arguments: PEID is Expected.")
+		return shim.Error("queryEntitySuspension : Incorrect Number Of Arguments: PEID is Expected.")
+	}
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Getting certificate Details Error : " + string(err.Error()))
+	}
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]]; !ok {
+		return shim.Error("Unauthorized  Access")
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{args[0]})
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : Composite Key Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : Composite Key Error : " + string(err.Error()))
+	}
+	value, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("queryEntitySuspension : GetState Failed for PEID : " + args[0] + " , Error : " + string(err.Error()))
+		return shim.Error("queryEntitySuspension : GetState Failed for PEID : " + args[0] + " , Error : " + string(err.Error()))
+	}
+	items := make([]string, 0)
+	if value != nil {
+		suspension := EntitySuspension{}
+		if err := json.Unmarshal(value, &suspension); err != nil {
+			logger.Errorf("queryEntitySuspension : Unmarshaling Error : " + string(err.Error()))
+			return shim.Error("queryEntitySuspension : Unmarshaling Error : " + string(err.Error()))
+		}
+		for urn := range suspension.Templates {
+			items = append(items, urn)
+		}
+		sort.Strings(items)
+	}
+	resultData := map[string]interface{}{
+		"peid":      args[0],
+		"suspended": value != nil,
+		"items":     items}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//=============================================================================================================
+//suspendTemplatesByEntity is called on blacklisting of an entity. Sets the operator wise status of all the
+//templates of the entity from A to I and keeps the changed urn and operators, so that rbe restores exactly those.
+//args: peid, update timestamp
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) suspendTemplatesByEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		logger.Errorf("suspendTemplatesByEntity : Incorrect Number Of Arguments: PEID and update timestamp are Expected.")
+		return shim.Error("suspendTemplatesByEntity : Incorrect Number Of Arguments: PEID and update timestamp are Expected.")
+	}
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("suspendTemplatesByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]]; !ok {
+		return shim.Error("Unauthorized  Access")
+	}
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "SUSPEND"); err != nil {
+		logger.Errorf("suspendTemplatesByEntity : " + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("suspendTemplatesByEntity : Composite Key Error : " + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : Composite Key Error : " + string(err.Error()))
+	}
+	if value, _ := stub.GetState(suspensionKey); len(value) > 0 {
+		logger.Errorf("suspendTemplatesByEntity : Templates are already suspended for PEID : " + peid)
+		return shim.Error("suspendTemplatesByEntity : Templates are already suspended for PEID : " + peid)
+	}
+
+	queryString := fmt.Sprintf("{\"selector\":{\"obj\":{\"$in\":[\"Templates\",\"ContentTemplates\"]},\"peid\":\"%s\"}, \"use_index\":\"templateSearchBypeid\"}", peid)
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		logger.Errorf("suspendTemplatesByEntity : GetQueryResult is Failed with error :" + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : GetQueryResult is Failed with error :" + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	suspended := make(map[string][]string)
+	items := make([]string, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			logger.Errorf("suspendTemplatesByEntity : Iterator Error : " + string(err.Error()))
+			return shim.Error("suspendTemplatesByEntity : Iterator Error : " + string(err.Error()))
+		}
+		template := Template{}
+		err = json.Unmarshal(recordBytes.Value, &template)
+		if err != nil {
+			logger.Errorf("suspendTemplatesByEntity : Unmarshaling Error : " + string(err.Error()))
+			return shim.Error("suspendTemplatesByEntity : Unmarshaling Error : " + string(err.Error()))
+		}
+		operators := make([]string, 0)
+		for operator, sts := range template.Status {
+			if sts == "A" {
+				template.Status[operator] = "I"
+				operators = append(operators, operator)
+			}
+		}
+		if len(operators) == 0 {
+			continue
+		}
+		sort.Strings(operators)
+		setStatusChange(&template, operators, "A", "I", "ENT", Organizations[0], args[1])
+		template.UpdatedBy = Organizations[0]
+		template.UpdateTs = args[1]
+		TempAsBytes, err := json.Marshal(template)
+		if err != nil {
+			logger.Errorf("suspendTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("suspendTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(template.TemplateID, TempAsBytes)
+		if err != nil {
+			logger.Errorf("suspendTemplatesByEntity : PutState Error for TemplateID " + template.TemplateID + " , Error : " + string(err.Error()))
+			return shim.Error("suspendTemplatesByEntity : PutState Error for TemplateID " + template.TemplateID + " , Error : " + string(err.Error()))
+		}
+		suspended[template.TemplateID] = operators
+		items = append(items, template.TemplateID)
+	}
+
+	suspension := EntitySuspension{ObjType: "EntitySuspension", PEID: peid, Templates: suspended, UpdatedBy: Organizations[0], UpdateTs: args[1]}
+	suspensionAsBytes, err := json.Marshal(suspension)
+	if err != nil {
+		logger.Errorf("suspendTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+	}
+	err = stub.PutState(suspensionKey, suspensionAsBytes)
+	if err != nil {
+		logger.Errorf("suspendTemplatesByEntity : PutState Error : " + string(err.Error()))
+		return shim.Error("suspendTemplatesByEntity : PutState Error : " + string(err.Error()))
+	}
+	eventbytes := Event{Data: string(suspensionAsBytes), Txid: stub.GetTxID()}
+	payload, _ := json.Marshal(eventbytes)
+	err2 := stub.SetEvent(EVTSUSPENDTEMPLATE, []byte(payload))
+	if err2 != nil {
+		logger.Errorf("suspendTemplatesByEntity : Event Creation Error for EventID : " + string(EVTSUSPENDTEMPLATE))
+		return shim.Error("suspendTemplatesByEntity : Event Creation Error for EventID : " + string(EVTSUSPENDTEMPLATE))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":    stub.GetTxID(),
+		"PEID":      peid,
+		"items":     items,
+		"message":   "Templates suspended successfully",
+		"TxnStatus": "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//=============================================================================================================
+//restoreTemplatesByEntity is called on un-blacklisting of an entity. Sets back to A the operator wise status of
+//the templates suspended by sbe for the entity.
+//args: peid, update timestamp
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) restoreTemplatesByEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		logger.Errorf("restoreTemplatesByEntity : Incorrect Number Of Arguments: PEID and update timestamp are Expected.")
+		return shim.Error("restoreTemplatesByEntity : Incorrect Number Of Arguments: PEID and update timestamp are Expected.")
+	}
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("restoreTemplatesByEntity : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : Getting certificate Details Error : " + string(err.Error()))
+	}
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]]; !ok {
+		return shim.Error("Unauthorized  Access")
+	}
+	peid := args[0]
+	if err := checkEntityCascade(stub, peid, "RESTORE"); err != nil {
+		logger.Errorf("restoreTemplatesByEntity : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : " + string(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey("EntitySuspension", []string{peid})
+	if err != nil {
+		logger.Errorf("restoreTemplatesByEntity : Composite Key Error : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : Composite Key Error : " + string(err.Error()))
+	}
+	value, err := stub.GetState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreTemplatesByEntity : GetState Failed for PEID : " + peid + " , Error : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : GetState Failed for PEID : " + peid + " , Error : " + string(err.Error()))
+	}
+	items := make([]string, 0)
+	failed_urn := make([]string, 0)
+	//entities blacklisted before sbe kept the suspended templates have nothing to restore
+	if value == nil {
+		logger.Infof("restoreTemplatesByEntity : No suspended Templates for PEID : " + peid)
+		resultData := map[string]interface{}{
+			"trxnID":     stub.GetTxID(),
+			"PEID":       peid,
+			"items":      items,
+			"failed_urn": failed_urn,
+			"message":    "No suspended Templates for PEID",
+			"TxnStatus":  "true"}
+		respJSON, _ := json.Marshal(resultData)
+		return shim.Success(respJSON)
+	}
+	suspension := EntitySuspension{}
+	err = json.Unmarshal(value, &suspension)
+	if err != nil {
+		logger.Errorf("restoreTemplatesByEntity : Unmarshaling Error : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : Unmarshaling Error : " + string(err.Error()))
+	}
+
+	//map order is random, sorted for the same write set on every peer
+	urns := make([]string, 0, len(suspension.Templates))
+	for urn := range suspension.Templates {
+		urns = append(urns, urn)
+	}
+	sort.Strings(urns)
+	for _, urn := range urns {
+		operators := suspension.Templates[urn]
+		TempBytes, err := stub.GetState(urn)
+		if err != nil || TempBytes == nil {
+			failed_urn = append(failed_urn, urn)
+			continue
+		}
+		template := Template{}
+		err = json.Unmarshal(TempBytes, &template)
+		if err != nil || template.PEID != peid {
+			failed_urn = append(failed_urn, urn)
+			continue
+		}
+		restored := make([]string, 0)
+		for _, operator := range operators {
+			//status changed by the operator after the suspension is left as it is
+			if template.Status[operator] == "I" {
+				template.Status[operator] = "A"
+				restored = append(restored, operator)
+			}
+		}
+		if len(restored) > 0 {
+			setStatusChange(&template, restored, "I", "A", "", Organizations[0], args[1])
+		}
+		template.UpdatedBy = Organizations[0]
+		template.UpdateTs = args[1]
+		TempAsBytes, err := json.Marshal(template)
+		if err != nil {
+			logger.Errorf("restoreTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+			return shim.Error("restoreTemplatesByEntity : Marshalling Error : " + string(err.Error()))
+		}
+		err = stub.PutState(urn, TempAsBytes)
+		if err != nil {
+			logger.Errorf("restoreTemplatesByEntity : PutState Error for TemplateID " + urn + " , Error : " + string(err.Error()))
+			return shim.Error("restoreTemplatesByEntity : PutState Error for TemplateID " + urn + " , Error : " + string(err.Error()))
+		}
+		items = append(items, urn)
+	}
+	err = stub.DelState(suspensionKey)
+	if err != nil {
+		logger.Errorf("restoreTemplatesByEntity : DelState Error : " + string(err.Error()))
+		return shim.Error("restoreTemplatesByEntity : DelState Error : " + string(err.Error()))
+	}
+	eventbytes := Event{Data: peid + "-" + args[1], Txid: stub.GetTxID()}
+	payload, _ := json.Marshal(eventbytes)
+	err2 := stub.SetEvent(EVTRESTORETEMPLATE, []byte(payload))
+	if err2 != nil {
+		logger.Errorf("restoreTemplatesByEntity : Event Creation Error for EventID : " + string(EVTRESTORETEMPLATE))
+		return shim.Error("restoreTemplatesByEntity : Event Creation Error for EventID : " + string(EVTRESTORETEMPLATE))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"PEID":       peid,
+		"items":      items,
+		"failed_urn": failed_urn,
+		"message":    "Templates restored successfully",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
 // ===================================================================================
 //main function for the Template ChainCode
 // ===================================================================================
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Event Names
+const EVTAPPROVETEMPLATE = "APPROVE-TEMPLATE"
+
+//Operator wise status of a template, besides A and I of uts
+const (
+	TemplatePending  = "P"
+	TemplateRejected = "R"
+)
+
+//operator wise review of a template
+var templateApproval = map[string]bool{
+	"A": true,
+	"R": true,
+}
+
+//Reason codes of a rejection or of a status change
+var statusReason = map[string]string{
+	"CNT": "Content not as per the category",
+	"HDR": "Header not registered for the entity",
+	"VAR": "Variables not declared as per the content",
+	"DUP": "Duplicate of an existing template",
+	"SPM": "Spam or fraud complaints",
+	"ENT": "Entity blacklisted",
+	"REQ": "Requested by the entity",
+	"OTH": "Other",
+}
+
+//StatusChange is the last change of the operator wise status of a template, kept in the template record so
+//that th returns who changed what
+type StatusChange struct {
+	Operators []string `json:"op"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Reason    string   `json:"rsn,omitempty"`
+	UpdatedBy string   `json:"uby"`
+	UpdateTs  string   `json:"uts"`
+}
+
+//newTemplateStatus returns the status of a new template: active for the operator registering it and pending
+//for the others
+func newTemplateStatus(dltNode string) map[string]string {
+	templateStatus := make(map[string]string)
+	for operator := range statusForAllDomain {
+		templateStatus[operator] = TemplatePending
+	}
+	if _, ok := templateStatus[dltNode]; ok {
+		templateStatus[dltNode] = "A"
+	}
+	return templateStatus
+}
+
+//setStatusChange records the status change of the operators on the template
+func setStatusChange(template *Template, operators []string, from, to, reason, organization, updateTs string) {
+	template.LastChange = &StatusChange{Operators: operators, From: from, To: to, Reason: reason, UpdatedBy: organization, UpdateTs: updateTs}
+	if template.Reasons == nil {
+		template.Reasons = make(map[string]string)
+	}
+	for _, operator := range operators {
+		if len(reason) > 0 {
+			template.Reasons[operator] = reason
+		} else {
+			delete(template.Reasons, operator)
+		}
+	}
+}
+
+//validReason checks the reason code, mandatory when mandatory is set
+func validReason(reason string, mandatory bool) bool {
+	if len(reason) == 0 {
+		return !mandatory
+	}
+	_, ok := statusReason[reason]
+	return ok
+}
+
+//=============================================================================================================
+//approveTemplate records the review of a template by the invoking operator: A approves it (it becomes active
+//for the operator), R rejects it with a reason code. A rejected template can be approved later.
+//args: urn, A/R, reason code (mandatory for R), update timestamp
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) approveTemplate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		logger.Errorf("approveTemplate : Incorrect Number Of Arguments: TemplateID, Status, Reason and update timestamp are Expected.")
+		return shim.Error("approveTemplate : Incorrect Number Of Arguments: TemplateID, Status, Reason and update timestamp are Expected.")
+	}
+	if !validEnumEntry(args[1], templateApproval) {
+		return shim.Error("{\"Error\":\"Please enter one of these value for Status 'A' or 'R' \"}")
+	}
+	if !validReason(args[2], args[1] == TemplateRejected) {
+		return shim.Error("{\"Error\":\"Please enter a reason code, one of CNT, HDR, VAR, DUP, SPM, ENT, REQ, OTH\"}")
+	}
+	organization, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		logger.Errorf("approveTemplate : " + string(err.Error()))
+		return shim.Error("approveTemplate : " + string(err.Error()))
+	}
+	template, err := getTemplate(stub, args[0])
+	if err != nil || template == nil {
+		return shim.Error("approveTemplate : No Existing Templates for TemplateID : " + args[0])
+	}
+	current := template.Status[dltNode]
+	if current != TemplatePending && !(current == TemplateRejected && args[1] == "A") {
+		return shim.Error("approveTemplate : Template is not pending approval for " + dltNode)
+	}
+	if args[1] == "A" {
+		if suspended, err := isEntitySuspended(stub, template.PEID); err != nil || suspended {
+			logger.Errorf("approveTemplate : Templates of the entity are suspended, PEID : " + template.PEID)
+			return shim.Error("approveTemplate : Templates of the entity are suspended, PEID : " + template.PEID)
+		}
+		if errMsg := checkTemplateHeaders(stub, template.TemplateType, template.CommunicationType, template.Category, template.CLI); len(errMsg) > 0 {
+			logger.Errorf("approveTemplate : " + errMsg)
+			return shim.Error(errMsg)
+		}
+	}
+
+	template.Status[dltNode] = args[1]
+	setStatusChange(template, []string{dltNode}, current, args[1], args[2], organization, args[3])
+	template.UpdatedBy = organization
+	template.UpdateTs = args[3]
+	TempAsBytes, _ := json.Marshal(template)
+	if err := stub.PutState(template.TemplateID, TempAsBytes); err != nil {
+		logger.Errorf("approveTemplate : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("approveTemplate : PutState Failed Error : " + string(err.Error()))
+	}
+	eventbytes := Event{Data: string(TempAsBytes), Txid: stub.GetTxID()}
+	payload, _ := json.Marshal(eventbytes)
+	if err := stub.SetEvent(EVTAPPROVETEMPLATE, payload); err != nil {
+		logger.Errorf("approveTemplate : Event Creation Error for EventID : " + string(EVTAPPROVETEMPLATE))
+		return shim.Error("approveTemplate : Event Creation Error for EventID : " + string(EVTAPPROVETEMPLATE))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"TemplateID": args[0],
+		"Status":     args[1],
+		"Reason":     args[2],
+		"message":    "Template reviewed successfully",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//queryPendingTemplates returns the templates pending approval of the invoking operator
+//args: none, or peid
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) queryPendingTemplates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		logger.Errorf("queryPendingTemplates:Invalid number of arguments are provided for transaction")
+		return shim.Error("{\"Error\":\"Invalid number of arguments are provided for transaction\"}")
+	}
+	_, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		return shim.Error("queryPendingTemplates : " + string(err.Error()))
+	}
+	//obj leaves out the template versions, which have an operator wise sts as well
+	queryString := fmt.Sprintf("{\"selector\":{\"obj\":{\"$in\":[\"Templates\",\"ContentTemplates\"]},\"sts.%s\":\"%s\"}}", dltNode, TemplatePending)
+	if len(args) == 1 && len(args[0]) > 0 {
+		queryString = fmt.Sprintf("{\"selector\":{\"obj\":{\"$in\":[\"Templates\",\"ContentTemplates\"]},\"peid\":\"%s\",\"sts.%s\":\"%s\"}, \"use_index\":\"templateSearchBypeid\"}", args[0], dltNode, TemplatePending)
+	}
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return shim.Error("{\"Error\":\"GetQueryResult is Failed with error- " + string(err.Error()) + "\"}")
+	}
+	defer resultsIterator.Close()
+	records := make([]Template, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("{\"Error\":\"Iterator Error- " + string(err.Error()) + "\"}")
+		}
+		record := Template{}
+		if err := json.Unmarshal(recordBytes.Value, &record); err != nil {
+			return shim.Error("{\"Error\":\"Unmarshaling Error- " + string(err.Error()) + "\"}")
+		}
+		records = append(records, record)
+	}
+	resultData := map[string]interface{}{
+		"status":    "true",
+		"operator":  dltNode,
+		"templates": records,
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func pendingTemplates(t *testing.T, network *templateNetwork, args ...string) []string {
+	res := network.invoke(append([]string{"qpt"}, args...)...)
+	if res.Status != shim.OK {
+		t.Fatalf("qpt failed: %s", res.Message)
+	}
+	var result struct {
+		Templates []Template `json:"templates"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	urns := make([]string, 0)
+	for _, template := range result.Templates {
+		urns = append(urns, template.TemplateID)
+	}
+	return urns
+}
+
+func TestOperatorTemplateReview(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	for _, urn := range []string{"901", "902"} {
+		if res := network.invoke("st", contentTemplate(urn, "CTSMS", "T", "8", "HDFCBK")); res.Status != shim.OK {
+			t.Fatalf("st failed: %s", res.Message)
+		}
+	}
+	var template Template
+	network.stub.get(t, "901", &template)
+	if len(template.Status) != len(statusForAllDomain) || template.Status["AI"] != "A" || template.Status["JI"] != TemplatePending {
+		t.Fatalf("expected 901 active for airtel and pending for the others, got %v", template.Status)
+	}
+	if urns := pendingTemplates(t, network); len(urns) != 0 {
+		t.Fatalf("expected nothing pending for airtel, got %v", urns)
+	}
+	if res := network.invoke("ato", "901", "A", "", "1571470001"); res.Status == shim.OK {
+		t.Fatal("template approved by the operator which registered it")
+	}
+
+	network.stub.setDomain(t, "jio.com")
+	if urns := pendingTemplates(t, network); len(urns) != 2 || urns[0] != "901" || urns[1] != "902" {
+		t.Fatalf("expected 901 and 902 pending for jio, got %v", urns)
+	}
+	if urns := pendingTemplates(t, network, "1102"); len(urns) != 0 {
+		t.Fatalf("expected nothing pending for another entity, got %v", urns)
+	}
+	if res := network.invoke("ato", "901", "R", "", "1571470001"); res.Status == shim.OK {
+		t.Fatal("template rejected without a reason")
+	}
+	if res := network.invoke("ato", "901", "R", "BAD", "1571470001"); res.Status == shim.OK {
+		t.Fatal("template rejected with an unknown reason")
+	}
+	if res := network.invoke("ato", "901", "R", "CNT", "1571470001"); res.Status != shim.OK {
+		t.Fatalf("ato failed: %s", res.Message)
+	}
+	if res := network.invoke("ato", "901", "R", "CNT", "1571470002"); res.Status == shim.OK {
+		t.Fatal("template rejected twice")
+	}
+	network.stub.get(t, "901", &template)
+	if template.Status["JI"] != TemplateRejected || template.Reasons["JI"] != "CNT" || template.LastChange == nil ||
+		template.LastChange.From != TemplatePending || template.LastChange.UpdatedBy != "jio.com" {
+		t.Fatalf("unexpected review of jio %v %v %+v", template.Status, template.Reasons, template.LastChange)
+	}
+	if urns := pendingTemplates(t, network); len(urns) != 1 || urns[0] != "902" {
+		t.Fatalf("expected 902 pending for jio, got %v", urns)
+	}
+
+	//a rejected template can be approved later, once its headers are valid
+	network.addHeader("SMS", "HDFCBK", "T", "3")
+	if res := network.invoke("ato", "901", "A", "", "1571470003"); res.Status == shim.OK {
+		t.Fatal("template approved on a header of another category")
+	}
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("ato", "901", "A", "", "1571470004"); res.Status != shim.OK {
+		t.Fatalf("ato failed: %s", res.Message)
+	}
+	template = Template{}
+	network.stub.get(t, "901", &template)
+	if template.Status["JI"] != "A" || len(template.Reasons["JI"]) > 0 || template.Status["VO"] != TemplatePending {
+		t.Fatalf("expected 901 active for jio only, got %v %v", template.Status, template.Reasons)
+	}
+
+	suspensionKey, _ := network.stub.CreateCompositeKey("EntitySuspension", []string{"1101"})
+	network.stub.put(t, suspensionKey, map[string]string{"peid": "1101"})
+	if res := network.invoke("ato", "902", "A", "", "1571470005"); res.Status == shim.OK {
+		t.Fatal("template of a suspended entity approved")
+	}
+	if res := network.invoke("ato", "902", "R", "ENT", "1571470005"); res.Status != shim.OK {
+		t.Fatalf("ato failed: %s", res.Message)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Recordings of voice templates are indexed under {TemplateAudio, hash, urn}
+const AudioObjType = "TemplateAudio"
+
+//longest announcement in seconds, and the difference allowed between the registered and the played duration
+const maxAudioDuration = 600
+const audioDurationTolerance = 1
+
+var audioHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+//voice templates may carry a recording
+var voiceTemplateType = map[string]bool{
+	"CTVOICE": true,
+	"CSVOICE": true,
+}
+
+//TemplateAudio is the pre-recorded announcement of a voice template
+type TemplateAudio struct {
+	Hash       string `json:"hash"` //SHA-256 of the audio file, hex
+	Duration   int    `json:"dur"`  //seconds
+	Language   string `json:"lang"` //ISO 639 code, e.g. hi or en-IN
+	Transcript string `json:"trns,omitempty"`
+}
+
+//parseTemplateAudio reads and validates the recording of a template, only voice templates can have one
+func parseTemplateAudio(value interface{}, templateType string) (*TemplateAudio, string) {
+	if value == nil {
+		return nil, ""
+	}
+	if !voiceTemplateType[templateType] {
+		return nil, "aud is only for 'CTVOICE' or 'CSVOICE' templates"
+	}
+	audioAsBytes, _ := json.Marshal(value)
+	audio := TemplateAudio{}
+	if err := json.Unmarshal(audioAsBytes, &audio); err != nil {
+		return nil, "aud should be {\"hash\", \"dur\", \"lang\", \"trns\"}"
+	}
+	if errMsg := validateTemplateAudio(&audio); len(errMsg) > 0 {
+		return nil, errMsg
+	}
+	return &audio, ""
+}
+
+//validateTemplateAudio checks the fields of a recording, the hash is kept in lower case
+func validateTemplateAudio(audio *TemplateAudio) string {
+	audio.Hash = strings.ToLower(audio.Hash)
+	if !audioHashPattern.MatchString(audio.Hash) {
+		return "aud hash should be the SHA-256 of the audio file in hex"
+	}
+	if audio.Duration < 1 || audio.Duration > maxAudioDuration {
+		return "aud dur should be from 1 to " + strconv.Itoa(maxAudioDuration) + " seconds"
+	}
+	if !languagePattern.MatchString(audio.Language) {
+		return "aud lang should be a language code like hi or en-IN"
+	}
+	return ""
+}
+
+//audioHash returns the hash of a recording, empty without one
+func audioHash(audio *TemplateAudio) string {
+	if audio == nil {
+		return ""
+	}
+	return audio.Hash
+}
+
+func getAudioKey(stub shim.ChaincodeStubInterface, hash, urn string) (string, error) {
+	return stub.CreateCompositeKey(AudioObjType, []string{hash, urn})
+}
+
+//checkAudioDuplicates returns an error message when another active template (other than urn) of the entity has the
+//same recording
+func checkAudioDuplicates(stub shim.ChaincodeStubInterface, peid, urn string, audio *TemplateAudio) string {
+	if audio == nil {
+		return ""
+	}
+	urns, err := urnsByKey(stub, AudioObjType, []string{audio.Hash})
+	if err != nil {
+		return "Unable to read the template recordings : " + string(err.Error())
+	}
+	for _, other := range urns {
+		if other == urn {
+			continue
+		}
+		if template, err := getActiveTemplate(stub, other); err == nil && template != nil && template.PEID == peid {
+			return "Template recording is the same as of TemplateID " + other
+		}
+	}
+	return ""
+}
+
+//indexTemplateAudio adds the recording of a template to the index
+func indexTemplateAudio(stub shim.ChaincodeStubInterface, urn string, audio *TemplateAudio) error {
+	if audio == nil {
+		return nil
+	}
+	audioKey, err := getAudioKey(stub, audio.Hash, urn)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(audioKey, []byte{0x00})
+}
+
+//removeTemplateAudio removes the recording of a template from the index, when it is revised
+func removeTemplateAudio(stub shim.ChaincodeStubInterface, urn string, audio *TemplateAudio) error {
+	if audio == nil {
+		return nil
+	}
+	audioKey, err := getAudioKey(stub, audio.Hash, urn)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(audioKey)
+}
+
+//========================================================================================
+//verifyTemplateAudio checks that a played recording is the one of the effective version of an active voice
+//template. Without urn, returns the templates of the recording
+//args: urn, hash and optionally the played duration in seconds
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) verifyTemplateAudio(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 && len(args) != 3 {
+		logger.Errorf("verifyTemplateAudio : Incorrect Number Of Arguments: TemplateID, hash and duration are Expected.")
+		return shim.Error("verifyTemplateAudio : Incorrect Number Of Arguments: TemplateID, hash and duration are Expected.")
+	}
+	hash := strings.ToLower(args[1])
+	if len(args[0]) == 0 {
+		urns, err := urnsByKey(stub, AudioObjType, []string{hash})
+		if err != nil {
+			return shim.Error("verifyTemplateAudio : Unable to read the template recordings : " + string(err.Error()))
+		}
+		resultData := map[string]interface{}{
+			"status": "true",
+			"hash":   hash,
+			"urns":   urns,
+		}
+		respJson, _ := json.Marshal(resultData)
+		return shim.Success(respJson)
+	}
+
+	_, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		return shim.Error("verifyTemplateAudio : " + string(err.Error()))
+	}
+	template, err := getTemplate(stub, args[0])
+	if err != nil || template == nil {
+		return shim.Error("verifyTemplateAudio : No Existing Templates for TemplateID : " + args[0])
+	}
+	if template.Audio == nil {
+		return shim.Error("verifyTemplateAudio : Template has no recording")
+	}
+	if template.Status[dltNode] != "A" {
+		return shim.Error("verifyTemplateAudio : Template is not active")
+	}
+	if template.Audio.Hash != hash {
+		return shim.Error("verifyTemplateAudio : Recording is not the one of the template")
+	}
+	if len(args) == 3 && len(args[2]) > 0 {
+		duration, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Error("verifyTemplateAudio : Duration is not numeric")
+		}
+		if duration < template.Audio.Duration-audioDurationTolerance || duration > template.Audio.Duration+audioDurationTolerance {
+			return shim.Error("verifyTemplateAudio : Played duration is not the one of the template")
+		}
+	}
+	resultData := map[string]interface{}{
+		"TemplateID": args[0],
+		"Version":    effectiveVersion(*template),
+		"aud":        template.Audio,
+		"message":    "Recording is of the template",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+var (
+	welcomeHash = strings.Repeat("ab", 32)
+	offerHash   = strings.Repeat("cd", 32)
+)
+
+// voiceTemplate is an st request of a voice template with a recording
+func voiceTemplate(t *testing.T, urn, hash string, duration int, cli ...string) string {
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(contentTemplate(urn, "CTVOICE", "T", "8", cli...)), &template); err != nil {
+		t.Fatal(err)
+	}
+	template["aud"] = map[string]interface{}{"hash": hash, "dur": duration, "lang": "en-IN", "trns": template["tcont"]}
+	payload, _ := json.Marshal(template)
+	return string(payload)
+}
+
+func TestParseTemplateAudio(t *testing.T) {
+	tests := []struct {
+		name         string
+		aud          string
+		templateType string
+		err          string
+	}{
+		{"recording", `{"hash":"` + strings.ToUpper(welcomeHash) + `","dur":30,"lang":"hi"}`, "CTVOICE", ""},
+		{"SMS template", `{"hash":"` + welcomeHash + `","dur":30,"lang":"hi"}`, "CTSMS", "aud is only for"},
+		{"short hash", `{"hash":"abcd","dur":30,"lang":"hi"}`, "CSVOICE", "SHA-256"},
+		{"no duration", `{"hash":"` + welcomeHash + `","dur":0,"lang":"hi"}`, "CTVOICE", "aud dur should be from 1 to 600"},
+		{"too long", `{"hash":"` + welcomeHash + `","dur":601,"lang":"hi"}`, "CTVOICE", "aud dur should be from 1 to 600"},
+		{"language name", `{"hash":"` + welcomeHash + `","dur":30,"lang":"Hindi"}`, "CTVOICE", "aud lang should be"},
+	}
+	for _, test := range tests {
+		var value interface{}
+		if err := json.Unmarshal([]byte(test.aud), &value); err != nil {
+			t.Fatal(err)
+		}
+		audio, errMsg := parseTemplateAudio(value, test.templateType)
+		if len(test.err) == 0 && (len(errMsg) > 0 || audio.Hash != welcomeHash) {
+			t.Fatalf("%s: unexpected %+v %s", test.name, audio, errMsg)
+		}
+		if len(test.err) > 0 && !strings.Contains(errMsg, test.err) {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.err, errMsg)
+		}
+	}
+}
+
+func TestTemplateAudioFingerprint(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("VOICE", "1601234567", "T", "8")
+	if res := network.invoke("st", voiceTemplate(t, "801", welcomeHash, 30, "1601234567")); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	if res := network.invoke("st", voiceTemplate(t, "802", welcomeHash, 30, "1601234567")); res.Status == shim.OK || !strings.Contains(res.Message, "recording is the same as of TemplateID 801") {
+		t.Fatalf("expected the recording of 801 rejected, got %d %s", res.Status, res.Message)
+	}
+	if res := network.invoke("st", voiceTemplate(t, "803", offerHash, 45, "1601234567")); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"recording", []string{"801", strings.ToUpper(welcomeHash), "31"}, ""},
+		{"without duration", []string{"801", welcomeHash}, ""},
+		{"other recording", []string{"801", offerHash, "30"}, "Recording is not the one of the template"},
+		{"cut short", []string{"801", welcomeHash, "28"}, "Played duration is not the one of the template"},
+		{"duration not numeric", []string{"801", welcomeHash, "30s"}, "Duration is not numeric"},
+		{"unknown template", []string{"899", welcomeHash, "30"}, "No Existing Templates"},
+	}
+	for _, test := range tests {
+		res := network.invoke(append([]string{"vta"}, test.args...)...)
+		if len(test.err) == 0 && res.Status != shim.OK {
+			t.Fatalf("%s: vta failed: %s", test.name, res.Message)
+		}
+		if len(test.err) > 0 && (res.Status == shim.OK || !strings.Contains(res.Message, test.err)) {
+			t.Fatalf("%s: expected %q, got %d %s", test.name, test.err, res.Status, res.Message)
+		}
+	}
+
+	//without urn the templates of the recording are returned
+	res := network.invoke("vta", "", offerHash)
+	if res.Status != shim.OK {
+		t.Fatalf("vta failed: %s", res.Message)
+	}
+	var result struct {
+		URNs []string `json:"urns"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.URNs) != 1 || result.URNs[0] != "803" {
+		t.Fatalf("expected 803 for the recording, got %v", result.URNs)
+	}
+	network.stub.setDomain(t, "jio.com")
+	if res := network.invoke("vta", "801", welcomeHash, "30"); res.Status == shim.OK || !strings.Contains(res.Message, "not active") {
+		t.Fatalf("expected a template not active for jio, got %d %s", res.Status, res.Message)
+	}
+}
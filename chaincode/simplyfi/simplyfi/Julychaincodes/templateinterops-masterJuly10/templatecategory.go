@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	cid "github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Headers of the templates are read from the header chaincode of their channel on the header channel
+const HeaderChannel = "chheader"
+
+//Category rules are changed with SET_CATEGORY_RULE proposals approved on the governance chaincode,
+//executed with scr once per proposal: the execution is recorded under {GovernanceProposal, id}
+const GovernanceChaincode = "governance"
+const GovernanceChannel = "entitychannel"
+const GovernanceProposalObjType = "GovernanceProposal"
+
+//sbe and rbe are accepted only while the blacklist cascade of the entity chaincode has the step of this chaincode
+//(CascadeTargetName) pending
+const EntityChaincode = "entity"
+const EntityChannel = "entitychannel"
+const CascadeTargetName = "TEMPLATES"
+
+//Category rules are stored under the composite key {TemplateCategoryRule, ctyp}
+const CategoryRuleObjType = "TemplateCategoryRule"
+
+//=========================================================================================================
+// CategoryRule is the row of the category matrix for a communication type of template: the header types
+// the template can be sent with and whether the header has to be of the category of the template
+//=========================================================================================================
+type CategoryRule struct {
+	ObjType           string   `json:"obj"`
+	CommunicationType string   `json:"ctyp"`  //P, T, SE, SI -- Key field
+	HeaderTypes       []string `json:"htyp"`  //header types allowed for the templates
+	MatchCategory     bool     `json:"mctgr"` //header ctgr has to be the template ctgr, for content templates
+	UpdatedBy         string   `json:"uby"`
+	UpdateTs          string   `json:"uts"`
+}
+
+//header of a template, from the header chaincode of its channel
+type templateHeader struct {
+	CLI        string          `json:"cli"`
+	HeaderType string          `json:"htyp"`
+	Category   string          `json:"ctgr"`
+	Deleted    bool            `json:"del"`
+	Status     json.RawMessage `json:"sts"` //status per operator, a single status on headers not migrated on headervoice
+}
+
+//isDeleted - header deleted across operators, "D" on headervoice records not migrated
+func (header templateHeader) isDeleted() bool {
+	return header.Deleted || string(header.Status) == `"D"`
+}
+
+//default matrix: every communication type only on headers of the same type and category
+var defaultCategoryRules = []CategoryRule{
+	{CommunicationType: "P", HeaderTypes: []string{"P"}, MatchCategory: true},
+	{CommunicationType: "T", HeaderTypes: []string{"T"}, MatchCategory: true},
+	{CommunicationType: "SE", HeaderTypes: []string{"SE"}, MatchCategory: true},
+	{CommunicationType: "SI", HeaderTypes: []string{"SI"}, MatchCategory: true},
+}
+
+//header channel of a template type
+var headerChannelOfTemplate = map[string]string{
+	"CTSMS":   "SMS",
+	"CSSMS":   "SMS",
+	"CTVOICE": "VOICE",
+	"CSVOICE": "VOICE",
+}
+
+//header chaincode of a channel on the header channel
+var channelHeaderChaincode = map[string]string{
+	"SMS":   "header",
+	"VOICE": "headervoice",
+}
+
+//getCategoryRule returns the category rule of a communication type, nil when there is none
+func getCategoryRule(stub shim.ChaincodeStubInterface, communicationType string) (*CategoryRule, error) {
+	ruleKey, err := stub.CreateCompositeKey(CategoryRuleObjType, []string{communicationType})
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(ruleKey)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	rule := CategoryRule{}
+	if err := json.Unmarshal(value, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+//putCategoryRule saves a category rule
+func putCategoryRule(stub shim.ChaincodeStubInterface, rule CategoryRule) ([]byte, error) {
+	ruleKey, err := stub.CreateCompositeKey(CategoryRuleObjType, []string{rule.CommunicationType})
+	if err != nil {
+		return nil, err
+	}
+	rule.ObjType = CategoryRuleObjType
+	RuleAsBytes, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	return RuleAsBytes, stub.PutState(ruleKey, RuleAsBytes)
+}
+
+//registerDefaultCategoryRules saves the default rules which are not on the ledger yet, rules changed with
+//scr are kept on upgrade
+func registerDefaultCategoryRules(stub shim.ChaincodeStubInterface) error {
+	for _, rule := range defaultCategoryRules {
+		existing, err := getCategoryRule(stub, rule.CommunicationType)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		if _, err := putCategoryRule(stub, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//queryHeaderChaincode returns the headers found with qh on a chaincode of the header channel
+func queryHeaderChaincode(stub shim.ChaincodeStubInterface, chaincode string, cli []string) ([]queriedHeader, error) {
+	ccArgs := [][]byte{[]byte("qh")}
+	for _, header := range cli {
+		ccArgs = append(ccArgs, []byte(header))
+	}
+	response := stub.InvokeChaincode(chaincode, ccArgs, HeaderChannel)
+	if response.Status != shim.OK {
+		return nil, errors.New("qh on " + chaincode + " failed : " + response.Message)
+	}
+	result := struct {
+		DataOfHeader []queriedHeader `json:"dataOfHeader"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		return nil, err
+	}
+	return result.DataOfHeader, nil
+}
+
+type queriedHeader struct {
+	Value templateHeader `json:"Value"`
+}
+
+//getTemplateHeaders reads the headers of a template type from the header chaincode of its channel
+func getTemplateHeaders(stub shim.ChaincodeStubInterface, templateType string, cli []string) (map[string]templateHeader, error) {
+	headers := make(map[string]templateHeader)
+	data, err := queryHeaderChaincode(stub, channelHeaderChaincode[headerChannelOfTemplate[templateType]], cli)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range data {
+		headers[header.Value.CLI] = header.Value
+	}
+	return headers, nil
+}
+
+//getApprovedProposal reads an approved proposal of the governance chaincode into payload, fails when the
+//proposal is of another type, not approved or already executed
+func getApprovedProposal(stub shim.ChaincodeStubInterface, proposalID, proposalType string, payload interface{}) error {
+	proposalKey, err := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+	if err != nil {
+		return err
+	}
+	executedTx, err := stub.GetState(proposalKey)
+	if err != nil {
+		return err
+	} else if executedTx != nil {
+		return fmt.Errorf("Proposal %s already executed in transaction %s", proposalID, string(executedTx))
+	}
+
+	ccArgs := [][]byte{[]byte("searchProposal"), []byte(proposalID)}
+	response := stub.InvokeChaincode(GovernanceChaincode, ccArgs, GovernanceChannel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("Unable to get proposal %s : %s", proposalID, response.Message)
+	}
+	proposal := struct {
+		Type    string          `json:"ptyp"`
+		Status  string          `json:"sts"`
+		Payload json.RawMessage `json:"payload"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &proposal); err != nil {
+		return fmt.Errorf("Unable to read proposal %s", proposalID)
+	}
+	if proposal.Type != proposalType {
+		return fmt.Errorf("Proposal %s is not a %s proposal", proposalID, proposalType)
+	}
+	if proposal.Status != "A" {
+		return fmt.Errorf("Proposal %s is not approved", proposalID)
+	}
+	if err := json.Unmarshal(proposal.Payload, payload); err != nil {
+		return fmt.Errorf("Unable to read the payload of proposal %s", proposalID)
+	}
+	return nil
+}
+
+//markProposalExecuted records the transaction executing a governance proposal
+func markProposalExecuted(stub shim.ChaincodeStubInterface, proposalID string) error {
+	proposalKey, err := stub.CreateCompositeKey(GovernanceProposalObjType, []string{proposalID})
+	if err != nil {
+		return err
+	}
+	return stub.PutState(proposalKey, []byte(stub.GetTxID()))
+}
+
+//checkTemplateHeaders checks that the headers of a template are registered for its channel and are compatible
+//with its communication type and category as per the category matrix. Returns an empty string when they are
+func checkTemplateHeaders(stub shim.ChaincodeStubInterface, templateType, communicationType, category string, cli []string) string {
+	rule, err := getCategoryRule(stub, communicationType)
+	if err != nil {
+		return "Unable to read the category rule : " + string(err.Error())
+	}
+	if rule == nil {
+		return "No category rule for communicationType " + communicationType
+	}
+	headers, err := getTemplateHeaders(stub, templateType, cli)
+	if err != nil {
+		return "Unable to read the headers : " + string(err.Error())
+	}
+	for _, name := range cli {
+		header, ok := headers[name]
+		if !ok || header.isDeleted() {
+			return "Header " + name + " is not registered for " + headerChannelOfTemplate[templateType]
+		}
+		allowed := false
+		for _, headerType := range rule.HeaderTypes {
+			if header.HeaderType == headerType {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return "Header " + name + " of type " + header.HeaderType + " can not be used for communicationType " + communicationType
+		}
+		if rule.MatchCategory && len(category) > 0 && header.Category != category {
+			return "Header " + name + " of category " + header.Category + " can not be used for category " + category
+		}
+	}
+	return ""
+}
+
+//========================================================================================
+//setCategoryRule adds or replaces the row of the category matrix of a communication type
+//with the payload {"ctyp", "htyp", "mctgr"} of an approved SET_CATEGORY_RULE proposal
+//args: {proposalID, "uts"}
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) setCategoryRule(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 2 {
+		logger.Errorf("setCategoryRule : Incorrect Number Of Arguments: proposal id and uts are Expected.")
+		return shim.Error("setCategoryRule : Incorrect Number Of Arguments: proposal id and uts are Expected.")
+	}
+	rule := CategoryRule{}
+	err := getApprovedProposal(stub, args[0], "SET_CATEGORY_RULE", &rule)
+	if err != nil {
+		logger.Errorf("setCategoryRule : " + string(err.Error()))
+		return shim.Error("setCategoryRule : " + string(err.Error()))
+	}
+	rule.UpdateTs = args[1]
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		logger.Errorf("setCategoryRule : Getting certificate Details Error : " + string(err.Error()))
+		return shim.Error("setCategoryRule : Getting certificate Details Error : " + string(err.Error()))
+	}
+	Organizations := certData.Issuer.Organization
+	if _, ok := dltDomainNames[Organizations[0]]; !ok {
+		return shim.Error("Unauthorized  Access")
+	}
+	if !validEnumEntry(rule.CommunicationType, communicationTypeForCT) {
+		jsonResp = "{\"Error\":\"Please enter one of these value for communicationType 'P','T','SE' or 'SI' \"}"
+		logger.Errorf("setCategoryRule:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+	if len(rule.HeaderTypes) == 0 {
+		jsonResp = "{\"Error\":\"htyp is empty\"}"
+		logger.Errorf("setCategoryRule:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+	for _, headerType := range rule.HeaderTypes {
+		if !validEnumEntry(headerType, communicationTypeForCT) {
+			jsonResp = "{\"Error\":\"Please enter 'P','T','SE' or 'SI' for htyp\"}"
+			logger.Errorf("setCategoryRule:" + string(jsonResp))
+			return shim.Error(jsonResp)
+		}
+	}
+	if len(rule.UpdateTs) == 0 {
+		jsonResp = "{\"Error\":\"uts is empty\"}"
+		logger.Errorf("setCategoryRule:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+	rule.UpdatedBy = Organizations[0]
+
+	RuleAsBytes, err := putCategoryRule(stub, rule)
+	if err != nil {
+		logger.Errorf("setCategoryRule : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setCategoryRule : PutState Failed Error : " + string(err.Error()))
+	}
+	if err := markProposalExecuted(stub, args[0]); err != nil {
+		logger.Errorf("setCategoryRule : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setCategoryRule : PutState Failed Error : " + string(err.Error()))
+	}
+	logger.Infof("setCategoryRule : PutState Success : " + string(RuleAsBytes))
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"proposalID": args[0],
+		"rule":      rule,
+		"message":   "Category rule saved successfully",
+		"TxnStatus": "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//queryCategoryRules returns the category matrix
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) queryCategoryRules(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(CategoryRuleObjType, []string{})
+	if err != nil {
+		logger.Errorf("queryCategoryRules : GetStateByPartialCompositeKey Failed Error : " + string(err.Error()))
+		return shim.Error("queryCategoryRules : GetStateByPartialCompositeKey Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+	records := make([]CategoryRule, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("queryCategoryRules : Iterator Error : " + string(err.Error()))
+		}
+		rule := CategoryRule{}
+		if err := json.Unmarshal(recordBytes.Value, &rule); err != nil {
+			return shim.Error("queryCategoryRules : Unmarshaling Error : " + string(err.Error()))
+		}
+		records = append(records, rule)
+	}
+	resultData := map[string]interface{}{
+		"status": "true",
+		"rules":  records,
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// fakeHeaders answers qh of a header chaincode with the given headers
+type fakeHeaders struct {
+	headers map[string]templateHeader
+}
+
+func (chaincode *fakeHeaders) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (chaincode *fakeHeaders) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function != "qh" {
+		return shim.Error("Received Unknown Function invocation")
+	}
+	found := make([]queriedHeader, 0)
+	for _, cli := range args {
+		if header, ok := chaincode.headers[cli]; ok {
+			found = append(found, queriedHeader{Value: header})
+		}
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"dataOfHeader": found})
+	return shim.Success(payload)
+}
+
+// fakeGovernance answers searchProposal with the given proposals
+type fakeGovernance struct {
+	proposals map[string]string
+}
+
+func (governance *fakeGovernance) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (governance *fakeGovernance) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "searchProposal" {
+		if proposal, ok := governance.proposals[args[0]]; ok {
+			return shim.Success([]byte(proposal))
+		}
+	}
+	return shim.Error("The proposal id doesn't exists")
+}
+
+// templateNetwork is the templates chaincode with the header chaincodes of both channels and the
+// governance chaincode it reads
+type templateNetwork struct {
+	cc         *TemplateMgmtChaincode
+	stub       *testStub
+	sms        *fakeHeaders
+	voice      *fakeHeaders
+	governance *fakeGovernance
+}
+
+func newTemplateNetwork(t *testing.T) *templateNetwork {
+	network := &templateNetwork{
+		cc:         new(TemplateMgmtChaincode),
+		sms:        &fakeHeaders{headers: map[string]templateHeader{}},
+		voice:      &fakeHeaders{headers: map[string]templateHeader{}},
+		governance: &fakeGovernance{proposals: map[string]string{}},
+	}
+	network.stub = newTestStub(t, "templates", network.cc, "airtel.com")
+	network.stub.MockPeerChaincode(channelHeaderChaincode["SMS"]+"/"+HeaderChannel, shim.NewMockStub("header", network.sms))
+	network.stub.MockPeerChaincode(channelHeaderChaincode["VOICE"]+"/"+HeaderChannel, shim.NewMockStub("headervoice", network.voice))
+	network.stub.MockPeerChaincode(GovernanceChaincode+"/"+GovernanceChannel, shim.NewMockStub(GovernanceChaincode, network.governance))
+	if res := network.stub.init(network.cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	return network
+}
+
+func (network *templateNetwork) addHeader(channel, cli, headerType, category string) {
+	headers := network.sms
+	if channel == "VOICE" {
+		headers = network.voice
+	}
+	headers.headers[cli] = templateHeader{CLI: cli, HeaderType: headerType, Category: category, Status: json.RawMessage(`{"AI":"A"}`)}
+}
+
+func (network *templateNetwork) invoke(args ...string) pb.Response {
+	return network.stub.invoke(network.cc, args...)
+}
+
+// contentTemplate is an st request of a content template with an OTP variable, its content
+// made unique by the urn
+func contentTemplate(urn, templateType, communicationType, category string, cli ...string) string {
+	template := map[string]interface{}{
+		"urn": urn, "peid": "1101", "tname": "OTP " + urn, "ttyp": templateType, "ctyp": communicationType,
+		"ctgr": category, "vars": "1", "coty": "T", "cli": cli, "cts": "1571470000", "uts": "1571470000",
+		"tcont": "Dear customer, " + urn + " is the reference of your order. OTP is {#var#}",
+		"vdef":  []map[string]interface{}{{"typ": "OTP", "len": 6}},
+	}
+	payload, _ := json.Marshal(template)
+	return string(payload)
+}
+
+// consentTemplate is an st request of a consent template
+func consentTemplate(urn, templateType string, cli ...string) string {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"urn": urn, "peid": "1101", "tname": "Consent " + urn, "ttyp": templateType, "ctyp": "SE", "csty": "1",
+		"cli": cli, "cts": "1571470000", "uts": "1571470000",
+		"tcont": "Reply Y to receive the offers of reference " + urn,
+	})
+	return string(payload)
+}
+
+func TestTemplateCategoryMatrix(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	network.addHeader("SMS", "HDFCSE", "SE", "8")
+	network.addHeader("SMS", "HDFCCT", "T", "3")
+	network.addHeader("SMS", "123456", "P", "8")
+	network.addHeader("VOICE", "1601234567", "T", "8")
+	network.sms.headers["HDFCDL"] = templateHeader{CLI: "HDFCDL", HeaderType: "T", Category: "8", Deleted: true}
+	tests := []struct {
+		name    string
+		request string
+		err     string
+	}{
+		{"same type and category", contentTemplate("101", "CTSMS", "T", "8", "HDFCBK"), ""},
+		{"promotional on a numeric header", contentTemplate("102", "CTSMS", "P", "8", "123456"), ""},
+		{"promotional on a transactional header", contentTemplate("103", "CTSMS", "P", "8", "HDFCBK"), "of type T can not be used for communicationType P"},
+		{"other category", contentTemplate("104", "CTSMS", "T", "8", "HDFCCT"), "of category 3 can not be used for category 8"},
+		{"one header of another category", contentTemplate("105", "CTSMS", "T", "8", "HDFCBK", "HDFCCT"), "Header HDFCCT"},
+		{"unknown header", contentTemplate("106", "CTSMS", "T", "8", "NOHDR"), "Header NOHDR is not registered for SMS"},
+		{"deleted header", contentTemplate("107", "CTSMS", "T", "8", "HDFCDL"), "Header HDFCDL is not registered for SMS"},
+		{"header of the other channel", contentTemplate("108", "CTSMS", "T", "8", "1601234567"), "is not registered for SMS"},
+		{"consent on a service explicit header", consentTemplate("109", "CSSMS", "HDFCSE"), ""},
+		{"consent on a transactional header", consentTemplate("110", "CSSMS", "HDFCBK"), "of type T can not be used for communicationType SE"},
+	}
+	for _, test := range tests {
+		res := network.invoke("st", test.request)
+		if len(test.err) == 0 && res.Status != shim.OK {
+			t.Fatalf("%s: st failed: %s", test.name, res.Message)
+		}
+		if len(test.err) > 0 && (res.Status == shim.OK || !strings.Contains(res.Message, test.err)) {
+			t.Fatalf("%s: expected %q, got %d %s", test.name, test.err, res.Status, res.Message)
+		}
+	}
+}
+
+func TestTemplateStatusRechecksHeaders(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("st", contentTemplate("201", "CTSMS", "T", "8", "HDFCBK")); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	if res := network.invoke("uts", "201", "I", "1571470001"); res.Status != shim.OK {
+		t.Fatalf("uts failed: %s", res.Message)
+	}
+	//the header changed category since the template was registered
+	network.addHeader("SMS", "HDFCBK", "T", "3")
+	if res := network.invoke("uts", "201", "A", "1571470002"); res.Status == shim.OK {
+		t.Fatal("template activated on a header of another category")
+	}
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("uts", "201", "A", "1571470003"); res.Status != shim.OK {
+		t.Fatalf("uts failed: %s", res.Message)
+	}
+}
+
+func TestSetCategoryRule(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCSI", "SI", "8")
+	network.governance.proposals["P1"] = `{"id":"P1","ptyp":"SET_CATEGORY_RULE","sts":"A","payload":{"ctyp":"T","htyp":["T","SI"],"mctgr":true}}`
+	network.governance.proposals["P2"] = `{"id":"P2","ptyp":"SET_CATEGORY_RULE","sts":"P","payload":{"ctyp":"P","htyp":["T"],"mctgr":false}}`
+	network.governance.proposals["P3"] = `{"id":"P3","ptyp":"SET_QUORUM","sts":"A","payload":{"qnum":2,"qden":3}}`
+	network.governance.proposals["P4"] = `{"id":"P4","ptyp":"SET_CATEGORY_RULE","sts":"A","payload":{"ctyp":"T","htyp":["X"],"mctgr":true}}`
+
+	var result struct {
+		Rules []CategoryRule `json:"rules"`
+	}
+	if err := json.Unmarshal(network.invoke("qcr").Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rules) != len(defaultCategoryRules) {
+		t.Fatalf("expected the %d default rules, got %+v", len(defaultCategoryRules), result.Rules)
+	}
+	if res := network.invoke("st", contentTemplate("301", "CTSMS", "T", "8", "HDFCSI")); res.Status == shim.OK {
+		t.Fatal("transactional template registered on a SI header by the default matrix")
+	}
+	for _, proposalID := range []string{"P2", "P3", "P4", "P9"} {
+		if res := network.invoke("scr", proposalID, "1571470001"); res.Status == shim.OK {
+			t.Fatalf("rule of proposal %s saved", proposalID)
+		}
+	}
+	if res := network.invoke("scr", "P1", "1571470001"); res.Status != shim.OK {
+		t.Fatalf("scr failed: %s", res.Message)
+	}
+	if res := network.invoke("scr", "P1", "1571470002"); res.Status == shim.OK {
+		t.Fatal("proposal executed twice")
+	}
+	if res := network.invoke("st", contentTemplate("302", "CTSMS", "T", "8", "HDFCSI")); res.Status != shim.OK {
+		t.Fatalf("st failed with the new rule: %s", res.Message)
+	}
+	//Init of an upgrade keeps the rule changed
+	if res := network.stub.init(network.cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	rule, err := getCategoryRule(network.stub, "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rule.HeaderTypes) != 2 || rule.UpdatedBy != "airtel.com" {
+		t.Fatalf("rule of P1 not kept: %+v", rule)
+	}
+}
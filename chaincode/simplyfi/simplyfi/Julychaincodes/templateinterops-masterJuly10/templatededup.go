@@ -0,0 +1,429 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Content of the templates of an entity is indexed under {TemplateFingerprint, peid, fingerprint, urn} for the
+//exact duplicates and {TemplateBand, peid, band, urn} for the near duplicates
+const FingerprintObjType = "TemplateFingerprint"
+const BandObjType = "TemplateBand"
+
+//Templates registered before the duplicate detection are indexed with ite, IndexBatchSize templates at a time by default
+const IndexBatchSize = 500
+
+//near duplicates: shingles of 3 words, 16 minhash values in 8 bands of 2, candidates sharing a band are
+//similar when the jaccard similarity of their shingles is at least 0.7
+const shingleSize = 3
+const minhashBands = 8
+const minhashRows = 2
+const nearDuplicateSimilarity = 0.7
+
+//variable placeholders, {#var#} as given by the entities and their variants
+var placeholderPattern = regexp.MustCompile(`\{#?[^{}]*#?\}`)
+var wordPattern = regexp.MustCompile(`[a-z0-9]+`)
+
+//SimilarTemplate is a template of the entity whose content is similar to the one checked
+type SimilarTemplate struct {
+	TemplateID string  `json:"urn"`
+	Similarity float64 `json:"similarity"`
+}
+
+//normaliseContent lower cases the content, replaces the variable placeholders and collapses the white space
+func normaliseContent(content string) string {
+	content = placeholderPattern.ReplaceAllString(strings.ToLower(content), " {#var#} ")
+	return strings.Join(strings.Fields(content), " ")
+}
+
+//contentFingerprint is the fingerprint of the normalised content, the same for exact duplicates
+func contentFingerprint(content string) string {
+	sum := sha256.Sum256([]byte(normaliseContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+//contentShingles returns the word shingles of the normalised content
+func contentShingles(content string) map[string]bool {
+	words := wordPattern.FindAllString(normaliseContent(content), -1)
+	shingles := make(map[string]bool)
+	if len(words) < shingleSize {
+		shingles[strings.Join(words, " ")] = true
+		return shingles
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		shingles[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return shingles
+}
+
+//jaccard returns the share of shingles common to both contents
+func jaccard(a, b map[string]bool) float64 {
+	common := 0
+	for shingle := range a {
+		if b[shingle] {
+			common++
+		}
+	}
+	total := len(a) + len(b) - common
+	if total == 0 {
+		return 0
+	}
+	return float64(common) / float64(total)
+}
+
+//contentBands returns the minhash bands of the content, contents sharing a band are near duplicate candidates
+func contentBands(content string) []string {
+	shingles := contentShingles(content)
+	signature := make([]uint64, minhashBands*minhashRows)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+		for shingle := range shingles {
+			h := fnv.New64a()
+			fmt.Fprintf(h, "%d:%s", i, shingle)
+			if value := h.Sum64(); value < signature[i] {
+				signature[i] = value
+			}
+		}
+	}
+	bands := make([]string, minhashBands)
+	for band := 0; band < minhashBands; band++ {
+		h := fnv.New64a()
+		for row := 0; row < minhashRows; row++ {
+			fmt.Fprintf(h, "%d,", signature[band*minhashRows+row])
+		}
+		bands[band] = fmt.Sprintf("%d-%016x", band, h.Sum64())
+	}
+	return bands
+}
+
+//contentKeys returns the fingerprint and band keys of a template content
+func contentKeys(stub shim.ChaincodeStubInterface, peid, urn, content string) ([]string, error) {
+	keys := make([]string, 0, minhashBands+1)
+	key, err := stub.CreateCompositeKey(FingerprintObjType, []string{peid, contentFingerprint(content), urn})
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, key)
+	for _, band := range contentBands(content) {
+		key, err := stub.CreateCompositeKey(BandObjType, []string{peid, band, urn})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+//indexTemplateContent adds the content of a template to the duplicate index of its entity
+func indexTemplateContent(stub shim.ChaincodeStubInterface, peid, urn, content string) error {
+	keys, err := contentKeys(stub, peid, urn, content)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//removeTemplateContent removes the content of a template from the duplicate index, when the content is revised
+func removeTemplateContent(stub shim.ChaincodeStubInterface, peid, urn, content string) error {
+	keys, err := contentKeys(stub, peid, urn, content)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := stub.DelState(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//isTemplateActive reports whether a template is in use, active or pending approval for an operator. Templates
+//inactive or rejected for every operator are not taken as duplicates
+func isTemplateActive(template Template) bool {
+	if len(template.Status) == 0 {
+		return true
+	}
+	for _, status := range template.Status {
+		if status == "A" || status == TemplatePending {
+			return true
+		}
+	}
+	return false
+}
+
+//getActiveTemplate returns the template of a urn when it is active, nil otherwise
+func getActiveTemplate(stub shim.ChaincodeStubInterface, urn string) (*Template, error) {
+	template, err := getTemplate(stub, urn)
+	if err != nil || template == nil || !isTemplateActive(*template) {
+		return nil, err
+	}
+	return template, nil
+}
+
+//urnsByKey returns the urns indexed under a partial key, the urn being the last attribute of the keys
+func urnsByKey(stub shim.ChaincodeStubInterface, objectType string, attributes []string) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	urns := make([]string, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := stub.SplitCompositeKey(recordBytes.Key)
+		if err != nil || len(keyParts) == 0 {
+			continue
+		}
+		urns = append(urns, keyParts[len(keyParts)-1])
+	}
+	return urns, nil
+}
+
+//checkTemplateDuplicates returns an error message when the entity already has an active template (other than urn)
+//with the same normalised content, otherwise its active templates with similar content
+func checkTemplateDuplicates(stub shim.ChaincodeStubInterface, peid, urn, content string) ([]SimilarTemplate, string) {
+	duplicates, err := urnsByKey(stub, FingerprintObjType, []string{peid, contentFingerprint(content)})
+	if err != nil {
+		return nil, "Unable to read the template fingerprints : " + string(err.Error())
+	}
+	for _, duplicate := range duplicates {
+		if duplicate == urn {
+			continue
+		}
+		if template, err := getActiveTemplate(stub, duplicate); err == nil && template != nil {
+			return nil, "Template content is the same as of TemplateID " + duplicate
+		}
+	}
+
+	shingles := contentShingles(content)
+	similar := make([]SimilarTemplate, 0)
+	checked := map[string]bool{urn: true}
+	for _, band := range contentBands(content) {
+		candidates, err := urnsByKey(stub, BandObjType, []string{peid, band})
+		if err != nil {
+			return nil, "Unable to read the template fingerprints : " + string(err.Error())
+		}
+		for _, candidate := range candidates {
+			if checked[candidate] {
+				continue
+			}
+			checked[candidate] = true
+			template, err := getActiveTemplate(stub, candidate)
+			if err != nil || template == nil {
+				continue
+			}
+			if similarity := jaccard(shingles, contentShingles(template.TempContent)); similarity >= nearDuplicateSimilarity {
+				similar = append(similar, SimilarTemplate{TemplateID: candidate, Similarity: similarity})
+			}
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool { return similar[i].Similarity > similar[j].Similarity })
+	return similar, ""
+}
+
+//========================================================================================
+//queryTemplateClusters returns the groups of templates of an entity with similar content
+//args: peid
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) queryTemplateClusters(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		logger.Errorf("queryTemplateClusters:Invalid number of arguments are provided for transaction")
+		return shim.Error("{\"Error\":\"Invalid number of arguments are provided for transaction\"}")
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(BandObjType, []string{args[0]})
+	if err != nil {
+		return shim.Error("{\"Error\":\"GetStateByPartialCompositeKey is Failed with error- " + string(err.Error()) + "\"}")
+	}
+	defer resultsIterator.Close()
+
+	//templates sharing a band, the keys come sorted by band
+	candidates := make(map[string][]string)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("{\"Error\":\"Iterator Error- " + string(err.Error()) + "\"}")
+		}
+		_, keyParts, err := stub.SplitCompositeKey(recordBytes.Key)
+		if err != nil || len(keyParts) != 3 {
+			continue
+		}
+		candidates[keyParts[1]] = append(candidates[keyParts[1]], keyParts[2])
+	}
+
+	//templates are linked when their similarity is confirmed, clusters are the linked groups
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(urn string) string {
+		if parent[urn] == "" || parent[urn] == urn {
+			parent[urn] = urn
+			return urn
+		}
+		parent[urn] = find(parent[urn])
+		return parent[urn]
+	}
+	shingles := make(map[string]map[string]bool)
+	shinglesOf := func(urn string) map[string]bool {
+		if _, ok := shingles[urn]; !ok {
+			shingles[urn] = nil
+			if template, err := getActiveTemplate(stub, urn); err == nil && template != nil {
+				shingles[urn] = contentShingles(template.TempContent)
+			}
+		}
+		return shingles[urn]
+	}
+	compared := make(map[string]bool)
+	for _, urns := range candidates {
+		for i := 0; i < len(urns); i++ {
+			for j := i + 1; j < len(urns); j++ {
+				pair := urns[i] + ":" + urns[j]
+				if compared[pair] || find(urns[i]) == find(urns[j]) {
+					continue
+				}
+				compared[pair] = true
+				a, b := shinglesOf(urns[i]), shinglesOf(urns[j])
+				if a != nil && b != nil && jaccard(a, b) >= nearDuplicateSimilarity {
+					parent[find(urns[i])] = find(urns[j])
+				}
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for urn := range parent {
+		root := find(urn)
+		groups[root] = append(groups[root], urn)
+	}
+	clusters := make([][]string, 0)
+	for _, urns := range groups {
+		if len(urns) > 1 {
+			sort.Strings(urns)
+			clusters = append(clusters, urns)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+
+	resultData := map[string]interface{}{
+		"status":   "true",
+		"PEID":     args[0],
+		"clusters": clusters,
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
+
+//========================================================================================
+//indexTemplates adds templates registered before the duplicate detection to the index
+//args: urn...
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) indexTemplates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) == 0 {
+		return shim.Error("indexTemplates : Input Argument should not be empty")
+	}
+	if _, _, err := getInvokingNode(stub); err != nil {
+		return shim.Error("indexTemplates : " + string(err.Error()))
+	}
+	indexed := make([]string, 0)
+	failed_urn := make([]string, 0)
+	for _, urn := range args {
+		template, err := getTemplate(stub, urn)
+		if err != nil || template == nil {
+			failed_urn = append(failed_urn, urn)
+			continue
+		}
+		if err := indexTemplateContent(stub, template.PEID, urn, template.TempContent); err != nil {
+			return shim.Error("indexTemplates : PutState Failed Error : " + string(err.Error()))
+		}
+		if err := indexTemplateAudio(stub, urn, template.Audio); err != nil {
+			return shim.Error("indexTemplates : PutState Failed Error : " + string(err.Error()))
+		}
+		indexed = append(indexed, urn)
+	}
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"items":      indexed,
+		"failed_urn": failed_urn,
+		"message":    "Templates indexed successfully",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//indexExistingTemplates adds the templates registered before the duplicate detection to the index, a batch of
+//records at a time. It is invoked with the returned bookmark until done is true
+//args: batch size (optional), bookmark (optional)
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) indexExistingTemplates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if _, _, err := getInvokingNode(stub); err != nil {
+		return shim.Error("indexExistingTemplates : " + string(err.Error()))
+	}
+	batchSize := IndexBatchSize
+	if len(args) > 0 && len(args[0]) > 0 {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("indexExistingTemplates : Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+	bookmark := ""
+	if len(args) > 1 {
+		bookmark = args[1]
+	}
+
+	//templates are stored under their urn, the range of the simple keys leaves out the composite keys
+	resultsIterator, metadata, err := stub.GetStateByRangeWithPagination("", "", int32(batchSize), bookmark)
+	if err != nil {
+		logger.Errorf("indexExistingTemplates : GetStateByRangeWithPagination Failed Error : " + string(err.Error()))
+		return shim.Error("indexExistingTemplates : GetStateByRangeWithPagination Failed Error : " + string(err.Error()))
+	}
+	defer resultsIterator.Close()
+	indexed := make([]string, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("indexExistingTemplates : Iterator Error : " + string(err.Error()))
+		}
+		template := Template{}
+		if err := json.Unmarshal(recordBytes.Value, &template); err != nil || template.TemplateID != recordBytes.Key {
+			continue
+		}
+		if template.ObjType != "Templates" && template.ObjType != "ContentTemplates" {
+			continue
+		}
+		if err := indexTemplateContent(stub, template.PEID, template.TemplateID, template.TempContent); err != nil {
+			return shim.Error("indexExistingTemplates : PutState Failed Error : " + string(err.Error()))
+		}
+		if err := indexTemplateAudio(stub, template.TemplateID, template.Audio); err != nil {
+			return shim.Error("indexExistingTemplates : PutState Failed Error : " + string(err.Error()))
+		}
+		indexed = append(indexed, template.TemplateID)
+	}
+	logger.Infof("indexExistingTemplates : %d templates indexed", len(indexed))
+	resultData := map[string]interface{}{
+		"trxnID":    stub.GetTxID(),
+		"items":     indexed,
+		"bookmark":  metadata.Bookmark,
+		"done":      len(metadata.Bookmark) == 0 || int(metadata.FetchedRecordsCount) < batchSize,
+		"message":   "Templates indexed successfully",
+		"TxnStatus": "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const offerContent = "Dear customer, your order of the new phone has been shipped and will reach you within three working days. OTP is {#var#}"
+
+// withContent replaces the content of an st request
+func withContent(t *testing.T, request, content string) string {
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(request), &template); err != nil {
+		t.Fatal(err)
+	}
+	template["tcont"] = content
+	payload, _ := json.Marshal(template)
+	return string(payload)
+}
+
+func TestNearDuplicateTemplates(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("st", withContent(t, contentTemplate("401", "CTSMS", "T", "8", "HDFCBK"), offerContent)); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	//the same content with other spacing and case is a duplicate of the entity
+	duplicate := strings.ToUpper(strings.Replace(offerContent, " ", "  ", -1))
+	if res := network.invoke("st", withContent(t, contentTemplate("402", "CTSMS", "T", "8", "HDFCBK"), duplicate)); res.Status == shim.OK || !strings.Contains(res.Message, "401") {
+		t.Fatalf("expected the duplicate of 401 rejected, got %d %s", res.Status, res.Message)
+	}
+
+	similar := strings.Replace(offerContent, "three working days", "three business days", 1)
+	res := network.invoke("st", withContent(t, contentTemplate("403", "CTSMS", "T", "8", "HDFCBK"), similar))
+	if res.Status != shim.OK {
+		t.Fatalf("st of a similar template failed: %s", res.Message)
+	}
+	var result struct {
+		Similar []SimilarTemplate `json:"similar"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Similar) != 1 || result.Similar[0].TemplateID != "401" || result.Similar[0].Similarity < 0.7 {
+		t.Fatalf("expected 403 similar to 401, got %+v", result.Similar)
+	}
+
+	res = network.invoke("st", withContent(t, contentTemplate("404", "CTSMS", "T", "8", "HDFCBK"), "Your account statement for the month is ready. OTP is {#var#}"))
+	if res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	result.Similar = nil
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Similar) != 0 {
+		t.Fatalf("expected no similar templates, got %+v", result.Similar)
+	}
+}
+
+//templates registered before the duplicate detection are found once ite is run to the end
+func TestIndexExistingTemplates(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	for _, urn := range []string{"501", "502", "503", "504", "505"} {
+		network.stub.put(t, urn, Template{ObjType: "Templates", TemplateID: urn, PEID: "1101",
+			TempContent: "Dear customer, your parcel " + urn + " has been shipped and will reach you soon. OTP is {#var#}"})
+	}
+	if res := network.invoke("ite", "0", ""); res.Status == shim.OK {
+		t.Fatal("ite accepted a batch size of 0")
+	}
+
+	indexed := make([]string, 0)
+	bookmark := ""
+	for calls := 1; ; calls++ {
+		if calls > 3 {
+			t.Fatal("ite not done after 3 batches of 2")
+		}
+		res := network.invoke("ite", "2", bookmark)
+		if res.Status != shim.OK {
+			t.Fatalf("ite failed: %s", res.Message)
+		}
+		var result struct {
+			Items    []string `json:"items"`
+			Bookmark string   `json:"bookmark"`
+			Done     bool     `json:"done"`
+		}
+		if err := json.Unmarshal(res.Payload, &result); err != nil {
+			t.Fatal(err)
+		}
+		indexed = append(indexed, result.Items...)
+		if result.Done {
+			break
+		}
+		bookmark = result.Bookmark
+	}
+	if strings.Join(indexed, ",") != "501,502,503,504,505" {
+		t.Fatalf("expected the 5 templates indexed, got %v", indexed)
+	}
+
+	content := "Dear customer, your parcel 503 has been shipped and will reach you soon. OTP is {#var#}"
+	if res := network.invoke("st", withContent(t, contentTemplate("506", "CTSMS", "T", "8", "HDFCBK"), content)); res.Status == shim.OK || !strings.Contains(res.Message, "503") {
+		t.Fatalf("expected the duplicate of 503 rejected, got %d %s", res.Status, res.Message)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Segment limits are kept in one record under the composite key {SegmentConfig, network}
+const SegmentConfigObjType = "SegmentConfig"
+
+//characters of a single SMS and of a part of a concatenated SMS
+const (
+	gsm7Single    = 160
+	gsm7Part      = 153
+	unicodeSingle = 70
+	unicodePart   = 67
+)
+
+//GSM 03.38 basic character set, a character each
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+//GSM 03.38 extension table, two characters each (escape and character)
+const gsm7Extension = "\f^{}\\[~]|€"
+
+//SegmentConfig is the limit of SMS parts of a template, set by the operators
+type SegmentConfig struct {
+	ObjType        string `json:"obj"`
+	MaxSegments    int    `json:"maxseg"` //maximum SMS parts of a template with its variables at maximum length
+	VariableLength int    `json:"vlen"`   //maximum length of a variable for which the template does not give one
+	UpdatedBy      string `json:"uby"`
+	UpdateTs       string `json:"uts"`
+}
+
+var defaultSegmentConfig = SegmentConfig{MaxSegments: 10, VariableLength: 30}
+
+//SMS templates are checked for their encoding and parts
+var smsTemplateType = map[string]bool{
+	"CTSMS": true,
+	"CSSMS": true,
+}
+
+func getSegmentConfigKey(stub shim.ChaincodeStubInterface) (string, error) {
+	return stub.CreateCompositeKey(SegmentConfigObjType, []string{"network"})
+}
+
+//getSegmentConfig returns the segment limits, the default ones when not set
+func getSegmentConfig(stub shim.ChaincodeStubInterface) (SegmentConfig, error) {
+	config := defaultSegmentConfig
+	configKey, err := getSegmentConfigKey(stub)
+	if err != nil {
+		return config, err
+	}
+	value, err := stub.GetState(configKey)
+	if err != nil || value == nil {
+		return config, err
+	}
+	err = json.Unmarshal(value, &config)
+	return config, err
+}
+
+//registerDefaultSegmentConfig saves the default segment limits when they are not on the ledger yet
+func registerDefaultSegmentConfig(stub shim.ChaincodeStubInterface) error {
+	configKey, err := getSegmentConfigKey(stub)
+	if err != nil {
+		return err
+	}
+	if value, err := stub.GetState(configKey); err != nil || value != nil {
+		return err
+	}
+	config := defaultSegmentConfig
+	config.ObjType = SegmentConfigObjType
+	ConfigAsBytes, _ := json.Marshal(config)
+	return stub.PutState(configKey, ConfigAsBytes)
+}
+
+//isGSM7 reports whether the content, without its variable placeholders, can be sent as GSM-7 text
+func isGSM7(content string) bool {
+	for _, r := range placeholderPattern.ReplaceAllString(content, "") {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			return false
+		}
+	}
+	return true
+}
+
+//textLength is the length of a text in GSM-7 characters or in UCS-2 code units for unicode
+func textLength(text string, unicode bool) int {
+	if unicode {
+		return len(utf16.Encode([]rune(text)))
+	}
+	length := 0
+	for _, r := range text {
+		length++
+		if strings.ContainsRune(gsm7Extension, r) {
+			length++
+		}
+	}
+	return length
+}
+
+//messageLength is the length of the message with the variables at their maximum length, in order of the
+//placeholders, defaultLength for the variables without one
+func messageLength(content string, unicode bool, variableLengths []int, defaultLength int) int {
+	placeholders := placeholderPattern.FindAllStringIndex(content, -1)
+	length := 0
+	previous := 0
+	for i, placeholder := range placeholders {
+		length += textLength(content[previous:placeholder[0]], unicode)
+		if i < len(variableLengths) {
+			length += variableLengths[i]
+		} else {
+			length += defaultLength
+		}
+		previous = placeholder[1]
+	}
+	return length + textLength(content[previous:], unicode)
+}
+
+//smsSegments is the number of SMS parts for a message length
+func smsSegments(length int, unicode bool) int {
+	single, part := gsm7Single, gsm7Part
+	if unicode {
+		single, part = unicodeSingle, unicodePart
+	}
+	if length <= single {
+		return 1
+	}
+	return (length + part - 1) / part
+}
+
+//parseVariableLengths reads the maximum length of the variables given as a JSON array of numbers
+func parseVariableLengths(value interface{}) ([]int, string) {
+	if value == nil {
+		return nil, ""
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, "vlen should be a list of numbers"
+	}
+	variableLengths := make([]int, len(list))
+	for i, v := range list {
+		length, ok := v.(float64)
+		if !ok || length < 1 || length != float64(int(length)) {
+			return nil, "vlen should be a list of numbers"
+		}
+		variableLengths[i] = int(length)
+	}
+	return variableLengths, ""
+}
+
+//checkTemplateSegments checks the content of a SMS template against its content type (coty T has to be GSM-7,
+//consent templates are unicode when not GSM-7) and the segment limit. Returns the maximum message length and
+//the number of parts, 0 for voice templates
+func checkTemplateSegments(stub shim.ChaincodeStubInterface, templateType, contenttype, content string, variableLengths []int) (int, int, string) {
+	if !smsTemplateType[templateType] {
+		return 0, 0, ""
+	}
+	gsm7 := isGSM7(content)
+	if contenttype == "T" && !gsm7 {
+		return 0, 0, "Template content has characters outside GSM-7, coty should be U"
+	}
+	config, err := getSegmentConfig(stub)
+	if err != nil {
+		return 0, 0, "Unable to read the segment limit : " + string(err.Error())
+	}
+	unicode := contenttype == "U" || !gsm7
+	length := messageLength(content, unicode, variableLengths, config.VariableLength)
+	segments := smsSegments(length, unicode)
+	if segments > config.MaxSegments {
+		return length, segments, "Template needs " + strconv.Itoa(segments) + " SMS parts, maximum allowed is " + strconv.Itoa(config.MaxSegments)
+	}
+	return length, segments, ""
+}
+
+//========================================================================================
+//setSegmentConfig sets the segment limits
+//args: {"maxseg", "vlen", "uts"}
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) setSegmentConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		logger.Errorf("setSegmentConfig : Incorrect Number Of Arguments: segment limits are Expected.")
+		return shim.Error("setSegmentConfig : Incorrect Number Of Arguments: segment limits are Expected.")
+	}
+	config := SegmentConfig{}
+	if err := json.Unmarshal([]byte(args[0]), &config); err != nil {
+		logger.Errorf("setSegmentConfig : Input arguments unmarhsaling Error : " + string(err.Error()))
+		return shim.Error("setSegmentConfig : Input arguments unmarhsaling Error : " + string(err.Error()))
+	}
+	organization, _, err := getInvokingNode(stub)
+	if err != nil {
+		logger.Errorf("setSegmentConfig : " + string(err.Error()))
+		return shim.Error("setSegmentConfig : " + string(err.Error()))
+	}
+	if config.MaxSegments < 1 || config.VariableLength < 1 || len(config.UpdateTs) == 0 {
+		return shim.Error("{\"Error\":\"maxseg and vlen should be atleast 1 and uts is mandatory\"}")
+	}
+	config.ObjType = SegmentConfigObjType
+	config.UpdatedBy = organization
+	configKey, err := getSegmentConfigKey(stub)
+	if err != nil {
+		return shim.Error("setSegmentConfig : Composite Key Error : " + string(err.Error()))
+	}
+	ConfigAsBytes, _ := json.Marshal(config)
+	if err := stub.PutState(configKey, ConfigAsBytes); err != nil {
+		logger.Errorf("setSegmentConfig : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setSegmentConfig : PutState Failed Error : " + string(err.Error()))
+	}
+	resultData := map[string]interface{}{
+		"trxnID":    stub.GetTxID(),
+		"config":    config,
+		"message":   "Segment limits saved successfully",
+		"TxnStatus": "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//querySegments returns the segment limits or, for a content, its encoding, length and parts
+//args: none, or tcont, coty and optionally vlen as a JSON array
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) querySegments(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	config, err := getSegmentConfig(stub)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Unable to read the segment limit- " + string(err.Error()) + "\"}")
+	}
+	resultData := map[string]interface{}{
+		"status": "true",
+		"config": config,
+	}
+	if len(args) >= 2 {
+		var variableLengths []int
+		if len(args) > 2 && len(args[2]) > 0 {
+			var value interface{}
+			if err := json.Unmarshal([]byte(args[2]), &value); err != nil {
+				return shim.Error("{\"Error\":\"vlen should be a list of numbers\"}")
+			}
+			if lengths, errMsg := parseVariableLengths(value); len(errMsg) > 0 {
+				return shim.Error("{\"Error\":\"" + errMsg + "\"}")
+			} else {
+				variableLengths = lengths
+			}
+		}
+		unicode := args[1] == "U" || !isGSM7(args[0])
+		length := messageLength(args[0], unicode, variableLengths, config.VariableLength)
+		resultData["gsm7"] = isGSM7(args[0])
+		resultData["mlen"] = length
+		resultData["seg"] = smsSegments(length, unicode)
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestSegmentMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		unicode  bool
+		lengths  []int
+		length   int
+		segments int
+	}{
+		{"single GSM-7", strings.Repeat("a", 160), false, nil, 160, 1},
+		{"two GSM-7 parts", strings.Repeat("a", 161), false, nil, 161, 2},
+		{"full two GSM-7 parts", strings.Repeat("a", 306), false, nil, 306, 2},
+		{"three GSM-7 parts", strings.Repeat("a", 307), false, nil, 307, 3},
+		{"extension characters count twice", strings.Repeat("€", 80), false, nil, 160, 1},
+		{"one extension character more", strings.Repeat("€", 80) + "[", false, nil, 162, 2},
+		{"single unicode", strings.Repeat("अ", 70), true, nil, 70, 1},
+		{"two unicode parts", strings.Repeat("अ", 71), true, nil, 71, 2},
+		{"three unicode parts", strings.Repeat("अ", 135), true, nil, 135, 3},
+		{"surrogate pairs count twice", strings.Repeat("😀", 35), true, nil, 70, 1},
+		{"given variable length", "Hi {#var#} and {#var#}", false, []int{5, 8}, 3 + 5 + 5 + 8, 1},
+		{"default variable length", "Hi {#var#} and {#var#}", false, []int{5}, 3 + 5 + 5 + 30, 1},
+		{"variables make parts", strings.Repeat("a", 150) + "{#var#}", false, []int{11}, 161, 2},
+	}
+	for _, test := range tests {
+		length := messageLength(test.content, test.unicode, test.lengths, defaultSegmentConfig.VariableLength)
+		if length != test.length {
+			t.Fatalf("%s: expected length %d, got %d", test.name, test.length, length)
+		}
+		if segments := smsSegments(length, test.unicode); segments != test.segments {
+			t.Fatalf("%s: expected %d parts, got %d", test.name, test.segments, segments)
+		}
+	}
+	if !isGSM7("Pay {#var#} € [now] @ £5") || isGSM7("Pay ₹500") || isGSM7("नमस्ते") {
+		t.Fatal("unexpected GSM-7 check")
+	}
+}
+
+func TestTemplateSegmentLimit(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("ssc", `{"maxseg":0,"vlen":30,"uts":"1571470001"}`); res.Status == shim.OK {
+		t.Fatal("segment limit of 0 saved")
+	}
+	if res := network.invoke("ssc", `{"maxseg":1,"vlen":30,"uts":"1571470001"}`); res.Status != shim.OK {
+		t.Fatalf("ssc failed: %s", res.Message)
+	}
+
+	//154 characters and the OTP of 6 are one SMS, a character more makes two
+	single := strings.Repeat("a", 146) + " OTP is {#var#}"
+	res := network.invoke("st", withContent(t, contentTemplate("601", "CTSMS", "T", "8", "HDFCBK"), single))
+	if res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	var result struct {
+		Segments int `json:"seg"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Segments != 1 {
+		t.Fatalf("expected 1 part, got %d", result.Segments)
+	}
+	long := strings.Repeat("b", 147) + " OTP is {#var#}"
+	if res := network.invoke("st", withContent(t, contentTemplate("602", "CTSMS", "T", "8", "HDFCBK"), long)); res.Status == shim.OK || !strings.Contains(res.Message, "needs 2 SMS parts") {
+		t.Fatalf("expected 2 parts rejected, got %d %s", res.Status, res.Message)
+	}
+	rupee := "Pay ₹500 today. OTP is {#var#}"
+	if res := network.invoke("st", withContent(t, contentTemplate("603", "CTSMS", "T", "8", "HDFCBK"), rupee)); res.Status == shim.OK || !strings.Contains(res.Message, "outside GSM-7") {
+		t.Fatalf("expected a text template outside GSM-7 rejected, got %d %s", res.Status, res.Message)
+	}
+
+	res = network.invoke("qsc", strings.Repeat("अ", 60)+" {#var#}", "U", "[10]")
+	if res.Status != shim.OK {
+		t.Fatalf("qsc failed: %s", res.Message)
+	}
+	var query struct {
+		GSM7     bool `json:"gsm7"`
+		Length   int  `json:"mlen"`
+		Segments int  `json:"seg"`
+	}
+	if err := json.Unmarshal(res.Payload, &query); err != nil {
+		t.Fatal(err)
+	}
+	if query.GSM7 || query.Length != 71 || query.Segments != 2 {
+		t.Fatalf("unexpected parts %+v", query)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Domains allowed in URL variables are stored under the composite key {UrlWhitelist, domain}
+const WhitelistObjType = "UrlWhitelist"
+
+//Variable Type
+const (
+	VariableNumeric      = "N"
+	VariableAlphanumeric = "AN"
+	VariableAmount       = "AMT"
+	VariableDate         = "DT"
+	VariableURL          = "URL"
+	VariableOTP          = "OTP"
+)
+
+//value of a variable of each type
+var variablePattern = map[string]*regexp.Regexp{
+	VariableNumeric:      regexp.MustCompile(`^[0-9]+$`),
+	VariableAlphanumeric: regexp.MustCompile(`^[\p{L}\p{N} .,'&()/#_-]+$`),
+	VariableAmount:       regexp.MustCompile(`^(Rs\.?|INR|₹)? ?[0-9][0-9,]*(\.[0-9]{1,2})?$`),
+	VariableDate:         regexp.MustCompile(`^[0-9]{1,4}[-/.][0-9A-Za-z]{1,3}[-/.][0-9]{1,4}( [0-9]{1,2}:[0-9]{2}(:[0-9]{2})?( ?[AaPp][Mm])?)?$`),
+	VariableURL:          regexp.MustCompile(`^https?://[^\s]+$`),
+	VariableOTP:          regexp.MustCompile(`^[0-9A-Za-z]{4,10}$`),
+}
+
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+//links in a variable not declared as URL
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|\b[a-z0-9-]+\.(com|in|net|org|co|ly|io|me|info|biz|xyz|link|app|gl)\b)`)
+
+//TemplateVariable declares the type and maximum length of a variable of the template, in order of the {#var#}
+type TemplateVariable struct {
+	Type      string `json:"typ"` //N, AN, AMT, DT, URL, OTP
+	MaxLength int    `json:"len"`
+}
+
+//WhitelistedDomain is a domain, with its sub domains, allowed in URL variables
+type WhitelistedDomain struct {
+	ObjType   string `json:"obj"`
+	Domain    string `json:"dom"`
+	UpdatedBy string `json:"uby"`
+	UpdateTs  string `json:"uts"`
+}
+
+//parseVariables reads the variable declarations given as a JSON array, they have to be one for each {#var#}
+//of the content and as many as vars when given
+func parseVariables(value interface{}, content, noOfVariables string) ([]TemplateVariable, string) {
+	if value == nil {
+		return nil, ""
+	}
+	declarations, _ := json.Marshal(value)
+	variables := make([]TemplateVariable, 0)
+	if err := json.Unmarshal(declarations, &variables); err != nil {
+		return nil, "vdef should be a list of {\"typ\", \"len\"}"
+	}
+	for i, variable := range variables {
+		if _, ok := variablePattern[variable.Type]; !ok {
+			return nil, "Variable " + strconv.Itoa(i+1) + " : typ should be one of N, AN, AMT, DT, URL, OTP"
+		}
+		if variable.MaxLength < 1 {
+			return nil, "Variable " + strconv.Itoa(i+1) + " : len should be atleast 1"
+		}
+	}
+	if placeholders := len(placeholderPattern.FindAllString(content, -1)); placeholders != len(variables) {
+		return nil, "vdef has " + strconv.Itoa(len(variables)) + " variables, the content " + strconv.Itoa(placeholders)
+	}
+	if len(noOfVariables) > 0 && noOfVariables != strconv.Itoa(len(variables)) {
+		return nil, "vdef has " + strconv.Itoa(len(variables)) + " variables, vars is " + noOfVariables
+	}
+	return variables, ""
+}
+
+//variableLengthsOf returns the maximum length of the declared variables
+func variableLengthsOf(variables []TemplateVariable) []int {
+	lengths := make([]int, len(variables))
+	for i, variable := range variables {
+		lengths[i] = variable.MaxLength
+	}
+	return lengths
+}
+
+//contentPattern matches a message of the content, a group for each variable. White space of the content
+//matches any white space
+func contentPattern(content string) (*regexp.Regexp, error) {
+	parts := placeholderPattern.Split(content, -1)
+	for i, part := range parts {
+		parts[i] = whitespacePattern.ReplaceAllString(regexp.QuoteMeta(part), `\s+`)
+	}
+	return regexp.Compile(`(?s)^` + strings.Join(parts, `(.*?)`) + `$`)
+}
+
+//isWhitelistedURL reports whether the host of the URL is a whitelisted domain or one of its sub domains
+func isWhitelistedURL(stub shim.ChaincodeStubInterface, value string) bool {
+	link, err := url.Parse(value)
+	if err != nil || len(link.Hostname()) == 0 {
+		return false
+	}
+	labels := strings.Split(strings.ToLower(link.Hostname()), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		domainKey, err := stub.CreateCompositeKey(WhitelistObjType, []string{strings.Join(labels[i:], ".")})
+		if err != nil {
+			return false
+		}
+		if value, err := stub.GetState(domainKey); err == nil && value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+//checkVariableValue checks a value against the declaration of its variable, links are only allowed in URL
+//variables of whitelisted domains
+func checkVariableValue(stub shim.ChaincodeStubInterface, position int, value string, variable *TemplateVariable, defaultLength int) string {
+	name := "Variable " + strconv.Itoa(position)
+	maxLength := defaultLength
+	if variable != nil {
+		maxLength = variable.MaxLength
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return name + " is longer than " + strconv.Itoa(maxLength)
+	}
+	if variable != nil && variable.Type == VariableURL {
+		if !variablePattern[VariableURL].MatchString(value) {
+			return name + " is not a URL"
+		}
+		if !isWhitelistedURL(stub, value) {
+			return name + " is not of a whitelisted domain"
+		}
+		return ""
+	}
+	if linkPattern.MatchString(value) {
+		return name + " has a URL, it is allowed only in variables declared as URL"
+	}
+	if variable != nil && !variablePattern[variable.Type].MatchString(value) {
+		return name + " is not of type " + variable.Type
+	}
+	return ""
+}
+
+//========================================================================================
+//verifyTemplateMessage checks that a message is of the effective content of an active template and its
+//variables are as declared
+//args: urn, message
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) verifyTemplateMessage(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		logger.Errorf("verifyTemplateMessage : Incorrect Number Of Arguments: TemplateID and message are Expected.")
+		return shim.Error("verifyTemplateMessage : Incorrect Number Of Arguments: TemplateID and message are Expected.")
+	}
+	_, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		return shim.Error("verifyTemplateMessage : " + string(err.Error()))
+	}
+	template, err := getTemplate(stub, args[0])
+	if err != nil || template == nil {
+		return shim.Error("verifyTemplateMessage : No Existing Templates for TemplateID : " + args[0])
+	}
+	if template.Status[dltNode] != "A" {
+		return shim.Error("verifyTemplateMessage : Template is not active")
+	}
+	pattern, err := contentPattern(template.TempContent)
+	if err != nil {
+		return shim.Error("verifyTemplateMessage : Unable to read the template content : " + string(err.Error()))
+	}
+	match := pattern.FindStringSubmatch(args[1])
+	if match == nil {
+		return shim.Error("verifyTemplateMessage : Message is not of the template content")
+	}
+	config, err := getSegmentConfig(stub)
+	if err != nil {
+		return shim.Error("verifyTemplateMessage : Unable to read the segment limit : " + string(err.Error()))
+	}
+	for i, value := range match[1:] {
+		var variable *TemplateVariable
+		if i < len(template.Variables) {
+			variable = &template.Variables[i]
+		}
+		defaultLength := config.VariableLength
+		if i < len(template.VariableLengths) {
+			defaultLength = template.VariableLengths[i]
+		}
+		if errMsg := checkVariableValue(stub, i+1, value, variable, defaultLength); len(errMsg) > 0 {
+			return shim.Error("verifyTemplateMessage : " + errMsg)
+		}
+	}
+	resultData := map[string]interface{}{
+		"TemplateID": args[0],
+		"Version":    effectiveVersion(*template),
+		"vars":       match[1:],
+		"message":    "Message is of the template",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//setWhitelistedDomain adds (A) or removes (D) a domain allowed in URL variables
+//args: domain, A/D, update timestamp
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) setWhitelistedDomain(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		logger.Errorf("setWhitelistedDomain : Incorrect Number Of Arguments: domain, action and update timestamp are Expected.")
+		return shim.Error("setWhitelistedDomain : Incorrect Number Of Arguments: domain, action and update timestamp are Expected.")
+	}
+	organization, _, err := getInvokingNode(stub)
+	if err != nil {
+		return shim.Error("setWhitelistedDomain : " + string(err.Error()))
+	}
+	domain := strings.Trim(strings.ToLower(args[0]), ". ")
+	if len(domain) == 0 || !strings.Contains(domain, ".") || strings.ContainsAny(domain, "/: ") {
+		return shim.Error("{\"Error\":\"Please enter a domain like example.com\"}")
+	}
+	domainKey, err := stub.CreateCompositeKey(WhitelistObjType, []string{domain})
+	if err != nil {
+		return shim.Error("setWhitelistedDomain : Composite Key Error : " + string(err.Error()))
+	}
+	switch args[1] {
+	case "A":
+		record := WhitelistedDomain{ObjType: WhitelistObjType, Domain: domain, UpdatedBy: organization, UpdateTs: args[2]}
+		DomainAsBytes, _ := json.Marshal(record)
+		err = stub.PutState(domainKey, DomainAsBytes)
+	case "D":
+		if value, _ := stub.GetState(domainKey); value == nil {
+			return shim.Error("setWhitelistedDomain : Domain is not whitelisted : " + domain)
+		}
+		err = stub.DelState(domainKey)
+	default:
+		return shim.Error("{\"Error\":\"Please enter one of these value for action 'A' or 'D' \"}")
+	}
+	if err != nil {
+		logger.Errorf("setWhitelistedDomain : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("setWhitelistedDomain : PutState Failed Error : " + string(err.Error()))
+	}
+	resultData := map[string]interface{}{
+		"trxnID":    stub.GetTxID(),
+		"domain":    domain,
+		"message":   "Whitelisted domains updated successfully",
+		"TxnStatus": "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//queryWhitelistedDomains returns the domains allowed in URL variables
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) queryWhitelistedDomains(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(WhitelistObjType, []string{})
+	if err != nil {
+		return shim.Error("{\"Error\":\"GetStateByPartialCompositeKey is Failed with error- " + string(err.Error()) + "\"}")
+	}
+	defer resultsIterator.Close()
+	records := make([]WhitelistedDomain, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error("{\"Error\":\"Iterator Error- " + string(err.Error()) + "\"}")
+		}
+		record := WhitelistedDomain{}
+		if err := json.Unmarshal(recordBytes.Value, &record); err != nil {
+			return shim.Error("{\"Error\":\"Unmarshaling Error- " + string(err.Error()) + "\"}")
+		}
+		records = append(records, record)
+	}
+	resultData := map[string]interface{}{
+		"status":  "true",
+		"domains": records,
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
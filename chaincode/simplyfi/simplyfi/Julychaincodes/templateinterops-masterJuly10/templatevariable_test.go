@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestParseVariables(t *testing.T) {
+	content := "Order {#var#} of {#var#}"
+	tests := []struct {
+		name string
+		vdef string
+		vars string
+		err  string
+	}{
+		{"declared", `[{"typ":"N","len":8},{"typ":"AMT","len":12}]`, "2", ""},
+		{"unknown type", `[{"typ":"N","len":8},{"typ":"EMAIL","len":12}]`, "", "Variable 2 : typ should be one of"},
+		{"no length", `[{"typ":"N","len":0},{"typ":"AMT","len":12}]`, "", "Variable 1 : len should be atleast 1"},
+		{"fewer than the content", `[{"typ":"N","len":8}]`, "", "vdef has 1 variables, the content 2"},
+		{"other than vars", `[{"typ":"N","len":8},{"typ":"AMT","len":12}]`, "3", "vars is 3"},
+		{"not a list", `{"typ":"N"}`, "", "vdef should be a list"},
+	}
+	for _, test := range tests {
+		var value interface{}
+		if err := json.Unmarshal([]byte(test.vdef), &value); err != nil {
+			t.Fatal(err)
+		}
+		variables, errMsg := parseVariables(value, content, test.vars)
+		if len(test.err) == 0 && (len(errMsg) > 0 || len(variables) != 2) {
+			t.Fatalf("%s: unexpected %v %s", test.name, variables, errMsg)
+		}
+		if len(test.err) > 0 && !strings.Contains(errMsg, test.err) {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.err, errMsg)
+		}
+	}
+}
+
+func TestVerifyTemplateMessage(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(contentTemplate("701", "CTSMS", "T", "8", "HDFCBK")), &template); err != nil {
+		t.Fatal(err)
+	}
+	template["vars"] = "4"
+	template["tcont"] = "Your order {#var#} of {#var#} ships on {#var#}, track it at {#var#}"
+	template["vdef"] = []map[string]interface{}{{"typ": "N", "len": 8}, {"typ": "AMT", "len": 12}, {"typ": "DT", "len": 10}, {"typ": "URL", "len": 40}}
+	request, _ := json.Marshal(template)
+	if res := network.invoke("st", string(request)); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	if res := network.invoke("swd", "https://example.com", "A", "1571470001"); res.Status == shim.OK {
+		t.Fatal("URL whitelisted as a domain")
+	}
+	if res := network.invoke("swd", "Example.com", "A", "1571470001"); res.Status != shim.OK {
+		t.Fatalf("swd failed: %s", res.Message)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		err     string
+	}{
+		{"declared values", "Your order 12345 of Rs. 1,499.00 ships on 21-10-2026, track it at https://track.example.com/12345", ""},
+		{"white space of the content", "Your order 12345 of INR 99\nships on 21/10/2026, track it at http://example.com/t", ""},
+		{"other content", "Your order 12345 of Rs. 99 ships today", "Message is not of the template content"},
+		{"not numeric", "Your order A2345 of Rs. 99 ships on 21-10-2026, track it at https://example.com/t", "Variable 1 is not of type N"},
+		{"longer than declared", "Your order 123456789 of Rs. 99 ships on 21-10-2026, track it at https://example.com/t", "Variable 1 is longer than 8"},
+		{"not an amount", "Your order 12345 of ninety ships on 21-10-2026, track it at https://example.com/t", "Variable 2 is not of type AMT"},
+		{"link outside the URL variable", "Your order 12345 of Rs. 99 ships on bit.ly/x, track it at https://example.com/t", "Variable 3 has a URL"},
+		{"domain not whitelisted", "Your order 12345 of Rs. 99 ships on 21-10-2026, track it at https://example.com.evil.in/t", "Variable 4 is not of a whitelisted domain"},
+		{"not a URL", "Your order 12345 of Rs. 99 ships on 21-10-2026, track it at example.com/t", "Variable 4 is not a URL"},
+	}
+	for _, test := range tests {
+		res := network.invoke("vtm", "701", test.message)
+		if len(test.err) == 0 && res.Status != shim.OK {
+			t.Fatalf("%s: vtm failed: %s", test.name, res.Message)
+		}
+		if len(test.err) > 0 && (res.Status == shim.OK || !strings.Contains(res.Message, test.err)) {
+			t.Fatalf("%s: expected %q, got %d %s", test.name, test.err, res.Status, res.Message)
+		}
+	}
+
+	if res := network.invoke("swd", "example.com", "D", "1571470002"); res.Status != shim.OK {
+		t.Fatalf("swd failed: %s", res.Message)
+	}
+	if res := network.invoke("vtm", "701", tests[0].message); res.Status == shim.OK {
+		t.Fatal("URL of a domain removed from the whitelist accepted")
+	}
+	//the template is pending for the other operators
+	network.stub.setDomain(t, "jio.com")
+	if res := network.invoke("vtm", "701", tests[0].message); res.Status == shim.OK || !strings.Contains(res.Message, "not active") {
+		t.Fatalf("expected a template not active for jio, got %d %s", res.Status, res.Message)
+	}
+}
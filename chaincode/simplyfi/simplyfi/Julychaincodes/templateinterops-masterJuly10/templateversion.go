@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	cid "github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Event Names
+const EVTMODTEMPLATE = "MODIFY-TEMPLATE"
+const EVTAPPROVEVERSION = "APPROVE-TEMPLATE-VERSION"
+
+//Versions are stored under the composite key {TemplateVersion, urn, ver}, the effective version is also the
+//record under the urn so that the existing queries keep returning it
+const VersionObjType = "TemplateVersion"
+
+//Version State
+const (
+	VersionPending    = "P"
+	VersionEffective  = "E"
+	VersionSuperseded = "S"
+	VersionRejected   = "R"
+)
+
+//operator wise approval of a version
+var versionApproval = map[string]bool{
+	"A": true,
+	"R": true,
+}
+
+//isVersionApproved returns true when every operator of the network approved the version. Operators no longer
+//in statusForAllDomain are not waited for
+func isVersionApproved(status map[string]string) bool {
+	for operator, approval := range status {
+		if _, ok := statusForAllDomain[operator]; ok && approval != "A" {
+			return false
+		}
+	}
+	return true
+}
+
+//=========================================================================================================
+// TemplateVersion is a revision of the content of a template. The field names differ from the ones of the
+// template (tid, not urn, and no peid), so that the rich queries on templates do not return versions
+//=========================================================================================================
+type TemplateVersion struct {
+	ObjType       string            `json:"obj"`
+	TemplateID    string            `json:"tid"`
+	Version       int               `json:"ver"`
+	TempContent   string            `json:"tcont"`
+	NoOfVariables string            `json:"vars"`
+	Contenttype   string            `json:"coty"`
+	MessageLength int               `json:"mlen,omitempty"`
+	Segments      int               `json:"seg,omitempty"`
+	Audio         *TemplateAudio    `json:"aud,omitempty"`
+	Status        map[string]string `json:"sts"`  //operator wise approval P, A or R
+	State         string            `json:"vsts"` //P pending, E effective, S superseded, R rejected
+	Creator       string            `json:"crtr"`
+	CreateTs      string            `json:"cts"`
+	UpdatedBy     string            `json:"uby"`
+	UpdateTs      string            `json:"uts"`
+}
+
+//effectiveVersion returns the version of the template content, templates registered before versioning are 1
+func effectiveVersion(template Template) int {
+	if template.Version == 0 {
+		return 1
+	}
+	return template.Version
+}
+
+func getVersionKey(stub shim.ChaincodeStubInterface, urn string, version int) (string, error) {
+	return stub.CreateCompositeKey(VersionObjType, []string{urn, fmt.Sprintf("%06d", version)})
+}
+
+//getTemplateVersion returns a version of a template, nil when it is not stored
+func getTemplateVersion(stub shim.ChaincodeStubInterface, urn string, version int) (*TemplateVersion, error) {
+	versionKey, err := getVersionKey(stub, urn, version)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(versionKey)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	templateVersion := TemplateVersion{}
+	if err := json.Unmarshal(value, &templateVersion); err != nil {
+		return nil, err
+	}
+	return &templateVersion, nil
+}
+
+func putTemplateVersion(stub shim.ChaincodeStubInterface, templateVersion TemplateVersion) ([]byte, error) {
+	versionKey, err := getVersionKey(stub, templateVersion.TemplateID, templateVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	templateVersion.ObjType = VersionObjType
+	VersionAsBytes, err := json.Marshal(templateVersion)
+	if err != nil {
+		return nil, err
+	}
+	return VersionAsBytes, stub.PutState(versionKey, VersionAsBytes)
+}
+
+//versionOfTemplate returns the effective version as kept in the template record
+func versionOfTemplate(template Template) TemplateVersion {
+	approval := make(map[string]string)
+	for operator := range template.Status {
+		approval[operator] = "A"
+	}
+	return TemplateVersion{
+		ObjType:       VersionObjType,
+		TemplateID:    template.TemplateID,
+		Version:       effectiveVersion(template),
+		TempContent:   template.TempContent,
+		NoOfVariables: template.NoOfVariables,
+		Contenttype:   template.Contenttype,
+		MessageLength: template.MessageLength,
+		Segments:      template.Segments,
+		Audio:         template.Audio,
+		Status:        approval,
+		State:         VersionEffective,
+		Creator:       template.Creator,
+		CreateTs:      template.CreateTs,
+		UpdatedBy:     template.UpdatedBy,
+		UpdateTs:      template.UpdateTs,
+	}
+}
+
+//getTemplateVersions returns all the versions of a template in version order. The effective version of a
+//template never modified is only in the template record
+func getTemplateVersions(stub shim.ChaincodeStubInterface, template Template) ([]TemplateVersion, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(VersionObjType, []string{template.TemplateID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	versions := make([]TemplateVersion, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		templateVersion := TemplateVersion{}
+		if err := json.Unmarshal(recordBytes.Value, &templateVersion); err != nil {
+			return nil, err
+		}
+		versions = append(versions, templateVersion)
+	}
+	if len(versions) == 0 {
+		versions = append(versions, versionOfTemplate(template))
+	}
+	return versions, nil
+}
+
+//getTemplate returns the template record of a urn, nil when it does not exist
+func getTemplate(stub shim.ChaincodeStubInterface, urn string) (*Template, error) {
+	value, err := stub.GetState(urn)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	template := Template{}
+	if err := json.Unmarshal(value, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+//getInvokingNode returns the organization and the dlt node of the invoker
+func getInvokingNode(stub shim.ChaincodeStubInterface) (string, string, error) {
+	certData, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("Getting certificate Details Error : %s", err.Error())
+	}
+	Organizations := certData.Issuer.Organization
+	dltNode, ok := dltDomainNames[Organizations[0]]
+	if !ok {
+		return "", "", fmt.Errorf("Unauthorized  Access")
+	}
+	return Organizations[0], dltNode, nil
+}
+
+//=============================================================================================================
+//modifyTemplate requests a revision of the content of a template, by the operator which registered it. The
+//revision is a new version, approved by each operator with atv; the current version stays effective until
+//then. Only one revision can be pending.
+//args: {"urn", "tcont", "vars", "coty", "hash", "dur", "lang", "trns", "uts"}, vars and coty for content
+//templates, hash, dur, lang and trns for a new recording of a voice template
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) modifyTemplate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 1 {
+		logger.Errorf("modifyTemplate : Incorrect Number Of Arguments: template revision is Expected.")
+		return shim.Error("modifyTemplate : Incorrect Number Of Arguments: template revision is Expected.")
+	}
+	var data map[string]string
+	err := json.Unmarshal([]byte(args[0]), &data)
+	if err != nil {
+		logger.Errorf("modifyTemplate : Input arguments unmarhsaling Error : " + string(err.Error()))
+		return shim.Error("modifyTemplate : Input arguments unmarhsaling Error : " + string(err.Error()))
+	}
+	organization, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		logger.Errorf("modifyTemplate : " + string(err.Error()))
+		return shim.Error("modifyTemplate : " + string(err.Error()))
+	}
+	for _, field := range []string{"urn", "tcont", "uts"} {
+		if len(data[field]) == 0 {
+			jsonResp = "{\"Error\":\"" + field + " is empty\"}"
+			logger.Errorf("modifyTemplate:" + string(jsonResp))
+			return shim.Error(jsonResp)
+		}
+	}
+	template, err := getTemplate(stub, data["urn"])
+	if err != nil {
+		logger.Errorf("modifyTemplate : GetState Failed for TemplateID : " + data["urn"] + " , Error : " + string(err.Error()))
+		return shim.Error("modifyTemplate : GetState Failed for TemplateID : " + data["urn"] + " , Error : " + string(err.Error()))
+	}
+	if template == nil {
+		return shim.Error("modifyTemplate : No Existing Templates for TemplateID : " + data["urn"])
+	}
+	if template.Creator != organization {
+		return shim.Error("modifyTemplate : Template " + data["urn"] + " is registered by " + template.Creator + ", not by " + organization)
+	}
+	if template.PendingVersion != 0 {
+		return shim.Error("modifyTemplate : Version " + strconv.Itoa(template.PendingVersion) + " of the template is pending approval")
+	}
+
+	revision := versionOfTemplate(*template)
+	revision.TempContent = data["tcont"]
+	if template.TemplateType == "CTSMS" || template.TemplateType == "CTVOICE" {
+		if len(data["vars"]) > 0 {
+			if _, err := strconv.Atoi(data["vars"]); err != nil {
+				jsonResp = "{\"Error\":\"vars is not numeric\"}"
+				logger.Errorf("modifyTemplate:" + string(jsonResp))
+				return shim.Error(jsonResp)
+			}
+			revision.NoOfVariables = data["vars"]
+		}
+		if len(data["coty"]) > 0 {
+			if !validEnumEntry(data["coty"], contentType) {
+				jsonResp = "{\"Error\":\"Please enter one of these value for coty 'T' or 'U' \"}"
+				logger.Errorf("modifyTemplate:" + string(jsonResp))
+				return shim.Error(jsonResp)
+			}
+			revision.Contenttype = data["coty"]
+		}
+	}
+	if len(data["hash"]) > 0 {
+		if !voiceTemplateType[template.TemplateType] {
+			return shim.Error("{\"Error\":\"aud is only for 'CTVOICE' or 'CSVOICE' templates\"}")
+		}
+		duration, _ := strconv.Atoi(data["dur"])
+		revision.Audio = &TemplateAudio{Hash: data["hash"], Duration: duration, Language: data["lang"], Transcript: data["trns"]}
+		if errMsg := validateTemplateAudio(revision.Audio); len(errMsg) > 0 {
+			jsonResp = "{\"Error\":\"" + errMsg + "\"}"
+			logger.Errorf("modifyTemplate:" + string(jsonResp))
+			return shim.Error(jsonResp)
+		}
+	}
+	if revision.TempContent == template.TempContent && revision.NoOfVariables == template.NoOfVariables && revision.Contenttype == template.Contenttype && audioHash(revision.Audio) == audioHash(template.Audio) {
+		return shim.Error("modifyTemplate : Revision is the same as the effective version")
+	}
+
+	similar, errMsg := checkTemplateDuplicates(stub, template.PEID, template.TemplateID, revision.TempContent)
+	if len(errMsg) == 0 {
+		errMsg = checkAudioDuplicates(stub, template.PEID, template.TemplateID, revision.Audio)
+	}
+	if len(errMsg) == 0 && len(template.Variables) > 0 {
+		_, errMsg = parseVariables(template.Variables, revision.TempContent, revision.NoOfVariables)
+	}
+	if len(errMsg) == 0 {
+		revision.MessageLength, revision.Segments, errMsg = checkTemplateSegments(stub, template.TemplateType, revision.Contenttype, revision.TempContent, template.VariableLengths)
+	}
+	if len(errMsg) > 0 {
+		jsonResp = "{\"Error\":\"" + errMsg + "\"}"
+		logger.Errorf("modifyTemplate:" + string(jsonResp))
+		return shim.Error(jsonResp)
+	}
+
+	versions, err := getTemplateVersions(stub, *template)
+	if err != nil {
+		logger.Errorf("modifyTemplate : Unable to read the versions : " + string(err.Error()))
+		return shim.Error("modifyTemplate : Unable to read the versions : " + string(err.Error()))
+	}
+	//the effective version is stored as a version on the first revision, for the history
+	if len(versions) == 1 && versions[0].State == VersionEffective {
+		if _, err := putTemplateVersion(stub, versions[0]); err != nil {
+			return shim.Error("modifyTemplate : PutState Failed Error : " + string(err.Error()))
+		}
+	}
+	revision.Version = versions[len(versions)-1].Version + 1
+	revision.State = VersionPending
+	revision.Status = make(map[string]string)
+	for operator := range template.Status {
+		revision.Status[operator] = VersionPending
+	}
+	revision.Status[dltNode] = "A"
+	revision.Creator = organization
+	revision.CreateTs = data["uts"]
+	revision.UpdatedBy = organization
+	revision.UpdateTs = data["uts"]
+
+	VersionAsBytes, err := putTemplateVersion(stub, revision)
+	if err != nil {
+		logger.Errorf("modifyTemplate : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("modifyTemplate : PutState Failed Error : " + string(err.Error()))
+	}
+	template.Version = effectiveVersion(*template)
+	template.PendingVersion = revision.Version
+	template.UpdatedBy = organization
+	template.UpdateTs = data["uts"]
+	TempAsBytes, _ := json.Marshal(template)
+	if err := stub.PutState(template.TemplateID, TempAsBytes); err != nil {
+		logger.Errorf("modifyTemplate : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("modifyTemplate : PutState Failed Error : " + string(err.Error()))
+	}
+	logger.Infof("modifyTemplate : PutState Success : " + string(VersionAsBytes))
+
+	eventbytes := Event{Data: string(VersionAsBytes), Txid: stub.GetTxID()}
+	payload, _ := json.Marshal(eventbytes)
+	if err := stub.SetEvent(EVTMODTEMPLATE, payload); err != nil {
+		logger.Errorf("modifyTemplate : Event Creation Error for EventID : " + string(EVTMODTEMPLATE))
+		return shim.Error("modifyTemplate : Event Creation Error for EventID : " + string(EVTMODTEMPLATE))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"TemplateID": template.TemplateID,
+		"Version":    revision.Version,
+		"similar":    similar,
+		"message":    "Template revision requested successfully",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//=============================================================================================================
+//approveTemplateVersion records the approval (A) or rejection (R) of a pending version by the invoking operator.
+//The version becomes effective once approved by every operator, a rejection closes it and the current version
+//stays effective.
+//args: urn, version, A/R, update timestamp
+//==============================================================================================================
+func (dlt *TemplateMgmtChaincode) approveTemplateVersion(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		logger.Errorf("approveTemplateVersion : Incorrect Number Of Arguments: TemplateID, Version, Status and update timestamp are Expected.")
+		return shim.Error("approveTemplateVersion : Incorrect Number Of Arguments: TemplateID, Version, Status and update timestamp are Expected.")
+	}
+	version, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error("approveTemplateVersion : Version is not numeric")
+	}
+	if !validEnumEntry(args[2], versionApproval) {
+		return shim.Error("{\"Error\":\"Please enter one of these value for Status 'A' or 'R' \"}")
+	}
+	organization, dltNode, err := getInvokingNode(stub)
+	if err != nil {
+		logger.Errorf("approveTemplateVersion : " + string(err.Error()))
+		return shim.Error("approveTemplateVersion : " + string(err.Error()))
+	}
+	template, err := getTemplate(stub, args[0])
+	if err != nil || template == nil {
+		return shim.Error("approveTemplateVersion : No Existing Templates for TemplateID : " + args[0])
+	}
+	if template.PendingVersion != version {
+		return shim.Error("approveTemplateVersion : Version " + args[1] + " is not pending approval")
+	}
+	revision, err := getTemplateVersion(stub, args[0], version)
+	if err != nil || revision == nil {
+		return shim.Error("approveTemplateVersion : Version " + args[1] + " does not exist")
+	}
+	if revision.Status[dltNode] != VersionPending {
+		return shim.Error("approveTemplateVersion : Version " + args[1] + " is already reviewed by " + dltNode)
+	}
+
+	revision.Status[dltNode] = args[2]
+	revision.UpdatedBy = organization
+	revision.UpdateTs = args[3]
+	approved := isVersionApproved(revision.Status)
+	if args[2] == "R" {
+		revision.State = VersionRejected
+		template.PendingVersion = 0
+	} else if approved {
+		previous, err := getTemplateVersion(stub, args[0], effectiveVersion(*template))
+		if err == nil && previous != nil {
+			previous.State = VersionSuperseded
+			previous.UpdatedBy = organization
+			previous.UpdateTs = args[3]
+			if _, err := putTemplateVersion(stub, *previous); err != nil {
+				return shim.Error("approveTemplateVersion : PutState Failed Error : " + string(err.Error()))
+			}
+		}
+		revision.State = VersionEffective
+		if err := removeTemplateContent(stub, template.PEID, template.TemplateID, template.TempContent); err != nil {
+			return shim.Error("approveTemplateVersion : Template Index Error : " + string(err.Error()))
+		}
+		if err := indexTemplateContent(stub, template.PEID, template.TemplateID, revision.TempContent); err != nil {
+			return shim.Error("approveTemplateVersion : Template Index Error : " + string(err.Error()))
+		}
+		if err := removeTemplateAudio(stub, template.TemplateID, template.Audio); err != nil {
+			return shim.Error("approveTemplateVersion : Template Index Error : " + string(err.Error()))
+		}
+		if err := indexTemplateAudio(stub, template.TemplateID, revision.Audio); err != nil {
+			return shim.Error("approveTemplateVersion : Template Index Error : " + string(err.Error()))
+		}
+		template.TempContent = revision.TempContent
+		template.NoOfVariables = revision.NoOfVariables
+		template.Contenttype = revision.Contenttype
+		template.MessageLength = revision.MessageLength
+		template.Segments = revision.Segments
+		template.Audio = revision.Audio
+		template.Version = revision.Version
+		template.PendingVersion = 0
+	}
+
+	VersionAsBytes, err := putTemplateVersion(stub, *revision)
+	if err != nil {
+		logger.Errorf("approveTemplateVersion : PutState Failed Error : " + string(err.Error()))
+		return shim.Error("approveTemplateVersion : PutState Failed Error : " + string(err.Error()))
+	}
+	if revision.State != VersionPending {
+		template.UpdatedBy = organization
+		template.UpdateTs = args[3]
+		TempAsBytes, _ := json.Marshal(template)
+		if err := stub.PutState(template.TemplateID, TempAsBytes); err != nil {
+			logger.Errorf("approveTemplateVersion : PutState Failed Error : " + string(err.Error()))
+			return shim.Error("approveTemplateVersion : PutState Failed Error : " + string(err.Error()))
+		}
+	}
+
+	eventbytes := Event{Data: string(VersionAsBytes), Txid: stub.GetTxID()}
+	payload, _ := json.Marshal(eventbytes)
+	if err := stub.SetEvent(EVTAPPROVEVERSION, payload); err != nil {
+		logger.Errorf("approveTemplateVersion : Event Creation Error for EventID : " + string(EVTAPPROVEVERSION))
+		return shim.Error("approveTemplateVersion : Event Creation Error for EventID : " + string(EVTAPPROVEVERSION))
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"TemplateID": args[0],
+		"Version":    version,
+		"State":      revision.State,
+		"message":    "Template version updated successfully",
+		"TxnStatus":  "true"}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//========================================================================================
+//queryTemplateVersions returns all the versions of a template
+//args: urn
+//========================================================================================
+func (dlt *TemplateMgmtChaincode) queryTemplateVersions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		logger.Errorf("queryTemplateVersions:Invalid number of arguments are provided for transaction")
+		return shim.Error("{\"Error\":\"Invalid number of arguments are provided for transaction\"}")
+	}
+	template, err := getTemplate(stub, args[0])
+	if err != nil || template == nil {
+		return shim.Error("{\"Error\":\"No Existing Template for TemplateID- " + args[0] + "\"}")
+	}
+	versions, err := getTemplateVersions(stub, *template)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Unable to read the versions- " + string(err.Error()) + "\"}")
+	}
+	resultData := map[string]interface{}{
+		"status":   "true",
+		"ver":      effectiveVersion(*template),
+		"pver":     template.PendingVersion,
+		"versions": versions,
+	}
+	respJson, _ := json.Marshal(resultData)
+	return shim.Success(respJson)
+}
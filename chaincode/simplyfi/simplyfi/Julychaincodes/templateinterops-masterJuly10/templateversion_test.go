@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// getVersion reads a version of a template with gt
+func getVersion(t *testing.T, network *templateNetwork, urn, version string) (Template, TemplateVersion) {
+	res := network.invoke("gt", urn, version)
+	if res.Status != shim.OK {
+		t.Fatalf("gt %s %s failed: %s", urn, version, res.Message)
+	}
+	var result struct {
+		Template Template        `json:"templates"`
+		Version  TemplateVersion `json:"version"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Template, result.Version
+}
+
+func approveVersion(t *testing.T, network *templateNetwork, domain, urn, version, approval string) {
+	network.stub.setDomain(t, domain)
+	if res := network.invoke("atv", urn, version, approval, "1571470100"); res.Status != shim.OK {
+		t.Fatalf("atv by %s failed: %s", domain, res.Message)
+	}
+}
+
+func TestTemplateVersionApproval(t *testing.T) {
+	network := newTemplateNetwork(t)
+	network.addHeader("SMS", "HDFCBK", "T", "8")
+	if res := network.invoke("st", contentTemplate("401", "CTSMS", "T", "8", "HDFCBK")); res.Status != shim.OK {
+		t.Fatalf("st failed: %s", res.Message)
+	}
+	//gt of the effective version of a template never modified
+	_, effective := getVersion(t, network, "401", "1")
+	if effective.Version != 1 || effective.State != VersionEffective {
+		t.Fatalf("expected version 1 effective, got %+v", effective)
+	}
+
+	revision := `{"urn":"401","tcont":"Dear customer, OTP of your order 401 is {#var#}","uts":"1571470010"}`
+	network.stub.setDomain(t, "jio.com")
+	if res := network.invoke("mt", revision); res.Status == shim.OK {
+		t.Fatal("template revised by an operator which did not register it")
+	}
+	network.stub.setDomain(t, "airtel.com")
+	if res := network.invoke("mt", revision); res.Status != shim.OK {
+		t.Fatalf("mt failed: %s", res.Message)
+	}
+	if res := network.invoke("mt", revision); res.Status == shim.OK {
+		t.Fatal("second revision accepted while one is pending")
+	}
+	template, pending := getVersion(t, network, "401", "2")
+	if pending.State != VersionPending || pending.Status["AI"] != "A" || pending.Status["JI"] != VersionPending || template.PendingVersion != 2 {
+		t.Fatalf("expected version 2 pending the other operators, got %+v pver %d", pending, template.PendingVersion)
+	}
+	if pending.TempContent == template.TempContent {
+		t.Fatal("pending version effective before its approval")
+	}
+
+	for _, domain := range []string{"vil.com", "bsnl.com", "mtnl.com", "qtl.infotelconnect.com", "tata.com"} {
+		approveVersion(t, network, domain, "401", "2", "A")
+	}
+	if res := network.invoke("atv", "401", "2", "A", "1571470101"); res.Status == shim.OK {
+		t.Fatal("version reviewed twice by an operator")
+	}
+	if _, pending := getVersion(t, network, "401", "2"); pending.State != VersionPending {
+		t.Fatalf("expected version 2 pending the approval of jio, got %s", pending.State)
+	}
+	approveVersion(t, network, "jio.com", "401", "2", "A")
+	template, approved := getVersion(t, network, "401", "2")
+	if approved.State != VersionEffective || template.Version != 2 || template.PendingVersion != 0 || template.TempContent != approved.TempContent {
+		t.Fatalf("expected version 2 effective, got %+v ver %d pver %d", approved, template.Version, template.PendingVersion)
+	}
+	if _, superseded := getVersion(t, network, "401", "1"); superseded.State != VersionSuperseded {
+		t.Fatalf("expected version 1 superseded, got %s", superseded.State)
+	}
+
+	//a rejection closes the revision, the effective version stays
+	network.stub.setDomain(t, "airtel.com")
+	if res := network.invoke("mt", `{"urn":"401","tcont":"OTP {#var#} for order 401","uts":"1571470200"}`); res.Status != shim.OK {
+		t.Fatalf("mt failed: %s", res.Message)
+	}
+	approveVersion(t, network, "bsnl.com", "401", "3", "R")
+	template, rejected := getVersion(t, network, "401", "3")
+	if rejected.State != VersionRejected || template.Version != 2 || template.PendingVersion != 0 {
+		t.Fatalf("expected version 3 rejected and version 2 effective, got %+v ver %d", rejected, template.Version)
+	}
+	if res := network.invoke("gt", "401", "4"); res.Status == shim.OK {
+		t.Fatal("gt returned a version which does not exist")
+	}
+}
+
+// operators no longer of the network, left in the status of older templates, are not waited for
+func TestVersionApprovalOfFormerOperators(t *testing.T) {
+	status := map[string]string{"AI": "A", "JI": "A", "Org1": VersionPending}
+	if !isVersionApproved(status) {
+		t.Fatal("version waiting for an operator not in the network")
+	}
+	status["JI"] = VersionPending
+	if isVersionApproved(status) {
+		t.Fatal("version approved while an operator has not reviewed it")
+	}
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim" // import for Chaincode Interface
+	pb "github.com/hyperledger/fabric/protos/peer"      // import for peer response
+)
+
+// Object type and docType of the scheduled campaigns, the one-shot Scrubbing records keep _CampaignObj
+const _CampaignPlanObj = "CampaignPlan"
+
+// Layout of the campaign window, same as the dates stored by the other records
+const _CampaignTimeLayout = "2006-01-02 15:04:05"
+
+// Layout of the hour the throughput cap is counted against
+const _CampaignHourLayout = "2006-01-02 15"
+
+// Status of a scheduled campaign
+const (
+	_CampaignPending  = "Pending"
+	_CampaignApproved = "Approved"
+	_CampaignRejected = "Rejected"
+	_CampaignClosed   = "Closed"
+)
+
+// Scheduled Campaign Data
+type CampaignPlan struct {
+	DocType        string            `json:"campaignDocType"`
+	CampaignId     string            `json:"campaignId"`
+	EntityId       string            `json:"campaignEntityId"`
+	TelemarketerId string            `json:"campaignTmId"`
+	HeaderName     string            `json:"campaignHeaderName"`
+	TemplateId     string            `json:"campaignTemplateId"`
+	Category       string            `json:"campaignCategory"`
+	WindowStart    string            `json:"windowStart"`
+	WindowEnd      string            `json:"windowEnd"`
+	TargetCount    int               `json:"targetCount"`
+	HourlyCap      int               `json:"hourlyCap"`
+	Submitted      int               `json:"submittedCount"`
+	HourSlot       string            `json:"hourSlot"`
+	HourSubmitted  int               `json:"hourSubmittedCount"`
+	Approvals      map[string]string `json:"approvals"` //tspId -> Pending / Approved / Rejected
+	Status         string            `json:"campaignStatus"`
+	CreatedDate    string            `json:"campaignCreatedDate"`
+	ModifiedDate   string            `json:"campaignModifiedDate"`
+	ClosedReason   string            `json:"closedReason,omitempty"`
+}
+
+// Input of registerCampaign
+type campaignRequest struct {
+	CampaignId     string   `json:"campaignId"`
+	EntityId       string   `json:"campaignEntityId"`
+	TelemarketerId string   `json:"campaignTmId"`
+	HeaderName     string   `json:"campaignHeaderName"`
+	TemplateId     string   `json:"campaignTemplateId"`
+	Category       string   `json:"campaignCategory"`
+	WindowStart    string   `json:"windowStart"`
+	WindowEnd      string   `json:"windowEnd"`
+	TargetCount    int      `json:"targetCount"`
+	HourlyCap      int      `json:"hourlyCap"`
+	TSPIds         []string `json:"tspIds"`
+}
+
+// Time of the transaction in IST, used instead of the clock of the peer so all endorsers agree
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	txTs, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(txTs.Seconds, int64(txTs.Nanos)).In(loc), nil
+}
+
+// Parses a campaign window date given in IST
+func parseCampaignTime(value string) (time.Time, error) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	return time.ParseInLocation(_CampaignTimeLayout, value, loc)
+}
+
+// Retrieving a scheduled campaign, nil when it does not exist
+func getCampaignPlan(stub shim.ChaincodeStubInterface, campaignId string) (*CampaignPlan, error) {
+	valueAsBytes, err := getRecord(stub, _CampaignPlanObj, campaignId)
+	if err != nil || valueAsBytes == nil {
+		return nil, err
+	}
+	campaign := &CampaignPlan{}
+	err = json.Unmarshal(valueAsBytes, campaign)
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// Saving a scheduled campaign
+func putCampaignPlan(stub shim.ChaincodeStubInterface, campaign *CampaignPlan) error {
+	campaignAsBytes, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(getKey(stub, _CampaignPlanObj, campaign.CampaignId), campaignAsBytes)
+}
+
+// Closes the campaign when its window has ended, returns true if it was closed now
+func closeIfExpired(campaign *CampaignPlan, now time.Time) bool {
+	if campaign.Status == _CampaignClosed || campaign.Status == _CampaignRejected {
+		return false
+	}
+	windowEnd, err := parseCampaignTime(campaign.WindowEnd)
+	if err != nil || now.Before(windowEnd) {
+		return false
+	}
+	campaign.Status = _CampaignClosed
+	campaign.ClosedReason = "Window Ended"
+	campaign.ModifiedDate = now.Format(_CampaignTimeLayout)
+	return true
+}
+
+// Checks the invoker is the TM of the campaign or one of the operators which approved it
+func isCampaignSubmitter(stub shim.ChaincodeStubInterface, campaign *CampaignPlan) bool {
+	if tmId := getInvokerTM(stub); tmId != "" {
+		return tmId == campaign.TelemarketerId
+	}
+	for tspId, approval := range campaign.Approvals {
+		if approval == _CampaignApproved && isInvokerTSP(stub, tspId) {
+			return true
+		}
+	}
+	return false
+}
+
+//Registering a scheduled Campaign in Blockchain
+/*
+	fcnName: registerCampaign
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [campaign json] with campaignId, campaignEntityId, campaignTmId, campaignHeaderName,
+	campaignTemplateId, campaignCategory, windowStart, windowEnd, targetCount, hourlyCap and tspIds
+	header and template should be approved and belong to the entity, the window should end in the future,
+	every operator in tspIds should exist and has to approve the campaign before deliveries are accepted
+	return pb.Response= Payload of the campaign created
+*/
+func (c *Telco) registerCampaign(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 1 {
+		return shim.Error("Incorrect Number of Arguments. Expecting 1(Campaign JSON)")
+	}
+	request := campaignRequest{}
+	err := json.Unmarshal([]byte(args[0]), &request)
+	if err != nil {
+		jsonResp = "{\"Error\":\"JSON Unmarshalling Error for Campaign \"}"
+		return shim.Error(jsonResp)
+	}
+	if request.CampaignId == "" || request.EntityId == "" || request.HeaderName == "" || request.TemplateId == "" || request.Category == "" {
+		return shim.Error("{\"Error\":\"campaignId, campaignEntityId, campaignHeaderName, campaignTemplateId and campaignCategory are mandatory\"}")
+	}
+	if request.TargetCount <= 0 || request.HourlyCap <= 0 {
+		return shim.Error("{\"Error\":\"targetCount and hourlyCap should be positive numbers\"}")
+	}
+	if len(request.TSPIds) == 0 {
+		return shim.Error("{\"Error\":\"Atleast one operator is required in tspIds\"}")
+	}
+	windowStart, err := parseCampaignTime(request.WindowStart)
+	if err != nil {
+		return shim.Error("{\"Error\":\"windowStart should be in the format " + _CampaignTimeLayout + "\"}")
+	}
+	windowEnd, err := parseCampaignTime(request.WindowEnd)
+	if err != nil {
+		return shim.Error("{\"Error\":\"windowEnd should be in the format " + _CampaignTimeLayout + "\"}")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !windowEnd.After(windowStart) || !windowEnd.After(now) {
+		return shim.Error("{\"Error\":\"windowEnd should be after windowStart and in the future\"}")
+	}
+
+	existing, err := getCampaignPlan(stub, request.CampaignId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + request.CampaignId + "\"}"
+		return shim.Error(jsonResp)
+	} else if existing != nil {
+		jsonResp = "{\"Error\":\"Campaign already exists: " + request.CampaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	headAsBytes, err := getRecord(stub, _HeaderObj, request.HeaderName)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + request.HeaderName + "\"}"
+		return shim.Error(jsonResp)
+	} else if headAsBytes == nil {
+		jsonResp = "{\"Error\" : \"Header does not exist: " + request.HeaderName + "\"}"
+		return shim.Error(jsonResp)
+	}
+	header := &Header{}
+	json.Unmarshal(headAsBytes, header)
+	if header.HeaderStatus != "Approved" || header.HeaderEntityId != request.EntityId {
+		jsonResp = "{\"Error\":\"Header " + request.HeaderName + " is not Approved for Entity " + request.EntityId + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	tempAsBytes, err := getRecord(stub, _TemplateObj, request.TemplateId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + request.TemplateId + "\"}"
+		return shim.Error(jsonResp)
+	} else if tempAsBytes == nil {
+		jsonResp = "{\"Error\" : \"Template does not exist: " + request.TemplateId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	template := &Template{}
+	json.Unmarshal(tempAsBytes, template)
+	if template.TemplateStatus != "Approved" || template.TemplateEntityId != request.EntityId {
+		jsonResp = "{\"Error\":\"Template " + request.TemplateId + " is not Approved for Entity " + request.EntityId + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	approvals := make(map[string]string)
+	for _, tspId := range request.TSPIds {
+		tspAsBytes, err := getRecord(stub, _TSPObj, tspId)
+		if err != nil || tspAsBytes == nil {
+			jsonResp = "{\"Error\" : \"Telecom Service Provider does not exist: " + tspId + "\"}"
+			return shim.Error(jsonResp)
+		}
+		if live, reason := isTMConnectionLive(stub, tspId, request.TelemarketerId); !live {
+			return shim.Error("{\"Error\":\"" + reason + "\"}")
+		}
+		approvals[tspId] = _CampaignPending
+	}
+
+	campaign := &CampaignPlan{}
+	campaign.DocType = _CampaignPlanObj
+	campaign.CampaignId = request.CampaignId
+	campaign.EntityId = request.EntityId
+	campaign.TelemarketerId = request.TelemarketerId
+	campaign.HeaderName = request.HeaderName
+	campaign.TemplateId = request.TemplateId
+	campaign.Category = request.Category
+	campaign.WindowStart = request.WindowStart
+	campaign.WindowEnd = request.WindowEnd
+	campaign.TargetCount = request.TargetCount
+	campaign.HourlyCap = request.HourlyCap
+	campaign.Approvals = approvals
+	campaign.Status = _CampaignPending
+	campaign.CreatedDate = now.Format(_CampaignTimeLayout)
+	campaign.ModifiedDate = campaign.CreatedDate
+	err = putCampaignPlan(stub, campaign)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Creating Campaign Data Failed\"}"
+		return shim.Error(jsonResp)
+	}
+	campaignAsBytes, _ := json.Marshal(campaign)
+	return shim.Success(campaignAsBytes)
+}
+
+//Approving or Rejecting a scheduled Campaign by an operator
+/*
+	fcnName: approveCampaign
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [campaignId, decision(Approved/Rejected)] or, as before, [campaignId, tspId, decision]
+	the operator approving is the one of the invoker certificate, a tspId given should be of that operator
+	the campaign turns Approved once all its operators approved it and Rejected as soon as one of them rejects it
+	return pb.Response= Payload of the campaign updated
+*/
+func (c *Telco) approveCampaign(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(CampaignId, Decision)")
+	}
+	campaignId := args[0]
+	decision := args[len(args)-1]
+	if decision != _CampaignApproved && decision != _CampaignRejected {
+		return shim.Error("{\"Error\":\"Decision should be Approved or Rejected\"}")
+	}
+	campaign, err := getCampaignPlan(stub, campaignId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	} else if campaign == nil {
+		jsonResp = "{\"Error\" : \"Campaign does not exist: " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if closeIfExpired(campaign, now) {
+		if err := putCampaignPlan(stub, campaign); err != nil {
+			return shim.Error("{\"Error\":\"Updating Campaign Data Failed\"}")
+		}
+		campaignAsBytes, _ := json.Marshal(campaign)
+		return shim.Success(campaignAsBytes)
+	}
+	if campaign.Status != _CampaignPending {
+		jsonResp = "{\"Error\":\"Campaign " + campaignId + " is already " + campaign.Status + "\"}"
+		return shim.Error(jsonResp)
+	}
+	tspId := ""
+	if len(args) == 3 {
+		tspId = args[1]
+	} else {
+		approvers := make([]string, 0, len(campaign.Approvals))
+		for approver := range campaign.Approvals {
+			approvers = append(approvers, approver)
+		}
+		sort.Strings(approvers)
+		//a domain may hold several TSP ids, the first one still pending is decided
+		for _, approver := range approvers {
+			if !isInvokerTSP(stub, approver) {
+				continue
+			}
+			if tspId == "" || campaign.Approvals[approver] == _CampaignPending {
+				tspId = approver
+			}
+			if campaign.Approvals[approver] == _CampaignPending {
+				break
+			}
+		}
+	}
+	if _, ok := campaign.Approvals[tspId]; !ok || !isInvokerTSP(stub, tspId) {
+		jsonResp = "{\"Error\":\"Invoker is not an approver of Campaign " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	campaign.Approvals[tspId] = decision
+	if decision == _CampaignRejected {
+		campaign.Status = _CampaignRejected
+	} else {
+		campaign.Status = _CampaignApproved
+		for _, approval := range campaign.Approvals {
+			if approval != _CampaignApproved {
+				campaign.Status = _CampaignPending
+				break
+			}
+		}
+	}
+	campaign.ModifiedDate = now.Format(_CampaignTimeLayout)
+	err = putCampaignPlan(stub, campaign)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Updating Campaign Data Failed\"}")
+	}
+	campaignAsBytes, _ := json.Marshal(campaign)
+	return shim.Success(campaignAsBytes)
+}
+
+//Counting a delivery submission against the campaign
+/*
+	fcnName: submitCampaignDelivery
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [campaignId, count]
+	the campaign should be Approved and the transaction within its window, the count is rejected when it
+	exceeds the throughput cap of the current hour or the target count of the campaign
+	a campaign whose window has ended is closed instead and nothing is accepted, the TM of the campaign
+	should have a live connection with every operator of the campaign
+	the invoker should be the TM of the campaign or one of the operators which approved it
+	return pb.Response= Payload with accepted count, submitted count, hour count and status
+*/
+func (c *Telco) submitCampaignDelivery(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(CampaignId, Count)")
+	}
+	campaignId := args[0]
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count <= 0 {
+		return shim.Error("{\"Error\":\"Count should be a positive number\"}")
+	}
+	campaign, err := getCampaignPlan(stub, campaignId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	} else if campaign == nil {
+		jsonResp = "{\"Error\" : \"Campaign does not exist: " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	if !isCampaignSubmitter(stub, campaign) {
+		jsonResp = "{\"Error\":\"Invoker is neither the TM nor an approving operator of Campaign " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	accepted := 0
+	if !closeIfExpired(campaign, now) {
+		if campaign.Status != _CampaignApproved {
+			jsonResp = "{\"Error\":\"Campaign " + campaignId + " is " + campaign.Status + "\"}"
+			return shim.Error(jsonResp)
+		}
+		windowStart, _ := parseCampaignTime(campaign.WindowStart)
+		if now.Before(windowStart) {
+			jsonResp = "{\"Error\":\"Campaign " + campaignId + " window starts at " + campaign.WindowStart + "\"}"
+			return shim.Error(jsonResp)
+		}
+		for tspId := range campaign.Approvals {
+			if live, reason := isTMConnectionLive(stub, tspId, campaign.TelemarketerId); !live {
+				return shim.Error("{\"Error\":\"" + reason + "\"}")
+			}
+		}
+		hourSlot := now.Format(_CampaignHourLayout)
+		if campaign.HourSlot != hourSlot {
+			campaign.HourSlot = hourSlot
+			campaign.HourSubmitted = 0
+		}
+		if campaign.HourSubmitted+count > campaign.HourlyCap {
+			jsonResp = fmt.Sprintf("{\"Error\":\"Hourly cap exceeded, %d of %d left for the hour %s\"}", campaign.HourlyCap-campaign.HourSubmitted, campaign.HourlyCap, hourSlot)
+			return shim.Error(jsonResp)
+		}
+		if campaign.Submitted+count > campaign.TargetCount {
+			jsonResp = fmt.Sprintf("{\"Error\":\"Target count exceeded, %d of %d left\"}", campaign.TargetCount-campaign.Submitted, campaign.TargetCount)
+			return shim.Error(jsonResp)
+		}
+		campaign.HourSubmitted += count
+		campaign.Submitted += count
+		campaign.ModifiedDate = now.Format(_CampaignTimeLayout)
+		accepted = count
+	}
+	err = putCampaignPlan(stub, campaign)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Updating Campaign Data Failed\"}")
+	}
+	resultData := map[string]interface{}{
+		"campaignId":         campaignId,
+		"accepted":           accepted,
+		"submittedCount":     campaign.Submitted,
+		"hourSubmittedCount": campaign.HourSubmitted,
+		"campaignStatus":     campaign.Status,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//Closing the campaigns whose window has ended
+/*
+	fcnName: closeExpiredCampaigns
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [empty]
+	campaigns are also closed when they are approved or delivered to after their window, this is for the
+	ones nobody touches and is meant to be invoked periodically
+	return pb.Response= Payload of the campaignIds closed
+*/
+func (c *Telco) closeExpiredCampaigns(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryString := fmt.Sprintf("{\"selector\":{\"campaignDocType\":\"%s\",\"campaignStatus\":{\"$in\":[\"%s\",\"%s\"]},\"windowEnd\":{\"$lte\":\"%s\"}}}", _CampaignPlanObj, _CampaignPending, _CampaignApproved, now.Format(_CampaignTimeLayout))
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+	closed := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		campaign := &CampaignPlan{}
+		if err := json.Unmarshal(queryResponse.Value, campaign); err != nil {
+			continue
+		}
+		if closeIfExpired(campaign, now) {
+			if err := putCampaignPlan(stub, campaign); err != nil {
+				return shim.Error("{\"Error\":\"Closing Campaign " + campaign.CampaignId + " Failed\"}")
+			}
+			closed = append(closed, campaign.CampaignId)
+		}
+	}
+	respJSON, _ := json.Marshal(closed)
+	return shim.Success(respJSON)
+}
+
+//Retrieving the scheduled Campaign given CampaignId from Blockchain
+/*
+	fcnName: getCampaign
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [campaignId]
+	return pb.Response= Payload of the campaign, else error saying does not exist
+*/
+func (c *Telco) getCampaign(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of Arguments. Expecting 1(CampaignId)")
+	}
+	campaignId := args[0]
+	valueAsBytes, err := getRecord(stub, _CampaignPlanObj, campaignId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	} else if valueAsBytes == nil {
+		jsonResp = "{\"Error\" : \"Campaign does not exist: " + campaignId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	return shim.Success(valueAsBytes)
+}
+
+//Retrieving the scheduled Campaigns given EntityId from Blockchain
+/*
+	fcnName: queryCampaignByEntity
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [entityId]
+	return pb.Response= Payload of all the campaigns of the entity
+*/
+func (c *Telco) queryCampaignByEntity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1(EntityId)")
+	}
+	entityId := args[0]
+	queryString := fmt.Sprintf("{\"selector\":{\"campaignDocType\":\"%s\",\"campaignEntityId\":\"%s\"}}", _CampaignPlanObj, entityId)
+	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Failed to get state for " + entityId + "\"}")
+	}
+	return shim.Success(queryResults)
+}
+
+//Retrieving the scheduled Campaigns given Status from Blockchain
+/*
+	fcnName: queryCampaignByStatus
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [status]
+	return pb.Response= Payload of all the campaigns with the status
+*/
+func (c *Telco) queryCampaignByStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1(Status)")
+	}
+	status := args[0]
+	queryString := fmt.Sprintf("{\"selector\":{\"campaignDocType\":\"%s\",\"campaignStatus\":\"%s\"}}", _CampaignPlanObj, status)
+	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Failed to get state for " + status + "\"}")
+	}
+	return shim.Success(queryResults)
+}
+
+//Retrieving the scheduled Campaigns whose window overlaps the given dates from Blockchain
+/*
+	fcnName: queryCampaignByDate
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [StartDate, EndDate] in the format 2006-01-02 15:04:05
+	return pb.Response= Payload of all the campaigns running between the dates
+*/
+func (c *Telco) queryCampaignByDate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2(StartDate, EndDate)")
+	}
+	sDate := args[0]
+	eDate := args[1]
+	if _, err := parseCampaignTime(sDate); err != nil {
+		return shim.Error("{\"Error\":\"StartDate should be in the format " + _CampaignTimeLayout + "\"}")
+	}
+	if _, err := parseCampaignTime(eDate); err != nil {
+		return shim.Error("{\"Error\":\"EndDate should be in the format " + _CampaignTimeLayout + "\"}")
+	}
+	queryString := fmt.Sprintf("{\"selector\":{\"campaignDocType\":\"%s\",\"windowStart\":{\"$lte\":\"%s\"},\"windowEnd\":{\"$gte\":\"%s\"}}}", _CampaignPlanObj, eDate, sDate)
+	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
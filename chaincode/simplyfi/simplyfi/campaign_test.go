@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// newCampaignStub has the header, template and TM1 connections with AI and JI a campaign of E1 needs
+func newCampaignStub(t *testing.T) (*Telco, *testStub) {
+	cc := new(Telco)
+	stub := newTestStub(t, "telco", cc, "airtel.com")
+	validity := time.Now().AddDate(1, 0, 0).Format(_ConnectionDateLayout)
+	for _, tspId := range []string{"AI", "JI"} {
+		stub.put(t, getKey(stub, _TSPObj, tspId), TSP{TSPId: tspId, TSPName: tspId})
+		stub.put(t, getKey(stub, _TMOnTSPObj, tspId, "TM1"), TMWithTSP{TSPId: tspId, TMId: "TM1", ConnectionId: tspId + "TM1", Validity: validity, Status: _ConnectionApproved})
+	}
+	stub.put(t, getKey(stub, _HeaderObj, "AIRTEL"), Header{HeaderName: "AIRTEL", HeaderEntityId: "E1", HeaderStatus: "Approved"})
+	stub.put(t, getKey(stub, _TemplateObj, "T1"), Template{TemplateId: "T1", TemplateEntityId: "E1", TemplateStatus: "Approved"})
+	return cc, stub
+}
+
+// registerTestCampaign registers C1 of TM1 with AI and JI, running from an hour ago for a day, approved by both
+func registerTestCampaign(t *testing.T, cc *Telco, stub *testStub, targetCount, hourlyCap int) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	now := time.Now().In(loc)
+	request := fmt.Sprintf(`{"campaignId":"C1","campaignEntityId":"E1","campaignTmId":"TM1","campaignHeaderName":"AIRTEL","campaignTemplateId":"T1","campaignCategory":"1","windowStart":"%s","windowEnd":"%s","targetCount":%d,"hourlyCap":%d,"tspIds":["AI","JI"]}`,
+		now.Add(-time.Hour).Format(_CampaignTimeLayout), now.AddDate(0, 0, 1).Format(_CampaignTimeLayout), targetCount, hourlyCap)
+	if res := stub.invoke(cc, "registerCampaign", request); res.Status != shim.OK {
+		t.Fatalf("registerCampaign failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "1"); res.Status == shim.OK {
+		t.Fatal("delivery accepted for a campaign not approved")
+	}
+	stub.setTM(t, "airtel.com", "TM1")
+	if res := stub.invoke(cc, "approveCampaign", "C1", _CampaignApproved); res.Status == shim.OK {
+		t.Fatal("campaign approved by the TM on behalf of its operator")
+	}
+	for _, domain := range []string{"airtel.com", "jio.com"} {
+		stub.setDomain(t, domain)
+		if res := stub.invoke(cc, "approveCampaign", "C1", _CampaignApproved); res.Status != shim.OK {
+			t.Fatalf("approveCampaign by %s failed: %s", domain, res.Message)
+		}
+	}
+}
+
+func TestSubmitCampaignDeliveryInvoker(t *testing.T) {
+	cc, stub := newCampaignStub(t)
+	registerTestCampaign(t, cc, stub, 1000, 1000)
+
+	stub.setDomain(t, "vil.com")
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "10"); res.Status == shim.OK {
+		t.Fatal("delivery accepted from an operator not approving the campaign")
+	}
+	stub.setTM(t, "airtel.com", "TM2")
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "10"); res.Status == shim.OK {
+		t.Fatal("delivery accepted from another TM")
+	}
+	stub.setTM(t, "airtel.com", "TM1")
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "10"); res.Status != shim.OK {
+		t.Fatalf("delivery of the TM failed: %s", res.Message)
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "10"); res.Status != shim.OK {
+		t.Fatalf("delivery of an approving operator failed: %s", res.Message)
+	}
+	campaign, _ := getCampaignPlan(stub, "C1")
+	if campaign.Submitted != 20 {
+		t.Fatalf("expected 20 submitted, got %d", campaign.Submitted)
+	}
+}
+
+func TestSubmitCampaignDeliveryCaps(t *testing.T) {
+	cc, stub := newCampaignStub(t)
+	registerTestCampaign(t, cc, stub, 150, 100)
+
+	res := stub.invoke(cc, "submitCampaignDelivery", "C1", "60")
+	if res.Status != shim.OK {
+		t.Fatalf("submitCampaignDelivery failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "41"); res.Status == shim.OK {
+		t.Fatal("delivery accepted over the hourly cap")
+	}
+	res = stub.invoke(cc, "submitCampaignDelivery", "C1", "40")
+	if res.Status != shim.OK {
+		t.Fatalf("submitCampaignDelivery up to the hourly cap failed: %s", res.Message)
+	}
+	var result struct {
+		Accepted int `json:"accepted"`
+		Hour     int `json:"hourSubmittedCount"`
+	}
+	json.Unmarshal(res.Payload, &result)
+	if result.Accepted != 40 || result.Hour != 100 {
+		t.Fatalf("expected 40 accepted and 100 for the hour, got %s", res.Payload)
+	}
+
+	//the next hour starts a new count, the target count still holds
+	campaign, _ := getCampaignPlan(stub, "C1")
+	campaign.HourSlot = "2019-08-01 10"
+	stub.put(t, getKey(stub, _CampaignPlanObj, "C1"), campaign)
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "51"); res.Status == shim.OK {
+		t.Fatal("delivery accepted over the target count")
+	}
+	if res := stub.invoke(cc, "submitCampaignDelivery", "C1", "50"); res.Status != shim.OK {
+		t.Fatalf("delivery up to the target count failed: %s", res.Message)
+	}
+	campaign, _ = getCampaignPlan(stub, "C1")
+	if campaign.Submitted != 150 || campaign.HourSubmitted != 50 {
+		t.Fatalf("expected 150 submitted and 50 for the hour, got %d %d", campaign.Submitted, campaign.HourSubmitted)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +34,18 @@ type Consentdetails struct {
 	UpdatedBy         string `json:"uby"`
 	UpdatedOrg        string `json:"uorg"`
 	CommunicationMode string `json:"cmode"`
+
+	//double opt-in, see consentconfirmation.go
+	ServingOperator string       `json:"sop,omitempty"`   //operator serving the subscriber, the one to confirm
+	ConfirmExpiry   epochSeconds `json:"cexp,omitempty"`  //epoch by which the consent has to be confirmed
+	ConfirmChannel  string       `json:"cchnl,omitempty"` //channel the subscriber confirmed on
+	ConfirmRef      string       `json:"cref,omitempty"`  //reference hash of the confirmation
+	ConfirmedBy     string       `json:"cby,omitempty"`
+	ConfirmTs       string       `json:"cfts,omitempty"`
+
+	RevokedFor string `json:"rvkf,omitempty"` //key of a revocation by the subscriber, e.g. cli:BLKCUB, see consentrevocation.go
+
+	Categories []string `json:"ctgrs,omitempty"` //categories the subscriber agreed to, see consentcategory.go
 }
 
 //ErrorData holds only Error Consesnts
@@ -69,6 +82,15 @@ type ConsentStatus struct {
 	UpdateTs  string `json:"uts"`
 }
 
+//EntitySuspension holds the consents suspended on blacklisting of an entity, with their status before suspension
+type EntitySuspension struct {
+	ObjectType string            `json:"obj"`
+	EntityID   string            `json:"eid"`
+	Consents   map[string]string `json:"consents"` //urn wise status before suspension
+	UpdateTs   string            `json:"uts"`
+	UpdatedBy  string            `json:"uby"`
+}
+
 //EventPayLoad is strcuture for EventPayload
 type EventPayLoad struct {
 	consent Consentdetails
@@ -110,6 +132,16 @@ const _ConsentChurnedStatus = "4"
 const _ConsentRevokedStatus = "3"
 const _ConsentApprovedStatus = "2"
 const _ConsentRaisedStatus = "1"
+
+//_ConsentSuspendedStatus is set only by the entity blacklist cascade, it is not a valid input status
+const _ConsentSuspendedStatus = "5"
+const _SuspensionObjectType = "EntitySuspension"
+
+//suspendConsentsByEntity and restoreConsentsByEntity are accepted only while the blacklist cascade of
+//the entity chaincode has the step of this chaincode (_CascadeTargetName) pending
+const _EntityChaincode = "entity"
+const _EntityChannel = "entitychannel"
+const _CascadeTargetName = "CONSENT"
 const _PurposeBoth = "1"
 
 //below are the error message format
@@ -175,6 +207,17 @@ func isValidStatus(status string) (bool, string) {
 	return true, ""
 }
 
+//isValidStatusToUpdate checks the status given to the update methods, approval (2) is only by confirmConsent
+func isValidStatusToUpdate(status string) (bool, string) {
+	if isValid, errMsg := isValidStatus(status); !isValid {
+		return false, errMsg
+	}
+	if status == _ConsentApprovedStatus {
+		return false, "Consent can be approved only by the serving operator with confirmConsent"
+	}
+	return true, ""
+}
+
 func isValidConsentToModify(c Consentdetails) (bool, string) {
 	if len(c.UpdateTs) == 0 {
 		return false, "UpdateTs is mandatory"
@@ -189,6 +232,9 @@ func isValidConsentToRecord(c Consentdetails) (bool, string) {
 	if len(c.UpdateTs) == 0 {
 		return false, "UpdateTs is mandatory"
 	}
+	if len(c.ServingOperator) == 0 {
+		return false, "ServingOperator (sop) is mandatory"
+	}
 	return true, ""
 }
 
@@ -264,6 +310,13 @@ func (cm *ConsentManager) RecordConsent(stub shim.ChaincodeStubInterface) pb.Res
 
 	_, creater := cm.getInvokerIdentity(stub)
 
+	deadline, err := confirmationDeadline(stub)
+	if err != nil {
+		_consentLogger.Errorf("Unable to compute the confirmation deadline : %v", err)
+		return shim.Error(getErrorMsg("Unable to compute the confirmation deadline"))
+	}
+	cache := headerCategories{}
+
 	for _, eachConsent := range consents {
 
 		//validation
@@ -316,9 +369,32 @@ func (cm *ConsentManager) RecordConsent(stub shim.ChaincodeStubInterface) pb.Res
 
 		}
 
+		if isValid, errMsg := isValidServingOperator(eachConsent); !isValid {
+			_consentLogger.Infof(_Format2, ".Error :", eachConsent.ConsentID, errMsg)
+
+			e := ErrorData{ID: eachConsent.ConsentID, Msg: errMsg}
+			fConsents = append(fConsents, e)
+			continue
+
+		}
+
+		if isValid, errMsg := applyConsentCategories(stub, &eachConsent, cache); !isValid {
+			_consentLogger.Infof(_Format2, ".Error :", eachConsent.ConsentID, errMsg)
+
+			e := ErrorData{ID: eachConsent.ConsentID, Msg: errMsg}
+			fConsents = append(fConsents, e)
+			continue
+
+		}
+
 		//Update each Consent Object
 		eachConsent.ObjectType = _ObjectType
 		eachConsent.Status = _ConsentRaisedStatus
+		eachConsent.ConfirmExpiry = deadline
+		eachConsent.ConfirmChannel = ""
+		eachConsent.ConfirmRef = ""
+		eachConsent.ConfirmedBy = ""
+		eachConsent.ConfirmTs = ""
 
 		eachConsent.Creator = creater
 		eachConsent.UpdatedBy = creater
@@ -357,7 +433,9 @@ func (cm *ConsentManager) RecordConsent(stub shim.ChaincodeStubInterface) pb.Res
 	return shim.Success(respJSON)
 }
 
-//RecordConsentInBulk records the Consents in Bulk. It will make status of all the consents as "Approved (2)", default Purpose as "1".
+//RecordConsentInBulk records the Consents in Bulk. It will make status of all the consents as "ConsentRaised (1)", default Purpose as "1".
+//Like RecordConsent, each consent needs its serving operator (sop), which approves it with ConfirmConsent, and categories (ctgrs)
+//including the one of its header, which are mandatory in bulk.
 //Returned payload contains three blocks - 'failedData', 'successData' and 'sucessPhone'. FailedData contains an array of map with Falure details, SuccessData contains an array of map with details of Successfully Saved Consent and SucessPhone will contains the array of successful phones saved in ledger. This functionality has less validation.
 //--FailedData block is as below:
 //"errormsg": <Reason for Failure>,
@@ -395,6 +473,13 @@ func (cm *ConsentManager) RecordConsentInBulk(stub shim.ChaincodeStubInterface)
 
 	_, creater := cm.getInvokerIdentity(stub)
 
+	deadline, err := confirmationDeadline(stub)
+	if err != nil {
+		_consentLogger.Errorf("Unable to compute the confirmation deadline : %v", err)
+		return shim.Error(getErrorMsg("Unable to compute the confirmation deadline"))
+	}
+	cache := headerCategories{}
+
 	t1 := time.Now()
 	for _, eachConsent := range consents {
 
@@ -418,9 +503,42 @@ func (cm *ConsentManager) RecordConsentInBulk(stub shim.ChaincodeStubInterface)
 			}
 		} */
 
+		if isValid, errMsg := isValidServingOperator(eachConsent); !isValid {
+			_consentLogger.Infof(_Format2, ".Error :", eachConsent.ConsentID, errMsg)
+
+			e := ErrorData{ID: eachConsent.ConsentID, Msg: errMsg}
+			fConsents = append(fConsents, e)
+			continue
+
+		}
+
+		//the categories are not defaulted to the one of the header in bulk
+		if len(eachConsent.Categories) == 0 {
+			_consentLogger.Infof(_Format2, ".Error :", eachConsent.ConsentID, "Categories (ctgrs) are mandatory")
+
+			e := ErrorData{ID: eachConsent.ConsentID, Msg: "Categories (ctgrs) are mandatory"}
+			fConsents = append(fConsents, e)
+			continue
+
+		}
+
+		if isValid, errMsg := applyConsentCategories(stub, &eachConsent, cache); !isValid {
+			_consentLogger.Infof(_Format2, ".Error :", eachConsent.ConsentID, errMsg)
+
+			e := ErrorData{ID: eachConsent.ConsentID, Msg: errMsg}
+			fConsents = append(fConsents, e)
+			continue
+
+		}
+
 		//Update each Consent Object
 		eachConsent.ObjectType = _ObjectType
-		eachConsent.Status = _ConsentApprovedStatus
+		eachConsent.Status = _ConsentRaisedStatus
+		eachConsent.ConfirmExpiry = deadline
+		eachConsent.ConfirmChannel = ""
+		eachConsent.ConfirmRef = ""
+		eachConsent.ConfirmedBy = ""
+		eachConsent.ConfirmTs = ""
 
 		eachConsent.Creator = creater
 		eachConsent.UpdatedBy = creater
@@ -557,7 +675,7 @@ func (cm *ConsentManager) UpdateConsentStatus(stub shim.ChaincodeStubInterface)
 		return shim.Error("{\"error\":\"Invalid Update Timestamp to modify the consent." + errMsg + "\"}")
 	}
 
-	if isValid, errMsg := isValidStatus(newStatus); !isValid {
+	if isValid, errMsg := isValidStatusToUpdate(newStatus); !isValid {
 		_consentLogger.Infof("Invalid Status to update the consent.", errMsg)
 		return shim.Error("{\"error\":\"Invalid Status to update the consent." + errMsg + "\"}")
 	}
@@ -649,7 +767,7 @@ func (cm *ConsentManager) UpdateConsentStatusByIDs(stub shim.ChaincodeStubInterf
 
 	newStatus := args[1]
 	newStatus = strings.TrimSpace(newStatus)
-	if isValid, errMsg := isValidStatus(newStatus); !isValid {
+	if isValid, errMsg := isValidStatusToUpdate(newStatus); !isValid {
 		_consentLogger.Infof("Unable to modify the consent. :", errMsg)
 		jsonResp = "{\"error\":\"Invalid json provided as input\"}" + errMsg + "\"}"
 		return shim.Error(jsonResp)
@@ -774,7 +892,7 @@ func (cm *ConsentManager) UpdateConsentStatusByHeader(stub shim.ChaincodeStubInt
 	// stsT : target status for all the element for the query result
 	stsT := args[1] // can be either 2,3, or any other valid state
 
-	if isValid, errMsg := isValidStatus(stsT); !isValid {
+	if isValid, errMsg := isValidStatusToUpdate(stsT); !isValid {
 		_consentLogger.Infof("Invalid Status to update the consent. :", errMsg)
 		return shim.Error("{\"error\":\"Invalid Status to update the consent." + errMsg + "\"}")
 	}
@@ -1152,7 +1270,7 @@ func (cm *ConsentManager) RevokeActiveConsentsByMsisdn(stub shim.ChaincodeStubIn
 	//setting default value 3 (Revoked)
 	sts := _ConsentRevokedStatus
 	if len(args) == 3 && len(args[2]) > 0 {
-		if isValid, errMsg := isValidStatus(args[2]); !isValid {
+		if isValid, errMsg := isValidStatusToUpdate(args[2]); !isValid {
 			_consentLogger.Infof("Invalid status as Input. :", errMsg)
 			return shim.Error("{\"error\":\"Invalid status as Input." + errMsg + "\"}")
 		}
@@ -1555,6 +1673,255 @@ func (cm *ConsentManager) QueryConsentsWithPagination(stub shim.ChaincodeStubInt
 	return shim.Success(respJSON)
 }
 
+//checkEntityCascade returns an error unless the blacklist cascade of the entity, read from the entity chaincode,
+//has the given action (SUSPEND / RESTORE) pending for this chaincode
+func checkEntityCascade(stub shim.ChaincodeStubInterface, entityID, action string) error {
+	ccArgs := [][]byte{[]byte("getBlacklistCascade"), []byte(entityID)}
+	response := stub.InvokeChaincode(_EntityChaincode, ccArgs, _EntityChannel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("No blacklist cascade for entity %s: %s", entityID, response.Message)
+	}
+	cascade := struct {
+		Action string `json:"action"`
+		Steps  map[string]struct {
+			Status string `json:"sts"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &cascade); err != nil {
+		return fmt.Errorf("Invalid blacklist cascade for entity %s", entityID)
+	}
+	if cascade.Action != action || cascade.Steps[_CascadeTargetName].Status != "P" {
+		return fmt.Errorf("No %s of entity %s pending for %s", action, entityID, _CascadeTargetName)
+	}
+	return nil
+}
+
+//GetEntitySuspension returns the URNs of the consents kept suspended for the entity, read by the entity chaincode
+//to confirm its blacklist cascade
+//args[0] entityID
+func (cm *ConsentManager) GetEntitySuspension(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) != 1 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	authorize, _ := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	entityID := strings.TrimSpace(args[0])
+	suspensionKey, err := stub.CreateCompositeKey(_SuspensionObjectType, []string{entityID})
+	if err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	existingRec, err := stub.GetState(suspensionKey)
+	if err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	items := make([]string, 0)
+	if len(existingRec) > 0 {
+		var suspension EntitySuspension
+		if err := json.Unmarshal(existingRec, &suspension); err != nil {
+			return shim.Error(getErrorMsg(_Format8))
+		}
+		for consentID := range suspension.Consents {
+			items = append(items, consentID)
+		}
+		sort.Strings(items)
+	}
+	resultData := map[string]interface{}{
+		"entityID":  entityID,
+		"suspended": len(existingRec) > 0,
+		"items":     items,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//SuspendConsentsByEntity is invoked on blacklisting of an entity. It sets the status of all the raised (1) and approved (2)
+//consents of the entity to Suspended (5) and keeps their earlier status, so that RestoreConsentsByEntity restores exactly those.
+//args[0] entityID
+//args[1] updateTs
+//Returned payload contains "items" with the URNs of the suspended consents
+func (cm *ConsentManager) SuspendConsentsByEntity(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within SuspendConsentsByEntity")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 2 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	entityID := strings.TrimSpace(args[0])
+	newUpdatedTS := args[1]
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid {
+		return shim.Error(getErrorMsg("Invalid Update Timestamp.", errMsg))
+	}
+
+	if err := checkEntityCascade(stub, entityID, "SUSPEND"); err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey(_SuspensionObjectType, []string{entityID})
+	if err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	if existingRec, _ := stub.GetState(suspensionKey); len(existingRec) > 0 {
+		return shim.Error(getErrorMsg("Consents are already suspended for entity", entityID))
+	}
+
+	consentSearchCriteria := `{
+		"obj":"Consent"	,
+		"eid":"%s",
+		"sts":{"$in":["%s","%s"]}
+	}`
+	authorize, updatedBy := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	consents, err := cm.queryConsentRecords(stub, fmt.Sprintf(consentSearchCriteria, entityID, _ConsentRaisedStatus, _ConsentApprovedStatus), "consentSearchByEntity")
+	if err != nil {
+		return shim.Error(getErrorMsg("Unable to query the consents of entity", entityID, err.Error()))
+	}
+
+	suspension := EntitySuspension{ObjectType: _SuspensionObjectType, EntityID: entityID, Consents: make(map[string]string), UpdateTs: newUpdatedTS, UpdatedBy: updatedBy}
+	items := make([]string, 0)
+
+	for _, consent := range consents {
+		suspension.Consents[consent.ConsentID] = consent.Status
+		consent.Status = _ConsentSuspendedStatus
+		consent.UpdateTs = newUpdatedTS
+		consent.UpdatedBy = updatedBy
+
+		marshalConsentJSON, _ := json.Marshal(consent)
+		if finalErr := stub.PutState(consent.ConsentID, marshalConsentJSON); finalErr != nil {
+			_consentLogger.Errorf(_Format9 + consent.ConsentID)
+			return shim.Error(getErrorMsg(_Format9, consent.ConsentID))
+		}
+		items = append(items, consent.ConsentID)
+	}
+
+	suspensionJSON, _ := json.Marshal(suspension)
+	if err := stub.PutState(suspensionKey, suspensionJSON); err != nil {
+		return shim.Error(getErrorMsg(_Format2, err.Error()))
+	}
+	if retErr := stub.SetEvent(_UpdateEvent, suspensionJSON); retErr != nil {
+		_consentLogger.Errorf("Event not generated for event : UPDATE_CONSENT")
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":   stub.GetTxID(),
+		"entityID": entityID,
+		"items":    items,
+		"message":  "Suspend Consents Successful",
+		"status":   "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//RestoreConsentsByEntity is invoked on un-blacklisting of an entity. It sets the consents suspended by SuspendConsentsByEntity
+//back to the status they had before suspension. Consents whose status got changed after suspension are left untouched.
+//args[0] entityID
+//args[1] updateTs
+//Returned payload contains "items" with the URNs of the restored consents
+func (cm *ConsentManager) RestoreConsentsByEntity(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within RestoreConsentsByEntity")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 2 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	entityID := strings.TrimSpace(args[0])
+	newUpdatedTS := args[1]
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid {
+		return shim.Error(getErrorMsg("Invalid Update Timestamp.", errMsg))
+	}
+
+	if err := checkEntityCascade(stub, entityID, "RESTORE"); err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	suspensionKey, err := stub.CreateCompositeKey(_SuspensionObjectType, []string{entityID})
+	if err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	existingRec, err := stub.GetState(suspensionKey)
+	if err != nil {
+		return shim.Error(getErrorMsg(err.Error()))
+	}
+	authorize, updatedBy := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	items := make([]string, 0)
+	fConsents := make([]ErrorData, 0)
+	//entities blacklisted before the suspension record was introduced have nothing to restore
+	if len(existingRec) == 0 {
+		resultData := map[string]interface{}{
+			"trxnID":     stub.GetTxID(),
+			"entityID":   entityID,
+			"items":      items,
+			"failedData": fConsents,
+			"message":    "No suspended consents for entity",
+			"status":     "true",
+		}
+		respJSON, _ := json.Marshal(resultData)
+		return shim.Success(respJSON)
+	}
+	var suspension EntitySuspension
+	if err := json.Unmarshal(existingRec, &suspension); err != nil {
+		return shim.Error(getErrorMsg(_Format8))
+	}
+
+	//items and failedData list the consents in URN order
+	consentIDs := make([]string, 0, len(suspension.Consents))
+	for consentID := range suspension.Consents {
+		consentIDs = append(consentIDs, consentID)
+	}
+	sort.Strings(consentIDs)
+	for _, consentID := range consentIDs {
+		previousStatus := suspension.Consents[consentID]
+		consentRec, err := stub.GetState(consentID)
+		if err != nil || len(consentRec) == 0 {
+			fConsents = append(fConsents, ErrorData{ID: consentID, Msg: _Format6})
+			continue
+		}
+		var consent Consentdetails
+		if err := json.Unmarshal(consentRec, &consent); err != nil {
+			fConsents = append(fConsents, ErrorData{ID: consentID, Msg: _Format8})
+			continue
+		}
+		if consent.Status != _ConsentSuspendedStatus {
+			fConsents = append(fConsents, ErrorData{ID: consentID, Msg: "Consent status changed after suspension"})
+			continue
+		}
+		consent.Status = previousStatus
+		consent.UpdateTs = newUpdatedTS
+		consent.UpdatedBy = updatedBy
+
+		marshalConsentJSON, _ := json.Marshal(consent)
+		if finalErr := stub.PutState(consent.ConsentID, marshalConsentJSON); finalErr != nil {
+			_consentLogger.Errorf(_Format9 + consent.ConsentID)
+			return shim.Error(getErrorMsg(_Format9, consent.ConsentID))
+		}
+		items = append(items, consent.ConsentID)
+	}
+
+	if err := stub.DelState(suspensionKey); err != nil {
+		return shim.Error(getErrorMsg(_Format3, err.Error()))
+	}
+	if retErr := stub.SetEvent(_UpdateEvent, existingRec); retErr != nil {
+		_consentLogger.Errorf("Event not generated for event : UPDATE_CONSENT")
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":     stub.GetTxID(),
+		"entityID":   entityID,
+		"items":      items,
+		"failedData": fConsents,
+		"message":    "Restore Consents Successful",
+		"status":     "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
 //getConsentsByPhoneNumber returns the consents upon the given MSISDN and Status
 func (cm *ConsentManager) getConsentsByPhoneNumber(stub shim.ChaincodeStubInterface, msisdn, sts string) []Consentdetails {
 	consentSearchCriteria := `{
@@ -1652,7 +2019,17 @@ func getErrorMsg(format string, msg ...string) string {
 }
 
 //retrieveConsentRecords fetches the consent record for trhe given sea4rch criteria
+//Query errors are logged and give no records, use queryConsentRecords where they must fail the transaction
 func (cm *ConsentManager) retrieveConsentRecords(stub shim.ChaincodeStubInterface, criteria string, indexs ...string) []Consentdetails {
+	records, err := cm.queryConsentRecords(stub, criteria, indexs...)
+	if err != nil {
+		_consentLogger.Errorf("Unable to retrieve consents:: %v", err)
+	}
+	return records
+}
+
+//queryConsentRecords fetches the consent records for the given search criteria and returns the query errors
+func (cm *ConsentManager) queryConsentRecords(stub shim.ChaincodeStubInterface, criteria string, indexs ...string) ([]Consentdetails, error) {
 	var finalSelector string
 	records := make([]Consentdetails, 0)
 
@@ -1664,17 +2041,25 @@ func (cm *ConsentManager) retrieveConsentRecords(stub shim.ChaincodeStubInterfac
 	}
 
 	_consentLogger.Infof("Query Selector : %s", finalSelector)
-	resultsIterator, _ := stub.GetQueryResult(finalSelector)
+	resultsIterator, err := stub.GetQueryResult(finalSelector)
+	if err != nil {
+		return records, err
+	}
+	defer resultsIterator.Close()
 	for resultsIterator.HasNext() {
 		record := Consentdetails{}
-		recordBytes, _ := resultsIterator.Next()
-		err := json.Unmarshal(recordBytes.Value, &record)
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return records, err
+		}
+		err = json.Unmarshal(recordBytes.Value, &record)
 		if err != nil {
 			_consentLogger.Infof("Unable to unmarshal consent retrieves:: %v", err)
+			continue
 		}
 		records = append(records, record)
 	}
-	return records
+	return records, nil
 }
 
 func hasElem(s interface{}, elem interface{}) bool {
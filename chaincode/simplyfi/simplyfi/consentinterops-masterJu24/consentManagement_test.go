@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//entityChaincode answers getBlacklistCascade of the entity chaincode with the given action and status of the
+//CONSENT step
+type entityChaincode struct {
+	action string
+	status string
+}
+
+func (ec *entityChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (ec *entityChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if ec.action == "" {
+		return shim.Error("No cascade exists for the EntityID")
+	}
+	cascade := map[string]interface{}{"action": ec.action, "steps": map[string]interface{}{_CascadeTargetName: map[string]string{"sts": ec.status}}}
+	payload, _ := json.Marshal(cascade)
+	return shim.Success(payload)
+}
+
+func newSuspensionStub(t *testing.T) (*SmartContract, *testStub, *entityChaincode) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	entity := new(entityChaincode)
+	stub.MockPeerChaincode(_EntityChaincode+"/"+_EntityChannel, shim.NewMockStub(_EntityChaincode, entity))
+	return cc, stub, entity
+}
+
+func TestSuspendConsentsRequiresPendingCascade(t *testing.T) {
+	cc, stub, entity := newSuspensionStub(t)
+	stub.put(t, "C1", legacyConsent("C1", "9876543210", "E1"))
+
+	for _, state := range []entityChaincode{{}, {action: "RESTORE", status: "P"}, {action: "SUSPEND", status: "C"}} {
+		*entity = state
+		if res := stub.invoke(cc, "suspendConsentsByEntity", "E1", "1564740000"); res.Status == shim.OK {
+			t.Fatalf("suspendConsentsByEntity accepted with cascade %+v", state)
+		}
+	}
+
+	*entity = entityChaincode{action: "SUSPEND", status: "P"}
+	if res := stub.invoke(cc, "suspendConsentsByEntity", "E1", "1564740000"); res.Status != shim.OK {
+		t.Fatalf("suspendConsentsByEntity failed: %s", res.Message)
+	}
+	var consent Consentdetails
+	stub.get(t, "C1", &consent)
+	if consent.Status != _ConsentSuspendedStatus {
+		t.Fatalf("expected the consent suspended, got %s", consent.Status)
+	}
+	res := stub.invoke(cc, "getEntitySuspension", "E1")
+	if res.Status != shim.OK || string(res.Payload) != `{"entityID":"E1","items":["C1"],"suspended":true}` {
+		t.Fatalf("expected C1 suspended, got %d %s %s", res.Status, res.Message, res.Payload)
+	}
+
+	if res := stub.invoke(cc, "restoreConsentsByEntity", "E1", "1564826400"); res.Status == shim.OK {
+		t.Fatal("restoreConsentsByEntity accepted for a pending SUSPEND")
+	}
+	*entity = entityChaincode{action: "RESTORE", status: "P"}
+	if res := stub.invoke(cc, "restoreConsentsByEntity", "E1", "1564826400"); res.Status != shim.OK {
+		t.Fatalf("restoreConsentsByEntity failed: %s", res.Message)
+	}
+	stub.get(t, "C1", &consent)
+	if consent.Status != _ConsentApprovedStatus {
+		t.Fatalf("expected the consent approved again, got %s", consent.Status)
+	}
+	res = stub.invoke(cc, "getEntitySuspension", "E1")
+	if res.Status != shim.OK || string(res.Payload) != `{"entityID":"E1","items":[],"suspended":false}` {
+		t.Fatalf("expected nothing suspended, got %d %s %s", res.Status, res.Message, res.Payload)
+	}
+}
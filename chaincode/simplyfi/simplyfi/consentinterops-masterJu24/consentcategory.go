@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//Templates chaincode, read for the type of the consent template
+const _TemplateChaincode = "templates"
+const _TemplateChannel = "telcocommon"
+
+//Header chaincodes, read for the category of the header of a consent
+const _HeaderChannel = "chheader"
+
+//headerChaincodeOfTemplate - header chaincode of the channel of a consent template type
+var headerChaincodeOfTemplate = map[string]string{
+	"CSSMS":   "header",
+	"CSVOICE": "headervoice",
+}
+
+//_PurposePromotional consents do not cover service explicit messages
+const _PurposePromotional = "2"
+
+//consentCategories - categories a subscriber can agree to, the same as of headers and templates
+var consentCategories = map[string]bool{
+	"0": true,
+	"1": true,
+	"2": true,
+	"3": true,
+	"4": true,
+	"5": true,
+	"6": true,
+	"7": true,
+	"8": true,
+}
+
+//isValidCategories checks the categories and returns them sorted without duplicates
+func isValidCategories(categories []string) ([]string, bool, string) {
+	unique := make([]string, 0, len(categories))
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		if !validEnumEntry(category, consentCategories) {
+			return nil, false, "Categories can be from 0 to 8"
+		}
+		if !hasElem(unique, category) {
+			unique = append(unique, category)
+		}
+	}
+	sort.Strings(unique)
+	return unique, true, ""
+}
+
+//getConsentTemplateType returns the template type (ttyp) of the consent template from the templates chaincode. A template
+//which can not be read is an error
+func getConsentTemplateType(stub shim.ChaincodeStubInterface, templateID string) (string, error) {
+	response := stub.InvokeChaincode(_TemplateChaincode, [][]byte{[]byte("gt"), []byte(templateID)}, _TemplateChannel)
+	if response.Status != shim.OK {
+		_consentLogger.Infof("Consent template %s not read : %s", templateID, response.Message)
+		return "", fmt.Errorf("Consent template %s not found", templateID)
+	}
+	result := struct {
+		Template struct {
+			TemplateType string `json:"ttyp"`
+		} `json:"templates"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		return "", fmt.Errorf("Consent template %s not readable", templateID)
+	}
+	return result.Template.TemplateType, nil
+}
+
+//getHeaderCategory returns the category of the header of a consent. Consent templates have no category, a consent is for
+//the category of its header. The header is read from the header chaincode of the channel of the consent template, or of
+//SMS then voice when no template is given. A header which is not registered is an error
+func getHeaderCategory(stub shim.ChaincodeStubInterface, templateID, cli string) (string, error) {
+	chaincodes := []string{headerChaincodeOfTemplate["CSSMS"], headerChaincodeOfTemplate["CSVOICE"]}
+	if len(templateID) > 0 {
+		templateType, err := getConsentTemplateType(stub, templateID)
+		if err != nil {
+			return "", err
+		}
+		chaincode, ok := headerChaincodeOfTemplate[templateType]
+		if !ok {
+			return "", fmt.Errorf("Template %s is not a consent template", templateID)
+		}
+		chaincodes = []string{chaincode}
+	}
+	for _, chaincode := range chaincodes {
+		response := stub.InvokeChaincode(chaincode, [][]byte{[]byte("qh"), []byte(cli)}, _HeaderChannel)
+		if response.Status != shim.OK {
+			_consentLogger.Infof("Header %s not read from %s : %s", cli, chaincode, response.Message)
+			return "", fmt.Errorf("Header %s not readable", cli)
+		}
+		result := struct {
+			DataOfHeader []struct {
+				Value struct {
+					Category string `json:"ctgr"`
+				} `json:"Value"`
+			} `json:"dataOfHeader"`
+		}{}
+		if err := json.Unmarshal(response.Payload, &result); err != nil {
+			return "", fmt.Errorf("Header %s not readable", cli)
+		}
+		if len(result.DataOfHeader) > 0 {
+			return result.DataOfHeader[0].Value.Category, nil
+		}
+	}
+	return "", fmt.Errorf("Header %s is not registered", cli)
+}
+
+//headerCategories caches the header categories read for the consents of a transaction, by consent template and header
+type headerCategories map[string]string
+
+//of returns the category of the header of the consent, read once per consent template and header
+func (hc headerCategories) of(stub shim.ChaincodeStubInterface, c Consentdetails) (string, error) {
+	key := c.ConsentTemplateID + "/" + c.Cli
+	if category, ok := hc[key]; ok {
+		return category, nil
+	}
+	category, err := getHeaderCategory(stub, c.ConsentTemplateID, c.Cli)
+	if err != nil {
+		return "", err
+	}
+	hc[key] = category
+	return category, nil
+}
+
+//applyConsentCategories validates the categories of a consent to record against its header: they have to include the
+//category of the header, which is the default when none are given
+func applyConsentCategories(stub shim.ChaincodeStubInterface, c *Consentdetails, cache headerCategories) (bool, string) {
+	categories, isValid, errMsg := isValidCategories(c.Categories)
+	if !isValid {
+		return false, errMsg
+	}
+	headerCategory, err := cache.of(stub, *c)
+	if err != nil {
+		return false, err.Error()
+	}
+	if len(headerCategory) > 0 {
+		if len(categories) == 0 {
+			categories = []string{headerCategory}
+		} else if !hasElem(categories, headerCategory) {
+			return false, "Categories need to include " + headerCategory + ", the category of the header " + c.Cli
+		}
+	}
+	if len(categories) == 0 {
+		return false, "Categories (ctgrs) are mandatory"
+	}
+	c.Categories = categories
+	return true, ""
+}
+
+//coversCategory checks whether an approved consent covers a service explicit message of the category at the given epoch.
+//Consents recorded before categories have none and cover no category
+func coversCategory(c Consentdetails, category string, now int64) bool {
+	if c.Status != _ConsentApprovedStatus || c.Purpose == _PurposePromotional {
+		return false
+	}
+	if len(c.ExpiryDate) > 0 {
+		if expiry, err := strconv.ParseInt(c.ExpiryDate, 10, 64); err == nil && now > expiry {
+			return false
+		}
+	}
+	return hasElem(c.Categories, category)
+}
+
+//UpdateConsentCategoriesByIDs changes the categories of the Consents based upon the given ConsentIds as passed in the args[0]
+//args[0] string array of URN( consentIDs)
+//args[1] string array of categories, from 0 to 8
+//args[2] updateTs is the time to update the consent records
+//Returned payload contains two blocks - 'failedData' and 'successData', as UpdateConsentPurposeByIDs
+func (cm *ConsentManager) UpdateConsentCategoriesByIDs(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within UpdateConsentCategoriesByIDs")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 3 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	arr := make([]string, 0)
+	if err := json.Unmarshal([]byte(args[0]), &arr); err != nil {
+		return shim.Error(getErrorMsg(_Format0))
+	}
+	newCategories := make([]string, 0)
+	if err := json.Unmarshal([]byte(args[1]), &newCategories); err != nil {
+		return shim.Error(getErrorMsg(_Format0))
+	}
+	newCategories, isValid, errMsg := isValidCategories(newCategories)
+	if !isValid || len(newCategories) == 0 {
+		return shim.Error(getErrorMsg("Invalid Categories to modify the consent.", errMsg))
+	}
+	newUpdatedTS := args[2]
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid {
+		return shim.Error(getErrorMsg("Invalid Update TS to modify the consent.", errMsg))
+	}
+
+	//Success Consents Message
+	sConsents := make([]SuccessData, 0)
+	//Failed Consesnts Message
+	fConsents := make([]ErrorData, 0)
+
+	_, updatedBy := cm.getInvokerIdentity(stub)
+	cache := headerCategories{}
+
+	for _, searchConsentID := range arr {
+		searchConsentID = strings.TrimSpace(searchConsentID)
+		existingRec, err := stub.GetState(searchConsentID)
+		if err != nil || len(existingRec) == 0 {
+			fConsents = append(fConsents, ErrorData{ID: searchConsentID, Msg: _Format6})
+			continue
+		}
+		var consent Consentdetails
+		if err := json.Unmarshal(existingRec, &consent); err != nil {
+			fConsents = append(fConsents, ErrorData{ID: searchConsentID, Msg: _Format8})
+			continue
+		}
+		headerCategory, err := cache.of(stub, consent)
+		if err != nil {
+			fConsents = append(fConsents, ErrorData{ID: searchConsentID, Msg: err.Error()})
+			continue
+		}
+		if len(headerCategory) > 0 && !hasElem(newCategories, headerCategory) {
+			fConsents = append(fConsents, ErrorData{ID: searchConsentID, Msg: "Categories need to include " + headerCategory + ", the category of the header " + consent.Cli})
+			continue
+		}
+
+		consent.Categories = newCategories
+		consent.UpdateTs = newUpdatedTS
+		consent.UpdatedBy = updatedBy
+
+		consentJSON, _ := json.Marshal(consent)
+		if err := stub.PutState(consent.ConsentID, consentJSON); err != nil {
+			_consentLogger.Errorf(_Format9 + consent.ConsentID)
+			fConsents = append(fConsents, ErrorData{ID: consent.ConsentID, Msg: _Format9})
+			continue
+		}
+		sConsents = append(sConsents, SuccessData{TrxnID: stub.GetTxID(), ConsID: consent.ConsentID, Message: "Update Consent Categories Successful", ConsentDets: consent})
+	}
+	if len(sConsents) > 0 {
+		payloadbytes, _ := json.Marshal(sConsents)
+		if retErr := stub.SetEvent(_UpdateEvent, payloadbytes); retErr != nil {
+			_consentLogger.Errorf("Event not generated for event : UPDATE_CONSENT")
+		}
+	}
+
+	totalResponse := TotalResponse{SuccesConsents: sConsents, FailedConsents: fConsents}
+	respJSON, _ := json.Marshal(totalResponse)
+	return shim.Success(respJSON)
+}
+
+//CheckConsentsForScrub is the consent check of service explicit (SE) scrubbing. A MSISDN can be sent a message of the
+//header and category only with an approved consent for the header, not expired, not only promotional (2) and covering
+//the category of the message.
+//The scrub chaincodes (scrubsms, scrubvoice) invoke it for a SE scrub with the cli and ctgr of the scrub and the MSISDNs of
+//the scrubbed file, given to them as transient data so that they never reach the ledger, and refuse the scrub when any
+//MSISDN is notCovered.
+//args[0] cli (header)
+//args[1] category of the message (of its template), from 0 to 8
+//args[2] string array of MSISDNs
+//Returns "covered" with the MSISDN wise consent URN and "notCovered" with the MSISDNs without such a consent
+func (cm *ConsentManager) CheckConsentsForScrub(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within CheckConsentsForScrub")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 3 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	cli := strings.TrimSpace(args[0])
+	category := strings.TrimSpace(args[1])
+	if len(cli) == 0 {
+		return shim.Error(getErrorMsg("Cli/Header is mandatory"))
+	}
+	if !validEnumEntry(category, consentCategories) {
+		return shim.Error(getErrorMsg("Categories can be from 0 to 8"))
+	}
+	msisdns := make([]string, 0)
+	if err := json.Unmarshal([]byte(args[2]), &msisdns); err != nil {
+		return shim.Error(getErrorMsg(_Format0))
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(getErrorMsg("Unable to read the transaction timestamp"))
+	}
+
+	covered := make(map[string]string)
+	notCovered := make([]string, 0)
+	for _, msisdn := range msisdns {
+		msisdn = strings.TrimSpace(msisdn)
+		for _, consent := range cm.getConsentsByMsisdnCliStatus(stub, msisdn, cli, _ConsentApprovedStatus) {
+			if coversCategory(consent, category, now) {
+				covered[msisdn] = consent.ConsentID
+				break
+			}
+		}
+		if _, ok := covered[msisdn]; !ok {
+			notCovered = append(notCovered, msisdn)
+		}
+	}
+
+	resultData := map[string]interface{}{
+		"cli":        cli,
+		"ctgr":       category,
+		"covered":    covered,
+		"notCovered": notCovered,
+		"status":     "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
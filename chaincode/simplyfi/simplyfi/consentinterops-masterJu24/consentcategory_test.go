@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//templatesChaincode answers gt of the templates chaincode with templates of the given types, shaped as on the ledger:
+//consent templates have an empty ctgr
+type templatesChaincode map[string]string
+
+func (tc templatesChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (tc templatesChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	templateType, ok := tc[args[0]]
+	if !ok {
+		return shim.Error("No Existing Template for TemplateID- " + args[0])
+	}
+	template := map[string]interface{}{
+		"obj": "Template", "urn": args[0], "peid": "E1", "cli": []string{"BLKCUB"}, "tname": "Consent " + args[0], "ttyp": templateType,
+		"ctyp": "SE", "csty": "1", "coty": "", "vars": "", "ctgr": "", "tcont": "Reply Y to receive the updates of BLKCUB",
+		"tmid": "", "crtr": "airtel.com", "cts": "1571470000", "uby": "airtel.com", "uts": "1571470000", "sts": map[string]string{"AI": "A"}, "ver": 1,
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"templates": template})
+	return shim.Success(payload)
+}
+
+//headerChaincode answers qh of a header chaincode with the category of its headers
+type headerChaincode map[string]string
+
+func (hc headerChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (hc headerChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	headerNotExist := make([]map[string]interface{}, 0)
+	headerExist := make([]string, 0)
+	dataOfHeader := make([]map[string]interface{}, 0)
+	for _, cli := range args {
+		category, ok := hc[cli]
+		if !ok {
+			headerNotExist = append(headerNotExist, map[string]interface{}{"Header_Name": cli, "Value": "Record does not exist for Header"})
+			continue
+		}
+		headerExist = append(headerExist, cli)
+		value := map[string]interface{}{"hid": "H" + cli, "peid": "E1", "htyp": "SE", "cli": cli, "ctgr": category, "sts": map[string]string{"AI": "A"}}
+		dataOfHeader = append(dataOfHeader, map[string]interface{}{"Header_Name": cli, "Value": value})
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"headerNotExist": headerNotExist, "headerExist": headerExist, "dataOfHeader": dataOfHeader})
+	return shim.Success(payload)
+}
+
+//newCategoryStub returns a consent stub with the SMS consent template CST1, the voice consent template CST2 and the content
+//template CT1 on the templates chaincode, the SMS header BLKCUB of category 3 and the voice header 1601234567 of category 5
+func newCategoryStub(t *testing.T) *testStub {
+	stub := newTestStub(t, "consent", new(SmartContract), "airtel.com")
+	templates := templatesChaincode{"CST1": "CSSMS", "CST2": "CSVOICE", "CT1": "CTSMS"}
+	stub.MockPeerChaincode(_TemplateChaincode+"/"+_TemplateChannel, shim.NewMockStub("templates", templates))
+	sms := headerChaincode{"BLKCUB": "3"}
+	stub.MockPeerChaincode(headerChaincodeOfTemplate["CSSMS"]+"/"+_HeaderChannel, shim.NewMockStub("header", sms))
+	voice := headerChaincode{"1601234567": "5"}
+	stub.MockPeerChaincode(headerChaincodeOfTemplate["CSVOICE"]+"/"+_HeaderChannel, shim.NewMockStub("headervoice", voice))
+	return stub
+}
+
+func TestHeaderCategory(t *testing.T) {
+	stub := newCategoryStub(t)
+
+	tests := []struct {
+		name       string
+		templateID string
+		cli        string
+		category   string
+		isValid    bool
+	}{
+		{"SMS consent template", "CST1", "BLKCUB", "3", true},
+		{"voice consent template", "CST2", "1601234567", "5", true},
+		{"no template", "", "BLKCUB", "3", true},
+		{"no template, voice header", "", "1601234567", "5", true},
+		{"header of another channel", "CST1", "1601234567", "", false},
+		{"header not registered", "", "NOHDR", "", false},
+		{"template not found", "CST9", "BLKCUB", "", false},
+		{"content template", "CT1", "BLKCUB", "", false},
+	}
+	for _, test := range tests {
+		category, err := getHeaderCategory(stub, test.templateID, test.cli)
+		if test.isValid && (err != nil || category != test.category) {
+			t.Fatalf("%s: expected category %s, got %q %v", test.name, test.category, category, err)
+		}
+		if !test.isValid && err == nil {
+			t.Fatalf("%s: expected an error, got category %q", test.name, category)
+		}
+	}
+}
+
+func TestApplyConsentCategories(t *testing.T) {
+	stub := newCategoryStub(t)
+	cache := headerCategories{}
+
+	consent := Consentdetails{ConsentTemplateID: "CST1", Cli: "BLKCUB"}
+	if isValid, errMsg := applyConsentCategories(stub, &consent, cache); !isValid || len(consent.Categories) != 1 || consent.Categories[0] != "3" {
+		t.Fatalf("expected the category of the header, got %v %v %s", consent.Categories, isValid, errMsg)
+	}
+	consent = Consentdetails{ConsentTemplateID: "CST1", Cli: "BLKCUB", Categories: []string{"4", "3", "4"}}
+	if isValid, errMsg := applyConsentCategories(stub, &consent, cache); !isValid || len(consent.Categories) != 2 || consent.Categories[0] != "3" {
+		t.Fatalf("expected categories 3 and 4, got %v %v %s", consent.Categories, isValid, errMsg)
+	}
+	consent = Consentdetails{ConsentTemplateID: "CST1", Cli: "BLKCUB", Categories: []string{"4"}}
+	if isValid, _ := applyConsentCategories(stub, &consent, cache); isValid {
+		t.Fatal("consent accepted without the category of its header")
+	}
+	consent = Consentdetails{ConsentTemplateID: "CST9", Cli: "BLKCUB", Categories: []string{"3"}}
+	if isValid, _ := applyConsentCategories(stub, &consent, cache); isValid {
+		t.Fatal("consent accepted with a template not on the templates chaincode")
+	}
+}
+
+func TestBulkConsentsNeedCategories(t *testing.T) {
+	stub := newCategoryStub(t)
+	withoutCategories := consentRequest("C1", "airtel.com")
+	delete(withoutCategories, "ctgrs")
+	otherCategory := consentRequest("C2", "airtel.com")
+	otherCategory["ctgrs"] = []string{"4"}
+	result := recordConsents(t, stub, "bulkConsentsUpload", withoutCategories, otherCategory, consentRequest("C3", "airtel.com"))
+	if len(result.FailedConsents) != 2 || result.FailedConsents[0].ID != "C1" || result.FailedConsents[1].ID != "C2" {
+		t.Fatalf("expected C1 without ctgrs and C2 without the header category refused, got %+v", result.FailedConsents)
+	}
+	if len(result.SuccesConsents) != 1 || result.SuccesConsents[0].ConsID != "C3" {
+		t.Fatalf("expected C3 recorded, got %+v", result.SuccesConsents)
+	}
+}
+
+func TestCheckConsentsForScrub(t *testing.T) {
+	stub := newCategoryStub(t)
+	covering := subscriberConsent("C1", "E1", "BLKCUB", _ConsentApprovedStatus, "airtel.com")
+	covering["ctgrs"] = []string{"3"}
+	stub.put(t, "C1", covering)
+	//consents recorded before categories have none and cover no category
+	legacy := subscriberConsent("C2", "E1", "BLKCUB", _ConsentApprovedStatus, "airtel.com")
+	legacy["msisdn"] = "9876543211"
+	stub.put(t, "C2", legacy)
+	promotional := subscriberConsent("C3", "E1", "BLKCUB", _ConsentApprovedStatus, "airtel.com")
+	promotional["msisdn"] = "9876543212"
+	promotional["pur"] = _PurposePromotional
+	promotional["ctgrs"] = []string{"3"}
+	stub.put(t, "C3", promotional)
+
+	res := stub.invoke(new(SmartContract), "checkConsentsForScrub", "BLKCUB", "3", `["9876543210","9876543211","9876543212","9876543213"]`)
+	if res.Status != shim.OK {
+		t.Fatalf("checkConsentsForScrub failed: %s", res.Message)
+	}
+	result := struct {
+		Covered    map[string]string `json:"covered"`
+		NotCovered []string          `json:"notCovered"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Covered) != 1 || result.Covered["9876543210"] != "C1" || len(result.NotCovered) != 3 {
+		t.Fatalf("expected only 9876543210 covered by C1, got %v %v", result.Covered, result.NotCovered)
+	}
+	res = stub.invoke(new(SmartContract), "checkConsentsForScrub", "BLKCUB", "4", `["9876543210"]`)
+	if err := json.Unmarshal(res.Payload, &result); err != nil || len(result.NotCovered) != 1 {
+		t.Fatalf("expected 9876543210 not covered for category 4, got %s", res.Payload)
+	}
+}
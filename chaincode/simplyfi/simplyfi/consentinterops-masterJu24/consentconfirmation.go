@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//_ConfirmWindowKey keeps the seconds within which a raised consent has to be confirmed
+const _ConfirmWindowKey = "CONSENT_CONFIRM_WINDOW"
+
+//_DefaultConfirmWindow is 48 hours, saved by Init when no window is on the ledger
+const _DefaultConfirmWindow = 172800
+
+//_ExpireBatchSize is the maximum number of raised consents ExpireConsents expires in one transaction
+const _ExpireBatchSize = 500
+
+//_ConsentExpiredStatus is set only by ExpireConsents on raised consents not confirmed in time, it is not a valid input status
+const _ConsentExpiredStatus = "6"
+
+const _ConfirmEvent = "CONFIRM_CONSENT"
+
+//confirmChannel - channels on which the subscriber confirms a consent
+var confirmChannel = map[string]bool{
+	"1": true, //WEB
+	"2": true, //SMS
+	"3": true, //IVR
+	"4": true, //USSD
+	"5": true, //APP
+}
+
+var confirmRefRegex = regexp.MustCompile("^[0-9a-f]{64}$")
+
+//operatorDomains - domains of the operators, the serving operator (sop) of a consent is one of them
+var operatorDomains = map[string]bool{
+	"airtel.com":             true, //Airtel
+	"vil.com":                true, //Vodafone Idea
+	"bsnl.com":               true, //BSNL
+	"mtnl.com":               true, //MTNL
+	"qtl.infotelconnect.com": true, //QTL
+	"tata.com":               true, //TATA
+	"jio.com":                true, //JIO
+}
+
+//epochSeconds is an epoch stored as a JSON number. Consents raised before it was a number have it as a string,
+//which is read as well and converted by migrateNumericConfirmExpiry
+type epochSeconds int64
+
+func (e *epochSeconds) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if len(value) == 0 || value == "null" {
+		*e = 0
+		return nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cexp needs to be an epoch in seconds: %v", err)
+	}
+	*e = epochSeconds(seconds)
+	return nil
+}
+
+//isValidServingOperator checks that the serving operator of a raised consent is the domain of an operator. It is
+//often the operator raising the consent, which then confirms it as well
+func isValidServingOperator(c Consentdetails) (bool, string) {
+	if !operatorDomains[c.ServingOperator] {
+		return false, "ServingOperator (sop) needs to be the domain of an operator"
+	}
+	return true, ""
+}
+
+//getConfirmWindow returns the confirmation window in seconds, the default one if not set
+func getConfirmWindow(stub shim.ChaincodeStubInterface) (int64, error) {
+	windowBytes, err := stub.GetState(_ConfirmWindowKey)
+	if err != nil {
+		return 0, err
+	}
+	if windowBytes == nil {
+		return _DefaultConfirmWindow, nil
+	}
+	return strconv.ParseInt(string(windowBytes), 10, 64)
+}
+
+//registerDefaultConfirmWindow saves the default confirmation window when there is none on the ledger
+func registerDefaultConfirmWindow(stub shim.ChaincodeStubInterface) error {
+	windowBytes, err := stub.GetState(_ConfirmWindowKey)
+	if err != nil || windowBytes != nil {
+		return err
+	}
+	return stub.PutState(_ConfirmWindowKey, []byte(strconv.Itoa(_DefaultConfirmWindow)))
+}
+
+//getTxSeconds returns the transaction timestamp in epoch seconds, the same on all the endorsers
+func getTxSeconds(stub shim.ChaincodeStubInterface) (int64, error) {
+	tst, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	return tst.Seconds, nil
+}
+
+//confirmationDeadline returns the epoch by which a consent raised in this transaction has to be confirmed
+func confirmationDeadline(stub shim.ChaincodeStubInterface) (epochSeconds, error) {
+	window, err := getConfirmWindow(stub)
+	if err != nil {
+		return 0, err
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return 0, err
+	}
+	return epochSeconds(now + window), nil
+}
+
+//isConfirmationDue checks whether the confirmation window of the consent is over at the given epoch.
+//Consents raised before the confirmation flow have no deadline and do not expire
+func isConfirmationDue(c Consentdetails, now int64) bool {
+	return c.ConfirmExpiry > 0 && now > int64(c.ConfirmExpiry)
+}
+
+//ConfirmConsent is the second step of the double opt-in. The operator serving the subscriber records the confirmation
+//given by the subscriber and the consent moves from ConsentRaised (1) to Approved (2). Consents without a serving
+//operator can not be confirmed.
+//args[0] consentId(URN)
+//args[1] confirmation channel - (1)WEB, (2)SMS, (3)IVR, (4)USSD or (5)APP
+//args[2] reference hash of the confirmation (SHA-256, hex) e.g. of the reply SMS or the IVR recording
+//args[3] updateTs
+//Returns "trxnID", "consentID", "message" and the confirmed "consent"
+func (cm *ConsentManager) ConfirmConsent(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within ConfirmConsent")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 4 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	consentID := strings.TrimSpace(args[0])
+	channel := strings.TrimSpace(args[1])
+	reference := strings.ToLower(strings.TrimSpace(args[2]))
+	newUpdatedTS := args[3]
+
+	if !validEnumEntry(channel, confirmChannel) {
+		return shim.Error(getErrorMsg("Confirmation channel can be either (1)WEB, (2)SMS, (3)IVR, (4)USSD or (5)APP"))
+	}
+	if !confirmRefRegex.MatchString(reference) {
+		return shim.Error(getErrorMsg("Confirmation reference needs to be a SHA-256 hash in hex"))
+	}
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid || len(newUpdatedTS) == 0 {
+		return shim.Error(getErrorMsg("Invalid Update Timestamp to modify the consent.", errMsg))
+	}
+
+	existingRec, err := stub.GetState(consentID)
+	if err != nil {
+		return shim.Error(getErrorMsg("Error while fetching Consent with id", consentID))
+	}
+	if len(existingRec) == 0 {
+		return shim.Error(getErrorMsg("Consent does not exist with id", consentID))
+	}
+	var consent Consentdetails
+	if err := json.Unmarshal(existingRec, &consent); err != nil {
+		return shim.Error(getErrorMsg(_Format8))
+	}
+	if consent.Status != _ConsentRaisedStatus {
+		return shim.Error(getErrorMsg("Only a raised (1) consent can be confirmed. Status is", consent.Status))
+	}
+
+	authorize, confirmedBy := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	if len(consent.ServingOperator) == 0 {
+		return shim.Error(getErrorMsg("Consent has no serving operator to confirm it, it needs to be raised again"))
+	}
+	if consent.ServingOperator != confirmedBy {
+		return shim.Error(getErrorMsg("Consent can be confirmed only by the serving operator", consent.ServingOperator))
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(getErrorMsg("Unable to read the transaction timestamp"))
+	}
+	if isConfirmationDue(consent, now) {
+		return shim.Error(getErrorMsg("Confirmation window is over, the consent needs to be raised again"))
+	}
+
+	consent.Status = _ConsentApprovedStatus
+	consent.ConfirmChannel = channel
+	consent.ConfirmRef = reference
+	consent.ConfirmedBy = confirmedBy
+	consent.ConfirmTs = newUpdatedTS
+	consent.UpdateTs = newUpdatedTS
+	consent.UpdatedBy = confirmedBy
+
+	consentJSON, _ := json.Marshal(consent)
+	if err := stub.PutState(consent.ConsentID, consentJSON); err != nil {
+		_consentLogger.Errorf(_Format9 + consent.ConsentID)
+		return shim.Error(getErrorMsg(_Format9, consent.ConsentID))
+	}
+	if retErr := stub.SetEvent(_ConfirmEvent, consentJSON); retErr != nil {
+		_consentLogger.Errorf(_Format5, _ConfirmEvent)
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":    stub.GetTxID(),
+		"consentID": consent.ConsentID,
+		"message":   "Consent Confirmation Successful",
+		"consent":   consent,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//ExpireConsents sets the raised (1) consents not confirmed within the confirmation window to Expired (6), at most
+//_ExpireBatchSize of them per transaction. It is invoked periodically by the operators.
+//args[0] updateTs
+//Returned payload contains "items" with the URNs of the expired consents and "done", false when more are left
+func (cm *ConsentManager) ExpireConsents(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within ExpireConsents")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 1 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	newUpdatedTS := args[0]
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid {
+		return shim.Error(getErrorMsg("Invalid Update Timestamp.", errMsg))
+	}
+	authorize, updatedBy := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	now, err := getTxSeconds(stub)
+	if err != nil {
+		return shim.Error(getErrorMsg("Unable to read the transaction timestamp"))
+	}
+
+	consentSearchCriteria := `{
+		"obj":"Consent"	,
+		"sts":"%s",
+		"cexp":{"$lt":%d}
+	}`
+	//the expired consents no longer match, the next transaction continues with the others
+	values, done, err := queryBatch(stub, fmt.Sprintf(consentSearchCriteria, _ConsentRaisedStatus, now), _ExpireBatchSize)
+	if err != nil {
+		_consentLogger.Errorf("Unable to retrieve consents:: %v", err)
+		return shim.Error(getErrorMsg("Unable to retrieve the raised consents", err.Error()))
+	}
+
+	items := make([]string, 0)
+	for _, value := range values {
+		var consent Consentdetails
+		if err := json.Unmarshal(value, &consent); err != nil {
+			return shim.Error(getErrorMsg(_Format8))
+		}
+		consent.Status = _ConsentExpiredStatus
+		consent.UpdateTs = newUpdatedTS
+		consent.UpdatedBy = updatedBy
+
+		consentJSON, _ := json.Marshal(consent)
+		if err := stub.PutState(consent.ConsentID, consentJSON); err != nil {
+			_consentLogger.Errorf(_Format9 + consent.ConsentID)
+			return shim.Error(getErrorMsg(_Format9, consent.ConsentID))
+		}
+		items = append(items, consent.ConsentID)
+	}
+	if len(items) > 0 {
+		itemsJSON, _ := json.Marshal(items)
+		if retErr := stub.SetEvent(_UpdateEvent, itemsJSON); retErr != nil {
+			_consentLogger.Errorf("Event not generated for event : UPDATE_CONSENT")
+		}
+	}
+
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"items":   items,
+		"done":    done,
+		"message": "Expire Consents Successful",
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//SetConfirmWindow sets the seconds within which a raised consent has to be confirmed. It applies to the consents raised afterwards.
+//args[0] window in seconds
+func (cm *ConsentManager) SetConfirmWindow(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 1 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	authorize, _ := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	window, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil || window < 1 {
+		return shim.Error(getErrorMsg("Confirmation window needs to be a number of seconds"))
+	}
+	if err := stub.PutState(_ConfirmWindowKey, []byte(strconv.FormatInt(window, 10))); err != nil {
+		return shim.Error(getErrorMsg(_Format2, err.Error()))
+	}
+	respJSON, _ := json.Marshal(map[string]interface{}{"trxnID": stub.GetTxID(), "window": window, "status": "true"})
+	return shim.Success(respJSON)
+}
+
+//GetConfirmWindow returns the seconds within which a raised consent has to be confirmed
+func (cm *ConsentManager) GetConfirmWindow(stub shim.ChaincodeStubInterface) pb.Response {
+	window, err := getConfirmWindow(stub)
+	if err != nil {
+		return shim.Error(getErrorMsg("Unable to read the confirmation window"))
+	}
+	respJSON, _ := json.Marshal(map[string]interface{}{"window": window, "status": "true"})
+	return shim.Success(respJSON)
+}
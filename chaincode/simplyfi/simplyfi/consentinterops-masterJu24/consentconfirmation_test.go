@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const testConfirmRef = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+//raisedConsent is a consent raised by airtel.com waiting for the confirmation of the serving operator
+func raisedConsent(urn, servingOperator string) map[string]interface{} {
+	consent := legacyConsent(urn, "9999999999", "E1")
+	consent["sts"] = _ConsentRaisedStatus
+	consent["pur"] = _PurposeBoth
+	if len(servingOperator) > 0 {
+		consent["sop"] = servingOperator
+	}
+	return consent
+}
+
+func TestConfirmConsentByServingOperator(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", raisedConsent("C1", "jio.com"))
+
+	if res := stub.invoke(cc, "confirmConsent", "C1", "2", testConfirmRef, "1760860800"); res.Status == shim.OK {
+		t.Fatal("consent confirmed by the raising operator")
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "confirmConsent", "C1", "2", testConfirmRef, "1760860800"); res.Status != shim.OK {
+		t.Fatalf("confirmConsent failed: %s", res.Message)
+	}
+	var consent Consentdetails
+	stub.get(t, "C1", &consent)
+	if consent.Status != _ConsentApprovedStatus || consent.ConfirmedBy != "jio.com" {
+		t.Fatalf("expected the consent approved by jio.com, got %q by %q", consent.Status, consent.ConfirmedBy)
+	}
+}
+
+func TestConfirmConsentWithoutServingOperator(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", raisedConsent("C1", ""))
+
+	for _, domain := range []string{"airtel.com", "jio.com"} {
+		stub.setDomain(t, domain)
+		if res := stub.invoke(cc, "confirmConsent", "C1", "2", testConfirmRef, "1760860800"); res.Status == shim.OK {
+			t.Fatalf("consent without sop confirmed by %s", domain)
+		}
+	}
+}
+
+func TestServingOperatorOfRaisedConsent(t *testing.T) {
+	for _, test := range []struct {
+		servingOperator string
+		valid           bool
+	}{
+		{"jio.com", true},
+		{"airtel.com", true},
+		{"example.com", false},
+		{"", false},
+	} {
+		consent := Consentdetails{ServingOperator: test.servingOperator}
+		if valid, errMsg := isValidServingOperator(consent); valid != test.valid {
+			t.Errorf("sop %q: expected valid %v, got %v %s", test.servingOperator, test.valid, valid, errMsg)
+		}
+	}
+}
+
+//consentRequest is a consent of recordConsent or bulkConsentsUpload for the template CST1 of newCategoryStub
+func consentRequest(urn, servingOperator string) map[string]interface{} {
+	return map[string]interface{}{"urn": urn, "msisdn": "9876543210", "cstid": "CST1", "eid": "E1", "cli": "BLKCUB", "sts": "1", "pur": "1",
+		"cmode": "2", "uorg": "airtel.com", "sop": servingOperator, "ctgrs": []string{"3"}, "cts": "1760860800", "uts": "1760860800"}
+}
+
+func recordConsents(t *testing.T, stub *testStub, function string, consents ...map[string]interface{}) TotalResponse {
+	request, _ := json.Marshal(consents)
+	res := stub.invoke(new(SmartContract), function, string(request))
+	if res.Status != shim.OK {
+		t.Fatalf("%s failed: %s", function, res.Message)
+	}
+	var result TotalResponse
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+//the subscriber is most often served by the operator raising the consent, which then confirms it
+func TestConfirmConsentOfRaisingOperator(t *testing.T) {
+	stub := newCategoryStub(t)
+	result := recordConsents(t, stub, "recordConsent", consentRequest("C1", "airtel.com"))
+	if len(result.FailedConsents) != 0 {
+		t.Fatalf("consent served by the raising operator refused: %+v", result.FailedConsents)
+	}
+	var raised map[string]interface{}
+	stub.get(t, "C1", &raised)
+	if cexp, ok := raised["cexp"].(float64); raised["sts"] != _ConsentRaisedStatus || !ok || cexp <= 0 {
+		t.Fatalf("expected a raised consent with a numeric cexp, got %v %v", raised["sts"], raised["cexp"])
+	}
+	if res := stub.invoke(new(SmartContract), "confirmConsent", "C1", "2", testConfirmRef, "1760860801"); res.Status != shim.OK {
+		t.Fatalf("confirmConsent failed: %s", res.Message)
+	}
+	var consent Consentdetails
+	stub.get(t, "C1", &consent)
+	if consent.Status != _ConsentApprovedStatus || consent.ConfirmedBy != "airtel.com" {
+		t.Fatalf("expected the consent approved by airtel.com, got %q by %q", consent.Status, consent.ConfirmedBy)
+	}
+}
+
+func TestBulkConsentsStartRaised(t *testing.T) {
+	stub := newCategoryStub(t)
+	result := recordConsents(t, stub, "bulkConsentsUpload", consentRequest("C1", "jio.com"), consentRequest("C2", ""))
+	if len(result.SuccesConsents) != 1 || len(result.FailedConsents) != 1 || result.FailedConsents[0].ID != "C2" {
+		t.Fatalf("expected C1 recorded and C2 without sop refused, got %+v", result)
+	}
+	var consent Consentdetails
+	stub.get(t, "C1", &consent)
+	if consent.Status != _ConsentRaisedStatus || consent.ConfirmExpiry == 0 {
+		t.Fatalf("expected C1 raised with a deadline, got %q %d", consent.Status, consent.ConfirmExpiry)
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(new(SmartContract), "confirmConsent", "C1", "3", testConfirmRef, "1760860801"); res.Status != shim.OK {
+		t.Fatalf("confirmConsent failed: %s", res.Message)
+	}
+}
+
+func expireConsents(t *testing.T, stub *testStub) ([]string, bool) {
+	res := stub.invoke(new(SmartContract), "expireConsents", "1760860800")
+	if res.Status != shim.OK {
+		t.Fatalf("expireConsents failed: %s", res.Message)
+	}
+	var result struct {
+		Items []string `json:"items"`
+		Done  bool     `json:"done"`
+	}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Items, result.Done
+}
+
+func TestExpireConsentsInBatches(t *testing.T) {
+	stub := newTestStub(t, "consent", new(SmartContract), "airtel.com")
+	for i := 0; i <= _ExpireBatchSize; i++ {
+		consent := raisedConsent(fmt.Sprintf("C%04d", i), "jio.com")
+		consent["cexp"] = 1000000000 + i
+		stub.put(t, consent["urn"].(string), consent)
+	}
+	future := raisedConsent("F1", "jio.com")
+	future["cexp"] = time.Now().Add(time.Hour).Unix()
+	stub.put(t, "F1", future)
+	//numbers of fewer digits are earlier, as strings "999999999" would sort after them
+	short := raisedConsent("S1", "jio.com")
+	short["cexp"] = 999999999
+	stub.put(t, "S1", short)
+
+	items, done := expireConsents(t, stub)
+	if len(items) != _ExpireBatchSize || done {
+		t.Fatalf("expected a first batch of %d, got %d done %v", _ExpireBatchSize, len(items), done)
+	}
+	items, done = expireConsents(t, stub)
+	if len(items) != 2 || !done {
+		t.Fatalf("expected the last 2 consents expired, got %v done %v", items, done)
+	}
+	var consent Consentdetails
+	for urn, status := range map[string]string{"C0000": _ConsentExpiredStatus, "S1": _ConsentExpiredStatus, "F1": _ConsentRaisedStatus} {
+		stub.get(t, urn, &consent)
+		if consent.Status != status {
+			t.Fatalf("%s: expected status %s, got %s", urn, status, consent.Status)
+		}
+	}
+}
+
+func TestMigrateConfirmExpiryToNumber(t *testing.T) {
+	stub := newTestStub(t, "consent", new(SmartContract), "airtel.com")
+	consent := raisedConsent("C1", "jio.com")
+	consent["cexp"] = "1000000000"
+	stub.put(t, "C1", consent)
+	if items, _ := expireConsents(t, stub); len(items) != 0 {
+		t.Fatalf("expected the string cexp not compared, got %v", items)
+	}
+
+	if res := stub.invoke(new(SmartContract), "runMigrations"); res.Status != shim.OK {
+		t.Fatalf("runMigrations failed: %s", res.Message)
+	}
+	var migrated map[string]interface{}
+	stub.get(t, "C1", &migrated)
+	if migrated["cexp"] != float64(1000000000) {
+		t.Fatalf("expected cexp as a number, got %#v", migrated["cexp"])
+	}
+	if items, done := expireConsents(t, stub); len(items) != 1 || !done {
+		t.Fatalf("expected C1 expired after the migration, got %v", items)
+	}
+}
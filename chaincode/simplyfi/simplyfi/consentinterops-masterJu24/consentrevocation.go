@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const _RevokeEvent = "REVOKE_CONSENT"
+
+//revokeKeyTypes - what the subscriber revokes by, e.g. a STOP reply to a header is by cli
+var revokeKeyTypes = map[string]bool{
+	"cli":    true, //header
+	"entity": true, //PEID
+	"cstid":  true, //consent template
+}
+
+//RevocationEvent is the payload of the REVOKE_CONSENT event, for the delivery operators to stop the messages
+type RevocationEvent struct {
+	TxnID     string   `json:"txid"`
+	Msisdn    string   `json:"msisdn"`
+	KeyType   string   `json:"ktyp"`
+	Key       string   `json:"key"`
+	EntityIDs []string `json:"eids"`
+	Clis      []string `json:"clis"`
+	ConsentID []string `json:"urns"`
+	RevokedBy string   `json:"uby"`
+	UpdateTs  string   `json:"uts"`
+}
+
+//revocationEntities returns the entities of the consents of the subscriber matching the key
+func (cm *ConsentManager) revocationEntities(stub shim.ChaincodeStubInterface, msisdn, keyType, key string) ([]string, error) {
+	if keyType == "entity" {
+		return []string{key}, nil
+	}
+	consentSearchCriteria := `{
+		"obj":"Consent"	,
+		"msisdn":"%s",
+		"%s":"%s"
+	}`
+	index := "consentSearchByHeaderMsisdn"
+	if keyType == "cstid" {
+		index = "consentSearchByMsisdn"
+	}
+	consents, err := cm.queryConsentRecords(stub, fmt.Sprintf(consentSearchCriteria, msisdn, keyType, key), index)
+	if err != nil {
+		return nil, err
+	}
+	entityIDs := make([]string, 0)
+	for _, consent := range consents {
+		if !hasElem(entityIDs, consent.EntityID) {
+			entityIDs = append(entityIDs, consent.EntityID)
+		}
+	}
+	sort.Strings(entityIDs)
+	return entityIDs, nil
+}
+
+//revokingOperator returns the operator which can revoke the consent: the serving operator, or the operator which
+//recorded the consent when it has none
+func revokingOperator(c Consentdetails) string {
+	if len(c.ServingOperator) > 0 {
+		return c.ServingOperator
+	}
+	return c.Creator
+}
+
+//RevokeConsentsBySubscriber revokes, on request of the subscriber, the raised (1), approved (2) and suspended (5) consents of
+//the MSISDN for an entity, across all the headers of the entity. The entity is given directly or found from a header (e.g. on
+//a STOP reply) or a consent template. Only the operator serving the subscriber can revoke; consents recorded before the
+//serving operator was kept can be revoked only by the operator which recorded them. A revoked consent is no longer suspended,
+//so RestoreConsentsByEntity leaves it revoked.
+//args[0] MSISDN
+//args[1] key type - cli, entity or cstid
+//args[2] header, entity ID or consent template ID
+//args[3] updateTs
+//Returned payload contains two blocks - 'failedData' and 'successData', as RecordConsent. One REVOKE_CONSENT event is raised
+//with the entities, headers and URNs revoked.
+func (cm *ConsentManager) RevokeConsentsBySubscriber(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within RevokeConsentsBySubscriber")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 4 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	msisdn := strings.TrimSpace(args[0])
+	keyType := strings.TrimSpace(args[1])
+	key := strings.TrimSpace(args[2])
+	newUpdatedTS := args[3]
+
+	if isValid, errMsg := isValidMsisdn(msisdn); !isValid {
+		return shim.Error(getErrorMsg(errMsg))
+	}
+	if !validEnumEntry(keyType, revokeKeyTypes) || len(key) == 0 {
+		return shim.Error(getErrorMsg("Revoke by either cli, entity or cstid, with its value"))
+	}
+	if isValid, errMsg := isValidDate(newUpdatedTS); !isValid || len(newUpdatedTS) == 0 {
+		return shim.Error(getErrorMsg("Invalid Update Timestamp to modify the consent.", errMsg))
+	}
+	authorize, updatedBy := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+
+	entityIDs, err := cm.revocationEntities(stub, msisdn, keyType, key)
+	if err != nil {
+		_consentLogger.Errorf("Unable to retrieve consents:: %v", err)
+		return shim.Error(getErrorMsg("Unable to retrieve the consents of the subscriber", err.Error()))
+	}
+	if len(entityIDs) == 0 {
+		return shim.Error(getErrorMsg(_Format6))
+	}
+
+	//Success Consents Message
+	sConsents := make([]SuccessData, 0)
+	//Failed Consesnts Message
+	fConsents := make([]ErrorData, 0)
+	event := RevocationEvent{TxnID: stub.GetTxID(), Msisdn: msisdn, KeyType: keyType, Key: key, EntityIDs: entityIDs, Clis: make([]string, 0), ConsentID: make([]string, 0), RevokedBy: updatedBy, UpdateTs: newUpdatedTS}
+
+	consentSearchCriteria := `{
+		"obj":"Consent"	,
+		"msisdn":"%s",
+		"eid":"%s",
+		"sts":{"$in":["%s","%s","%s"]}
+	}`
+	for _, entityID := range entityIDs {
+		consents, err := cm.queryConsentRecords(stub, fmt.Sprintf(consentSearchCriteria, msisdn, entityID, _ConsentRaisedStatus, _ConsentApprovedStatus, _ConsentSuspendedStatus), "consentSearchByMsisdnEntity")
+		if err != nil {
+			_consentLogger.Errorf("Unable to retrieve consents:: %v", err)
+			return shim.Error(getErrorMsg("Unable to retrieve the consents of the subscriber", err.Error()))
+		}
+		for _, consent := range consents {
+			if operator := revokingOperator(consent); operator != updatedBy {
+				fConsents = append(fConsents, ErrorData{ID: consent.ConsentID, Msg: "Consent can be revoked only by the serving operator " + operator})
+				continue
+			}
+			consent.Status = _ConsentRevokedStatus
+			consent.RevokedFor = keyType + ":" + key
+			consent.UpdateTs = newUpdatedTS
+			consent.UpdatedBy = updatedBy
+
+			consentJSON, _ := json.Marshal(consent)
+			if err := stub.PutState(consent.ConsentID, consentJSON); err != nil {
+				_consentLogger.Errorf(_Format3, consent.ConsentID)
+				fConsents = append(fConsents, ErrorData{ID: consent.ConsentID, Msg: _Format3})
+				continue
+			}
+			if !hasElem(event.Clis, consent.Cli) {
+				event.Clis = append(event.Clis, consent.Cli)
+			}
+			event.ConsentID = append(event.ConsentID, consent.ConsentID)
+			sConsents = append(sConsents, SuccessData{TrxnID: stub.GetTxID(), ConsID: consent.ConsentID, Message: "Consent Revoke Successful", ConsentDets: consent})
+		}
+	}
+
+	if len(event.ConsentID) > 0 {
+		payloadbytes, _ := json.Marshal(event)
+		if retErr := stub.SetEvent(_RevokeEvent, payloadbytes); retErr != nil {
+			_consentLogger.Errorf(_Format5, _RevokeEvent)
+		}
+	}
+
+	totalResponse := TotalResponse{SuccesConsents: sConsents, FailedConsents: fConsents}
+	respJSON, _ := json.Marshal(totalResponse)
+	return shim.Success(respJSON)
+}
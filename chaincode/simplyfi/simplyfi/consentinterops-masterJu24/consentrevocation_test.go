@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//subscriberConsent is a consent of the MSISDN 9876543210 recorded by airtel.com
+func subscriberConsent(urn, entityID, cli, status, servingOperator string) map[string]interface{} {
+	consent := legacyConsent(urn, "9876543210", entityID)
+	consent["cli"] = cli
+	consent["sts"] = status
+	if len(servingOperator) > 0 {
+		consent["sop"] = servingOperator
+	}
+	return consent
+}
+
+func newRevocationStub(t *testing.T) *testStub {
+	stub := newTestStub(t, "consent", new(SmartContract), "airtel.com")
+	stub.put(t, "C1", subscriberConsent("C1", "E1", "BLKCUB", _ConsentApprovedStatus, "airtel.com"))
+	stub.put(t, "C2", subscriberConsent("C2", "E1", "BLKCUC", _ConsentRaisedStatus, "airtel.com"))
+	stub.put(t, "C3", subscriberConsent("C3", "E1", "BLKCUB", _ConsentSuspendedStatus, "airtel.com"))
+	stub.put(t, "C4", subscriberConsent("C4", "E1", "BLKCUB", _ConsentApprovedStatus, "jio.com"))
+	legacy := subscriberConsent("C5", "E1", "BLKCUC", _ConsentApprovedStatus, "")
+	legacy["crtr"] = "vil.com"
+	stub.put(t, "C5", legacy)
+	stub.put(t, "C6", subscriberConsent("C6", "E2", "OTHERH", _ConsentApprovedStatus, "airtel.com"))
+	other := subscriberConsent("C7", "E1", "BLKCUB", _ConsentApprovedStatus, "airtel.com")
+	other["msisdn"] = "9876543211"
+	stub.put(t, "C7", other)
+	return stub
+}
+
+func revokeConsents(t *testing.T, stub *testStub, keyType, key string) ([]string, []string) {
+	res := stub.invoke(new(SmartContract), "revokeConsentsBySubscriber", "9876543210", keyType, key, "1760860800")
+	if res.Status != shim.OK {
+		t.Fatalf("revokeConsentsBySubscriber failed: %s", res.Message)
+	}
+	var result TotalResponse
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	revoked := make([]string, 0)
+	for _, consent := range result.SuccesConsents {
+		revoked = append(revoked, consent.ConsID)
+	}
+	failed := make([]string, 0)
+	for _, consent := range result.FailedConsents {
+		failed = append(failed, consent.ID)
+	}
+	sort.Strings(revoked)
+	sort.Strings(failed)
+	return revoked, failed
+}
+
+func checkStatus(t *testing.T, stub *testStub, statuses map[string]string) {
+	for urn, status := range statuses {
+		var consent Consentdetails
+		stub.get(t, urn, &consent)
+		if consent.Status != status {
+			t.Fatalf("%s: expected status %s, got %s", urn, status, consent.Status)
+		}
+	}
+}
+
+//a STOP reply to a header revokes the consents of the subscriber for all the headers of the entity
+func TestRevokeConsentsByHeader(t *testing.T) {
+	stub := newRevocationStub(t)
+	revoked, failed := revokeConsents(t, stub, "cli", "BLKCUB")
+	if len(revoked) != 3 || revoked[0] != "C1" || revoked[1] != "C2" || revoked[2] != "C3" {
+		t.Fatalf("expected C1, C2 and C3 revoked, got %v", revoked)
+	}
+	if len(failed) != 2 || failed[0] != "C4" || failed[1] != "C5" {
+		t.Fatalf("expected the consents of jio.com and vil.com refused, got %v", failed)
+	}
+	checkStatus(t, stub, map[string]string{"C1": _ConsentRevokedStatus, "C3": _ConsentRevokedStatus, "C4": _ConsentApprovedStatus,
+		"C5": _ConsentApprovedStatus, "C6": _ConsentApprovedStatus, "C7": _ConsentApprovedStatus})
+	var consent Consentdetails
+	stub.get(t, "C2", &consent)
+	if consent.Status != _ConsentRevokedStatus || consent.RevokedFor != "cli:BLKCUB" || consent.UpdatedBy != "airtel.com" {
+		t.Fatalf("unexpected revoked consent %+v", consent)
+	}
+
+	if res := stub.invoke(new(SmartContract), "revokeConsentsBySubscriber", "9876543210", "cli", "NOHDR", "1760860800"); res.Status == shim.OK {
+		t.Fatal("revocation accepted for a header without consents of the subscriber")
+	}
+}
+
+func TestRevokeConsentsByEntity(t *testing.T) {
+	stub := newRevocationStub(t)
+	//the consent without sop is revoked only by the operator which recorded it
+	stub.setDomain(t, "vil.com")
+	revoked, failed := revokeConsents(t, stub, "entity", "E1")
+	if len(revoked) != 1 || revoked[0] != "C5" || len(failed) != 4 {
+		t.Fatalf("expected only C5 revoked by vil.com, got %v failed %v", revoked, failed)
+	}
+	stub.setDomain(t, "jio.com")
+	if revoked, _ := revokeConsents(t, stub, "entity", "E1"); len(revoked) != 1 || revoked[0] != "C4" {
+		t.Fatalf("expected C4 revoked by jio.com, got %v", revoked)
+	}
+	checkStatus(t, stub, map[string]string{"C1": _ConsentApprovedStatus, "C4": _ConsentRevokedStatus, "C5": _ConsentRevokedStatus, "C6": _ConsentApprovedStatus})
+}
+
+func TestRevokeConsentsQueryError(t *testing.T) {
+	stub := newRevocationStub(t)
+	stub.queryErr = errors.New("couchdb unavailable")
+	for _, keyType := range []string{"cli", "entity"} {
+		res := stub.invoke(new(SmartContract), "revokeConsentsBySubscriber", "9876543210", keyType, "BLKCUB", "1760860800")
+		if res.Status == shim.OK {
+			t.Fatalf("revoke by %s succeeded without its query", keyType)
+		}
+	}
+	stub.queryErr = nil
+	checkStatus(t, stub, map[string]string{"C1": _ConsentApprovedStatus})
+}
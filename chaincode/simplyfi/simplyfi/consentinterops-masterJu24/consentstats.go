@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//_ExportMaxPageSize is the largest page of exportConsents
+const _ExportMaxPageSize = 1000
+
+//_StatsPageSize is the number of consents read at a time by getConsentStats
+const _StatsPageSize = 1000
+
+//exportColumns is the layout of the rows of exportConsents, new columns are only appended
+var exportColumns = []string{"urn", "msisdn", "eid", "cli", "cstid", "sts", "pur", "cmode", "exdt", "cts", "uts", "sop", "cfts"}
+
+//statsFilters - filters of getConsentStats and exportConsents, from and to are on cts (epoch)
+var statsFilters = map[string]bool{
+	"eid":   true,
+	"cli":   true,
+	"sts":   true,
+	"pur":   true,
+	"cmode": true,
+	"from":  true,
+	"to":    true,
+}
+
+//exportRow returns the consent in the order of exportColumns
+func exportRow(c Consentdetails) []string {
+	return []string{c.ConsentID, c.Msisdn, c.EntityID, c.Cli, c.ConsentTemplateID, c.Status, c.Purpose, c.CommunicationMode, c.ExpiryDate, c.CreateTs, c.UpdateTs, c.ServingOperator, c.ConfirmTs}
+}
+
+//statsCriteria builds the selector of the filters on the consents recorded by the creator along with the index to use and
+//the index fields to sort on. The filters need either eid or cli, so that an index is used
+func statsCriteria(filters map[string]string, creator string) (string, string, []string, string) {
+	selector := map[string]interface{}{"obj": _ObjectType, "crtr": creator}
+	for filter, value := range filters {
+		if !validEnumEntry(filter, statsFilters) {
+			return "", "", nil, "Unsupported filter " + filter + ". Filters can be eid, cli, sts, pur, cmode, from and to"
+		}
+		if filter == "from" || filter == "to" {
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return "", "", nil, "Date needs to be in Epoch format e.g. '1551788124'"
+			}
+			continue
+		}
+		selector[filter] = strings.TrimSpace(value)
+	}
+	createTs := map[string]string{}
+	if from, ok := filters["from"]; ok {
+		createTs["$gte"] = from
+	}
+	if to, ok := filters["to"]; ok {
+		createTs["$lte"] = to
+	}
+	if len(createTs) > 0 {
+		selector["cts"] = createTs
+	}
+
+	var index string
+	var sortFields []string
+	switch {
+	case len(filters["eid"]) > 0:
+		index, sortFields = "consentSearchByEntityCts", []string{"obj", "eid", "cts"}
+	case len(filters["cli"]) > 0:
+		index, sortFields = "consentSearchByCliCts", []string{"obj", "cli", "cts"}
+	default:
+		return "", "", nil, "Either eid or cli is mandatory"
+	}
+	criteria, _ := json.Marshal(selector)
+	return string(criteria), index, sortFields, ""
+}
+
+//consentStats are the counts of getConsentStats
+type consentStats struct {
+	total     int
+	byStatus  map[string]int
+	byPurpose map[string]int
+	byMode    map[string]int
+	byCli     map[string]int
+}
+
+//countConsents counts the consents of the query page by page, so that the count is not limited by the query limit
+//of the peer, and returns the query errors
+func countConsents(stub shim.ChaincodeStubInterface, queryString string) (*consentStats, error) {
+	stats := &consentStats{
+		byStatus:  make(map[string]int),
+		byPurpose: make(map[string]int),
+		byMode:    make(map[string]int),
+		byCli:     make(map[string]int),
+	}
+	bookmark := ""
+	for {
+		resultsIterator, responseMetaData, err := stub.GetQueryResultWithPagination(queryString, _StatsPageSize, bookmark)
+		if err != nil {
+			return nil, err
+		} else if resultsIterator == nil || responseMetaData == nil {
+			return nil, fmt.Errorf("No result for the query")
+		}
+		for resultsIterator.HasNext() {
+			recordBytes, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			record := Consentdetails{}
+			if err := json.Unmarshal(recordBytes.Value, &record); err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			stats.total++
+			stats.byStatus[record.Status]++
+			stats.byPurpose[record.Purpose]++
+			stats.byMode[record.CommunicationMode]++
+			stats.byCli[record.Cli]++
+		}
+		resultsIterator.Close()
+		if responseMetaData.FetchedRecordsCount < _StatsPageSize || len(responseMetaData.Bookmark) == 0 || responseMetaData.Bookmark == bookmark {
+			return stats, nil
+		}
+		bookmark = responseMetaData.Bookmark
+	}
+}
+
+//GetConsentStats returns the number of consents of the invoking operator matching the filters, in total and by status, purpose,
+//communication mode and header. Read only, the consents are counted page by page through the entity or header index.
+//args[0] filters as JSON e.g. {"eid":"1101","sts":"2","from":"1759276800","to":"1761955199"}, eid or cli is mandatory
+func (cm *ConsentManager) GetConsentStats(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within GetConsentStats")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 1 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	filters := make(map[string]string)
+	if err := json.Unmarshal([]byte(args[0]), &filters); err != nil {
+		return shim.Error(getErrorMsg(_Format0))
+	}
+	authorize, creator := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	criteria, index, _, errMsg := statsCriteria(filters, creator)
+	if len(errMsg) > 0 {
+		return shim.Error(getErrorMsg(errMsg))
+	}
+
+	queryString := fmt.Sprintf("{\"selector\":%s , \"use_index\" :\"%s\" }", criteria, index)
+	stats, err := countConsents(stub, queryString)
+	if err != nil {
+		_consentLogger.Errorf("GetQueryResultWithPagination Failed :" + string(err.Error()))
+		return shim.Error(getErrorMsg("GetQueryResultWithPagination Failed", err.Error()))
+	}
+
+	resultData := map[string]interface{}{
+		"filters": filters,
+		"total":   stats.total,
+		"sts":     stats.byStatus,
+		"pur":     stats.byPurpose,
+		"cmode":   stats.byMode,
+		"cli":     stats.byCli,
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+//ExportConsents returns a page of the consents of the invoking operator matching the filters as rows of exportColumns, in the
+//order of the index (entity or header, then creation), so that the pages can be appended to a CSV file as they are.
+//args[0] filters as JSON, as GetConsentStats
+//args[1] page size, at most _ExportMaxPageSize
+//args[2] bookmark, empty for the first page
+//Returns "columns", "rows", "recordscount" and "bookmark" for the next page
+func (cm *ConsentManager) ExportConsents(stub shim.ChaincodeStubInterface) pb.Response {
+	_consentLogger.Info("Within ExportConsents")
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 3 {
+		return shim.Error(getErrorMsg(_Format1))
+	}
+	filters := make(map[string]string)
+	if err := json.Unmarshal([]byte(args[0]), &filters); err != nil {
+		return shim.Error(getErrorMsg(_Format0))
+	}
+	authorize, creator := cm.getInvokerIdentity(stub)
+	if !authorize {
+		return shim.Error(getErrorMsg("Unauthorized access"))
+	}
+	criteria, index, sortFields, errMsg := statsCriteria(filters, creator)
+	if len(errMsg) > 0 {
+		return shim.Error(getErrorMsg(errMsg))
+	}
+	pageSize, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil || pageSize < 1 || pageSize > _ExportMaxPageSize {
+		return shim.Error(getErrorMsg("Page size needs to be from 1 to", strconv.Itoa(_ExportMaxPageSize)))
+	}
+
+	sort := make([]map[string]string, 0)
+	for _, field := range sortFields {
+		sort = append(sort, map[string]string{field: "asc"})
+	}
+	sortJSON, _ := json.Marshal(sort)
+	queryString := fmt.Sprintf("{\"selector\":%s , \"use_index\" :\"%s\", \"sort\":%s }", criteria, index, sortJSON)
+	resultsIterator, responseMetaData, err := stub.GetQueryResultWithPagination(queryString, int32(pageSize), args[2])
+	if err != nil {
+		_consentLogger.Errorf("GetQueryResultWithPagination Failed :" + string(err.Error()))
+		return shim.Error(getErrorMsg("GetQueryResultWithPagination Failed", err.Error()))
+	}
+	defer resultsIterator.Close()
+
+	rows := make([][]string, 0)
+	for resultsIterator.HasNext() {
+		recordBytes, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(getErrorMsg("Iterator Error", err.Error()))
+		}
+		record := Consentdetails{}
+		if err := json.Unmarshal(recordBytes.Value, &record); err != nil {
+			return shim.Error(getErrorMsg(_Format8))
+		}
+		rows = append(rows, exportRow(record))
+	}
+
+	resultData := map[string]interface{}{
+		"columns":      exportColumns,
+		"rows":         rows,
+		"recordscount": responseMetaData.FetchedRecordsCount,
+		"bookmark":     responseMetaData.Bookmark,
+		"status":       "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
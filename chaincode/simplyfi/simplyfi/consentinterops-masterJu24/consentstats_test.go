@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestConsentStatsCountsEveryPage(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	count := _StatsPageSize + 2
+	for i := 0; i < count; i++ {
+		urn := fmt.Sprintf("C%05d", i)
+		consent := legacyConsent(urn, fmt.Sprintf("99999%05d", i), "E1")
+		if i%2 == 0 {
+			consent["sts"] = _ConsentRaisedStatus
+		}
+		stub.put(t, urn, consent)
+	}
+	stub.put(t, "D1", legacyConsent("D1", "8888888888", "E2"))
+
+	res := stub.invoke(cc, "getConsentStats", `{"eid":"E1"}`)
+	if res.Status != shim.OK {
+		t.Fatalf("getConsentStats failed: %s", res.Message)
+	}
+	stats := struct {
+		Total  int            `json:"total"`
+		Status map[string]int `json:"sts"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != count || stats.Status[_ConsentRaisedStatus] != count/2 || stats.Status[_ConsentApprovedStatus] != count/2 {
+		t.Fatalf("expected %d consents, half raised, got %s", count, res.Payload)
+	}
+}
+
+func TestConsentStatsQueryError(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", legacyConsent("C1", "9999999999", "E1"))
+	//the stats must fail instead of counting nothing
+	stub.queryErr = errors.New("couchdb unavailable")
+	if res := stub.invoke(cc, "getConsentStats", `{"eid":"E1"}`); res.Status == shim.OK {
+		t.Fatalf("getConsentStats succeeded without its query: %s", res.Payload)
+	}
+	//without a certificate the invoker is unknown
+	plain := shim.NewMockStub("consent", cc)
+	if res := plain.MockInvoke("tx1", [][]byte{[]byte("getConsentStats"), []byte(`{"eid":"E1"}`)}); res.Status == shim.OK {
+		t.Fatalf("getConsentStats succeeded without an invoker: %s", res.Payload)
+	}
+}
+
+//an operator counts and exports only the consents it recorded
+func TestConsentStatsOfInvokingOperator(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", legacyConsent("C1", "9999999991", "E1"))
+	stub.put(t, "C2", legacyConsent("C2", "9999999992", "E1"))
+	ofJio := legacyConsent("C3", "9999999993", "E1")
+	ofJio["crtr"] = "jio.com"
+	stub.put(t, "C3", ofJio)
+
+	for domain, msisdns := range map[string][]string{"airtel.com": {"9999999991", "9999999992"}, "jio.com": {"9999999993"}, "vil.com": {}} {
+		stub.setDomain(t, domain)
+		res := stub.invoke(cc, "getConsentStats", `{"eid":"E1"}`)
+		var stats struct {
+			Total int `json:"total"`
+		}
+		if res.Status != shim.OK || json.Unmarshal(res.Payload, &stats) != nil || stats.Total != len(msisdns) {
+			t.Fatalf("%s: expected %d consents, got %d %s", domain, len(msisdns), res.Status, res.Payload)
+		}
+		res = stub.invoke(cc, "exportConsents", `{"eid":"E1"}`, "10", "")
+		var export struct {
+			Rows [][]string `json:"rows"`
+		}
+		if res.Status != shim.OK || json.Unmarshal(res.Payload, &export) != nil || len(export.Rows) != len(msisdns) {
+			t.Fatalf("%s: expected %d rows, got %d %s", domain, len(msisdns), res.Status, res.Payload)
+		}
+		for i, row := range export.Rows {
+			if row[1] != msisdns[i] {
+				t.Fatalf("%s: expected MSISDN %s, got %v", domain, msisdns[i], row)
+			}
+		}
+	}
+}
@@ -7,11 +7,14 @@ import (
 
 //SmartContract is a structure
 type SmartContract struct {
-	consentManager *ConsentManager
 }
 
 var _mainLogger = shim.NewLogger("ConsentManagementSmartContract")
 
+//consentManager is stateless, so it is created with the package and not in Init, which is
+//not called again when the peer restarts the chaincode container
+var consentManager = new(ConsentManager)
+
 func main() {
 	err := shim.Start(new(SmartContract))
 	if err != nil {
@@ -19,10 +22,22 @@ func main() {
 	}
 }
 
-//Init initializes chaincode.
+//Init initializes chaincode. It is called on instantiate and on every upgrade,
+//and applies the schema migrations not yet applied to the ledger.
 func (sc *SmartContract) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	_mainLogger.Infof("Inside the init method ")
-	sc.consentManager = new(ConsentManager)
+	version, done, err := runMigrations(stub)
+	if err != nil {
+		_mainLogger.Errorf("Init failed: %v", err)
+		return shim.Error(err.Error())
+	}
+	if !done {
+		_mainLogger.Warningf("Schema version %d, pending migrations to be continued with runMigrations", version)
+	}
+	if err := registerDefaultConfirmWindow(stub); err != nil {
+		_mainLogger.Errorf("Init failed: %v", err)
+		return shim.Error(err.Error())
+	}
 	return shim.Success(nil)
 }
 
@@ -48,33 +63,61 @@ func (sc *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	case "probe":
 		return sc.probe(stub)
 	case "recordConsent":
-		return sc.consentManager.RecordConsent(stub)
+		return consentManager.RecordConsent(stub)
 	case "getConsent":
-		return sc.consentManager.GetConsent(stub)
+		return consentManager.GetConsent(stub)
 	case "getHistory":
-		return sc.consentManager.GetHistoryByKey(stub)
+		return consentManager.GetHistoryByKey(stub)
 	case "updateConsentStatus":
-		return sc.consentManager.UpdateConsentStatus(stub)
+		return consentManager.UpdateConsentStatus(stub)
 	case "updateConsentStatusByHeaderAndMsisdn":
-		return sc.consentManager.UpdateConsentStatusByHeader(stub)
+		return consentManager.UpdateConsentStatusByHeader(stub)
 	case "updateConsentStatusByIDs":
-		return sc.consentManager.UpdateConsentStatusByIDs(stub)
+		return consentManager.UpdateConsentStatusByIDs(stub)
 	case "updateConsentExpiryByIDs":
-		return sc.consentManager.UpdateConsentExpiryDateByIDs(stub)
+		return consentManager.UpdateConsentExpiryDateByIDs(stub)
 	case "updateConsentExpiryByHeaderAndMsisdn":
-		return sc.consentManager.UpdateConsentExpiryDateByHeader(stub)
+		return consentManager.UpdateConsentExpiryDateByHeader(stub)
 	case "updateConsentPurposeByIDs":
-		return sc.consentManager.UpdateConsentPurposeByIDs(stub)
+		return consentManager.UpdateConsentPurposeByIDs(stub)
 	case "updateConsentPurposeByHeaderAndMsisdn":
-		return sc.consentManager.UpdateConsentPurposeByHeader(stub)
+		return consentManager.UpdateConsentPurposeByHeader(stub)
 	case "getActiveConsentsByMSISDN":
-		return sc.consentManager.GetActiveConsentsByMSISDN(stub)
+		return consentManager.GetActiveConsentsByMSISDN(stub)
 	case "queryConsentsWithPagination": //Rich Query to retrieve the Templates with pagination from DL
-		return sc.consentManager.QueryConsentsWithPagination(stub)
+		return consentManager.QueryConsentsWithPagination(stub)
 	case "revokeActiveConsentsByMsisdn":
-		return sc.consentManager.RevokeActiveConsentsByMsisdn(stub)
+		return consentManager.RevokeActiveConsentsByMsisdn(stub)
 	case "bulkConsentsUpload":
-		return sc.consentManager.RecordConsentInBulk(stub)
+		return consentManager.RecordConsentInBulk(stub)
+	case "suspendConsentsByEntity":
+		return consentManager.SuspendConsentsByEntity(stub)
+	case "restoreConsentsByEntity":
+		return consentManager.RestoreConsentsByEntity(stub)
+	case "getEntitySuspension":
+		return consentManager.GetEntitySuspension(stub)
+	case "confirmConsent":
+		return consentManager.ConfirmConsent(stub)
+	case "expireConsents":
+		return consentManager.ExpireConsents(stub)
+	case "setConfirmWindow":
+		return consentManager.SetConfirmWindow(stub)
+	case "getConfirmWindow":
+		return consentManager.GetConfirmWindow(stub)
+	case "revokeConsentsBySubscriber":
+		return consentManager.RevokeConsentsBySubscriber(stub)
+	case "getConsentStats":
+		return consentManager.GetConsentStats(stub)
+	case "exportConsents":
+		return consentManager.ExportConsents(stub)
+	case "updateConsentCategoriesByIDs":
+		return consentManager.UpdateConsentCategoriesByIDs(stub)
+	case "checkConsentsForScrub":
+		return consentManager.CheckConsentsForScrub(stub)
+	case "getSchemaVersion":
+		return sc.getSchemaVersionInfo(stub)
+	case "runMigrations":
+		return sc.runPendingMigrations(stub)
 
 	default:
 		return shim.Error("Invalid action provoided")
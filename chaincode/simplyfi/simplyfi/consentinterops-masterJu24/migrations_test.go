@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//legacyConsent is a consent as stored before purpose and categories were added
+func legacyConsent(urn, msisdn, entityID string) map[string]interface{} {
+	return map[string]interface{}{"obj": "Consent", "urn": urn, "msisdn": msisdn, "cstid": "CST1", "eid": entityID, "cli": "BLKCUB", "sts": "2", "crtr": "airtel.com", "cts": "2019-08-01 10:00:00", "uts": "2019-08-01 10:00:00"}
+}
+
+func TestInvokeWithoutInit(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+
+	if res := stub.invoke(cc, "probe"); res.Status != shim.OK {
+		t.Fatalf("probe failed: %s", res.Message)
+	}
+	res := stub.invoke(cc, "getSchemaVersion")
+	if res.Status != shim.OK {
+		t.Fatalf("getSchemaVersion failed: %s", res.Message)
+	}
+	info := struct {
+		Version int `json:"version"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &info); err != nil || info.Version != 0 {
+		t.Fatalf("expected schema version 0 before any migration, got %s", res.Payload)
+	}
+	if res := stub.invoke(cc, "getConsent", `{"type":"urn","urn":"C1"}`); res.Status != shim.OK {
+		t.Fatalf("getConsent failed: %s", res.Message)
+	}
+}
+
+func TestQueryErrorWithoutInit(t *testing.T) {
+	cc := new(SmartContract)
+	//the plain MockStub has no rich query, queries must fail without a nil iterator panic
+	stub := shim.NewMockStub("consent", cc)
+	stub.MockInvoke("tx1", [][]byte{[]byte("getConsent"), []byte(`{"type":"urn","urn":"C1"}`)})
+	if res := stub.MockInit("tx2", nil); res.Status == shim.OK {
+		t.Fatal("Init succeeded without being able to query the consents")
+	}
+}
+
+func TestRunMigrationsWithoutInit(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", legacyConsent("C1", "9999999999", "E1"))
+
+	res := stub.invoke(cc, "runMigrations")
+	if res.Status != shim.OK {
+		t.Fatalf("runMigrations failed: %s", res.Message)
+	}
+	var consent Consentdetails
+	stub.get(t, "C1", &consent)
+	if consent.Purpose != "" || len(consent.Categories) != 0 {
+		t.Fatalf("expected purpose and categories left empty, got %q %v", consent.Purpose, consent.Categories)
+	}
+}
+
+func TestInitMigratesLegacyConsents(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "consent", cc, "airtel.com")
+	stub.put(t, "C1", legacyConsent("C1", "9999999999", "E1"))
+	withPurpose := legacyConsent("C2", "9999999998", "E1")
+	withPurpose["pur"] = "2"
+	stub.put(t, "C2", withPurpose)
+
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	var consent Consentdetails
+	stub.get(t, "C2", &consent)
+	if consent.Purpose != "2" {
+		t.Fatalf("C2: expected purpose kept as 2, got %q", consent.Purpose)
+	}
+	for _, urn := range []string{"C1", "C2"} {
+		stub.get(t, urn, &consent)
+		if len(consent.Categories) != 0 {
+			t.Fatalf("%s: expected no categories defaulted, got %v", urn, consent.Categories)
+		}
+	}
+	if version := string(stub.State[_SchemaVersionKey]); version != "1" {
+		t.Fatalf("expected schema version 1, got %q", version)
+	}
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("second Init failed: %s", res.Message)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// _SchemaVersionKey keeps the version of the last migration completed on the ledger
+const _SchemaVersionKey = "CONSENT_SCHEMA_VERSION"
+
+// migrations - append new migrations at the end with the next version, never reorder
+var migrations = []migration{
+	{1, "cexp as a number for consents raised while it was a string", migrateNumericConfirmExpiry},
+}
+
+// migrateNumericConfirmExpiry saves again the consents with cexp as a string, epochSeconds
+// reads it and writes it as a number, which ExpireConsents compares
+func migrateNumericConfirmExpiry(stub shim.ChaincodeStubInterface, limit int) (bool, error) {
+	criteria := `{
+		"obj":"Consent",
+		"cexp":{"$type":"string"}
+	}`
+	values, done, err := queryBatch(stub, criteria, limit)
+	if err != nil {
+		return false, err
+	}
+	for _, value := range values {
+		var consent Consentdetails
+		if err := json.Unmarshal(value, &consent); err != nil {
+			return false, err
+		}
+		consentJSON, err := json.Marshal(consent)
+		if err != nil {
+			return false, err
+		}
+		if err := stub.PutState(consent.ConsentID, consentJSON); err != nil {
+			return false, err
+		}
+	}
+	_mainLogger.Infof("cexp converted to a number for %d consents", len(values))
+	return done, nil
+}
+
+// runPendingMigrations continues the migrations not completed by Init, one batch per transaction
+func (sc *SmartContract) runPendingMigrations(stub shim.ChaincodeStubInterface) peer.Response {
+	authorize, _ := consentManager.getInvokerIdentity(stub)
+	if authorize == false {
+		return shim.Error("Unauthorized access")
+	}
+	version, done, err := runMigrations(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	respJSON, _ := json.Marshal(map[string]interface{}{"trxnID": stub.GetTxID(), "version": version, "done": done, "status": "true"})
+	return shim.Success(respJSON)
+}
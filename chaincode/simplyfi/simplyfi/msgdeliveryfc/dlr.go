@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+const _DLRObjType = "dlrSummary"
+const _DLRReportObjType = "dlrReport"
+const _DLREvent = "DLR_SUMMARY"
+
+const _SecondsPerDay = 86400
+
+// svcProviderDomain is the certificate domain of each operator, a DLR summary is accepted
+// only from the domain of the operator it is reported for
+var svcProviderDomain = map[string]string{
+	"AI": "airtel.com",
+	"VO": "vil.com",
+	"ID": "vil.com",
+	"VI": "vil.com",
+	"BL": "bsnl.com",
+	"ML": "mtnl.com",
+	"QL": "qtl.infotelconnect.com",
+	"TA": "tata.com",
+	"JI": "jio.com",
+}
+
+// DLRCounts are the delivery report counts of the messages sent to a terminating operator
+type DLRCounts struct {
+	Submitted int64            `json:"sub"`
+	Delivered int64            `json:"dlv"`
+	Expired   int64            `json:"exp"`
+	Failed    map[string]int64 `json:"fld"` //failure reason code wise count
+}
+
+func (c *DLRCounts) add(o DLRCounts) {
+	c.Submitted += o.Submitted
+	c.Delivered += o.Delivered
+	c.Expired += o.Expired
+	if c.Failed == nil {
+		c.Failed = make(map[string]int64)
+	}
+	for reason, count := range o.Failed {
+		c.Failed[reason] += count
+	}
+}
+
+// reported is the number of messages with a final status
+func (c DLRCounts) reported() int64 {
+	total := c.Delivered + c.Expired
+	for _, count := range c.Failed {
+		total += count
+	}
+	return total
+}
+
+// DLRReport is a periodic summary of the delivery reports of a scrub token, sent by a terminating operator
+type DLRReport struct {
+	ReportID   string `json:"rid"`  //unique for the operator, a report is accumulated only once
+	ScrubToken string `json:"stok"` //scrub token of the delivery
+	Operator   string `json:"op"`   //terminating operator
+	ReportTs   string `json:"rts"`  //epoch time of the period reported
+	DLRCounts
+}
+
+// DLRSummary accumulates the reports of a scrub token for a terminating operator and a day.
+// peid and cli are copied from the scrub record for the statistics
+type DLRSummary struct {
+	ObjType    string `json:"obj"`
+	ScrubToken string `json:"stok"`
+	Operator   string `json:"op"`
+	ScrubType  string `json:"styp"`
+	PEID       string `json:"peid"`
+	CLI        string `json:"cli"`
+	Day        int64  `json:"day"` //epoch time of the start of the day (UTC)
+	DLRCounts
+	UpdateTs  string `json:"uts"`
+	UpdatedBy string `json:"uby"`
+}
+
+// IsValidDLRReport checks if the report fields are valid or not
+func IsValidDLRReport(r DLRReport) (bool, string) {
+	if len(r.ReportID) == 0 {
+		return false, "Report ID is mandatory"
+	}
+	if len(r.ScrubToken) == 0 {
+		return false, "Scrub token should be present there"
+	}
+	if !validEnumEntry(r.Operator, svcProvider) {
+		return false, "Operator: Enter either AI, VO, ID, BL, ML, QL, TA, JI or VI"
+	}
+	if _, err := strconv.ParseInt(r.ReportTs, 10, 64); err != nil {
+		return false, "Report time stamp needs to be in Epoch format e.g. '1551788124'"
+	}
+	if r.Submitted < 0 || r.Delivered < 0 || r.Expired < 0 {
+		return false, "Counts can not be negative"
+	}
+	for reason, count := range r.Failed {
+		if len(reason) == 0 || count < 0 {
+			return false, "Failed counts need a reason code and can not be negative"
+		}
+	}
+	return true, ""
+}
+
+// getTokenDLRs returns the summaries of the scrub token, of the operator only if given
+func getTokenDLRs(stub shim.ChaincodeStubInterface, keys ...string) ([]DLRSummary, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_DLRObjType, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	summaries := make([]DLRSummary, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var summary DLRSummary
+		if err := json.Unmarshal(queryResponse.Value, &summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// recordDLRSummary accumulates a DLR summary of a delivered scrub token, reported by the terminating operator itself
+// args[0] json of DLRReport, e.g.
+// {"rid":"AI-20191019-001","stok":"stok1","op":"AI","rts":"1571443200","sub":1000,"dlv":950,"exp":10,"fld":{"DND":20,"ABSENT":20}}
+func (s *MSGDeliveryManager) recordDLRSummary(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		errKey = strconv.Itoa(len(args))
+		errorDetails = "Invalid Number of Arguments"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var report DLRReport
+	if err := json.Unmarshal([]byte(args[0]), &report); err != nil {
+		errKey = args[0]
+		errorDetails = "Invalid JSON provided"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if isValid, errMsg := IsValidDLRReport(report); !isValid {
+		errKey = args[0]
+		errorDetails = errMsg
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	authorize, invoker := s.getInvokerIdentity(stub)
+	if !authorize || svcProviderDomain[report.Operator] != invoker {
+		errKey = report.Operator
+		errorDetails = "DLR summary can be recorded only by the operator reported for"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	reportKey, _ := stub.CreateCompositeKey(_DLRReportObjType, []string{report.Operator, report.ReportID})
+	if recordBytes, _ := stub.GetState(reportKey); len(recordBytes) > 0 {
+		errKey = args[0]
+		errorDetails = "Report with this report ID already recorded for the operator"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	//the reports are accepted only for the deliveries recorded
+	deliveryBytes, _ := stub.GetState(report.ScrubToken)
+	if len(deliveryBytes) == 0 {
+		errKey = report.ScrubToken
+		errorDetails = "MSGDelivery details does not exist with Token"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var delivery MSGDelivery
+	if err := json.Unmarshal(deliveryBytes, &delivery); err != nil {
+		errKey = report.ScrubToken
+		errorDetails = "Invalid MSGDelivery record"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	//a final status can be reported only for a message submitted
+	summaries, err := getTokenDLRs(stub, report.ScrubToken, report.Operator)
+	if err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to fetch the DLR summaries- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var tokenTotal DLRCounts
+	for _, summary := range summaries {
+		tokenTotal.add(summary.DLRCounts)
+	}
+	tokenTotal.add(report.DLRCounts)
+	if tokenTotal.reported() > tokenTotal.Submitted {
+		errKey = args[0]
+		errorDetails = "Delivered, expired and failed messages exceed the messages submitted to the operator"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+
+	reportTs, _ := strconv.ParseInt(report.ReportTs, 10, 64)
+	day := reportTs - reportTs%_SecondsPerDay
+	summaryKey, _ := stub.CreateCompositeKey(_DLRObjType, []string{report.ScrubToken, report.Operator, strconv.FormatInt(day, 10)})
+	summary := DLRSummary{}
+	if summaryBytes, _ := stub.GetState(summaryKey); len(summaryBytes) > 0 {
+		if err := json.Unmarshal(summaryBytes, &summary); err != nil {
+			errorDetails = "Invalid DLR summary record"
+			jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+			_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+			return shim.Error(jsonResp)
+		}
+	} else {
+		styp := getScrubType(delivery)
+		scrub, errMsg := getScrubRecord(stub, styp, report.ScrubToken)
+		if scrub == nil {
+			errKey = report.ScrubToken
+			errorDetails = "Scrub record not found- " + errMsg
+			jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+			_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+			return shim.Error(jsonResp)
+		}
+		summary = DLRSummary{ObjType: _DLRObjType, ScrubToken: report.ScrubToken, Operator: report.Operator,
+			ScrubType: styp, PEID: scrub.PEID, CLI: scrub.CLI, Day: day}
+	}
+	summary.add(report.DLRCounts)
+	summary.UpdatedBy = invoker
+	summary.UpdateTs = report.ReportTs
+
+	summaryJSON, _ := json.Marshal(summary)
+	reportJSON, _ := json.Marshal(report)
+	if err := stub.PutState(summaryKey, summaryJSON); err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to save DLR summary- " + repError
+		jsonResp = "{\"Data\":" + string(summaryJSON) + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if err := stub.PutState(reportKey, reportJSON); err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to save DLR report- " + repError
+		jsonResp = "{\"Data\":" + string(reportJSON) + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if retErr := stub.SetEvent(_DLREvent, summaryJSON); retErr != nil {
+		repError = strings.Replace(retErr.Error(), "\"", " ", -1)
+		errorDetails = "Event not generated for event : DLR_SUMMARY- " + repError
+		jsonResp = "{\"Data\":" + string(summaryJSON) + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("recordDLRSummary: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"stok":    report.ScrubToken,
+		"rid":     report.ReportID,
+		"data":    summary,
+		"message": "DLR summary recorded successfully",
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// queryTokenDLR returns the DLR counts of a scrub token, in total and operator wise
+// args[0] scrub token
+func (s *MSGDeliveryManager) queryTokenDLR(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		errKey = strconv.Itoa(len(args))
+		errorDetails = "Invalid Number of Arguments"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("queryTokenDLR: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	summaries, err := getTokenDLRs(stub, args[0])
+	if err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to fetch the DLR summaries- " + repError
+		jsonResp = "{\"Data\":\"" + args[0] + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("queryTokenDLR: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	total := DLRCounts{Failed: make(map[string]int64)}
+	byOperator := make(map[string]*DLRCounts)
+	for _, summary := range summaries {
+		total.add(summary.DLRCounts)
+		if _, ok := byOperator[summary.Operator]; !ok {
+			byOperator[summary.Operator] = &DLRCounts{Failed: make(map[string]int64)}
+		}
+		byOperator[summary.Operator].add(summary.DLRCounts)
+	}
+	resultData := map[string]interface{}{
+		"stok":   args[0],
+		"total":  total,
+		"byOp":   byOperator,
+		"status": "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
+// getDLRStatistics returns the DLR counts of a PEID or a header over a date range, in total,
+// operator wise and day wise. For a PEID the counts are given header wise as well
+// args[0] json {"peid":"", "cli":"", "fts":"", "tts":""} either peid or cli, fts and tts in epoch
+func (s *MSGDeliveryManager) getDLRStatistics(stub shim.ChaincodeStubInterface) peer.Response {
+	type Query struct {
+		PEID   string `json:"peid"`
+		CLI    string `json:"cli"`
+		FromTs string `json:"fts"`
+		ToTs   string `json:"tts"`
+	}
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		errKey = strconv.Itoa(len(args))
+		errorDetails = "Invalid Number of Arguments"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("getDLRStatistics: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var query Query
+	if err := json.Unmarshal([]byte(args[0]), &query); err != nil {
+		errKey = args[0]
+		errorDetails = "Invalid JSON provided"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("getDLRStatistics: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if (query.PEID == "") == (query.CLI == "") {
+		errKey = args[0]
+		errorDetails = "Either peid or cli is mandatory"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("getDLRStatistics: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	fromTs, errFrom := strconv.ParseInt(query.FromTs, 10, 64)
+	toTs, errTo := strconv.ParseInt(query.ToTs, 10, 64)
+	if errFrom != nil || errTo != nil || fromTs > toTs {
+		errKey = args[0]
+		errorDetails = "fts and tts need to be in Epoch format e.g. '1551788124', fts not after tts"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("getDLRStatistics: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var queryString string
+	dayRange := fmt.Sprintf("{\"$gte\":%d,\"$lte\":%d}", fromTs-fromTs%_SecondsPerDay, toTs)
+	if query.PEID != "" {
+		peidJSON, _ := json.Marshal(query.PEID)
+		queryString = fmt.Sprintf("{\"selector\":{\"obj\":\"%s\",\"peid\":%s,\"day\":%s},\"use_index\":\"dlrSearchByPeid\"}", _DLRObjType, peidJSON, dayRange)
+	} else {
+		cliJSON, _ := json.Marshal(query.CLI)
+		queryString = fmt.Sprintf("{\"selector\":{\"obj\":\"%s\",\"cli\":%s,\"day\":%s},\"use_index\":\"dlrSearchByCli\"}", _DLRObjType, cliJSON, dayRange)
+	}
+	_msgSMSLogger.Infof("Query Selector : %s", queryString)
+	resultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Could not fetch the data- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("getDLRStatistics: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	defer resultsIterator.Close()
+	total := DLRCounts{Failed: make(map[string]int64)}
+	byOperator := make(map[string]*DLRCounts)
+	byHeader := make(map[string]*DLRCounts)
+	byDay := make(map[string]*DLRCounts)
+	addTo := func(group map[string]*DLRCounts, key string, counts DLRCounts) {
+		if _, ok := group[key]; !ok {
+			group[key] = &DLRCounts{Failed: make(map[string]int64)}
+		}
+		group[key].add(counts)
+	}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var summary DLRSummary
+		if err := json.Unmarshal(queryResponse.Value, &summary); err != nil {
+			_msgSMSLogger.Errorf("getDLRStatistics: Invalid DLR summary %s", queryResponse.Key)
+			continue
+		}
+		total.add(summary.DLRCounts)
+		addTo(byOperator, summary.Operator, summary.DLRCounts)
+		addTo(byDay, strconv.FormatInt(summary.Day, 10), summary.DLRCounts)
+		if query.PEID != "" {
+			addTo(byHeader, summary.CLI, summary.DLRCounts)
+		}
+	}
+	resultData := map[string]interface{}{
+		"peid":   query.PEID,
+		"cli":    query.CLI,
+		"fts":    query.FromTs,
+		"tts":    query.ToTs,
+		"total":  total,
+		"byOp":   byOperator,
+		"byDay":  byDay,
+		"status": "true",
+	}
+	if query.PEID != "" {
+		resultData["byCli"] = byHeader
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
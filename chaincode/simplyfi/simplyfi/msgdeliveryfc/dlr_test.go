@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// fakeScrub answers qsd of the scrub chaincode with the scrub record of every token
+type fakeScrub struct {
+	record scrubRecord
+}
+
+func (f *fakeScrub) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (f *fakeScrub) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	payload, _ := json.Marshal(map[string]interface{}{"data": f.record})
+	return shim.Success(payload)
+}
+
+func newDLRStub(t *testing.T) (*SmartContract, *testStub) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "msgdelivery", cc, "airtel.com")
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	scrub := &fakeScrub{record: scrubRecord{ScrubToken: "S1", PEID: "E1", TMID: "TM1", CLI: "AIRTEL", Status: "C", SMSHash: "H1", SMSConsumer: "airtel.com"}}
+	stub.MockPeerChaincode(_ScrubSMSChaincode, shim.NewMockStub(_ScrubSMSChaincode, scrub))
+	stub.put(t, "S1", MSGDelivery{ObjType: "msgDelivery", ScrubToken: "S1", Creator: "airtel.com", CreateTimeStamp: "1571400000",
+		ScrubbedFileName: "f1", ScrubbedFileHash: "H1", ServiceProvider: "AI", ScrubType: _ScrubTypeSMS})
+	return cc, stub
+}
+
+func TestRecordDLRSummary(t *testing.T) {
+	cc, stub := newDLRStub(t)
+	report := `{"rid":"R1","stok":"S1","op":"AI","rts":"1571443200","sub":100,"dlv":90,"fld":{"DND":5}}`
+
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "dlr", report); res.Status == shim.OK {
+		t.Fatal("DLR summary recorded by another operator")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "dlr", report); res.Status != shim.OK {
+		t.Fatalf("dlr failed: %s", res.Message)
+	}
+	if res := stub.invoke(cc, "dlr", report); res.Status == shim.OK {
+		t.Fatal("report accumulated twice")
+	}
+	//the final statuses can not exceed the messages submitted to the operator
+	if res := stub.invoke(cc, "dlr", `{"rid":"R2","stok":"S1","op":"AI","rts":"1571446800","dlv":10}`); res.Status == shim.OK {
+		t.Fatal("more messages reported than submitted")
+	}
+	if res := stub.invoke(cc, "dlr", `{"rid":"R2","stok":"S1","op":"AI","rts":"1571446800","sub":50,"dlv":40,"exp":5}`); res.Status != shim.OK {
+		t.Fatalf("dlr failed: %s", res.Message)
+	}
+	key, _ := stub.CreateCompositeKey(_DLRObjType, []string{"S1", "AI", "1571443200"})
+	var summary DLRSummary
+	stub.get(t, key, &summary)
+	if summary.Submitted != 150 || summary.Delivered != 130 || summary.Expired != 5 || summary.Failed["DND"] != 5 || summary.PEID != "E1" || summary.CLI != "AIRTEL" {
+		t.Fatalf("expected the reports of the day accumulated, got %+v", summary)
+	}
+}
+
+func TestDLRStatistics(t *testing.T) {
+	cc, stub := newDLRStub(t)
+	reports := []struct{ domain, report string }{
+		{"airtel.com", `{"rid":"R1","stok":"S1","op":"AI","rts":"1571443200","sub":100,"dlv":90,"fld":{"DND":5}}`},
+		{"airtel.com", `{"rid":"R2","stok":"S1","op":"AI","rts":"1571533200","sub":10,"dlv":10}`},
+		{"jio.com", `{"rid":"R1","stok":"S1","op":"JI","rts":"1571446800","sub":20,"dlv":18,"exp":2}`},
+	}
+	for _, r := range reports {
+		stub.setDomain(t, r.domain)
+		if res := stub.invoke(cc, "dlr", r.report); res.Status != shim.OK {
+			t.Fatalf("dlr failed: %s", res.Message)
+		}
+	}
+
+	res := stub.invoke(cc, "qdlr", "S1")
+	if res.Status != shim.OK {
+		t.Fatalf("qdlr failed: %s", res.Message)
+	}
+	var token struct {
+		Total DLRCounts            `json:"total"`
+		ByOp  map[string]DLRCounts `json:"byOp"`
+	}
+	json.Unmarshal(res.Payload, &token)
+	if token.Total.Submitted != 130 || token.Total.Delivered != 118 || token.ByOp["AI"].Submitted != 110 || token.ByOp["JI"].Expired != 2 {
+		t.Fatalf("unexpected token counts %s", res.Payload)
+	}
+
+	//the first day only, from any time of the day
+	res = stub.invoke(cc, "dst", `{"peid":"E1","fts":"1571450000","tts":"1571529599"}`)
+	if res.Status != shim.OK {
+		t.Fatalf("dst failed: %s", res.Message)
+	}
+	var stats struct {
+		Total DLRCounts            `json:"total"`
+		ByDay map[string]DLRCounts `json:"byDay"`
+		ByCli map[string]DLRCounts `json:"byCli"`
+	}
+	json.Unmarshal(res.Payload, &stats)
+	if stats.Total.Submitted != 120 || len(stats.ByDay) != 1 || stats.ByDay["1571443200"].Delivered != 108 || stats.ByCli["AIRTEL"].Submitted != 120 {
+		t.Fatalf("unexpected statistics %s", res.Payload)
+	}
+}
@@ -46,6 +46,14 @@ func (sc *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		response = sc.message.createBulkMSGDelivery(stub)
 	case "qpg":
 		response = sc.message.getDataByPagination(stub)
+	case "rcn":
+		response = sc.message.reconcileScrubDelivery(stub)
+	case "dlr":
+		response = sc.message.recordDLRSummary(stub)
+	case "qdlr":
+		response = sc.message.queryTokenDLR(stub)
+	case "dst":
+		response = sc.message.getDLRStatistics(stub)
 	default:
 		response = shim.Error("Invalid action provided")
 	}
@@ -26,6 +26,7 @@ type MSGDelivery struct {
 	ScrubbedFileName string `json:"sFile"`  //Scrubbed file name
 	ScrubbedFileHash string `json:"sHash"`  //scrubbed file hash
 	ServiceProvider  string `json:"svcprv"` // service provider who created this scrubbing
+	ScrubType        string `json:"styp"`   //S (SMS scrub, default) or V (voice scrub)
 }
 
 //MSGDeliveryManages manages MSGDelivery related transactions
@@ -94,6 +95,9 @@ func IsValid(s MSGDelivery) (bool, string) {
 	if !validEnumEntry(s.ServiceProvider, svcProvider) {
 		return false, "ServiceProvider: Enter either AI, VO, ID, BL, ML, QL, TA, JI or VI"
 	}
+	if !validEnumEntry(s.ScrubType, scrubType) {
+		return false, "ScrubType: Enter either S or V"
+	}
 	return true, ""
 }
 
@@ -101,7 +105,7 @@ func IsValid(s MSGDelivery) (bool, string) {
 func (s *MSGDeliveryManager) createMSGDelivery(stub shim.ChaincodeStubInterface) peer.Response {
 	_, args := stub.GetFunctionAndParameters()
 	if len(args) < 1 {
-		errKey = string(len(args))
+		errKey = strconv.Itoa(len(args))
 		errorDetails = "Invalid Number of Arguments"
 		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_msgSMSLogger.Errorf("createMSGDelivery: " + jsonResp)
@@ -127,6 +131,7 @@ func (s *MSGDeliveryManager) createMSGDelivery(stub shim.ChaincodeStubInterface)
 	msgToSave.ObjType = "msgDelivery"
 	_, creator := s.getInvokerIdentity(stub)
 	msgToSave.Creator = creator
+	msgToSave.ScrubType = getScrubType(msgToSave)
 	scrubJSON, _ := json.Marshal(msgToSave)
 	if isValid, errMsg := IsValid(msgToSave); !isValid {
 		errKey = string(scrubJSON)
@@ -135,6 +140,22 @@ func (s *MSGDeliveryManager) createMSGDelivery(stub shim.ChaincodeStubInterface)
 		_msgSMSLogger.Errorf("createMSGDelivery: " + jsonResp)
 		return shim.Error(jsonResp)
 	}
+	//the TM of the scrub has to be connected to the operator delivering
+	if err := checkDeliveryTM(stub, msgToSave); err != nil {
+		errKey = string(scrubJSON)
+		errorDetails = "TM connection check failed- " + err.Error()
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("createMSGDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	//the scrub token has to be valid for the delivered file and is consumed by this delivery
+	if err := consumeScrubToken(stub, msgToSave); err != nil {
+		errKey = string(scrubJSON)
+		errorDetails = "Scrub validation failed- " + err.Error()
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("createMSGDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
 	_msgSMSLogger.Info("Saving MSGDelivery Details to the ledger with token----------", msgToSave.ScrubToken)
 	err = stub.PutState(msgToSave.ScrubToken, scrubJSON)
 	if err != nil {
@@ -170,7 +191,7 @@ func (s *MSGDeliveryManager) createMSGDelivery(stub shim.ChaincodeStubInterface)
 func (s *MSGDeliveryManager) createBulkMSGDelivery(stub shim.ChaincodeStubInterface) peer.Response {
 	_, args := stub.GetFunctionAndParameters()
 	if len(args) < 1 {
-		errKey = string(len(args))
+		errKey = strconv.Itoa(len(args))
 		errorDetails = "Invalid Number of Arguments"
 		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_msgSMSLogger.Errorf("createBulkMSGDelivery: " + jsonResp)
@@ -196,6 +217,7 @@ func (s *MSGDeliveryManager) createBulkMSGDelivery(stub shim.ChaincodeStubInterf
 		}
 		scrubToSave.Creator = creator
 		scrubToSave.ObjType = "msgDelivery"
+		scrubToSave.ScrubType = getScrubType(scrubToSave)
 		scrubJSON, _ := json.Marshal(scrubToSave)
 		if isValid, errMsg := IsValid(scrubToSave); !isValid {
 			errKey = string(scrubJSON)
@@ -205,6 +227,23 @@ func (s *MSGDeliveryManager) createBulkMSGDelivery(stub shim.ChaincodeStubInterf
 			rejectedStok = append(rejectedStok, scrubToSave.ScrubToken)
 			continue
 		}
+		//the TM of the scrub has to be connected to the operator delivering
+		if err := checkDeliveryTM(stub, scrubToSave); err != nil {
+			errKey = string(scrubJSON)
+			errorDetails = "TM connection check failed- " + err.Error()
+			jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+			_msgSMSLogger.Errorf("createBulkMSGDelivery: " + jsonResp)
+			rejectedStok = append(rejectedStok, scrubToSave.ScrubToken)
+			continue
+		}
+		if err := consumeScrubToken(stub, scrubToSave); err != nil {
+			errKey = string(scrubJSON)
+			errorDetails = "Scrub validation failed- " + err.Error()
+			jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+			_msgSMSLogger.Errorf("createBulkMSGDelivery: " + jsonResp)
+			rejectedStok = append(rejectedStok, scrubToSave.ScrubToken)
+			continue
+		}
 		_msgSMSLogger.Info("scrubToSave.ScrubToken----------", scrubToSave.ScrubToken)
 		err = stub.PutState(scrubToSave.ScrubToken, scrubJSON)
 		if err != nil {
@@ -242,7 +281,7 @@ func (s *MSGDeliveryManager) createBulkMSGDelivery(stub shim.ChaincodeStubInterf
 func (s *MSGDeliveryManager) queryMSGDelivery(stub shim.ChaincodeStubInterface) peer.Response {
 	_, args := stub.GetFunctionAndParameters()
 	if len(args) < 1 {
-		errKey = string(len(args))
+		errKey = strconv.Itoa(len(args))
 		errorDetails = "Invalid Number of Arguments"
 		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_msgSMSLogger.Errorf("queryMSGDelivery: " + jsonResp)
@@ -300,7 +339,7 @@ func (s *MSGDeliveryManager) getDataByPagination(stub shim.ChaincodeStubInterfac
 	}
 	_, args := stub.GetFunctionAndParameters()
 	if len(args) < 1 {
-		errKey = string(len(args))
+		errKey = strconv.Itoa(len(args))
 		errorDetails = "Invalid Number of Arguments"
 		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_msgSMSLogger.Errorf("getDataByPagination " + jsonResp)
@@ -327,7 +366,7 @@ func (s *MSGDeliveryManager) getDataByPagination(stub shim.ChaincodeStubInterfac
 	bookMark := tempQuery.Bookmark
 	paginationResults, err2 := getQueryResultForQueryStringWithPagination(stub, queryString, int32(pageSize), bookMark)
 	if err2 != nil {
-		errKey = queryString + "," + string(pageSize) + "," + bookMark
+		errKey = queryString + "," + tempQuery.PageSize + "," + bookMark
 		errorDetails = "Could not fetch the data"
 		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_msgSMSLogger.Errorf("getDataByPagination: " + jsonResp)
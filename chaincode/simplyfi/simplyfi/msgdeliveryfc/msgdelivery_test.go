@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// fakeTelco answers checkTMOnTSP of the telco chaincode, the connections listed as tspId/tmId are live
+type fakeTelco struct {
+	live map[string]bool
+}
+
+func (f *fakeTelco) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (f *fakeTelco) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	result := map[string]interface{}{"tspId": args[0], "tmId": args[1], "live": f.live[args[0]+"/"+args[1]]}
+	if !f.live[args[0]+"/"+args[1]] {
+		result["reason"] = "Connection of TM " + args[1] + " with TSP " + args[0] + " is Suspended"
+	}
+	payload, _ := json.Marshal(result)
+	return shim.Success(payload)
+}
+
+func TestDeliveryRequiresLiveTMConnection(t *testing.T) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "msgdelivery", cc, "airtel.com")
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	scrub := &fakeScrub{record: scrubRecord{ScrubToken: "S1", PEID: "E1", TMID: "TM1", CLI: "AIRTEL", Status: "A", SMSHash: "H1"}}
+	stub.MockPeerChaincode(_ScrubSMSChaincode, shim.NewMockStub(_ScrubSMSChaincode, scrub))
+	telco := &fakeTelco{live: map[string]bool{}}
+	stub.MockPeerChaincode(_TelcoChaincode, shim.NewMockStub(_TelcoChaincode, telco))
+	delivery := `{"stok":"S1","sFile":"f1","sHash":"H1","cts":"1571400000","svcprv":"AI"}`
+
+	if res := stub.invoke(cc, "cmd", delivery); res.Status == shim.OK {
+		t.Fatal("delivery accepted for a TM without a live connection")
+	}
+	res := stub.invoke(cc, "cbmd", "["+delivery+"]")
+	if res.Status != shim.OK {
+		t.Fatalf("cbmd failed: %s", res.Message)
+	}
+	var bulk struct {
+		Rejected []string `json:"stok_f"`
+	}
+	json.Unmarshal(res.Payload, &bulk)
+	if len(bulk.Rejected) != 1 || stub.State["S1"] != nil {
+		t.Fatalf("expected S1 rejected in bulk, got %s", res.Payload)
+	}
+
+	telco.live["AI/TM1"] = true
+	if res := stub.invoke(cc, "cmd", delivery); res.Status != shim.OK {
+		t.Fatalf("cmd failed: %s", res.Message)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// chaincodes holding the scrub records, expected on the channel of msgdelivery
+const _ScrubSMSChaincode = "scrubsms"
+const _ScrubVoiceChaincode = "scrubvoice"
+
+// chaincode holding the TM onboardings with the operators, expected on the channel of msgdelivery
+const _TelcoChaincode = "telco"
+
+// scrub types of a delivery, deliveries recorded before styp was added are SMS
+const _ScrubTypeSMS = "S"
+const _ScrubTypeVoice = "V"
+
+var scrubType = map[string]bool{
+	"S": true,
+	"V": true,
+}
+
+// scrubRecord holds the fields of the SMS and voice scrub records used by msgdelivery
+type scrubRecord struct {
+	ScrubToken    string `json:"stok"`
+	PEID          string `json:"peid"`
+	TMID          string `json:"tmid"`
+	CLI           string `json:"cli"`
+	Status        string `json:"sts"`
+	CreateTs      string `json:"cts"`
+	SMSHash       string `json:"sHash"`
+	VoiceHash     string `json:"ohash"`
+	SMSConsumer   string `json:"cby"`
+	VoiceConsumer string `json:"csby"`
+}
+
+func (r scrubRecord) hash(styp string) string {
+	if styp == _ScrubTypeVoice {
+		return r.VoiceHash
+	}
+	return r.SMSHash
+}
+
+func (r scrubRecord) consumer(styp string) string {
+	if styp == _ScrubTypeVoice {
+		return r.VoiceConsumer
+	}
+	return r.SMSConsumer
+}
+
+// getScrubType returns the scrub type of the delivery, SMS if not given
+func getScrubType(m MSGDelivery) string {
+	if m.ScrubType == "" {
+		return _ScrubTypeSMS
+	}
+	return m.ScrubType
+}
+
+func getScrubChaincode(styp string) (string, string) {
+	if styp == _ScrubTypeVoice {
+		return _ScrubVoiceChaincode, "VScrubbing"
+	}
+	return _ScrubSMSChaincode, "Scrubbing"
+}
+
+// consumeScrubToken marks the scrub token of the delivery as consumed by the invoker. The scrub
+// chaincode rejects the token if it does not exist, is not active, is already consumed, was
+// created by another operator or if the scrubbed file hash differs from the delivered one
+func consumeScrubToken(stub shim.ChaincodeStubInterface, m MSGDelivery) error {
+	styp := getScrubType(m)
+	chaincode, _ := getScrubChaincode(styp)
+	consumed := map[string]string{
+		"stok":  m.ScrubToken,
+		"sHash": m.ScrubbedFileHash,
+		"uts":   m.CreateTimeStamp,
+	}
+	if styp == _ScrubTypeVoice {
+		consumed = map[string]string{
+			"stok":  m.ScrubToken,
+			"ohash": m.ScrubbedFileHash,
+			"uts":   m.CreateTimeStamp,
+		}
+	}
+	consumedJSON, _ := json.Marshal(consumed)
+	response := stub.InvokeChaincode(chaincode, [][]byte{[]byte("cns"), consumedJSON}, "")
+	if response.Status != shim.OK {
+		return errors.New(strings.Replace(response.Message, "\"", " ", -1))
+	}
+	return nil
+}
+
+// checkDeliveryTM checks the TM of the scrubbed file has a live connection with the operator
+// delivering it, as checkTMOnTSP of the telco chaincode gives it at the time of the transaction
+func checkDeliveryTM(stub shim.ChaincodeStubInterface, m MSGDelivery) error {
+	scrub, errMsg := getScrubRecord(stub, getScrubType(m), m.ScrubToken)
+	if scrub == nil {
+		return errors.New("Scrub record not found- " + errMsg)
+	}
+	args := [][]byte{[]byte("checkTMOnTSP"), []byte(m.ServiceProvider), []byte(scrub.TMID)}
+	response := stub.InvokeChaincode(_TelcoChaincode, args, "")
+	if response.Status != shim.OK {
+		return errors.New(strings.Replace(response.Message, "\"", " ", -1))
+	}
+	connection := struct {
+		Live   bool   `json:"live"`
+		Reason string `json:"reason"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &connection); err != nil {
+		return errors.New("Invalid TM connection status")
+	}
+	if !connection.Live {
+		return errors.New(connection.Reason)
+	}
+	return nil
+}
+
+// getScrubRecord fetches the scrub record of the token, the error message is returned if not found
+func getScrubRecord(stub shim.ChaincodeStubInterface, styp, stok string) (*scrubRecord, string) {
+	chaincode, _ := getScrubChaincode(styp)
+	queryJSON, _ := json.Marshal(map[string]string{"stok": stok})
+	response := stub.InvokeChaincode(chaincode, [][]byte{[]byte("qsd"), queryJSON}, "")
+	if response.Status != shim.OK {
+		return nil, strings.Replace(response.Message, "\"", " ", -1)
+	}
+	result := struct {
+		Data scrubRecord `json:"data"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		return nil, "Invalid scrub record"
+	}
+	return &result.Data, ""
+}
+
+// checkDeliveryScrub returns why the scrub of the delivery is not valid, empty if it is valid
+func checkDeliveryScrub(stub shim.ChaincodeStubInterface, m MSGDelivery) string {
+	styp := getScrubType(m)
+	scrub, errMsg := getScrubRecord(stub, styp, m.ScrubToken)
+	if scrub == nil {
+		return "Scrub record not found- " + errMsg
+	}
+	if scrub.hash(styp) != m.ScrubbedFileHash {
+		return "Delivered file hash does not match the scrubbed file hash"
+	}
+	if consumer := scrub.consumer(styp); consumer != m.Creator {
+		if consumer == "" {
+			return "Scrub token not consumed"
+		}
+		return "Scrub token consumed by " + consumer
+	}
+	if scrub.Status != "C" {
+		return "Scrub status- " + scrub.Status
+	}
+	return ""
+}
+
+// reconcileScrubDelivery lists, one page at a time, the scrub tokens never delivered and the
+// deliveries without a valid scrub.
+// args[0] json with
+// styp - S (SMS, default) or V (voice)
+// bts  - only the scrubs created before this time stamp are checked for delivery, optional
+// ps   - page size
+// sbm  - bookmark of the scrub records, dbm - bookmark of the delivery records
+func (s *MSGDeliveryManager) reconcileScrubDelivery(stub shim.ChaincodeStubInterface) peer.Response {
+	type Query struct {
+		ScrubType     string `json:"styp"`
+		BeforeTs      string `json:"bts"`
+		PageSize      string `json:"ps"`
+		ScrubBookmark string `json:"sbm"`
+		DlvryBookmark string `json:"dbm"`
+	}
+	type invalidDelivery struct {
+		ScrubToken string `json:"stok"`
+		Reason     string `json:"reason"`
+	}
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		errKey = strconv.Itoa(len(args))
+		errorDetails = "Invalid Number of Arguments"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var query Query
+	if err := json.Unmarshal([]byte(args[0]), &query); err != nil {
+		errKey = args[0]
+		errorDetails = "Invalid JSON provided"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if query.ScrubType == "" {
+		query.ScrubType = _ScrubTypeSMS
+	}
+	if !validEnumEntry(query.ScrubType, scrubType) {
+		errKey = args[0]
+		errorDetails = "ScrubType: Enter either S or V"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	pageSize, err := strconv.ParseInt(query.PageSize, 10, 32)
+	if err != nil {
+		errKey = args[0]
+		errorDetails = "PageSize should be a Number"
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+
+	//active scrubs, i.e. not consumed, without a delivery record
+	chaincode, scrubObjType := getScrubChaincode(query.ScrubType)
+	scrubSelector := map[string]interface{}{"obj": scrubObjType, "sts": "A"}
+	if query.BeforeTs != "" {
+		scrubSelector["cts"] = map[string]string{"$lt": query.BeforeTs}
+	}
+	scrubQuery, _ := json.Marshal(map[string]interface{}{"selector": scrubSelector})
+	scrubArgs, _ := json.Marshal(map[string]string{"sq": string(scrubQuery), "ps": query.PageSize, "bm": query.ScrubBookmark})
+	response := stub.InvokeChaincode(chaincode, [][]byte{[]byte("qs"), scrubArgs}, "")
+	if response.Status != shim.OK {
+		repError = strings.Replace(response.Message, "\"", " ", -1)
+		errorDetails = "Could not fetch the scrub data- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	scrubPage := struct {
+		Records  []scrubRecord `json:"Records"`
+		Metadata struct {
+			Bookmark string `json:"Bookmark"`
+		} `json:"ResponseMetadata"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &scrubPage); err != nil {
+		errorDetails = "Invalid scrub data"
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	undelivered := make([]string, 0)
+	for _, scrub := range scrubPage.Records {
+		if recordBytes, _ := stub.GetState(scrub.ScrubToken); len(recordBytes) == 0 {
+			undelivered = append(undelivered, scrub.ScrubToken)
+		}
+	}
+
+	//deliveries of the scrub type and the reason their scrub is not valid
+	deliverySelector := map[string]interface{}{"obj": "msgDelivery", "styp": query.ScrubType}
+	if query.ScrubType == _ScrubTypeSMS {
+		delete(deliverySelector, "styp")
+		deliverySelector["$or"] = []interface{}{
+			map[string]interface{}{"styp": _ScrubTypeSMS},
+			map[string]interface{}{"styp": map[string]bool{"$exists": false}},
+		}
+	}
+	deliveryQuery, _ := json.Marshal(map[string]interface{}{"selector": deliverySelector})
+	resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(string(deliveryQuery), int32(pageSize), query.DlvryBookmark)
+	if err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Could not fetch the delivery data- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_msgSMSLogger.Errorf("reconcileScrubDelivery: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	defer resultsIterator.Close()
+	invalid := make([]invalidDelivery, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var delivery MSGDelivery
+		if err := json.Unmarshal(queryResponse.Value, &delivery); err != nil {
+			invalid = append(invalid, invalidDelivery{ScrubToken: queryResponse.Key, Reason: "Invalid delivery record"})
+			continue
+		}
+		if reason := checkDeliveryScrub(stub, delivery); reason != "" {
+			invalid = append(invalid, invalidDelivery{ScrubToken: delivery.ScrubToken, Reason: reason})
+		}
+	}
+
+	resultData := map[string]interface{}{
+		"styp":        query.ScrubType,
+		"undelivered": undelivered,
+		"sbm":         scrubPage.Metadata.Bookmark,
+		"invalid":     invalid,
+		"dbm":         responseMetadata.Bookmark,
+		"status":      "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
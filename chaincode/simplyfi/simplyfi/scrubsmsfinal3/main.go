@@ -48,6 +48,8 @@ func (sc *SmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		response = sc.scrubbing.createBulkScrubDetails(stub)
 	case "qs":
 		response = sc.scrubbing.queryScrub(stub)
+	case "cns":
+		response = sc.scrubbing.consumeScrub(stub)
 	default:
 		response = shim.Error("Invalid action provided")
 	}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//Consent chaincode, checks the consents of the MSISDNs of a service explicit scrub
+const _ConsentChaincode = "consent"
+const _ConsentChannel = "telcocommon"
+
+//checkScrubConsents checks with checkConsentsForScrub of the consent chaincode that every MSISDN of the scrubbed file of a
+//service explicit (SE) scrub has a consent for the header covering the category of the scrub. The MSISDNs are given as a
+//JSON array in the transient data under the scrub token, so that they are not recorded on the ledger.
+//Returns the reason to refuse the scrub, empty for other communication types or when all the MSISDNs are covered
+func checkScrubConsents(stub shim.ChaincodeStubInterface, scrub ScrubVoice) string {
+	if scrub.CommunicationType != "SE" {
+		return ""
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return "Unable to read the transient data"
+	}
+	msisdns, ok := transient[scrub.ScrubToken]
+	if !ok || len(msisdns) == 0 {
+		return "MSISDNs of the scrubbed file are mandatory in the transient data for a SE scrub"
+	}
+	ccArgs := [][]byte{[]byte("checkConsentsForScrub"), []byte(scrub.CLI), []byte(scrub.Category), msisdns}
+	response := stub.InvokeChaincode(_ConsentChaincode, ccArgs, _ConsentChannel)
+	if response.Status != shim.OK {
+		return "Unable to check the consents- " + strings.Replace(response.Message, "\"", " ", -1)
+	}
+	result := struct {
+		NotCovered []string `json:"notCovered"`
+	}{}
+	if err := json.Unmarshal(response.Payload, &result); err != nil {
+		return "Unable to read the consents check"
+	}
+	if len(result.NotCovered) > 0 {
+		return strconv.Itoa(len(result.NotCovered)) + " MSISDNs of the scrubbed file have no consent for the header and category"
+	}
+	return ""
+}
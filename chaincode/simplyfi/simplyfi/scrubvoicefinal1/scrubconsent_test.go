@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+//consentChaincode answers checkConsentsForScrub of the consent chaincode with the MSISDNs having a consent of the category
+type consentChaincode map[string]string
+
+func (cc consentChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (cc consentChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function != "checkConsentsForScrub" || len(args) != 3 {
+		return shim.Error("Invalid call of the consent chaincode")
+	}
+	msisdns := make([]string, 0)
+	if err := json.Unmarshal([]byte(args[2]), &msisdns); err != nil {
+		return shim.Error("Invalid MSISDNs")
+	}
+	covered := make(map[string]string)
+	notCovered := make([]string, 0)
+	for _, msisdn := range msisdns {
+		if cc[msisdn] == args[1] {
+			covered[msisdn] = "C" + msisdn
+		} else {
+			notCovered = append(notCovered, msisdn)
+		}
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"cli": args[0], "ctgr": args[1], "covered": covered, "notCovered": notCovered, "status": "true"})
+	return shim.Success(payload)
+}
+
+func scrubRequest(scrubToken, communicationType string) string {
+	scrub := ScrubVoice{ScrubToken: scrubToken, PEID: "E1", TMID: "TM1", CLI: "1601234567", CNAME: "AIRTEL", TemplateID: "T1", Category: "3",
+		CommunicationMode: "11", CommunicationType: communicationType, CreateTs: "1564740000", SourceFileName: "i" + scrubToken,
+		SourceFileHash: "I" + scrubToken, ScrubbedFileName: "f" + scrubToken, ScrubbedFileHash: "H" + scrubToken}
+	request, _ := json.Marshal(scrub)
+	return string(request)
+}
+
+func TestSEScrubNeedsConsents(t *testing.T) {
+	cc, stub := newScrubStub(t)
+	stub.MockPeerChaincode(_ConsentChaincode+"/"+_ConsentChannel, shim.NewMockStub("consent", consentChaincode{"9876543210": "3", "9876543211": "4"}))
+
+	if res := stub.invoke(cc, "cs", scrubRequest("S2", "SE")); res.Status == shim.OK {
+		t.Fatal("SE scrub recorded without the MSISDNs of the scrubbed file")
+	}
+	stub.transient = map[string][]byte{"S2": []byte(`["9876543210","9876543211"]`)}
+	if res := stub.invoke(cc, "cs", scrubRequest("S2", "SE")); res.Status == shim.OK {
+		t.Fatal("SE scrub recorded with an MSISDN without a consent for the category")
+	}
+	stub.transient = map[string][]byte{"S2": []byte(`["9876543210"]`)}
+	if res := stub.invoke(cc, "cs", scrubRequest("S2", "SE")); res.Status != shim.OK {
+		t.Fatalf("cs failed: %s", res.Message)
+	}
+	stub.transient = nil
+	if res := stub.invoke(cc, "cs", scrubRequest("S3", "T")); res.Status != shim.OK {
+		t.Fatalf("cs of a transactional scrub failed: %s", res.Message)
+	}
+
+	stub.transient = map[string][]byte{"S4": []byte(`["9876543210"]`), "S5": []byte(`["9876543211"]`)}
+	res := stub.invoke(cc, "cbs", "["+scrubRequest("S4", "SE")+","+scrubRequest("S5", "SE")+"]")
+	if res.Status != shim.OK {
+		t.Fatalf("cbs failed: %s", res.Message)
+	}
+	result := struct {
+		Rejected []ErrorDetails `json:"stok_f"`
+	}{}
+	if err := json.Unmarshal(res.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Data != "S5" {
+		t.Fatalf("expected S5 rejected, got %v", result.Rejected)
+	}
+	if _, ok := stub.State["S4"]; !ok {
+		t.Fatal("S4 not recorded")
+	}
+}
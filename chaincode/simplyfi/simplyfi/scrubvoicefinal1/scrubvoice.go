@@ -163,6 +163,7 @@ func IsValidScrubDataPresent(s ScrubVoice) (bool, string) {
 }
 
 //InitiateScrubbing creates a scrubbing record in the ledger
+//SE scrubs are refused unless every MSISDN of the scrubbed file has a consent for the cli and ctgr, see checkScrubConsents
 func (s *ScrubbingVoice) createScrubDetails(stub shim.ChaincodeStubInterface) peer.Response {
 	_, args := stub.GetFunctionAndParameters()
 	var scrubToSave ScrubVoice
@@ -212,6 +213,13 @@ func (s *ScrubbingVoice) createScrubDetails(stub shim.ChaincodeStubInterface) pe
 		_scrubVoiceLogger.Errorf("VcreateScrubDetails: " + jsonResp)
 		return shim.Error(jsonResp)
 	}
+	if errMsg := checkScrubConsents(stub, scrubToSave); len(errMsg) > 0 {
+		errKey = scrubToSave.ScrubToken
+		errorDetails = errMsg
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VcreateScrubDetails: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
 	_scrubVoiceLogger.Info("Saving Scrub Details to the ledger with token----------", scrubToSave.ScrubToken)
 	err = stub.PutState(scrubToSave.ScrubToken, scrubJSON)
 	if err != nil {
@@ -298,6 +306,11 @@ func (s *ScrubbingVoice) createBulkScrubDetails(stub shim.ChaincodeStubInterface
 			rejectedStok = append(rejectedStok, errMsg)
 			continue
 		}
+		if errMsg := checkScrubConsents(stub, scrubToSave); len(errMsg) > 0 {
+			_scrubVoiceLogger.Errorf("VcreateBulkScrubDetails: " + errMsg)
+			rejectedStok = append(rejectedStok, ErrorDetails{Data: scrubToSave.ScrubToken, Details: errMsg})
+			continue
+		}
 		_scrubVoiceLogger.Info("Saving Scrub Details to the ledger with token----------", scrubToSave.ScrubToken)
 		err = stub.PutState(scrubToSave.ScrubToken, scrubJSON)
 		if err != nil {
@@ -510,6 +523,125 @@ func (s *ScrubbingVoice) updateScrubStatus(stub shim.ChaincodeStubInterface) pee
 	return shim.Success(respJSON)
 }
 
+//consumeScrub marks the scrub token as consumed by a message delivery. The scrub has to be
+//active (A) and not consumed yet, and the delivered file hash has to match the scrubbed file hash.
+//The status is changed to C (consumed), so that a token is delivered only once. The token is consumed
+//by the invoker, which has to be the operator which created the scrub.
+//args[0] json with stok, ohash (delivered file hash) and uts
+func (s *ScrubbingVoice) consumeScrub(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) < 1 {
+		errKey = strconv.Itoa(len(args))
+		errorDetails = "Invalid Number of Arguments"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var consumedScrub ScrubVoice
+	errScrub := json.Unmarshal([]byte(args[0]), &consumedScrub)
+	if errScrub != nil {
+		repError = strings.Replace(errScrub.Error(), "\"", " ", -1)
+		errorDetails = "Invalid JSON provided- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	scrubRecord, err := stub.GetState(consumedScrub.ScrubToken)
+	if err != nil {
+		errKey = consumedScrub.ScrubToken
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to fetch the Scrub details- " + repError
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	} else if scrubRecord == nil {
+		errKey = consumedScrub.ScrubToken
+		errorDetails = "Scrub details does not exist with Token"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	var existingScrub ScrubVoice
+	err = json.Unmarshal([]byte(scrubRecord), &existingScrub)
+	if err != nil {
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Invalid JSON for storing- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if len(existingScrub.ConsumedBy) > 0 {
+		errKey = existingScrub.ScrubToken
+		errorDetails = "Scrub token already consumed by " + existingScrub.ConsumedBy
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if existingScrub.Status != "A" {
+		errKey = existingScrub.ScrubToken
+		errorDetails = "Scrub is not active, status- " + existingScrub.Status
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	if existingScrub.ScrubbedFileHash != consumedScrub.ScrubbedFileHash {
+		errKey = existingScrub.ScrubToken
+		errorDetails = "Delivered file hash does not match the scrubbed file hash"
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	//the operator which scrubbed the file is the only one to deliver it
+	isIdentified, consumer := s.getInvokerIdentity(stub)
+	if !isIdentified || consumer != existingScrub.Creator {
+		errKey = existingScrub.ScrubToken
+		errorDetails = "Scrub token can be consumed only by the scrubbing operator, invoker- " + consumer
+		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	existingScrub.UpdateTs = consumedScrub.UpdateTs
+	existingScrub.UpdatedBy = consumer
+	existingScrub.Status = "C"
+	existingScrub.ConsumedBy = consumer
+
+	scrubJSON, marshalErr := json.Marshal(existingScrub)
+	if marshalErr != nil {
+		repError = strings.Replace(marshalErr.Error(), "\"", " ", -1)
+		errorDetails = "Cannot Marshal the JSON- " + repError
+		jsonResp = "{\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	err = stub.PutState(existingScrub.ScrubToken, scrubJSON)
+	if err != nil {
+		errKey = string(scrubJSON)
+		repError = strings.Replace(err.Error(), "\"", " ", -1)
+		errorDetails = "Unable to save Scrub Details with Token- " + repError
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	retErr := stub.SetEvent(_UpdateEvent, scrubJSON)
+	if retErr != nil {
+		errKey = string(scrubJSON)
+		repError = strings.Replace(retErr.Error(), "\"", " ", -1)
+		errorDetails = "Event not generated for event : UPDATE_SCRUB- " + repError
+		jsonResp = "{\"Data\":" + errKey + ",\"ErrorDetails\":\"" + errorDetails + "\"}"
+		_scrubVoiceLogger.Errorf("VconsumeScrub: " + jsonResp)
+		return shim.Error(jsonResp)
+	}
+	resultData := map[string]interface{}{
+		"trxnID":  stub.GetTxID(),
+		"stok":    existingScrub.ScrubToken,
+		"csby":    existingScrub.ConsumedBy,
+		"message": "Scrub token consumed successfully",
+		"status":  "true",
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
 func (s *ScrubbingVoice) queryScrubDetails(stub shim.ChaincodeStubInterface) peer.Response {
 	_, args := stub.GetFunctionAndParameters()
 	if len(args) < 1 {
@@ -596,7 +728,7 @@ func (s *ScrubbingVoice) queryScrub(stub shim.ChaincodeStubInterface) peer.Respo
 	bookMark := tempQuery.Bookmark
 	paginationResults, err2 := getQueryResultForQueryStringWithPagination(stub, queryString, int32(pageSize), bookMark)
 	if err2 != nil {
-		errKey = queryString + "," + string(pageSize) + "," + bookMark
+		errKey = queryString + "," + tempQuery.PageSize + "," + bookMark
 		errorDetails = "Could not fetch the data"
 		jsonResp = "{\"Data\":\"" + errKey + "\",\"ErrorDetails\":\"" + errorDetails + "\"}"
 		_scrubVoiceLogger.Errorf("VqueryScrub: " + jsonResp)
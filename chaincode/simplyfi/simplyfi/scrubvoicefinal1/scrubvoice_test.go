@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func newScrubStub(t *testing.T) (*SmartContract, *testStub) {
+	cc := new(SmartContract)
+	stub := newTestStub(t, "scrubvoice", cc, "airtel.com")
+	if res := stub.init(cc); res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	stub.put(t, "S1", ScrubVoice{ObjType: "VScrubbing", ScrubToken: "S1", PEID: "E1", TMID: "TM1", CLI: "AIRTEL", TemplateID: "T1",
+		Category: "1", CommunicationType: "T", Creator: "airtel.com", Status: "A", ScrubbedFileName: "f1", ScrubbedFileHash: "H1"})
+	return cc, stub
+}
+
+func TestConsumeScrubByScrubbingOperator(t *testing.T) {
+	cc, stub := newScrubStub(t)
+
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "cns", `{"stok":"S1","ohash":"H1","uts":"1564740000"}`); res.Status == shim.OK {
+		t.Fatal("scrub token consumed by another operator")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "cns", `{"stok":"S1","ohash":"H2","uts":"1564740000"}`); res.Status == shim.OK {
+		t.Fatal("scrub token consumed for another file")
+	}
+	//the consumer is the invoker, not the one given in the arguments
+	if res := stub.invoke(cc, "cns", `{"stok":"S1","ohash":"H1","csby":"jio.com","uts":"1564740000"}`); res.Status != shim.OK {
+		t.Fatalf("cns failed: %s", res.Message)
+	}
+	var scrub ScrubVoice
+	stub.get(t, "S1", &scrub)
+	if scrub.Status != "C" || scrub.ConsumedBy != "airtel.com" {
+		t.Fatalf("expected the token consumed by airtel.com, got %s %s", scrub.Status, scrub.ConsumedBy)
+	}
+	if res := stub.invoke(cc, "cns", `{"stok":"S1","ohash":"H1","uts":"1564740001"}`); res.Status == shim.OK {
+		t.Fatal("scrub token consumed twice")
+	}
+}
@@ -10,12 +10,35 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim" // import for Chaincode Interface
+	id "github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
 	pb "github.com/hyperledger/fabric/protos/peer"      // import for peer response
 )
 
 type Telco struct {
 }
 
+//Object types prefixing the composite keys of the records
+const (
+	_EntityObj            = "Entity"
+	_RegistrarObj         = "Registrar"
+	_TSPObj               = "TSP"
+	_TMObj                = "TM"
+	_TMOnTSPObj           = "TMOnTSP"
+	_TemplateObj          = "Template"
+	_PreferenceObj        = "Preference"
+	_MasterConsentObj     = "MasterConsent"
+	_SubscriberConsentObj = "SubscriberConsent"
+	_HeaderObj            = "Header"
+	_CampaignObj          = "Campaign"
+	_ComplaintObj         = "Complaint"
+)
+
+//_KeySeparator joined the attributes of the keys before composite keys, it is still used to display them
+const _KeySeparator = "#$#"
+
+//_MigrationBatchSize is the default number of records moved to composite keys by migrateKeys
+const _MigrationBatchSize = 500
+
 //Entity Data
 type Entity struct {
 	DocType        string `json:"entityDocType"`
@@ -61,12 +84,18 @@ type TeleMarketer struct {
 
 //TSP Onboarding TM
 type TMWithTSP struct {
-	TSPId          string `json:"tspId"`
-	TMId           string `json:"tmId"`
-	ConnectionId   string `json:"connecId"`
-	ConnectionType string `json:"connecType"`
-	Validity       string `json:"tspTmValidity"`
-	Status         string `json:"TmTspStatus"`
+	TSPId           string `json:"tspId"`
+	TMId            string `json:"tmId"`
+	ConnectionId    string `json:"connecId"`
+	ConnectionType  string `json:"connecType"`
+	Validity        string `json:"tspTmValidity"`
+	Status          string `json:"TmTspStatus"`
+	BankGuarantee   string `json:"bgRef"`
+	SecurityDeposit string `json:"sdRef"`
+	RenewalValidity string `json:"renewalValidity"`
+	StatusReason    string `json:"statusReason"`
+	RequestedDate   string `json:"requestedDate"`
+	ModifiedDate    string `json:"modifiedDate"`
 }
 
 //Template Data
@@ -253,6 +282,22 @@ func (c *Telco) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return c.getTMOnboardingWithTSP(stub, args)
 	case "getTMByTSP":
 		return c.getAllTMByTSP(stub, args)
+	case "approveTMOnTSP":
+		return c.approveTMConnection(stub, args)
+	case "suspendTMOnTSP":
+		return c.suspendTMConnection(stub, args)
+	case "resumeTMOnTSP":
+		return c.resumeTMConnection(stub, args)
+	case "terminateTMOnTSP":
+		return c.terminateTMConnection(stub, args)
+	case "renewTMOnTSP":
+		return c.requestTMConnectionRenewal(stub, args)
+	case "approveTMOnTSPRenewal":
+		return c.approveTMConnectionRenewal(stub, args)
+	case "expireTMOnTSP":
+		return c.expireTMConnections(stub, args)
+	case "checkTMOnTSP":
+		return c.checkTMConnection(stub, args)
 	case "setTemplate":
 		return c.setTemplate(stub, args)
 	case "getTemplate":
@@ -331,6 +376,24 @@ func (c *Telco) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return c.updateScrubOutputHash(stub, args)
 	case "getAllCampaigns":
 		return c.getAllCampaigns(stub, args)
+	case "registerCampaign":
+		return c.registerCampaign(stub, args)
+	case "approveCampaign":
+		return c.approveCampaign(stub, args)
+	case "submitCampaignDelivery":
+		return c.submitCampaignDelivery(stub, args)
+	case "closeExpiredCampaigns":
+		return c.closeExpiredCampaigns(stub, args)
+	case "getCampaign":
+		return c.getCampaign(stub, args)
+	case "getCampaignsByEntity":
+		return c.queryCampaignByEntity(stub, args)
+	case "getCampaignsByStatus":
+		return c.queryCampaignByStatus(stub, args)
+	case "getCampaignsByDate":
+		return c.queryCampaignByDate(stub, args)
+	case "migrateKeys":
+		return c.migrateKeys(stub, args)
 	default:
 		return shim.Error("Not a Valid Function.")
 	}
@@ -379,7 +442,7 @@ func (c *Telco) setEntity(stub shim.ChaincodeStubInterface, args []string) pb.Re
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Entity. \"}"
 		return shim.Error(jsonResp)
 	}
-	err = stub.PutState(entityStruct.EntityId, entityAsBytes)
+	err = stub.PutState(getKey(stub, _EntityObj, entityStruct.EntityId), entityAsBytes)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Creating Entity Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -402,7 +465,7 @@ func (c *Telco) getEntity(stub shim.ChaincodeStubInterface, args []string) pb.Re
 		return shim.Error("Incorrect number of Arguments. Expecting 1(EntityId)")
 	}
 	entityId := args[0]
-	valueAsBytes, err := stub.GetState(entityId)
+	valueAsBytes, err := getRecord(stub, _EntityObj, entityId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + entityId + "\"}"
 		return shim.Error(jsonResp)
@@ -478,7 +541,7 @@ func (c *Telco) updateEntityStatus(stub shim.ChaincodeStubInterface, args []stri
 	}
 	entityId := args[0]
 	status := args[1]
-	valueAsBytes, err := stub.GetState(entityId)
+	valueAsBytes, err := getRecord(stub, _EntityObj, entityId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + entityId + "\"}"
 		return shim.Error(jsonResp)
@@ -494,7 +557,7 @@ func (c *Telco) updateEntityStatus(stub shim.ChaincodeStubInterface, args []stri
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Entity Status Updation \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(entityId, entityAsBytes)
+	err1 = stub.PutState(getKey(stub, _EntityObj, entityId), entityAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Entity Status Failed \"}"
 		return shim.Error(jsonResp)
@@ -539,7 +602,7 @@ func (c *Telco) setRegistrar(stub shim.ChaincodeStubInterface, args []string) pb
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Entity. \"}"
 		return shim.Error(jsonResp)
 	}
-	err = stub.PutState(registrarStruct.RegistrarId, registrarAsBytes)
+	err = stub.PutState(getKey(stub, _RegistrarObj, registrarStruct.RegistrarId), registrarAsBytes)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Creating Registrar Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -562,7 +625,7 @@ func (c *Telco) getRegistrar(stub shim.ChaincodeStubInterface, args []string) pb
 		return shim.Error("Incorrect number of Arguments. Expecting 1(RegistrarId)")
 	}
 	registrarId := args[0]
-	valueAsBytes, err := stub.GetState(registrarId)
+	valueAsBytes, err := getRecord(stub, _RegistrarObj, registrarId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + registrarId + "\"}"
 		return shim.Error(jsonResp)
@@ -617,7 +680,7 @@ func (c *Telco) updateRegistrarStatus(stub shim.ChaincodeStubInterface, args []s
 	}
 	regId := args[0]
 	status := args[1]
-	valueAsBytes, err := stub.GetState(regId)
+	valueAsBytes, err := getRecord(stub, _RegistrarObj, regId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + regId + "\"}"
 		return shim.Error(jsonResp)
@@ -633,7 +696,7 @@ func (c *Telco) updateRegistrarStatus(stub shim.ChaincodeStubInterface, args []s
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Registrar Status Updation \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(regId, regAsBytes)
+	err1 = stub.PutState(getKey(stub, _RegistrarObj, regId), regAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Registrar Status Failed \"}"
 		return shim.Error(jsonResp)
@@ -670,7 +733,7 @@ func (c *Telco) setTelecomServiceProvider(stub shim.ChaincodeStubInterface, args
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for TSP \"}"
 		return shim.Error(jsonResp)
 	}
-	err = stub.PutState(tspStruct.TSPId, tspAsBytes)
+	err = stub.PutState(getKey(stub, _TSPObj, tspStruct.TSPId), tspAsBytes)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Creating Telecom Service Providers Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -693,7 +756,7 @@ func (c *Telco) getTelecomServiceProvider(stub shim.ChaincodeStubInterface, args
 		return shim.Error("Incorrect number of Arguments. Expecting 1(TspId)")
 	}
 	tspId := args[0]
-	valueAsBytes, err := stub.GetState(tspId)
+	valueAsBytes, err := getRecord(stub, _TSPObj, tspId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tspId + "\"}"
 		return shim.Error(jsonResp)
@@ -736,7 +799,7 @@ func (c *Telco) setTelemarketer(stub shim.ChaincodeStubInterface, args []string)
 	tmStatus := args[7]
 	tmCIN := args[8]
 	// ==== Check if telemarketer already exists ====
-	tmAsBytes, err := stub.GetState(tmId)
+	tmAsBytes, err := getRecord(stub, _TMObj, tmId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmId + "\"}"
 		return shim.Error(jsonResp)
@@ -762,7 +825,7 @@ func (c *Telco) setTelemarketer(stub shim.ChaincodeStubInterface, args []string)
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for TM \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(tmStruct.TMId, tmAsBytes)
+	err1 = stub.PutState(getKey(stub, _TMObj, tmStruct.TMId), tmAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Creating TeleMarketers Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -786,7 +849,7 @@ func (c *Telco) getTelemarketer(stub shim.ChaincodeStubInterface, args []string)
 		return shim.Error("Incorrect number of Arguments. Expecting 1(TmId)")
 	}
 	tmId := args[0]
-	valueAsBytes, err := stub.GetState(tmId)
+	valueAsBytes, err := getRecord(stub, _TMObj, tmId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmId + "\"}"
 		return shim.Error(jsonResp)
@@ -808,9 +871,7 @@ func (c *Telco) getTelemarketer(stub shim.ChaincodeStubInterface, args []string)
 */
 func (c *Telco) getAllTelemarketers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	startKey := "TM100000000"
-	endKey := "TM999999999"
-	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_TMObj, []string{})
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -828,7 +889,7 @@ func (c *Telco) getAllTelemarketers(stub shim.ChaincodeStubInterface, args []str
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(",\"Record\":")
@@ -860,7 +921,7 @@ func (c *Telco) updateTelemarketerStatus(stub shim.ChaincodeStubInterface, args
 	}
 	tmId := args[0]
 	status := args[1]
-	valueAsBytes, err := stub.GetState(tmId)
+	valueAsBytes, err := getRecord(stub, _TMObj, tmId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmId + "\"}"
 		return shim.Error(jsonResp)
@@ -876,7 +937,7 @@ func (c *Telco) updateTelemarketerStatus(stub shim.ChaincodeStubInterface, args
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Telemarketer Status Updation \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(tmId, tmAsBytes)
+	err1 = stub.PutState(getKey(stub, _TMObj, tmId), tmAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Telemarketer Status Failed \"}"
 		return shim.Error(jsonResp)
@@ -889,26 +950,36 @@ func (c *Telco) updateTelemarketerStatus(stub shim.ChaincodeStubInterface, args
 	fcnName: setTMOnboardingWithTSP
 	arguments: 2
 	argument1: chaincode stub interface
-	argument2: array consists of [tspId, tmId, connectionId, connectionType, status, validity]
-	create a onbaording object of the structure TMWithTSP,
-	set all the necessary data of that object
-	marshall the object into jsonObject
+	argument2: array consists of [tspId, tmId, connectionId, connectionType, validity, bgRef, sdRef]
+	or, as before, [tspId, tmId, connectionId, connectionType, status, validity] whose status is not used
+	create a onbaording object of the structure TMWithTSP in Requested status,
+	the TSP approves it with approveTMConnection, validity is a date (2006-01-02) or date time (2006-01-02 15:04:05)
+	a new request is refused while an earlier connection is still Requested, Approved or Suspended
 	create a tx in blockchain using PutState using compositeKey(tspId, tmId)
 	return pb.Response= "Onboarding Created Successfully" if the telemarketer status is Active, else error
 */
 func (c *Telco) setTMOnboardingWithTSP(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	var jsonResp string
-	if len(args) != 6 {
-		return shim.Error("Incorrect Number of Arguments. Expecting 6(TSPId, TMId, ConnectionId, ConnectionType, Status, Validity))")
+	if len(args) == 6 {
+		//only the TSP approves a connection, the status of the earlier form is dropped
+		args = []string{args[0], args[1], args[2], args[3], args[5], "", ""}
+	}
+	if len(args) != 7 {
+		return shim.Error("Incorrect Number of Arguments. Expecting 7(TSPId, TMId, ConnectionId, ConnectionType, Validity, BGRef, SDRef))")
 	}
 	tspId := args[0]
 	tmId := args[1]
 	connId := args[2]
 	connType := args[3]
-	status := args[4]
-	validity := args[5]
+	validity := args[4]
+	bgRef := args[5]
+	sdRef := args[6]
 
-	valueAsBytes, err := stub.GetState(tmId)
+	if _, err := parseConnectionValidity(validity); err != nil {
+		jsonResp = "{\"Error\":\"Validity should be in the format " + _ConnectionTimeLayout + "\"}"
+		return shim.Error(jsonResp)
+	}
+	valueAsBytes, err := getRecord(stub, _TMObj, tmId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmId + "\"}"
 		return shim.Error(jsonResp)
@@ -920,15 +991,35 @@ func (c *Telco) setTMOnboardingWithTSP(stub shim.ChaincodeStubInterface, args []
 	err = json.Unmarshal(valueAsBytes, &tmStruct)
 
 	if strings.Compare(tmStruct.TMStatus, "Active") == 0 {
-		compKey := tspId + "#$#" + tmId
+		compKey := getKey(stub, _TMOnTSPObj, tspId, tmId)
+		now, err := getTxTime(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		existing, err := getTMConnection(stub, tspId, tmId)
+		if err != nil {
+			jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
+			return shim.Error(jsonResp)
+		}
+		if existing != nil {
+			status := effectiveConnectionStatus(existing, now)
+			if status != _ConnectionExpired && status != _ConnectionTerminated {
+				jsonResp = "{\"Error\":\"TSP Onboarding with TM is already " + status + "\"}"
+				return shim.Error(jsonResp)
+			}
+		}
 		tspTmStruct := &TMWithTSP{}
 
 		tspTmStruct.TSPId = tspId
 		tspTmStruct.TMId = tmId
 		tspTmStruct.ConnectionId = connId
 		tspTmStruct.ConnectionType = connType
-		tspTmStruct.Status = status
+		tspTmStruct.Status = _ConnectionRequested
 		tspTmStruct.Validity = validity
+		tspTmStruct.BankGuarantee = bgRef
+		tspTmStruct.SecurityDeposit = sdRef
+		tspTmStruct.RequestedDate = now.Format(_ConnectionTimeLayout)
+		tspTmStruct.ModifiedDate = tspTmStruct.RequestedDate
 
 		tspTmAsBytes, err := json.Marshal(tspTmStruct)
 		if err != nil {
@@ -964,13 +1055,13 @@ func (c *Telco) getTMOnboardingWithTSP(stub shim.ChaincodeStubInterface, args []
 	}
 	tspId := args[0]
 	tmId := args[1]
-	compKey := tspId + "#$#" + tmId
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _TMOnTSPObj, tspId, tmId)
+	valueAsBytes, err := getRecord(stub, _TMOnTSPObj, tspId, tmId)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"TSP Onboarding with TM does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"TSP Onboarding with TM does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	return shim.Success(valueAsBytes)
@@ -981,18 +1072,23 @@ func (c *Telco) getTMOnboardingWithTSP(stub shim.ChaincodeStubInterface, args []
 	fcnName: getAllTMByTSP
 	arguments: 2
 	argument1: chaincode stub interface
-	argument2: array consists of [TspId]
+	argument2: array consists of [TspId, includeAll(optional)]
 	to know the total number of telemarketers under TSP in blockchain
+	connections whose validity has lapsed are reported as Expired, expired and terminated connections
+	are left out unless includeAll is "true"
 	return pb.Response= Payload of all the telemarketers under particular TSP
 */
 func (c *Telco) getAllTMByTSP(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	var jsonResp string
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of Arguments. Expecting 1(TSPId)")
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 1(TSPId) or 2(TSPId, IncludeAll)")
+	}
+	includeAll := len(args) == 2 && args[1] == "true"
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	startKey := args[0] + "#$#" + "TM100000000"
-	endKey := args[0] + "#$#" + "TM999999999"
-	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_TMOnTSPObj, []string{args[0]})
 	if err != nil {
 		jsonResp = "{\"Error\":\"Could not get Telemarketers by TSP\"}"
 		return shim.Error(jsonResp)
@@ -1006,16 +1102,25 @@ func (c *Telco) getAllTMByTSP(stub shim.ChaincodeStubInterface, args []string) p
 		if err != nil {
 			return shim.Error(err.Error())
 		}
+		tspTmStruct := &TMWithTSP{}
+		if err := json.Unmarshal(queryResponse.Value, tspTmStruct); err != nil {
+			return shim.Error(err.Error())
+		}
+		tspTmStruct.Status = effectiveConnectionStatus(tspTmStruct, now)
+		if !includeAll && (tspTmStruct.Status == _ConnectionExpired || tspTmStruct.Status == _ConnectionTerminated) {
+			continue
+		}
+		tspTmAsBytes, _ := json.Marshal(tspTmStruct)
 		if bArrayMemberAlreadyWritten == true {
 			buffer.WriteString(",")
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(",\"Record\":")
-		buffer.WriteString(string(queryResponse.Value))
+		buffer.WriteString(string(tspTmAsBytes))
 		buffer.WriteString("}")
 		bArrayMemberAlreadyWritten = true
 	}
@@ -1056,7 +1161,7 @@ func (c *Telco) setTemplate(stub shim.ChaincodeStubInterface, args []string) pb.
 	tmpValidity := args[9]
 
 	// ==== Check if template already exists ====
-	tempAsBytes, err := stub.GetState(tmpId)
+	tempAsBytes, err := getRecord(stub, _TemplateObj, tmpId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmpId + "\"}"
 		return shim.Error(jsonResp)
@@ -1082,7 +1187,7 @@ func (c *Telco) setTemplate(stub shim.ChaincodeStubInterface, args []string) pb.
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Template \"}"
 		return shim.Error(jsonResp)
 	}
-	err = stub.PutState(tmpStruct.TemplateId, tmpAsBytes)
+	err = stub.PutState(getKey(stub, _TemplateObj, tmpStruct.TemplateId), tmpAsBytes)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Creating Template Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -1105,7 +1210,7 @@ func (c *Telco) getTemplate(stub shim.ChaincodeStubInterface, args []string) pb.
 		return shim.Error("Incorrect number of Arguments. Expecting 1(TmpId)")
 	}
 	tmpId := args[0]
-	valueAsBytes, err := stub.GetState(tmpId)
+	valueAsBytes, err := getRecord(stub, _TemplateObj, tmpId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmpId + "\"}"
 		return shim.Error(jsonResp)
@@ -1136,7 +1241,7 @@ func (c *Telco) modifyTemplateStatus(stub shim.ChaincodeStubInterface, args []st
 	}
 	tmpId := args[0]
 	status := args[1]
-	valueAsBytes, err := stub.GetState(tmpId)
+	valueAsBytes, err := getRecord(stub, _TemplateObj, tmpId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + tmpId + "\"}"
 		return shim.Error(jsonResp)
@@ -1152,7 +1257,7 @@ func (c *Telco) modifyTemplateStatus(stub shim.ChaincodeStubInterface, args []st
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for TemplateUpdate \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(tmpId, tempAsBytes)
+	err1 = stub.PutState(getKey(stub, _TemplateObj, tmpId), tempAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Template Status Failed\"}"
 		return shim.Error(jsonResp)
@@ -1316,7 +1421,7 @@ func (c *Telco) setPreference(stub shim.ChaincodeStubInterface, args []string) p
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Preference Creation \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(preferenceStruct.SubscriberNumber, preferenceAsBytes)
+	err1 = stub.PutState(getKey(stub, _PreferenceObj, preferenceStruct.SubscriberNumber), preferenceAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Creating Preference Data Failed \"}"
 		return shim.Error(jsonResp)
@@ -1339,7 +1444,7 @@ func (c *Telco) getPreference(stub shim.ChaincodeStubInterface, args []string) p
 		return shim.Error("Incorrect number of Arguments. Expecting 1(SubscriberNumber)")
 	}
 	subNum := args[0]
-	valueAsBytes, err := stub.GetState(subNum)
+	valueAsBytes, err := getRecord(stub, _PreferenceObj, subNum)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + subNum + "\"}"
 		return shim.Error(jsonResp)
@@ -1435,7 +1540,7 @@ func (c *Telco) setConsent(stub shim.ChaincodeStubInterface, args []string) pb.R
 	contentTemplateId := args[7]
 	status := "Pending"
 
-	compKey := entityId + "#$#" + masterConId
+	compKey := getKey(stub, _MasterConsentObj, entityId, masterConId)
 	conseStruct := &Consent{}
 	conseStruct.DocType = docType
 	conseStruct.MasterConsentId = masterConId
@@ -1477,13 +1582,13 @@ func (c *Telco) getConsentWithEntity(stub shim.ChaincodeStubInterface, args []st
 	consentId := args[0]
 	entityId := args[1]
 
-	compKey := entityId + "#$#" + consentId
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _MasterConsentObj, entityId, consentId)
+	valueAsBytes, err := getRecord(stub, _MasterConsentObj, entityId, consentId)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Consent does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Consent does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	return shim.Success(valueAsBytes)
@@ -1506,13 +1611,13 @@ func (c *Telco) getConsentWithSubscriber(stub shim.ChaincodeStubInterface, args
 	consentId := args[0]
 	sNo := args[1]
 
-	compKey := consentId + "#$#" + sNo
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _SubscriberConsentObj, consentId, sNo)
+	valueAsBytes, err := getRecord(stub, _SubscriberConsentObj, consentId, sNo)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Consent does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Consent does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	return shim.Success(valueAsBytes)
@@ -1542,13 +1647,13 @@ func (c *Telco) createConsentForSubscriberNo(stub shim.ChaincodeStubInterface, a
 	consentId := args[1]
 	status := args[2]
 
-	compKey := entityId + "#$#" + consentId
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _MasterConsentObj, entityId, consentId)
+	valueAsBytes, err := getRecord(stub, _MasterConsentObj, entityId, consentId)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Consent does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Consent does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	conseStruct := &Consent{}
@@ -1578,7 +1683,7 @@ func (c *Telco) createConsentForSubscriberNo(stub shim.ChaincodeStubInterface, a
 			conseStruct.ContentTemplateId = contentTemplateId
 			conseStruct.Status = "Pending"
 			consentAsBytes, err := json.Marshal(conseStruct)
-			compKey := conseStruct.MasterConsentId + "#$#" + subscribersArray[i]
+			compKey := getKey(stub, _SubscriberConsentObj, conseStruct.MasterConsentId, subscribersArray[i])
 			if err != nil {
 				jsonResp = "{\"Error\":\"JSON Marshalling Error for Consent Creation \"}"
 				return shim.Error(jsonResp)
@@ -1637,13 +1742,13 @@ func (c *Telco) updateConsentStatus(stub shim.ChaincodeStubInterface, args []str
 	consentId := args[0]
 	subNo := args[1]
 	status := args[2]
-	compKey := consentId + "#$#" + subNo
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _SubscriberConsentObj, consentId, subNo)
+	valueAsBytes, err := getRecord(stub, _SubscriberConsentObj, consentId, subNo)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Complaint does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Complaint does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	conseStruct := &Consent{}
@@ -1725,9 +1830,7 @@ func (c *Telco) queryAllConsentsByEntityId(stub shim.ChaincodeStubInterface, arg
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1(EntityId)")
 	}
-	startKey := args[0] + "#$#" + "CON100000000"
-	endKey := args[0] + "#$#" + "CON999999999"
-	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_MasterConsentObj, []string{args[0]})
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1745,7 +1848,7 @@ func (c *Telco) queryAllConsentsByEntityId(stub shim.ChaincodeStubInterface, arg
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(",\"Record\":")
@@ -1792,7 +1895,7 @@ func (c *Telco) setHeader(stub shim.ChaincodeStubInterface, args []string) pb.Re
 	headerValidity := args[6]
 
 	//==== Check if header already exists ====
-	headerAsBytes, err := stub.GetState(headerName)
+	headerAsBytes, err := getRecord(stub, _HeaderObj, headerName)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + headerName + "\"}"
 		return shim.Error(jsonResp)
@@ -1819,7 +1922,7 @@ func (c *Telco) setHeader(stub shim.ChaincodeStubInterface, args []string) pb.Re
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for Header \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(headerStruct.HeaderName, headerAsBytes)
+	err1 = stub.PutState(getKey(stub, _HeaderObj, headerStruct.HeaderName), headerAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Creating Header Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -1842,7 +1945,7 @@ func (c *Telco) getHeader(stub shim.ChaincodeStubInterface, args []string) pb.Re
 		return shim.Error("Incorrect number of Arguments. Expecting 1(HeaderName)")
 	}
 	headerName := args[0]
-	valueAsBytes, err := stub.GetState(headerName)
+	valueAsBytes, err := getRecord(stub, _HeaderObj, headerName)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + headerName + "\"}"
 		return shim.Error(jsonResp)
@@ -1876,7 +1979,7 @@ func (c *Telco) modifyHeaderStatus(stub shim.ChaincodeStubInterface, args []stri
 	loc, _ := time.LoadLocation("Asia/Kolkata")
 	newTime := time.Now().In(loc)
 	dt := newTime.Format("2006-01-02 15:04:05")
-	valueAsBytes, err := stub.GetState(headerName)
+	valueAsBytes, err := getRecord(stub, _HeaderObj, headerName)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + headerName + "\"}"
 		return shim.Error(jsonResp)
@@ -1893,7 +1996,7 @@ func (c *Telco) modifyHeaderStatus(stub shim.ChaincodeStubInterface, args []stri
 		jsonResp = "{\"Error\":\"JSON Marshalling Error for HeaderUpdate \"}"
 		return shim.Error(jsonResp)
 	}
-	err1 = stub.PutState(headerName, headerAsBytes)
+	err1 = stub.PutState(getKey(stub, _HeaderObj, headerName), headerAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Header Status Failed\"}"
 		return shim.Error(jsonResp)
@@ -1979,7 +2082,7 @@ func (c *Telco) transactionalScrubbing(stub shim.ChaincodeStubInterface, args []
 	subscribersArray := strings.Fields(subscriberNoReplaced)
 
 	for i := 0; i < len(subscribersArray); i++ {
-		valueAsBytes, err := stub.GetState(consentId + "#$#" + subscribersArray[i])
+		valueAsBytes, err := getRecord(stub, _SubscriberConsentObj, consentId, subscribersArray[i])
 		if err != nil {
 			jsonResp = "{\"Error\":\"Failed to get state for " + subscribersArray[i] + "\"}"
 			fmt.Println(jsonResp)
@@ -2075,7 +2178,7 @@ func (c *Telco) promotionalScrubbing(stub shim.ChaincodeStubInterface, args []st
 		slot = "All"
 	}
 
-	valueAsBytes, err := stub.GetState(templateId)
+	valueAsBytes, err := getRecord(stub, _TemplateObj, templateId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + templateId + "\"}"
 		return shim.Error(jsonResp)
@@ -2091,8 +2194,11 @@ func (c *Telco) promotionalScrubbing(stub shim.ChaincodeStubInterface, args []st
 	template := &Template{}
 	err = json.Unmarshal(valueAsBytes, &template)
 	category := template.TemplateCategory
+	if live, reason := isTMConnectionLive(stub, template.TSPId, template.TelemarketerId); !live {
+		return shim.Error("{\"Error\":\"" + reason + "\"}")
+	}
 	for i := 0; i < len(subscribersArray); i++ {
-		valueAsBytes, err := stub.GetState(subscribersArray[i])
+		valueAsBytes, err := getRecord(stub, _PreferenceObj, subscribersArray[i])
 		if err != nil {
 			unBlockedList = append(unBlockedList, subscribersArray[i])
 		} else if valueAsBytes == nil {
@@ -2205,7 +2311,7 @@ func (c *Telco) serviceScrubbing(stub shim.ChaincodeStubInterface, args []string
 	} else {
 		slot = "All"
 	}
-	valueAsBytes, err := stub.GetState(templateId)
+	valueAsBytes, err := getRecord(stub, _TemplateObj, templateId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + templateId + "\"}"
 		return shim.Error(jsonResp)
@@ -2216,15 +2322,18 @@ func (c *Telco) serviceScrubbing(stub shim.ChaincodeStubInterface, args []string
 	template := &Template{}
 	err = json.Unmarshal(valueAsBytes, &template)
 	category := template.TemplateCategory
+	if live, reason := isTMConnectionLive(stub, template.TSPId, template.TelemarketerId); !live {
+		return shim.Error("{\"Error\":\"" + reason + "\"}")
+	}
 	subscriberNoTrimmed := strings.Trim(subscribersList, "[ ]")
 	subscriberNoReplaced := strings.Replace(subscriberNoTrimmed, ",", " ", -1)
 	subscribersArray := strings.Fields(subscriberNoReplaced)
 
 	for i := 0; i < len(subscribersArray); i++ {
-		valueAsBytes, err := stub.GetState(consentId + "#$#" + subscribersArray[i])
+		valueAsBytes, err := getRecord(stub, _SubscriberConsentObj, consentId, subscribersArray[i])
 		if err != nil {
 			jsonResp = "{\"Error\":\"Failed to get state for " + subscribersArray[i] + "\"}"
-			prefAsBytes, err1 := stub.GetState(subscribersArray[i])
+			prefAsBytes, err1 := getRecord(stub, _PreferenceObj, subscribersArray[i])
 			if err1 != nil {
 				unBlockedList = append(unBlockedList, subscribersArray[i])
 			} else if prefAsBytes == nil {
@@ -2257,7 +2366,7 @@ func (c *Telco) serviceScrubbing(stub shim.ChaincodeStubInterface, args []string
 			}
 		} else if valueAsBytes == nil {
 			jsonResp = "{\"Error\" : \"Consent does not exist: " + subscribersArray[i] + "\"}"
-			prefAsBytes, err1 := stub.GetState(subscribersArray[i])
+			prefAsBytes, err1 := getRecord(stub, _PreferenceObj, subscribersArray[i])
 			if err1 != nil {
 				unBlockedList = append(unBlockedList, subscribersArray[i])
 			} else if prefAsBytes == nil {
@@ -2331,7 +2440,7 @@ func (c *Telco) createCampaign(stub shim.ChaincodeStubInterface, args []string)
 	loc, _ := time.LoadLocation("Asia/Kolkata")
 	newTime := time.Now().In(loc)
 	dateTime := newTime.Format("2006-01-02 15:04:05")
-	valueAsBytes, err := stub.GetState(templateId)
+	valueAsBytes, err := getRecord(stub, _TemplateObj, templateId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + templateId + "\"}"
 		return shim.Error(jsonResp)
@@ -2344,8 +2453,11 @@ func (c *Telco) createCampaign(stub shim.ChaincodeStubInterface, args []string)
 	err = json.Unmarshal(valueAsBytes, &template)
 	entityId := template.TemplateEntityId
 	templateStatus := template.TemplateStatus
+	if live, reason := isTMConnectionLive(stub, template.TSPId, template.TelemarketerId); !live {
+		return shim.Error("{\"Error\":\"" + reason + "\"}")
+	}
 
-	headAsBytes, err := stub.GetState(headerName)
+	headAsBytes, err := getRecord(stub, _HeaderObj, headerName)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + headerName + "\"}"
 		return shim.Error(jsonResp)
@@ -2374,7 +2486,7 @@ func (c *Telco) createCampaign(stub shim.ChaincodeStubInterface, args []string)
 			jsonResp = "{\"Error\":\"JSON Marshalling Error for Campaign Creation Transactional \"}"
 			return shim.Error(jsonResp)
 		}
-		err1 = stub.PutState(scrubStruct.CampaignId, scrubAsBytes)
+		err1 = stub.PutState(getKey(stub, _CampaignObj, scrubStruct.CampaignId), scrubAsBytes)
 		if err1 != nil {
 			jsonResp = "{\"Error\":\"Creating Campaign Data Transactional Failed\"}"
 			return shim.Error(jsonResp)
@@ -2401,7 +2513,7 @@ func (c *Telco) getScrubbingStatus(stub shim.ChaincodeStubInterface, args []stri
 		return shim.Error("Incorrect number of Arguments. Expecting 1(CampaignId)")
 	}
 	campaignId := args[0]
-	valueAsBytes, err := stub.GetState(campaignId)
+	valueAsBytes, err := getRecord(stub, _CampaignObj, campaignId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + campaignId + "\"}"
 		return shim.Error(jsonResp)
@@ -2437,7 +2549,7 @@ func (c *Telco) updateScrubOutputHash(stub shim.ChaincodeStubInterface, args []s
 	newTime := time.Now().In(loc)
 	dateTime := newTime.Format("2006-01-02 15:04:05")
 
-	valueAsBytes, err := stub.GetState(campaignId)
+	valueAsBytes, err := getRecord(stub, _CampaignObj, campaignId)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + campaignId + "\"}"
 		return shim.Error(jsonResp)
@@ -2453,7 +2565,7 @@ func (c *Telco) updateScrubOutputHash(stub shim.ChaincodeStubInterface, args []s
 	if err1 != nil {
 		return shim.Error(err1.Error())
 	}
-	err1 = stub.PutState(campaignId, scrubAsBytes)
+	err1 = stub.PutState(getKey(stub, _CampaignObj, campaignId), scrubAsBytes)
 	if err1 != nil {
 		jsonResp = "{\"Error\":\"Updating Campaign OutputHash Data Failed\"}"
 		return shim.Error(jsonResp)
@@ -2471,9 +2583,7 @@ func (c *Telco) updateScrubOutputHash(stub shim.ChaincodeStubInterface, args []s
 	return pb.Response= Payload of all the campaigns.
 */
 func (c *Telco) getAllCampaigns(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	startKey := "C100000000"
-	endKey := "C999999999"
-	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_CampaignObj, []string{})
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -2491,7 +2601,7 @@ func (c *Telco) getAllCampaigns(stub shim.ChaincodeStubInterface, args []string)
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(",\"Record\":")
@@ -2562,7 +2672,7 @@ func (c *Telco) raiseComplaint(stub shim.ChaincodeStubInterface, args []string)
 		comStatus = "Closed"
 	}
 	//get tspId from the Header given Header Id
-	headerAsBytes, err := stub.GetState(comHeaderName)
+	headerAsBytes, err := getRecord(stub, _HeaderObj, comHeaderName)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + comHeaderName + "\"}"
 		return shim.Error(jsonResp)
@@ -2574,7 +2684,7 @@ func (c *Telco) raiseComplaint(stub shim.ChaincodeStubInterface, args []string)
 	err = json.Unmarshal(headerAsBytes, &headerStruct)
 	comOAP := headerStruct.TSPId
 
-	compKey := comSubNo + "#$#" + comURNNo
+	compKey := getKey(stub, _ComplaintObj, comSubNo, comURNNo)
 
 	complStruct := &Complaint{}
 	complStruct.DocType = docType
@@ -2624,13 +2734,13 @@ func (c *Telco) fetchComplaint(stub shim.ChaincodeStubInterface, args []string)
 	}
 	subscriberNo := args[0]
 	urNumber := args[1]
-	compKey := subscriberNo + "#$#" + urNumber
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _ComplaintObj, subscriberNo, urNumber)
+	valueAsBytes, err := getRecord(stub, _ComplaintObj, subscriberNo, urNumber)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Complaint does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Complaint does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	return shim.Success(valueAsBytes)
@@ -2649,9 +2759,7 @@ func (c *Telco) getAllComplaintsBySubscriberNumber(stub shim.ChaincodeStubInterf
 	if len(args) != 1 {
 		return shim.Error("Incorrect Number of Arguments. Expecting 1(SubscriberNumber)")
 	}
-	startKey := args[0] + "#$#" + "COM100000000"
-	endKey := args[0] + "#$#" + "COM999999999"
-	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_ComplaintObj, []string{args[0]})
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -2669,7 +2777,7 @@ func (c *Telco) getAllComplaintsBySubscriberNumber(stub shim.ChaincodeStubInterf
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(",\"Record\":")
@@ -2703,13 +2811,13 @@ func (c *Telco) updateCDRStatus(stub shim.ChaincodeStubInterface, args []string)
 	urNum := args[1]
 	status := args[2]
 	remarks := args[3]
-	compKey := subscriberNo + "#$#" + urNum
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _ComplaintObj, subscriberNo, urNum)
+	valueAsBytes, err := getRecord(stub, _ComplaintObj, subscriberNo, urNum)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Complaint does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Complaint does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	complStruct := &Complaint{}
@@ -2750,13 +2858,13 @@ func (c *Telco) updateComplaintStatus(stub shim.ChaincodeStubInterface, args []s
 	status := args[2]
 	remarks := args[3]
 	action := args[4]
-	compKey := subscriberNo + "#$#" + urNum
-	valueAsBytes, err := stub.GetState(compKey)
+	compKey := getKey(stub, _ComplaintObj, subscriberNo, urNum)
+	valueAsBytes, err := getRecord(stub, _ComplaintObj, subscriberNo, urNum)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + compKey + "\"}"
+		jsonResp = "{\"Error\":\"Failed to get state for " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	} else if valueAsBytes == nil {
-		jsonResp = "{\"Error\" : \"Complaint does not exist: " + compKey + "\"}"
+		jsonResp = "{\"Error\" : \"Complaint does not exist: " + displayKey(compKey) + "\"}"
 		return shim.Error(jsonResp)
 	}
 	complStruct := &Complaint{}
@@ -2899,7 +3007,7 @@ func getQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(", \"Record\":")
@@ -2948,7 +3056,7 @@ func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorI
 		}
 		buffer.WriteString("{\"Key\":")
 		buffer.WriteString("\"")
-		buffer.WriteString(queryResponse.Key)
+		buffer.WriteString(displayKey(queryResponse.Key))
 		buffer.WriteString("\"")
 
 		buffer.WriteString(", \"Record\":")
@@ -2981,6 +3089,239 @@ func getQueryResultForQueryStringWithPagination(stub shim.ChaincodeStubInterface
 	return buffer.Bytes(), nil
 }
 
+//getKey returns the composite key of a record, prefixed with its object type.
+//Invalid attributes give an empty key, which is rejected by PutState
+func getKey(stub shim.ChaincodeStubInterface, objType string, attributes ...string) string {
+	key, err := stub.CreateCompositeKey(objType, attributes)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+//tspDomain maps the operator TSP ids to the domain issuing the certificates of the operator
+var tspDomain = map[string]string{
+	"AI": "airtel.com",
+	"VO": "vil.com",
+	"ID": "vil.com",
+	"VI": "vil.com",
+	"BL": "bsnl.com",
+	"ML": "mtnl.com",
+	"QL": "qtl.infotelconnect.com",
+	"TA": "tata.com",
+	"JI": "jio.com",
+}
+
+//getInvokerIdentity returns the domain of the organisation that issued the invoker certificate
+//and false if the certificate can not be parsed
+func getInvokerIdentity(stub shim.ChaincodeStubInterface) (bool, string) {
+	enCert, err := id.GetX509Certificate(stub)
+	if err != nil {
+		return false, "Unknown."
+	}
+	issuersOrgs := enCert.Issuer.Organization
+	if len(issuersOrgs) == 0 {
+		return false, "Unknown.."
+	}
+	return true, issuersOrgs[0]
+}
+
+//isInvokerTSP returns true if the invoker certificate is issued by the domain of the TSP, the
+//certificates the TSP issues to its telemarketers do not act for the TSP
+func isInvokerTSP(stub shim.ChaincodeStubInterface, tspId string) bool {
+	authorize, domain := getInvokerIdentity(stub)
+	if !authorize || tspDomain[tspId] == "" || tspDomain[tspId] != domain {
+		return false
+	}
+	_, isTM, err := id.GetAttributeValue(stub, _TMIdAttribute)
+	return err == nil && !isTM
+}
+
+//displayKey returns the attributes of a composite key joined the way the keys were built
+//before composite keys, so that the responses keep the same keys
+func displayKey(key string) string {
+	if !strings.HasPrefix(key, "\x00") {
+		return key
+	}
+	parts := strings.Split(strings.TrimSuffix(key[1:], "\x00"), "\x00")
+	return strings.Join(parts[1:], _KeySeparator)
+}
+
+//getRecord reads a record from its composite key or, until migrateKeys has moved it, from its
+//key in the flat key space. The flat key is shared by all the object types, so the record found
+//there is returned only if it is of the object type asked for
+func getRecord(stub shim.ChaincodeStubInterface, objType string, attributes ...string) ([]byte, error) {
+	key := getKey(stub, objType, attributes...)
+	value, err := stub.GetState(key)
+	if err != nil || len(value) > 0 {
+		return value, err
+	}
+	legacyKey := strings.Join(attributes, _KeySeparator)
+	value, err = stub.GetState(legacyKey)
+	if err != nil || len(value) == 0 || getLegacyKey(stub, legacyKey, value) != key {
+		return nil, err
+	}
+	return value, nil
+}
+
+//getLegacyKey returns the composite key of a record stored in the flat key space, identifying
+//the object type by the fields of the record. Returns empty if the record is not known
+func getLegacyKey(stub shim.ChaincodeStubInterface, key string, value []byte) string {
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(value, &record); err != nil {
+		return ""
+	}
+	has := func(field string) bool {
+		_, ok := record[field]
+		return ok
+	}
+	parts := strings.Split(key, _KeySeparator)
+	if len(parts) == 2 {
+		switch {
+		case has("connecId"):
+			return getKey(stub, _TMOnTSPObj, parts[0], parts[1])
+		case record["docType"] == "MasterConsent":
+			return getKey(stub, _MasterConsentObj, parts[0], parts[1])
+		case record["docType"] == "SubscriberConsent":
+			return getKey(stub, _SubscriberConsentObj, parts[0], parts[1])
+		case has("complaintsDocType"):
+			return getKey(stub, _ComplaintObj, parts[0], parts[1])
+		}
+		return ""
+	} else if len(parts) != 1 {
+		return ""
+	}
+	switch {
+	case has("entityDocType"):
+		return getKey(stub, _EntityObj, key)
+	case has("registrarId"):
+		return getKey(stub, _RegistrarObj, key)
+	case has("tmName"):
+		return getKey(stub, _TMObj, key)
+	case has("tspName"):
+		return getKey(stub, _TSPObj, key)
+	case has("tempId"):
+		return getKey(stub, _TemplateObj, key)
+	case has("preferenceDocType"):
+		return getKey(stub, _PreferenceObj, key)
+	case has("headerEntityId"):
+		return getKey(stub, _HeaderObj, key)
+	case has("campaignId"):
+		return getKey(stub, _CampaignObj, key)
+	}
+	return ""
+}
+
+//isInvokerOperator returns true if the invoker certificate is issued by the domain of an operator
+func isInvokerOperator(stub shim.ChaincodeStubInterface) bool {
+	authorize, domain := getInvokerIdentity(stub)
+	if !authorize {
+		return false
+	}
+	for _, tspDomainName := range tspDomain {
+		if tspDomainName == domain {
+			return true
+		}
+	}
+	return false
+}
+
+//_TMIdAttribute is the attribute of the certificates the operators issue to their telemarketers
+const _TMIdAttribute = "tmId"
+
+//getInvokerTM returns the telemarketer an operator issued the invoker certificate to, empty if the
+//certificate is not of a telemarketer
+func getInvokerTM(stub shim.ChaincodeStubInterface) string {
+	if !isInvokerOperator(stub) {
+		return ""
+	}
+	value, found, err := id.GetAttributeValue(stub, _TMIdAttribute)
+	if err != nil || !found {
+		return ""
+	}
+	return value
+}
+
+//Moving the records of the flat key space to composite keys
+/*
+	fcnName: migrateKeys
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [batchSize, bookmark], both optional, batchSize default 500
+	invoked by an operator only, range query over the simple keys from the bookmark, which leaves out
+	the composite keys, each known record is saved under its composite key and deleted from its old key,
+	a record already written to its composite key since the upgrade is kept and the old key is only
+	deleted (superseded). The records not known are left as they are and listed in skipped, they count
+	toward the batch size so that a batch reads at most batchSize keys
+	to be invoked with the bookmark returned until done is true
+	return pb.Response= Payload with the number of records migrated and superseded, skipped keys, bookmark and done
+*/
+func (c *Telco) migrateKeys(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isInvokerOperator(stub) {
+		return shim.Error("{\"Error\":\"Keys can be migrated only by an operator\"}")
+	}
+	batchSize := _MigrationBatchSize
+	if len(args) > 0 && args[0] != "" {
+		size, err := strconv.Atoi(args[0])
+		if err != nil || size <= 0 {
+			return shim.Error("Batch size should be a positive number")
+		}
+		batchSize = size
+	}
+	//the keys skipped are left in place, the range starts right after the last key read
+	startKey := ""
+	if len(args) > 1 && args[1] != "" {
+		startKey = args[1] + "\x00"
+	}
+	resultsIterator, err := stub.GetStateByRange(startKey, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+	migrated := 0
+	superseded := 0
+	skipped := make([]string, 0)
+	bookmark := ""
+	for migrated+superseded+len(skipped) < batchSize && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		bookmark = queryResponse.Key
+		newKey := getLegacyKey(stub, queryResponse.Key, queryResponse.Value)
+		if newKey == "" {
+			skipped = append(skipped, queryResponse.Key)
+			continue
+		}
+		existing, err := stub.GetState(newKey)
+		if err != nil {
+			return shim.Error("{\"Error\":\"Failed to get state for " + displayKey(newKey) + "\"}")
+		}
+		if len(existing) == 0 {
+			if err := stub.PutState(newKey, queryResponse.Value); err != nil {
+				return shim.Error("{\"Error\":\"Migrating " + queryResponse.Key + " Failed\"}")
+			}
+		}
+		if err := stub.DelState(queryResponse.Key); err != nil {
+			return shim.Error("{\"Error\":\"Deleting " + queryResponse.Key + " Failed\"}")
+		}
+		if len(existing) == 0 {
+			migrated++
+		} else {
+			superseded++
+		}
+	}
+	resultData := map[string]interface{}{
+		"migrated":   migrated,
+		"superseded": superseded,
+		"skipped":    skipped,
+		"bookmark":   bookmark,
+		"done":       !resultsIterator.HasNext(),
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
+
 //Main function for Telco Chaincode
 func main() {
 	err := shim.Start(new(Telco))
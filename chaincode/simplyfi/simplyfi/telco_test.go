@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestMigrateKeysInBatches(t *testing.T) {
+	cc := new(Telco)
+	stub := newTestStub(t, "telco", cc, "airtel.com")
+	stub.put(t, "A", map[string]int{"unknown": 1})
+	stub.put(t, "B", map[string]int{"unknown": 2})
+	stub.put(t, "E1", Entity{DocType: "Entity", EntityId: "E1", Status: "A"})
+	stub.put(t, "T1", Template{TemplateId: "T1", TemplateStatus: "A"})
+
+	stub.setDomain(t, "example.com")
+	if res := stub.invoke(cc, "migrateKeys", "2"); res.Status == shim.OK {
+		t.Fatal("keys migrated by an invoker which is not an operator")
+	}
+	stub.setDomain(t, "airtel.com")
+
+	var batch struct {
+		Migrated int      `json:"migrated"`
+		Skipped  []string `json:"skipped"`
+		Bookmark string   `json:"bookmark"`
+		Done     bool     `json:"done"`
+	}
+	//the records not known fill the first batch
+	res := stub.invoke(cc, "migrateKeys", "2")
+	if res.Status != shim.OK {
+		t.Fatalf("migrateKeys failed: %s", res.Message)
+	}
+	json.Unmarshal(res.Payload, &batch)
+	if batch.Migrated != 0 || len(batch.Skipped) != 2 || batch.Bookmark != "B" || batch.Done {
+		t.Fatalf("expected A and B skipped, got %s", res.Payload)
+	}
+
+	res = stub.invoke(cc, "migrateKeys", "2", batch.Bookmark)
+	if res.Status != shim.OK {
+		t.Fatalf("migrateKeys failed: %s", res.Message)
+	}
+	batch.Skipped = nil
+	json.Unmarshal(res.Payload, &batch)
+	if batch.Migrated != 2 || len(batch.Skipped) != 0 || !batch.Done {
+		t.Fatalf("expected E1 and T1 migrated, got %s", res.Payload)
+	}
+	if stub.State["E1"] != nil || stub.State["T1"] != nil || stub.State["A"] == nil {
+		t.Fatal("expected the known records moved and the others left")
+	}
+	var entity Entity
+	stub.get(t, getKey(stub, _EntityObj, "E1"), &entity)
+	var template Template
+	stub.get(t, getKey(stub, _TemplateObj, "T1"), &template)
+}
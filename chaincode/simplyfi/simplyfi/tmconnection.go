@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim" // import for Chaincode Interface
+	pb "github.com/hyperledger/fabric/protos/peer"      // import for peer response
+)
+
+// Status of a TM onboarding with TSP
+const (
+	_ConnectionRequested  = "Requested"
+	_ConnectionApproved   = "Approved"
+	_ConnectionSuspended  = "Suspended"
+	_ConnectionExpired    = "Expired"
+	_ConnectionTerminated = "Terminated"
+)
+
+// Layout of the connection validity, a plain date is valid till the end of that day
+const _ConnectionTimeLayout = "2006-01-02 15:04:05"
+const _ConnectionDateLayout = "2006-01-02"
+
+// Parses the validity of a connection given in IST
+func parseConnectionValidity(validity string) (time.Time, error) {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	validTill, err := time.ParseInLocation(_ConnectionTimeLayout, validity, loc)
+	if err == nil {
+		return validTill, nil
+	}
+	validTill, err = time.ParseInLocation(_ConnectionDateLayout, validity, loc)
+	if err != nil {
+		return validTill, err
+	}
+	return validTill.AddDate(0, 0, 1), nil
+}
+
+// Retrieving the TM onboarding with TSP, nil when it does not exist
+func getTMConnection(stub shim.ChaincodeStubInterface, tspId, tmId string) (*TMWithTSP, error) {
+	valueAsBytes, err := getRecord(stub, _TMOnTSPObj, tspId, tmId)
+	if err != nil || valueAsBytes == nil {
+		return nil, err
+	}
+	tspTmStruct := &TMWithTSP{}
+	err = json.Unmarshal(valueAsBytes, tspTmStruct)
+	if err != nil {
+		return nil, err
+	}
+	return tspTmStruct, nil
+}
+
+// Saving the TM onboarding with TSP
+func putTMConnection(stub shim.ChaincodeStubInterface, tspTmStruct *TMWithTSP) error {
+	tspTmAsBytes, err := json.Marshal(tspTmStruct)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(getKey(stub, _TMOnTSPObj, tspTmStruct.TSPId, tspTmStruct.TMId), tspTmAsBytes)
+}
+
+// Status of the connection at the given time, an Approved or Suspended connection past its validity is Expired
+// Onboardings stored before the lifecycle with status Active are taken as Approved
+func effectiveConnectionStatus(tspTmStruct *TMWithTSP, now time.Time) string {
+	status := tspTmStruct.Status
+	if status == "Active" {
+		status = _ConnectionApproved
+	}
+	if status != _ConnectionApproved && status != _ConnectionSuspended {
+		return status
+	}
+	validTill, err := parseConnectionValidity(tspTmStruct.Validity)
+	if err != nil || !now.Before(validTill) {
+		return _ConnectionExpired
+	}
+	return status
+}
+
+// Checks the TM has a live connection with the TSP at the time of the transaction, returns the reason when not
+// Traffic of an entity sending without a TM is not checked
+func isTMConnectionLive(stub shim.ChaincodeStubInterface, tspId, tmId string) (bool, string) {
+	if tmId == "" {
+		return true, ""
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return false, err.Error()
+	}
+	tspTmStruct, err := getTMConnection(stub, tspId, tmId)
+	if err != nil {
+		return false, "Failed to get state for TM " + tmId + " on TSP " + tspId
+	} else if tspTmStruct == nil {
+		return false, "TM " + tmId + " is not onboarded with TSP " + tspId
+	}
+	status := effectiveConnectionStatus(tspTmStruct, now)
+	if status != _ConnectionApproved {
+		return false, "Connection of TM " + tmId + " with TSP " + tspId + " is " + status
+	}
+	return true, ""
+}
+
+// Moves the connection given [tspId, tmId] from one of the statuses in from to the status to,
+// only the TSP of the connection can change its status
+func changeTMConnectionStatus(stub shim.ChaincodeStubInterface, args []string, to, reason string, from ...string) pb.Response {
+	var jsonResp string
+	tspId := args[0]
+	tmId := args[1]
+	if !isInvokerTSP(stub, tspId) {
+		return shim.Error("{\"Error\":\"Invoker is not the TSP " + tspId + " of the connection\"}")
+	}
+	tspTmStruct, err := getTMConnection(stub, tspId, tmId)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + tspId + _KeySeparator + tmId + "\"}"
+		return shim.Error(jsonResp)
+	} else if tspTmStruct == nil {
+		jsonResp = "{\"Error\" : \"TSP Onboarding with TM does not exist: " + tspId + _KeySeparator + tmId + "\"}"
+		return shim.Error(jsonResp)
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	status := effectiveConnectionStatus(tspTmStruct, now)
+	allowed := false
+	for _, s := range from {
+		if s == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		jsonResp = "{\"Error\":\"TSP Onboarding with TM is " + status + ", cannot be " + to + "\"}"
+		return shim.Error(jsonResp)
+	}
+	tspTmStruct.Status = to
+	tspTmStruct.StatusReason = reason
+	tspTmStruct.ModifiedDate = now.Format(_ConnectionTimeLayout)
+	err = putTMConnection(stub, tspTmStruct)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Updating TSP Onboarding with TM Data Failed\"}"
+		return shim.Error(jsonResp)
+	}
+	tspTmAsBytes, _ := json.Marshal(tspTmStruct)
+	return shim.Success(tspTmAsBytes)
+}
+
+//Approving the TM onboarding with TSP
+/*
+	fcnName: approveTMConnection
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId]
+	a Requested connection is Approved by its TSP when its validity is in the future and it carries
+	a bank guarantee or security deposit reference
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) approveTMConnection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(TSPId, TMId)")
+	}
+	if !isInvokerTSP(stub, args[0]) {
+		return shim.Error("{\"Error\":\"Invoker is not the TSP " + args[0] + " of the connection\"}")
+	}
+	tspTmStruct, err := getTMConnection(stub, args[0], args[1])
+	if err != nil || tspTmStruct == nil {
+		return shim.Error("{\"Error\" : \"TSP Onboarding with TM does not exist: " + args[0] + _KeySeparator + args[1] + "\"}")
+	}
+	if tspTmStruct.BankGuarantee == "" && tspTmStruct.SecurityDeposit == "" {
+		return shim.Error("{\"Error\":\"Bank guarantee or security deposit reference is required for approval\"}")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	validTill, err := parseConnectionValidity(tspTmStruct.Validity)
+	if err != nil || !now.Before(validTill) {
+		return shim.Error("{\"Error\":\"Validity of the TSP Onboarding with TM has lapsed\"}")
+	}
+	return changeTMConnectionStatus(stub, args, _ConnectionApproved, "", _ConnectionRequested)
+}
+
+//Suspending the TM onboarding with TSP
+/*
+	fcnName: suspendTMConnection
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId, reason]
+	only the TSP of the connection can suspend it
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) suspendTMConnection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of Arguments. Expecting 3(TSPId, TMId, Reason)")
+	}
+	return changeTMConnectionStatus(stub, args, _ConnectionSuspended, args[2], _ConnectionApproved)
+}
+
+//Resuming a suspended TM onboarding with TSP
+/*
+	fcnName: resumeTMConnection
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId]
+	only the TSP of the connection can resume it
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) resumeTMConnection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(TSPId, TMId)")
+	}
+	return changeTMConnectionStatus(stub, args, _ConnectionApproved, "", _ConnectionSuspended)
+}
+
+//Terminating the TM onboarding with TSP
+/*
+	fcnName: terminateTMConnection
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId, reason]
+	only the TSP of the connection can terminate it, a terminated connection can not be renewed,
+	the TM has to request a new one
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) terminateTMConnection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of Arguments. Expecting 3(TSPId, TMId, Reason)")
+	}
+	return changeTMConnectionStatus(stub, args, _ConnectionTerminated, args[2], _ConnectionRequested, _ConnectionApproved, _ConnectionSuspended, _ConnectionExpired)
+}
+
+//Requesting renewal of the TM onboarding with TSP
+/*
+	fcnName: requestTMConnectionRenewal
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId, validity, bgRef, sdRef]
+	an Approved, Suspended or Expired connection keeps its status till the TSP approves the renewal,
+	empty bgRef / sdRef keep the references already given
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) requestTMConnectionRenewal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var jsonResp string
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of Arguments. Expecting 5(TSPId, TMId, Validity, BGRef, SDRef)")
+	}
+	tspTmStruct, err := getTMConnection(stub, args[0], args[1])
+	if err != nil || tspTmStruct == nil {
+		return shim.Error("{\"Error\" : \"TSP Onboarding with TM does not exist: " + args[0] + _KeySeparator + args[1] + "\"}")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	status := effectiveConnectionStatus(tspTmStruct, now)
+	if status != _ConnectionApproved && status != _ConnectionSuspended && status != _ConnectionExpired {
+		jsonResp = "{\"Error\":\"TSP Onboarding with TM is " + status + ", cannot be renewed\"}"
+		return shim.Error(jsonResp)
+	}
+	validTill, err := parseConnectionValidity(args[2])
+	if err != nil || !now.Before(validTill) {
+		return shim.Error("{\"Error\":\"Validity should be a future date in the format " + _ConnectionTimeLayout + "\"}")
+	}
+	tspTmStruct.RenewalValidity = args[2]
+	if args[3] != "" {
+		tspTmStruct.BankGuarantee = args[3]
+	}
+	if args[4] != "" {
+		tspTmStruct.SecurityDeposit = args[4]
+	}
+	tspTmStruct.ModifiedDate = now.Format(_ConnectionTimeLayout)
+	err = putTMConnection(stub, tspTmStruct)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Updating TSP Onboarding with TM Data Failed\"}")
+	}
+	tspTmAsBytes, _ := json.Marshal(tspTmStruct)
+	return shim.Success(tspTmAsBytes)
+}
+
+//Approving the renewal of the TM onboarding with TSP
+/*
+	fcnName: approveTMConnectionRenewal
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId]
+	only the TSP of the connection can approve the renewal, the requested validity replaces the
+	current one, an Expired connection is Approved again, a Suspended one stays Suspended
+	return pb.Response= Payload of the connection updated
+*/
+func (c *Telco) approveTMConnectionRenewal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(TSPId, TMId)")
+	}
+	if !isInvokerTSP(stub, args[0]) {
+		return shim.Error("{\"Error\":\"Invoker is not the TSP " + args[0] + " of the connection\"}")
+	}
+	tspTmStruct, err := getTMConnection(stub, args[0], args[1])
+	if err != nil || tspTmStruct == nil {
+		return shim.Error("{\"Error\" : \"TSP Onboarding with TM does not exist: " + args[0] + _KeySeparator + args[1] + "\"}")
+	}
+	if tspTmStruct.RenewalValidity == "" {
+		return shim.Error("{\"Error\":\"No renewal is requested for the TSP Onboarding with TM\"}")
+	}
+	if tspTmStruct.Status == _ConnectionTerminated {
+		return shim.Error("{\"Error\":\"TSP Onboarding with TM is Terminated, cannot be renewed\"}")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if tspTmStruct.Status != _ConnectionSuspended {
+		tspTmStruct.Status = _ConnectionApproved
+	}
+	tspTmStruct.Validity = tspTmStruct.RenewalValidity
+	tspTmStruct.RenewalValidity = ""
+	tspTmStruct.ModifiedDate = now.Format(_ConnectionTimeLayout)
+	err = putTMConnection(stub, tspTmStruct)
+	if err != nil {
+		return shim.Error("{\"Error\":\"Updating TSP Onboarding with TM Data Failed\"}")
+	}
+	tspTmAsBytes, _ := json.Marshal(tspTmStruct)
+	return shim.Success(tspTmAsBytes)
+}
+
+//Marking the connections of a TSP whose validity has lapsed as Expired
+/*
+	fcnName: expireTMConnections
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId]
+	the checks already treat lapsed connections as expired, this writes the status for the ones still
+	stored as Approved or Suspended and is meant to be invoked periodically
+	return pb.Response= Payload of the TMIds expired
+*/
+func (c *Telco) expireTMConnections(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of Arguments. Expecting 1(TSPId)")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(_TMOnTSPObj, []string{args[0]})
+	if err != nil {
+		return shim.Error("{\"Error\":\"Could not get Telemarketers by TSP\"}")
+	}
+	defer resultsIterator.Close()
+	expired := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		tspTmStruct := &TMWithTSP{}
+		if err := json.Unmarshal(queryResponse.Value, tspTmStruct); err != nil {
+			continue
+		}
+		if tspTmStruct.Status == _ConnectionExpired || effectiveConnectionStatus(tspTmStruct, now) != _ConnectionExpired {
+			continue
+		}
+		tspTmStruct.Status = _ConnectionExpired
+		tspTmStruct.StatusReason = "Validity Ended"
+		tspTmStruct.ModifiedDate = now.Format(_ConnectionTimeLayout)
+		if err := putTMConnection(stub, tspTmStruct); err != nil {
+			return shim.Error("{\"Error\":\"Updating TSP Onboarding with TM Data Failed\"}")
+		}
+		expired = append(expired, tspTmStruct.TMId)
+	}
+	respJSON, _ := json.Marshal(expired)
+	return shim.Success(respJSON)
+}
+
+//Checking the TM has a live connection with the TSP
+/*
+	fcnName: checkTMConnection
+	arguments: 2
+	argument1: chaincode stub interface
+	argument2: array consists of [tspId, tmId]
+	used by scrubbing and delivery to refuse traffic from TMs without a live connection
+	return pb.Response= Payload with live and the reason when it is not live
+*/
+func (c *Telco) checkTMConnection(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of Arguments. Expecting 2(TSPId, TMId)")
+	}
+	live, reason := isTMConnectionLive(stub, args[0], args[1])
+	resultData := map[string]interface{}{
+		"tspId":  args[0],
+		"tmId":   args[1],
+		"live":   live,
+		"reason": reason,
+	}
+	respJSON, _ := json.Marshal(resultData)
+	return shim.Success(respJSON)
+}
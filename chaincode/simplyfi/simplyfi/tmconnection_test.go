@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// checkConnection returns live and the reason checkTMOnTSP gives for AI and TM1
+func checkConnection(t *testing.T, cc *Telco, stub *testStub) (bool, string) {
+	res := stub.invoke(cc, "checkTMOnTSP", "AI", "TM1")
+	if res.Status != shim.OK {
+		t.Fatalf("checkTMOnTSP failed: %s", res.Message)
+	}
+	var result struct {
+		Live   bool   `json:"live"`
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(res.Payload, &result)
+	return result.Live, result.Reason
+}
+
+func TestTMConnectionLifecycle(t *testing.T) {
+	cc := new(Telco)
+	stub := newTestStub(t, "telco", cc, "airtel.com")
+	stub.put(t, getKey(stub, _TMObj, "TM1"), TeleMarketer{TMId: "TM1", TMName: "TM1", TMStatus: "Active"})
+	validity := time.Now().AddDate(1, 0, 0).Format(_ConnectionDateLayout)
+
+	if res := stub.invoke(cc, "setTMOnTSP", "AI", "TM1", "CONN1", "P", validity, "BG1", ""); res.Status != shim.OK {
+		t.Fatalf("setTMOnTSP failed: %s", res.Message)
+	}
+	if live, reason := checkConnection(t, cc, stub); live || reason != "Connection of TM TM1 with TSP AI is Requested" {
+		t.Fatalf("expected a requested connection not live, got %v %q", live, reason)
+	}
+	stub.setDomain(t, "jio.com")
+	if res := stub.invoke(cc, "approveTMOnTSP", "AI", "TM1"); res.Status == shim.OK {
+		t.Fatal("connection approved by another TSP")
+	}
+	stub.setDomain(t, "airtel.com")
+	if res := stub.invoke(cc, "approveTMOnTSP", "AI", "TM1"); res.Status != shim.OK {
+		t.Fatalf("approveTMOnTSP failed: %s", res.Message)
+	}
+	if live, _ := checkConnection(t, cc, stub); !live {
+		t.Fatal("expected the approved connection live")
+	}
+
+	if res := stub.invoke(cc, "suspendTMOnTSP", "AI", "TM1", "Complaints"); res.Status != shim.OK {
+		t.Fatalf("suspendTMOnTSP failed: %s", res.Message)
+	}
+	if live, _ := checkConnection(t, cc, stub); live {
+		t.Fatal("expected the suspended connection not live")
+	}
+	if res := stub.invoke(cc, "resumeTMOnTSP", "AI", "TM1"); res.Status != shim.OK {
+		t.Fatalf("resumeTMOnTSP failed: %s", res.Message)
+	}
+
+	if res := stub.invoke(cc, "terminateTMOnTSP", "AI", "TM1", "Closed"); res.Status != shim.OK {
+		t.Fatalf("terminateTMOnTSP failed: %s", res.Message)
+	}
+	if live, _ := checkConnection(t, cc, stub); live {
+		t.Fatal("expected the terminated connection not live")
+	}
+	if res := stub.invoke(cc, "renewTMOnTSP", "AI", "TM1", validity, "", ""); res.Status == shim.OK {
+		t.Fatal("renewal requested for a terminated connection")
+	}
+}
+
+func TestTMConnectionValidity(t *testing.T) {
+	cc := new(Telco)
+	stub := newTestStub(t, "telco", cc, "airtel.com")
+	lapsed := time.Now().AddDate(0, 0, -2).Format(_ConnectionDateLayout)
+	validity := time.Now().AddDate(1, 0, 0).Format(_ConnectionDateLayout)
+
+	//onboardings stored before the lifecycle are Active
+	stub.put(t, getKey(stub, _TMOnTSPObj, "AI", "TM1"), TMWithTSP{TSPId: "AI", TMId: "TM1", Validity: validity, Status: "Active"})
+	if live, _ := checkConnection(t, cc, stub); !live {
+		t.Fatal("expected the legacy Active connection live")
+	}
+
+	stub.put(t, getKey(stub, _TMOnTSPObj, "AI", "TM1"), TMWithTSP{TSPId: "AI", TMId: "TM1", Validity: lapsed, Status: _ConnectionApproved})
+	if live, reason := checkConnection(t, cc, stub); live || reason != "Connection of TM TM1 with TSP AI is Expired" {
+		t.Fatalf("expected the lapsed connection expired, got %v %q", live, reason)
+	}
+	res := stub.invoke(cc, "expireTMOnTSP", "AI")
+	if res.Status != shim.OK || string(res.Payload) != `["TM1"]` {
+		t.Fatalf("expected TM1 expired, got %d %s %s", res.Status, res.Message, res.Payload)
+	}
+
+	if res := stub.invoke(cc, "renewTMOnTSP", "AI", "TM1", validity, "BG2", ""); res.Status != shim.OK {
+		t.Fatalf("renewTMOnTSP failed: %s", res.Message)
+	}
+	if live, _ := checkConnection(t, cc, stub); live {
+		t.Fatal("expected the connection not live till the renewal is approved")
+	}
+	if res := stub.invoke(cc, "approveTMOnTSPRenewal", "AI", "TM1"); res.Status != shim.OK {
+		t.Fatalf("approveTMOnTSPRenewal failed: %s", res.Message)
+	}
+	if live, _ := checkConnection(t, cc, stub); !live {
+		t.Fatal("expected the renewed connection live")
+	}
+}